@@ -0,0 +1,88 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/rodneyosodo/twiga/internal/events"
+	"github.com/rodneyosodo/twiga/internal/events/eventlog"
+)
+
+var errNoSubscription = errors.New("subscription is empty")
+
+// replay re-delivers the events archived in the time range given by the
+// arguments to a subscription, such as
+//
+//	eventlog replay -subscription notifications -topic 'events.#' -from 2024-06-01T00:00:00Z
+//
+// The subscription must be the ID and topic the consumer subscribed with. With
+// -rebuild the consumer handles the events again even if it already did, to
+// rewrite the rows missing from its tables.
+func replay(ctx context.Context, repo eventlog.Repository, redeliverer events.Redeliverer, args []string, logger *slog.Logger) error {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	id := fs.String("subscription", "", "ID of the subscription to re-deliver the events to")
+	topic := fs.String("topic", events.SubjectAllEvents, "topic of the subscription")
+	from := fs.String("from", "", "start of the time range, in RFC 3339")
+	to := fs.String("to", "", "end of the time range, in RFC 3339, now if empty")
+	rebuild := fs.Bool("rebuild", false, "handle the events again even if the subscription already did")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *id == "" {
+		return errNoSubscription
+	}
+
+	filter := eventlog.Filter{Topic: *topic, Rebuild: *rebuild}
+	var err error
+	if filter.From, err = parseTime(*from); err != nil {
+		return err
+	}
+	if filter.To, err = parseTime(*to); err != nil {
+		return err
+	}
+
+	handler := &redelivery{redeliverer: redeliverer, id: *id, topic: *topic}
+	replayed, err := eventlog.Replay(ctx, repo, handler, filter)
+	logger.Info(fmt.Sprintf("Replayed %d events to %s", replayed, *id))
+
+	return err
+}
+
+// redelivery hands the events it handles to the subscription.
+type redelivery struct {
+	redeliverer events.Redeliverer
+	id          string
+	topic       string
+}
+
+func (r *redelivery) Handle(ctx context.Context, msg map[string]interface{}) error {
+	return r.redeliverer.Redeliver(ctx, r.id, r.topic, msg)
+}
+
+func (r *redelivery) Cancel() error {
+	return nil
+}
+
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return t.UTC(), nil
+}
@@ -0,0 +1,116 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package api
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rodneyosodo/twiga/internal/events"
+)
+
+// GinDeadLetters registers the admin endpoints that inspect and replay the events
+// the subscriptions could not handle. Subscriptions are addressed by their ID and
+// the endpoints require the admin token, so nothing is registered without one.
+func GinDeadLetters(router *gin.Engine, dlq events.DeadLetterQueue, token string, subscriptions ...events.SubscriberConfig) {
+	if token == "" {
+		return
+	}
+
+	topics := make(map[string]string, len(subscriptions))
+	for _, s := range subscriptions {
+		topics[s.ID] = s.Topic
+	}
+
+	admin := router.Group("/admin", GinAdminAuth(token))
+	admin.GET("/dead-letters/:subscription", getDeadLetters(dlq, topics))
+	admin.POST("/dead-letters/:subscription/replay", replayDeadLetters(dlq, topics))
+}
+
+// GinAdminAuth rejects the requests that do not carry the admin token, which the
+// services also use to call each other.
+func GinAdminAuth(token string) func(ctx *gin.Context) {
+	return func(ctx *gin.Context) {
+		if subtle.ConstantTimeCompare([]byte(GinExtractToken(ctx)), []byte(token)) != 1 {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error": "Unauthorized",
+			})
+
+			return
+		}
+
+		ctx.Next()
+	}
+}
+
+func getDeadLetters(dlq events.DeadLetterQueue, topics map[string]string) func(ctx *gin.Context) {
+	return func(ctx *gin.Context) {
+		id := ctx.Param("subscription")
+		topic, ok := topics[id]
+		if !ok {
+			ctx.JSON(http.StatusNotFound, gin.H{
+				"error": "subscription not found",
+			})
+
+			return
+		}
+
+		strLimit := ctx.DefaultQuery("limit", "10")
+		limit, err := strconv.ParseUint(strLimit, 10, 64)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+
+			return
+		}
+
+		letters, err := dlq.DeadLetters(ctx, id, topic, limit)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{
+			"limit":        limit,
+			"dead_letters": letters,
+		})
+	}
+}
+
+func replayDeadLetters(dlq events.DeadLetterQueue, topics map[string]string) func(ctx *gin.Context) {
+	return func(ctx *gin.Context) {
+		id := ctx.Param("subscription")
+		topic, ok := topics[id]
+		if !ok {
+			ctx.JSON(http.StatusNotFound, gin.H{
+				"error": "subscription not found",
+			})
+
+			return
+		}
+
+		replayed, err := dlq.Replay(ctx, id, topic, ctx.DefaultQuery("id", ""))
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{
+			"replayed": replayed,
+		})
+	}
+}
@@ -63,7 +63,8 @@ func EncodeError(_ context.Context, err error, w http.ResponseWriter) {
 		strings.Contains(err.Error(), "insert or update on table") ||
 		strings.Contains(err.Error(), "value too long") ||
 		strings.Contains(err.Error(), "required") ||
-		strings.Contains(err.Error(), "empty"):
+		strings.Contains(err.Error(), "empty") ||
+		strings.Contains(err.Error(), "too large"):
 		w.WriteHeader(http.StatusBadRequest)
 	case strings.Contains(err.Error(), "new row for relation"):
 		w.WriteHeader(http.StatusConflict)
@@ -7,10 +7,40 @@
 
 package cache
 
-import "context"
+import (
+	"context"
+	"fmt"
+	"time"
+)
 
-type Cacher interface {
-	Add(ctx context.Context, key string, value interface{}) error
+// Cacher caches values of type T by key.
+type Cacher[T any] interface {
+	// Add caches the value for the TTL, or for the default duration of the cache when the TTL is zero.
+	Add(ctx context.Context, key string, value T, ttl time.Duration) error
 	Remove(ctx context.Context, key string) error
-	Get(ctx context.Context, key string) interface{}
+	// Get returns the cached value and reports whether the key was found.
+	Get(ctx context.Context, key string) (T, bool, error)
+	// MGet returns the cached values of the keys that were found.
+	MGet(ctx context.Context, keys []string) (map[string]T, error)
+	// MSet caches the values by key like Add.
+	MSet(ctx context.Context, values map[string]T, ttl time.Duration) error
+	// DeletePattern removes the keys matching the glob-style pattern of the Redis
+	// KEYS command, where * matches any sequence of characters including /, ? any
+	// single character, [...] any of the characters in the brackets and \
+	// escapes the next character.
+	DeletePattern(ctx context.Context, pattern string) error
+}
+
+// Namespace groups the keys of one kind of values in a shared keyspace. Its
+// version is part of every key, so bumping it, such as when the cached values
+// change shape, invalidates the whole namespace at once; the old keys are left
+// to expire.
+type Namespace struct {
+	Name    string
+	Version uint64
+}
+
+// Key returns the key in the namespace, such as users:v1:{key}.
+func (ns Namespace) Key(key string) string {
+	return fmt.Sprintf("%s:v%d:%s", ns.Name, ns.Version, key)
 }
@@ -0,0 +1,49 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package cache_test
+
+import (
+	"testing"
+
+	"github.com/rodneyosodo/twiga/internal/cache"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNamespaceKey(t *testing.T) {
+	cases := []struct {
+		desc      string
+		namespace cache.Namespace
+		key       string
+		expected  string
+	}{
+		{
+			desc:      "first version",
+			namespace: cache.Namespace{Name: "users", Version: 1},
+			key:       "0c5d7e3a",
+			expected:  "users:v1:0c5d7e3a",
+		},
+		{
+			desc:      "bumped version",
+			namespace: cache.Namespace{Name: "users", Version: 2},
+			key:       "0c5d7e3a",
+			expected:  "users:v2:0c5d7e3a",
+		},
+		{
+			desc:      "pattern",
+			namespace: cache.Namespace{Name: "posts", Version: 1},
+			key:       "*",
+			expected:  "posts:v1:*",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.namespace.Key(tc.key))
+		})
+	}
+}
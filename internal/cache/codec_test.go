@@ -0,0 +1,57 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package cache_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rodneyosodo/twiga/internal/cache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type value struct {
+	ID        string
+	Tags      []string
+	Count     uint64
+	Private   bool
+	CreatedAt time.Time
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+	want := value{
+		ID:        "0c5d7e3a-8f1b-4d6e-9a2c-3b4f5e6d7c8a",
+		Tags:      []string{"go", "cache"},
+		Count:     42,
+		Private:   true,
+		CreatedAt: time.Now().UTC().Round(time.Millisecond),
+	}
+
+	cases := []struct {
+		desc  string
+		codec cache.Codec
+	}{
+		{desc: "json", codec: cache.JSON},
+		{desc: "msgpack", codec: cache.MsgPack},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			data, err := tc.codec.Marshal(want)
+			require.NoError(t, err)
+
+			var got value
+			require.NoError(t, tc.codec.Unmarshal(data, &got))
+
+			assert.True(t, want.CreatedAt.Equal(got.CreatedAt), "expected %s, got %s", want.CreatedAt, got.CreatedAt)
+			got.CreatedAt = want.CreatedAt
+			assert.Equal(t, want, got)
+		})
+	}
+}
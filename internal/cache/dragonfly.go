@@ -9,49 +9,148 @@ package cache
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 )
 
-var _ Cacher = (*cache)(nil)
+// scanCount is the number of keys DeletePattern asks Redis to look at per scan.
+const scanCount = 100
 
-type cache struct {
-	client   *redis.Client
-	duration time.Duration
+type cache[T any] struct {
+	client    *redis.Client
+	namespace Namespace
+	codec     Codec
+	duration  time.Duration
 }
 
-func NewCache(client *redis.Client, duration time.Duration) Cacher {
-	return &cache{
-		client:   client,
-		duration: duration,
+// NewCache returns a cache of values of type T, encoded with the codec, that
+// keeps them in the namespace for duration unless a TTL is given.
+func NewCache[T any](client *redis.Client, namespace Namespace, codec Codec, duration time.Duration) Cacher[T] {
+	return &cache[T]{
+		client:    client,
+		namespace: namespace,
+		codec:     codec,
+		duration:  duration,
 	}
 }
 
-func (c *cache) Add(ctx context.Context, key string, value interface{}) error {
-	data, err := json.Marshal(value)
+func (c *cache[T]) Add(ctx context.Context, key string, value T, ttl time.Duration) error {
+	data, err := c.codec.Marshal(value)
 	if err != nil {
 		return err
 	}
 
-	return c.client.Set(ctx, key, string(data), c.duration).Err()
+	return c.client.Set(ctx, c.namespace.Key(key), data, c.ttl(ttl)).Err()
 }
 
-func (c *cache) Remove(ctx context.Context, key string) error {
-	if err := c.client.Del(ctx, key).Err(); err != nil && !errors.Is(err, redis.Nil) {
+func (c *cache[T]) Remove(ctx context.Context, key string) error {
+	if err := c.client.Del(ctx, c.namespace.Key(key)).Err(); err != nil && !errors.Is(err, redis.Nil) {
 		return err
 	}
 
 	return nil
 }
 
-func (c *cache) Get(ctx context.Context, key string) interface{} {
-	result, err := c.client.Get(ctx, key).Result()
-	if err != nil && !errors.Is(err, redis.Nil) {
-		return false
+func (c *cache[T]) Get(ctx context.Context, key string) (T, bool, error) {
+	var value T
+
+	data, err := c.client.Get(ctx, c.namespace.Key(key)).Bytes()
+	switch {
+	case errors.Is(err, redis.Nil):
+		return value, false, nil
+	case err != nil:
+		return value, false, err
+	}
+
+	if err := c.codec.Unmarshal(data, &value); err != nil {
+		return value, false, err
+	}
+
+	return value, true, nil
+}
+
+func (c *cache[T]) MGet(ctx context.Context, keys []string) (map[string]T, error) {
+	values := make(map[string]T, len(keys))
+	if len(keys) == 0 {
+		return values, nil
+	}
+
+	nsKeys := make([]string, len(keys))
+	for i, key := range keys {
+		nsKeys[i] = c.namespace.Key(key)
+	}
+	results, err := c.client.MGet(ctx, nsKeys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	for i, result := range results {
+		// Missing keys are returned as nil and values as strings.
+		data, ok := result.(string)
+		if !ok {
+			continue
+		}
+		var value T
+		if err := c.codec.Unmarshal([]byte(data), &value); err != nil {
+			return nil, err
+		}
+		values[keys[i]] = value
+	}
+
+	return values, nil
+}
+
+func (c *cache[T]) MSet(ctx context.Context, values map[string]T, ttl time.Duration) error {
+	if len(values) == 0 {
+		return nil
+	}
+
+	// MSET cannot expire the keys, so they are set one by one in a single round trip.
+	_, err := c.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for key, value := range values {
+			data, err := c.codec.Marshal(value)
+			if err != nil {
+				return err
+			}
+			pipe.Set(ctx, c.namespace.Key(key), data, c.ttl(ttl))
+		}
+
+		return nil
+	})
+
+	return err
+}
+
+func (c *cache[T]) DeletePattern(ctx context.Context, pattern string) error {
+	// Unlike KEYS, SCAN does not block the server while it walks the keyspace.
+	iter := c.client.Scan(ctx, 0, c.namespace.Key(pattern), scanCount).Iterator()
+
+	keys := make([]string, 0, scanCount)
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+		if len(keys) == scanCount {
+			if err := c.client.Del(ctx, keys...).Err(); err != nil {
+				return err
+			}
+			keys = keys[:0]
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	return c.client.Del(ctx, keys...).Err()
+}
+
+func (c *cache[T]) ttl(ttl time.Duration) time.Duration {
+	if ttl == 0 {
+		return c.duration
 	}
 
-	return result
+	return ttl
 }
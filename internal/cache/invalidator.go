@@ -0,0 +1,58 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package cache
+
+import (
+	"context"
+
+	"github.com/rodneyosodo/twiga/internal/events"
+)
+
+// Keys returns the keys of the cached values that the event changed.
+type Keys func(env events.Envelope) ([]string, error)
+
+type invalidator[T any] struct {
+	cacher Cacher[T]
+	keys   Keys
+}
+
+// NewInvalidator returns an event handler that removes from the cache the
+// values changed by each event. Events are published once the change is
+// committed, so this also drops values that were cached while it was made.
+func NewInvalidator[T any](cacher Cacher[T], keys Keys) events.EventHandler {
+	return &invalidator[T]{
+		cacher: cacher,
+		keys:   keys,
+	}
+}
+
+func (i *invalidator[T]) Handle(ctx context.Context, msg map[string]interface{}) error {
+	env, err := events.Decode(msg)
+	if err != nil {
+		return err
+	}
+	keys, err := i.keys(env)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		if key == "" {
+			continue
+		}
+		if err := i.cacher.Remove(ctx, key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (i *invalidator[T]) Cancel() error {
+	return nil
+}
@@ -0,0 +1,50 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package cache_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rodneyosodo/twiga/internal/cache"
+	"github.com/rodneyosodo/twiga/internal/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInvalidatorRemovesChangedKeys(t *testing.T) {
+	ctx := context.Background()
+	c := cache.NewMemory[string](10, time.Minute)
+	require.NoError(t, c.Add(ctx, "deleted", "post", 0))
+	require.NoError(t, c.Add(ctx, "kept", "post", 0))
+
+	handler := cache.NewInvalidator(c, func(env events.Envelope) ([]string, error) {
+		if env.Type != events.PostDeleted {
+			return nil, nil
+		}
+		data, err := events.DecodeData[events.PostEvent](env)
+
+		return []string{data.ID}, err
+	})
+
+	env, err := events.NewEnvelope(ctx, "/twiga/posts", events.PostDeleted, events.PostEvent{ID: "deleted"})
+	require.NoError(t, err)
+	msg, err := env.Encode()
+	require.NoError(t, err)
+
+	require.NoError(t, handler.Handle(ctx, msg))
+
+	_, ok, err := c.Get(ctx, "deleted")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	_, ok, err = c.Get(ctx, "kept")
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
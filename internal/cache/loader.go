@@ -0,0 +1,116 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// Misses are kept next to the values, so their keys are prefixed to tell them apart.
+const missPrefix = "missing:"
+
+// Loader is a cache that loads the values it misses.
+type Loader[T any] interface {
+	Cacher[T]
+	// Load returns the cached value of the key, calling load and caching its
+	// result on a miss. Concurrent misses on the key share a single call.
+	Load(ctx context.Context, key string, load func(ctx context.Context) (T, error)) (T, error)
+}
+
+type loader[T any] struct {
+	Cacher[T]
+	misses   Cacher[string]
+	ttl      time.Duration
+	notFound error
+	group    singleflight.Group
+}
+
+// NewLoader returns a loader that caches the values in cacher. Keys that load
+// reports as not found, with an error matching notFound, are remembered in
+// misses for ttl so that lookups of missing values do not reach the database
+// either. Lookups of remembered misses return notFound.
+func NewLoader[T any](cacher Cacher[T], misses Cacher[string], ttl time.Duration, notFound error) Loader[T] {
+	return &loader[T]{
+		Cacher:   cacher,
+		misses:   misses,
+		ttl:      ttl,
+		notFound: notFound,
+	}
+}
+
+func (l *loader[T]) Add(ctx context.Context, key string, value T, ttl time.Duration) error {
+	if err := l.Cacher.Add(ctx, key, value, ttl); err != nil {
+		return err
+	}
+
+	return l.misses.Remove(ctx, missPrefix+key)
+}
+
+func (l *loader[T]) Remove(ctx context.Context, key string) error {
+	// Loads in flight may have read the removed value, so they are not shared anymore.
+	l.group.Forget(key)
+	if err := l.Cacher.Remove(ctx, key); err != nil {
+		return err
+	}
+
+	return l.misses.Remove(ctx, missPrefix+key)
+}
+
+func (l *loader[T]) MSet(ctx context.Context, values map[string]T, ttl time.Duration) error {
+	if err := l.Cacher.MSet(ctx, values, ttl); err != nil {
+		return err
+	}
+	for key := range values {
+		if err := l.misses.Remove(ctx, missPrefix+key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (l *loader[T]) DeletePattern(ctx context.Context, pattern string) error {
+	if err := l.Cacher.DeletePattern(ctx, pattern); err != nil {
+		return err
+	}
+
+	return l.misses.DeletePattern(ctx, missPrefix+pattern)
+}
+
+func (l *loader[T]) Load(ctx context.Context, key string, load func(ctx context.Context) (T, error)) (T, error) {
+	if value, ok, err := l.Get(ctx, key); err == nil && ok {
+		return value, nil
+	}
+	if _, ok, err := l.misses.Get(ctx, missPrefix+key); err == nil && ok {
+		var value T
+
+		return value, l.notFound
+	}
+
+	// The load is shared with the other callers, so it must not be cancelled
+	// when the caller that started it goes away.
+	ctx = context.WithoutCancel(ctx)
+	v, err, _ := l.group.Do(key, func() (interface{}, error) {
+		value, err := load(ctx)
+		switch {
+		case err == nil:
+			// The value is usable even when it cannot be cached.
+			_ = l.Cacher.Add(ctx, key, value, 0)
+		case errors.Is(err, l.notFound):
+			_ = l.misses.Add(ctx, missPrefix+key, err.Error(), l.ttl)
+		}
+
+		return value, err
+	})
+
+	return v.(T), err //nolint:forcetypeassert
+}
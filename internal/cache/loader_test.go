@@ -0,0 +1,145 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rodneyosodo/twiga/internal/cache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errNotFound = errors.New("post not found")
+
+func newLoader() cache.Loader[string] {
+	return cache.NewLoader(
+		cache.NewMemory[string](10, time.Minute),
+		cache.NewMemory[string](10, time.Minute),
+		time.Minute,
+		errNotFound,
+	)
+}
+
+func TestLoadSharesConcurrentMisses(t *testing.T) {
+	ctx := context.Background()
+	l := newLoader()
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	load := func(context.Context) (string, error) {
+		calls.Add(1)
+		<-release
+
+		return "value", nil
+	}
+
+	const callers = 10
+	var wg sync.WaitGroup
+	results := make(chan string, callers)
+	for range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, err := l.Load(ctx, "key", load)
+			assert.NoError(t, err)
+			results <- value
+		}()
+	}
+	// Give the callers time to join the load before it completes.
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(results)
+
+	assert.Equal(t, int32(1), calls.Load())
+	for value := range results {
+		assert.Equal(t, "value", value)
+	}
+
+	value, ok, err := l.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "value", value)
+}
+
+func TestLoadCachesMisses(t *testing.T) {
+	ctx := context.Background()
+	l := newLoader()
+
+	var calls atomic.Int32
+	missing := func(context.Context) (string, error) {
+		calls.Add(1)
+
+		return "", errNotFound
+	}
+	failing := func(context.Context) (string, error) {
+		calls.Add(1)
+
+		return "", errors.New("connection refused")
+	}
+
+	for range 2 {
+		_, err := l.Load(ctx, "missing", missing)
+		assert.ErrorIs(t, err, errNotFound)
+	}
+	assert.Equal(t, int32(1), calls.Load(), "not found lookups should be cached")
+
+	for range 2 {
+		_, err := l.Load(ctx, "failing", failing)
+		assert.Error(t, err)
+	}
+	assert.Equal(t, int32(3), calls.Load(), "failed lookups should not be cached")
+
+	require.NoError(t, l.Add(ctx, "missing", "created", 0))
+	value, err := l.Load(ctx, "missing", missing)
+	require.NoError(t, err)
+	assert.Equal(t, "created", value)
+
+	require.NoError(t, l.Remove(ctx, "missing"))
+	_, err = l.Load(ctx, "missing", missing)
+	assert.ErrorIs(t, err, errNotFound)
+	assert.Equal(t, int32(4), calls.Load())
+}
+
+func TestLoaderBulkOperationsClearMisses(t *testing.T) {
+	ctx := context.Background()
+	l := newLoader()
+
+	missing := func(context.Context) (string, error) {
+		return "", errNotFound
+	}
+	for _, key := range []string{"1", "2"} {
+		_, err := l.Load(ctx, key, missing)
+		require.ErrorIs(t, err, errNotFound)
+	}
+
+	require.NoError(t, l.MSet(ctx, map[string]string{"1": "created"}, 0))
+	value, err := l.Load(ctx, "1", missing)
+	require.NoError(t, err)
+	assert.Equal(t, "created", value)
+
+	require.NoError(t, l.DeletePattern(ctx, "*"))
+	values, err := l.MGet(ctx, []string{"1", "2"})
+	require.NoError(t, err)
+	assert.Empty(t, values)
+
+	calls := 0
+	_, err = l.Load(ctx, "2", func(context.Context) (string, error) {
+		calls++
+
+		return "", errNotFound
+	})
+	assert.ErrorIs(t, err, errNotFound)
+	assert.Equal(t, 1, calls, "deleted misses should be loaded again")
+}
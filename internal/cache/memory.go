@@ -0,0 +1,147 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+type entry[T any] struct {
+	key       string
+	value     T
+	expiresAt time.Time
+}
+
+type memory[T any] struct {
+	mu       sync.Mutex
+	size     int
+	duration time.Duration
+	items    map[string]*list.Element
+	// order keeps the entries from the most to the least recently used.
+	order *list.List
+}
+
+// NewMemory returns an in-process cache of values of type T that keeps them
+// for duration unless a TTL is given. Once it holds size values, adding one
+// evicts the least recently used.
+func NewMemory[T any](size int, duration time.Duration) Cacher[T] {
+	return &memory[T]{
+		size:     size,
+		duration: duration,
+		items:    make(map[string]*list.Element, size),
+		order:    list.New(),
+	}
+}
+
+func (c *memory[T]) Add(_ context.Context, key string, value T, ttl time.Duration) error {
+	if ttl == 0 {
+		ttl = c.duration
+	}
+	expiresAt := time.Now().Add(ttl)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[key]; ok {
+		e := elem.Value.(*entry[T]) //nolint:forcetypeassert
+		e.value = value
+		e.expiresAt = expiresAt
+		c.order.MoveToFront(elem)
+
+		return nil
+	}
+
+	c.items[key] = c.order.PushFront(&entry[T]{key: key, value: value, expiresAt: expiresAt})
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+
+	return nil
+}
+
+func (c *memory[T]) Remove(_ context.Context, key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[key]; ok {
+		c.remove(elem)
+	}
+
+	return nil
+}
+
+func (c *memory[T]) Get(_ context.Context, key string) (T, bool, error) {
+	var value T
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[key]
+	if !ok {
+		return value, false, nil
+	}
+	e := elem.Value.(*entry[T]) //nolint:forcetypeassert
+	if time.Now().After(e.expiresAt) {
+		c.remove(elem)
+
+		return value, false, nil
+	}
+	c.order.MoveToFront(elem)
+
+	return e.value, true, nil
+}
+
+func (c *memory[T]) MGet(ctx context.Context, keys []string) (map[string]T, error) {
+	values := make(map[string]T, len(keys))
+	for _, key := range keys {
+		if value, ok, _ := c.Get(ctx, key); ok {
+			values[key] = value
+		}
+	}
+
+	return values, nil
+}
+
+func (c *memory[T]) MSet(ctx context.Context, values map[string]T, ttl time.Duration) error {
+	for key, value := range values {
+		if err := c.Add(ctx, key, value, ttl); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *memory[T]) DeletePattern(_ context.Context, pattern string) error {
+	if err := checkPattern(pattern); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, elem := range c.items {
+		matched, err := match(pattern, key)
+		if err != nil {
+			return err
+		}
+		if matched {
+			c.remove(elem)
+		}
+	}
+
+	return nil
+}
+
+func (c *memory[T]) remove(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.items, elem.Value.(*entry[T]).key) //nolint:forcetypeassert
+}
@@ -0,0 +1,184 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package cache_test
+
+import (
+	"context"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/rodneyosodo/twiga/internal/cache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryEvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	c := cache.NewMemory[string](2, time.Minute)
+
+	require.NoError(t, c.Add(ctx, "a", "1", 0))
+	require.NoError(t, c.Add(ctx, "b", "2", 0))
+
+	// Reading a makes b the least recently used.
+	_, ok, err := c.Get(ctx, "a")
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	require.NoError(t, c.Add(ctx, "c", "3", 0))
+
+	cases := []struct {
+		key   string
+		value string
+		found bool
+	}{
+		{key: "a", value: "1", found: true},
+		{key: "b", found: false},
+		{key: "c", value: "3", found: true},
+	}
+	for _, tc := range cases {
+		value, ok, err := c.Get(ctx, tc.key)
+		require.NoError(t, err)
+		assert.Equal(t, tc.found, ok, tc.key)
+		assert.Equal(t, tc.value, value, tc.key)
+	}
+}
+
+func TestMemoryExpiresValues(t *testing.T) {
+	ctx := context.Background()
+	c := cache.NewMemory[string](10, time.Minute)
+
+	require.NoError(t, c.Add(ctx, "short", "1", 10*time.Millisecond))
+	require.NoError(t, c.Add(ctx, "default", "2", 0))
+
+	time.Sleep(20 * time.Millisecond)
+
+	_, ok, err := c.Get(ctx, "short")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	value, ok, err := c.Get(ctx, "default")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "2", value)
+}
+
+func TestMemoryAddReplacesAndRemoveDrops(t *testing.T) {
+	ctx := context.Background()
+	c := cache.NewMemory[string](10, time.Minute)
+
+	require.NoError(t, c.Add(ctx, "key", "old", 0))
+	require.NoError(t, c.Add(ctx, "key", "new", 0))
+
+	value, ok, err := c.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "new", value)
+
+	require.NoError(t, c.Remove(ctx, "key"))
+	require.NoError(t, c.Remove(ctx, "missing"))
+
+	_, ok, err = c.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestMemoryBulkOperations(t *testing.T) {
+	ctx := context.Background()
+	c := cache.NewMemory[string](10, time.Minute)
+
+	require.NoError(t, c.MSet(ctx, map[string]string{
+		"users:1":     "alice",
+		"users:2":     "bob",
+		"followers:1": "bob",
+	}, 0))
+
+	values, err := c.MGet(ctx, []string{"users:1", "users:2", "users:3"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"users:1": "alice", "users:2": "bob"}, values)
+
+	require.NoError(t, c.DeletePattern(ctx, "users:*"))
+
+	values, err = c.MGet(ctx, []string{"users:1", "users:2", "followers:1"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"followers:1": "bob"}, values)
+
+	assert.Error(t, c.DeletePattern(ctx, "users:["))
+}
+
+func TestMemoryDeletePattern(t *testing.T) {
+	keys := []string{"posts:v1:1", "posts:v1:1/comments", "posts:v2:1", "users:a", "users:B", "users:*"}
+
+	cases := []struct {
+		desc    string
+		pattern string
+		kept    []string
+		err     error
+	}{
+		{
+			desc:    "star across slashes",
+			pattern: "posts:v1:*",
+			kept:    []string{"posts:v2:1", "users:a", "users:B", "users:*"},
+		},
+		{
+			desc:    "question mark",
+			pattern: "posts:v?:1",
+			kept:    []string{"posts:v1:1/comments", "users:a", "users:B", "users:*"},
+		},
+		{
+			desc:    "range",
+			pattern: "users:[a-z]",
+			kept:    []string{"posts:v1:1", "posts:v1:1/comments", "posts:v2:1", "users:B", "users:*"},
+		},
+		{
+			desc:    "negated set",
+			pattern: "users:[^aB]",
+			kept:    []string{"posts:v1:1", "posts:v1:1/comments", "posts:v2:1", "users:a", "users:B"},
+		},
+		{
+			desc:    "escaped star",
+			pattern: `users:\*`,
+			kept:    []string{"posts:v1:1", "posts:v1:1/comments", "posts:v2:1", "users:a", "users:B"},
+		},
+		{
+			desc:    "unterminated set",
+			pattern: "users:[a",
+			kept:    keys,
+			err:     path.ErrBadPattern,
+		},
+		{
+			desc:    "trailing escape",
+			pattern: `posts:\`,
+			kept:    keys,
+			err:     path.ErrBadPattern,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			ctx := context.Background()
+			c := cache.NewMemory[string](10, time.Minute)
+			values := make(map[string]string, len(keys))
+			for _, key := range keys {
+				values[key] = key
+			}
+			require.NoError(t, c.MSet(ctx, values, 0))
+
+			err := c.DeletePattern(ctx, tc.pattern)
+			assert.ErrorIs(t, err, tc.err)
+
+			kept, err := c.MGet(ctx, keys)
+			require.NoError(t, err)
+			remaining := make([]string, 0, len(kept))
+			for key := range kept {
+				remaining = append(remaining, key)
+			}
+			assert.ElementsMatch(t, tc.kept, remaining)
+		})
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package cache
+
+import "path"
+
+// checkPattern reports a pattern with an unterminated class or escape, which
+// match would otherwise only notice for some of the keys.
+func checkPattern(pattern string) error {
+	for pattern != "" {
+		switch pattern[0] {
+		case '\\':
+			if len(pattern) < 2 {
+				return path.ErrBadPattern
+			}
+			pattern = pattern[2:]
+		case '[':
+			_, rest, err := matchClass(pattern[1:], 0)
+			if err != nil {
+				return err
+			}
+			pattern = rest
+		default:
+			pattern = pattern[1:]
+		}
+	}
+
+	return nil
+}
+
+// match reports whether the key matches the pattern like the KEYS and SCAN
+// commands of Redis: * and ? match any bytes including /, [...] matches a set
+// or range of bytes, negated by a leading ^, and \ escapes the next byte.
+func match(pattern, key string) (bool, error) {
+	for pattern != "" {
+		switch pattern[0] {
+		case '*':
+			for pattern != "" && pattern[0] == '*' {
+				pattern = pattern[1:]
+			}
+			if pattern == "" {
+				return true, nil
+			}
+			for i := range len(key) + 1 {
+				matched, err := match(pattern, key[i:])
+				if err != nil || matched {
+					return matched, err
+				}
+			}
+
+			return false, nil
+		case '?':
+			if key == "" {
+				return false, nil
+			}
+			pattern, key = pattern[1:], key[1:]
+		case '[':
+			if key == "" {
+				return false, nil
+			}
+			matched, rest, err := matchClass(pattern[1:], key[0])
+			if err != nil || !matched {
+				return false, err
+			}
+			pattern, key = rest, key[1:]
+		default:
+			if pattern[0] == '\\' {
+				if len(pattern) < 2 {
+					return false, path.ErrBadPattern
+				}
+				pattern = pattern[1:]
+			}
+			if key == "" || pattern[0] != key[0] {
+				return false, nil
+			}
+			pattern, key = pattern[1:], key[1:]
+		}
+	}
+
+	return key == "", nil
+}
+
+// matchClass matches c against the class following a [ and returns the pattern
+// after the closing ].
+func matchClass(class string, c byte) (bool, string, error) {
+	negated := class != "" && class[0] == '^'
+	if negated {
+		class = class[1:]
+	}
+
+	matched := false
+	for {
+		switch {
+		case class == "":
+			return false, "", path.ErrBadPattern
+		case class[0] == ']':
+			return matched != negated, class[1:], nil
+		case class[0] == '\\' && len(class) > 1:
+			matched = matched || class[1] == c
+			class = class[2:]
+		case len(class) > 2 && class[1] == '-' && class[2] != ']':
+			lo, hi := class[0], class[2]
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+			matched = matched || (lo <= c && c <= hi)
+			class = class[3:]
+		default:
+			matched = matched || class[0] == c
+			class = class[1:]
+		}
+	}
+}
@@ -0,0 +1,226 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	kitprometheus "github.com/go-kit/kit/metrics/prometheus"
+	"github.com/gofrs/uuid"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	memoryTier = "memory"
+	redisTier  = "redis"
+)
+
+var lookups = kitprometheus.NewCounterFrom(stdprometheus.CounterOpts{
+	Namespace: "cache",
+	Name:      "lookups_total",
+	Help:      "Number of cache lookups by tier and result.",
+}, []string{"tier", "result"})
+
+// invalidation tells the other replicas to drop the keys, and those matching
+// the pattern, from their memory tier.
+type invalidation struct {
+	Origin  string   `json:"origin"`
+	Keys    []string `json:"keys,omitempty"`
+	Pattern string   `json:"pattern,omitempty"`
+}
+
+type tiered[T any] struct {
+	local   Cacher[T]
+	remote  Cacher[T]
+	client  *redis.Client
+	channel string
+	origin  string
+}
+
+// NewTiered returns a cache that looks values up in the local cache first and
+// then in the remote one, keeping what it finds in the local cache. Changes are
+// broadcast on the Redis channel so that every replica drops the key from its
+// local cache until ctx is done. Invalidations sent while a replica is
+// disconnected are lost, so the local cache should keep values briefly.
+func NewTiered[T any](ctx context.Context, local, remote Cacher[T], client *redis.Client, channel string) (Cacher[T], error) {
+	origin, err := uuid.NewV4()
+	if err != nil {
+		return nil, err
+	}
+
+	pubsub := client.Subscribe(ctx, channel)
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+
+		return nil, fmt.Errorf("failed to subscribe to cache invalidations: %w", err)
+	}
+
+	c := &tiered[T]{
+		local:   local,
+		remote:  remote,
+		client:  client,
+		channel: channel,
+		origin:  origin.String(),
+	}
+	go c.listen(ctx, pubsub)
+
+	return c, nil
+}
+
+func (c *tiered[T]) Add(ctx context.Context, key string, value T, ttl time.Duration) error {
+	if err := c.remote.Add(ctx, key, value, ttl); err != nil {
+		return err
+	}
+	if err := c.local.Add(ctx, key, value, ttl); err != nil {
+		return err
+	}
+
+	return c.invalidate(ctx, invalidation{Keys: []string{key}})
+}
+
+func (c *tiered[T]) Remove(ctx context.Context, key string) error {
+	if err := c.remote.Remove(ctx, key); err != nil {
+		return err
+	}
+	if err := c.local.Remove(ctx, key); err != nil {
+		return err
+	}
+
+	return c.invalidate(ctx, invalidation{Keys: []string{key}})
+}
+
+func (c *tiered[T]) Get(ctx context.Context, key string) (T, bool, error) {
+	if value, ok, err := c.local.Get(ctx, key); err == nil && ok {
+		lookups.With("tier", memoryTier, "result", "hit").Add(1)
+
+		return value, true, nil
+	}
+	lookups.With("tier", memoryTier, "result", "miss").Add(1)
+
+	value, ok, err := c.remote.Get(ctx, key)
+	if err != nil {
+		return value, false, err
+	}
+	if !ok {
+		lookups.With("tier", redisTier, "result", "miss").Add(1)
+
+		return value, false, nil
+	}
+	lookups.With("tier", redisTier, "result", "hit").Add(1)
+
+	// The value is usable even when it cannot be kept locally.
+	_ = c.local.Add(ctx, key, value, 0)
+
+	return value, true, nil
+}
+
+func (c *tiered[T]) MGet(ctx context.Context, keys []string) (map[string]T, error) {
+	values, err := c.local.MGet(ctx, keys)
+	if err != nil {
+		values = make(map[string]T, len(keys))
+	}
+	lookups.With("tier", memoryTier, "result", "hit").Add(float64(len(values)))
+
+	missed := make([]string, 0, len(keys)-len(values))
+	for _, key := range keys {
+		if _, ok := values[key]; !ok {
+			missed = append(missed, key)
+		}
+	}
+	if len(missed) == 0 {
+		return values, nil
+	}
+	lookups.With("tier", memoryTier, "result", "miss").Add(float64(len(missed)))
+
+	found, err := c.remote.MGet(ctx, missed)
+	if err != nil {
+		return nil, err
+	}
+	lookups.With("tier", redisTier, "result", "hit").Add(float64(len(found)))
+	lookups.With("tier", redisTier, "result", "miss").Add(float64(len(missed) - len(found)))
+
+	// The values are usable even when they cannot be kept locally.
+	_ = c.local.MSet(ctx, found, 0)
+	for key, value := range found {
+		values[key] = value
+	}
+
+	return values, nil
+}
+
+func (c *tiered[T]) MSet(ctx context.Context, values map[string]T, ttl time.Duration) error {
+	if err := c.remote.MSet(ctx, values, ttl); err != nil {
+		return err
+	}
+	if err := c.local.MSet(ctx, values, ttl); err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+
+	return c.invalidate(ctx, invalidation{Keys: keys})
+}
+
+func (c *tiered[T]) DeletePattern(ctx context.Context, pattern string) error {
+	if err := c.remote.DeletePattern(ctx, pattern); err != nil {
+		return err
+	}
+	if err := c.local.DeletePattern(ctx, pattern); err != nil {
+		return err
+	}
+
+	return c.invalidate(ctx, invalidation{Pattern: pattern})
+}
+
+func (c *tiered[T]) invalidate(ctx context.Context, inv invalidation) error {
+	if len(inv.Keys) == 0 && inv.Pattern == "" {
+		return nil
+	}
+	inv.Origin = c.origin
+
+	data, err := json.Marshal(inv)
+	if err != nil {
+		return err
+	}
+
+	return c.client.Publish(ctx, c.channel, data).Err()
+}
+
+func (c *tiered[T]) listen(ctx context.Context, pubsub *redis.PubSub) {
+	defer pubsub.Close()
+
+	msgs := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-msgs:
+			if !ok {
+				return
+			}
+
+			var inv invalidation
+			if err := json.Unmarshal([]byte(msg.Payload), &inv); err != nil || inv.Origin == c.origin {
+				continue
+			}
+			for _, key := range inv.Keys {
+				_ = c.local.Remove(ctx, key)
+			}
+			if inv.Pattern != "" {
+				_ = c.local.DeletePattern(ctx, inv.Pattern)
+			}
+		}
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package cursor
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor is the position of the last item of a page when items are ordered
+// by creation time and ID, both descending.
+type Cursor struct {
+	CreatedAt time.Time `json:"created_at"`
+	ID        string    `json:"id"`
+}
+
+// Encode returns the opaque representation of the cursor.
+func Encode(createdAt time.Time, id string) string {
+	data, err := json.Marshal(Cursor{CreatedAt: createdAt.UTC(), ID: id})
+	if err != nil {
+		return ""
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// Decode parses a cursor previously returned by Encode.
+func Decode(value string) (Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	var c Cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	if c.ID == "" || c.CreatedAt.IsZero() {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	return c, nil
+}
+
+// Trim drops the look-ahead item read past limit and returns the cursor of the
+// next page, or an empty string when items hold the last page.
+func Trim[T any](items []T, limit uint64, key func(T) Cursor) ([]T, string) {
+	if uint64(len(items)) <= limit {
+		return items, ""
+	}
+
+	items = items[:limit]
+	if limit == 0 {
+		return items, ""
+	}
+	last := key(items[len(items)-1])
+
+	return items, Encode(last.CreatedAt, last.ID)
+}
@@ -0,0 +1,76 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package cursor_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rodneyosodo/twiga/internal/cursor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrim(t *testing.T) {
+	now := time.Now()
+	items := []cursor.Cursor{
+		{CreatedAt: now, ID: "1"},
+		{CreatedAt: now.Add(-time.Minute), ID: "2"},
+		{CreatedAt: now.Add(-2 * time.Minute), ID: "3"},
+	}
+	key := func(c cursor.Cursor) cursor.Cursor { return c }
+
+	cases := []struct {
+		desc  string
+		limit uint64
+		len   int
+		next  string
+	}{
+		{
+			desc:  "last page",
+			limit: 3,
+			len:   3,
+		},
+		{
+			desc:  "more pages",
+			limit: 2,
+			len:   2,
+			next:  "2",
+		},
+		{
+			desc:  "zero limit",
+			limit: 0,
+			len:   0,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			page, next := cursor.Trim(items, tc.limit, key)
+			assert.Len(t, page, tc.len)
+			if tc.next == "" {
+				assert.Empty(t, next)
+
+				return
+			}
+
+			c, err := cursor.Decode(next)
+			require.NoError(t, err)
+			assert.Equal(t, tc.next, c.ID)
+			assert.True(t, items[1].CreatedAt.Equal(c.CreatedAt))
+		})
+	}
+}
+
+func TestDecode(t *testing.T) {
+	_, err := cursor.Decode("not a cursor")
+	assert.ErrorIs(t, err, cursor.ErrInvalidCursor)
+
+	_, err = cursor.Decode(cursor.Encode(time.Time{}, "id"))
+	assert.ErrorIs(t, err, cursor.ErrInvalidCursor)
+}
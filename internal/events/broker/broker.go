@@ -0,0 +1,70 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package broker
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+
+	"github.com/rodneyosodo/twiga/internal/events"
+	"github.com/rodneyosodo/twiga/internal/events/memory"
+	"github.com/rodneyosodo/twiga/internal/events/nats"
+	"github.com/rodneyosodo/twiga/internal/events/rabbitmq"
+)
+
+var ErrUnsupportedScheme = errors.New("unsupported event broker scheme")
+
+// NewPublisher returns the publisher of the broker that the scheme of the URL names.
+func NewPublisher(rawURL string, logger *slog.Logger) (events.Publisher, error) {
+	scheme, err := parseScheme(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	switch scheme {
+	case "amqp", "amqps":
+		return rabbitmq.NewPublisher(rawURL, logger)
+	case "nats", "tls":
+		return nats.NewPublisher(rawURL, logger)
+	case "memory":
+		return memory.NewPubSub(logger), nil
+	default:
+		return nil, fmt.Errorf("%w %q", ErrUnsupportedScheme, scheme)
+	}
+}
+
+// NewPubSub returns the pubsub of the broker that the scheme of the URL names.
+// The memory scheme only delivers events within the process.
+func NewPubSub(rawURL string, logger *slog.Logger) (events.PubSub, error) {
+	scheme, err := parseScheme(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	switch scheme {
+	case "amqp", "amqps":
+		return rabbitmq.NewPubSub(rawURL, logger)
+	case "nats", "tls":
+		return nats.NewPubSub(rawURL, logger)
+	case "memory":
+		return memory.NewPubSub(logger), nil
+	default:
+		return nil, fmt.Errorf("%w %q", ErrUnsupportedScheme, scheme)
+	}
+}
+
+func parseScheme(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+
+	return u.Scheme, nil
+}
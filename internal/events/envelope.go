@@ -0,0 +1,131 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+const (
+	// SpecVersion is the version of the CloudEvents specification the envelopes follow.
+	SpecVersion = "1.0"
+
+	dataContentType = "application/json"
+)
+
+var (
+	ErrMalformedEvent     = errors.New("malformed event")
+	ErrUnsupportedVersion = errors.New("unsupported event schema version")
+)
+
+// Data is the typed payload of an event.
+type Data interface {
+	// SchemaVersion returns the version of the layout of the data. It is bumped
+	// on every change that consumers of the previous version cannot read.
+	SchemaVersion() uint64
+}
+
+// Envelope wraps the data of an event with the CloudEvents context attributes,
+// along with the schemaversion extension carrying the version of the data and
+// the distributed tracing extension carrying the trace the event was created in.
+// The rebuild extension marks the events replayed to rebuild the state of a
+// consumer, which handles them again even if it already did.
+type Envelope struct {
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	SpecVersion     string          `json:"specversion"`
+	Time            time.Time       `json:"time"`
+	DataContentType string          `json:"datacontenttype"`
+	SchemaVersion   uint64          `json:"schemaversion"`
+	TraceParent     string          `json:"traceparent,omitempty"`
+	TraceState      string          `json:"tracestate,omitempty"`
+	Rebuild         bool            `json:"rebuild,omitempty"`
+	Data            json.RawMessage `json:"data"`
+}
+
+// NewEnvelope wraps the data in an envelope with a new ID and the trace context
+// of the span in ctx. The type of the event is also the topic it is published to.
+func NewEnvelope(ctx context.Context, source, eventType string, data Data) (Envelope, error) {
+	id, err := uuid.NewV4()
+	if err != nil {
+		return Envelope{}, err
+	}
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return Envelope{}, err
+	}
+	trace := InjectTrace(ctx)
+
+	return Envelope{
+		ID:              id.String(),
+		Source:          source,
+		Type:            eventType,
+		SpecVersion:     SpecVersion,
+		Time:            time.Now().UTC(),
+		DataContentType: dataContentType,
+		SchemaVersion:   data.SchemaVersion(),
+		TraceParent:     trace[traceParentKey],
+		TraceState:      trace[traceStateKey],
+		Data:            raw,
+	}, nil
+}
+
+// Encode returns the envelope as the message handed to the publisher.
+func (env Envelope) Encode() (map[string]interface{}, error) {
+	raw, err := json.Marshal(env)
+	if err != nil {
+		return nil, err
+	}
+
+	var msg map[string]interface{}
+	if err := json.Unmarshal(raw, &msg); err != nil {
+		return nil, err
+	}
+
+	return msg, nil
+}
+
+// Decode reads the envelope of a message received by a handler. Attributes
+// added by the broker, such as the topic, are ignored.
+func Decode(msg map[string]interface{}) (Envelope, error) {
+	raw, err := json.Marshal(msg)
+	if err != nil {
+		return Envelope{}, errors.Join(ErrMalformedEvent, err)
+	}
+
+	var env Envelope
+	if err := json.Unmarshal(raw, &env); err != nil {
+		return Envelope{}, errors.Join(ErrMalformedEvent, err)
+	}
+	if env.SpecVersion != SpecVersion || env.ID == "" || env.Type == "" {
+		return Envelope{}, ErrMalformedEvent
+	}
+
+	return env, nil
+}
+
+// DecodeData reads the data of the envelope. Data of another schema version
+// than the one of D is rejected rather than read with missing fields.
+func DecodeData[D Data](env Envelope) (D, error) {
+	var data D
+	if env.SchemaVersion != data.SchemaVersion() {
+		return data, fmt.Errorf("%w %d of %s", ErrUnsupportedVersion, env.SchemaVersion, env.Type)
+	}
+	if err := json.Unmarshal(env.Data, &data); err != nil {
+		return data, errors.Join(ErrMalformedEvent, err)
+	}
+
+	return data, nil
+}
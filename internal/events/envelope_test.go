@@ -0,0 +1,127 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package events_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rodneyosodo/twiga/internal/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnvelope(t *testing.T) {
+	comment := events.CommentEvent{
+		ID:        "comment",
+		PostID:    "post",
+		UserID:    "commenter",
+		Content:   "nice post",
+		CreatedAt: time.Now().UTC().Round(time.Millisecond),
+	}
+
+	env, err := events.NewEnvelope(context.Background(), "/twiga/posts", events.CommentCreated, comment)
+	require.NoError(t, err)
+	assert.NotEmpty(t, env.ID)
+	assert.Equal(t, events.SpecVersion, env.SpecVersion)
+	assert.Equal(t, comment.SchemaVersion(), env.SchemaVersion)
+
+	msg, err := env.Encode()
+	require.NoError(t, err)
+	assert.Equal(t, env.ID, msg["id"])
+	assert.Equal(t, events.CommentCreated, msg["type"])
+	assert.Equal(t, "/twiga/posts", msg["source"])
+
+	// The broker adds the topic and the timestamp to the published message.
+	msg["topic"] = events.CommentCreated
+	msg["timestamp"] = time.Now().UnixNano()
+
+	decoded, err := events.Decode(msg)
+	require.NoError(t, err)
+	assert.Equal(t, env.ID, decoded.ID)
+	assert.Equal(t, env.Type, decoded.Type)
+	assert.True(t, env.Time.Equal(decoded.Time))
+
+	data, err := events.DecodeData[events.CommentEvent](decoded)
+	require.NoError(t, err)
+	assert.Equal(t, comment, data)
+}
+
+func TestDecode(t *testing.T) {
+	cases := []struct {
+		desc string
+		msg  map[string]interface{}
+		err  error
+	}{
+		{
+			desc: "envelope",
+			msg:  map[string]interface{}{"id": "1", "type": events.PostCreated, "specversion": events.SpecVersion, "schemaversion": 1, "data": map[string]interface{}{}},
+		},
+		{
+			desc: "unwrapped event",
+			msg:  map[string]interface{}{"id": "1", "topic": events.PostCreated, "user_id": "author"},
+			err:  events.ErrMalformedEvent,
+		},
+		{
+			desc: "unknown spec version",
+			msg:  map[string]interface{}{"id": "1", "type": events.PostCreated, "specversion": "0.3"},
+			err:  events.ErrMalformedEvent,
+		},
+		{
+			desc: "missing type",
+			msg:  map[string]interface{}{"id": "1", "specversion": events.SpecVersion},
+			err:  events.ErrMalformedEvent,
+		},
+		{
+			desc: "invalid attribute",
+			msg:  map[string]interface{}{"id": 1, "type": events.PostCreated, "specversion": events.SpecVersion},
+			err:  events.ErrMalformedEvent,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			_, err := events.Decode(tc.msg)
+			assert.ErrorIs(t, err, tc.err)
+		})
+	}
+}
+
+func TestDecodeData(t *testing.T) {
+	cases := []struct {
+		desc string
+		env  events.Envelope
+		err  error
+	}{
+		{
+			desc: "current version",
+			env:  events.Envelope{Type: events.FollowerCreated, SchemaVersion: 1, Data: []byte(`{"follower_id":"a","followee_id":"b"}`)},
+		},
+		{
+			desc: "newer version",
+			env:  events.Envelope{Type: events.FollowerCreated, SchemaVersion: 2, Data: []byte(`{"follower":"a","followee":"b"}`)},
+			err:  events.ErrUnsupportedVersion,
+		},
+		{
+			desc: "malformed data",
+			env:  events.Envelope{Type: events.FollowerCreated, SchemaVersion: 1, Data: []byte(`{"follower_id":1}`)},
+			err:  events.ErrMalformedEvent,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			data, err := events.DecodeData[events.FollowerEvent](tc.env)
+			assert.ErrorIs(t, err, tc.err)
+			if tc.err == nil {
+				assert.Equal(t, events.FollowerEvent{FollowerID: "a", FolloweeID: "b"}, data)
+			}
+		})
+	}
+}
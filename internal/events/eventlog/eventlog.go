@@ -0,0 +1,87 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package eventlog
+
+import (
+	"context"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/rodneyosodo/twiga/internal/events"
+)
+
+// Event is an event archived in the log, as it was received from the broker.
+type Event struct {
+	Seq        uint64
+	ID         string
+	Topic      string
+	Payload    map[string]interface{}
+	OccurredAt time.Time
+}
+
+// Query selects the events that occurred in [From, To), archived after the
+// event with sequence number After.
+type Query struct {
+	From  time.Time
+	To    time.Time
+	After uint64
+	Limit uint64
+}
+
+// Repository keeps the events in the order they were archived.
+type Repository interface {
+	// Save archives the events, skipping those that are already archived.
+	Save(ctx context.Context, events ...Event) error
+	// Retrieve returns up to limit events matching the query, in the order they were archived.
+	Retrieve(ctx context.Context, q Query) ([]Event, error)
+}
+
+var _ events.EventHandler = (*archiver)(nil)
+
+type archiver struct {
+	repo Repository
+}
+
+// NewArchiver returns an event handler that archives every event it receives.
+// Events are identified by the ID of their envelope, so an event delivered more
+// than once is archived once.
+func NewArchiver(repo Repository) events.EventHandler {
+	return &archiver{repo: repo}
+}
+
+func (a *archiver) Handle(ctx context.Context, msg map[string]interface{}) error {
+	event := Event{
+		Payload:    msg,
+		OccurredAt: time.Now().UTC(),
+	}
+	event.Topic, _ = msg["topic"].(string)
+
+	env, err := events.Decode(msg)
+	if err != nil {
+		// Events that are not enveloped have no ID to be told apart by.
+		id, err := uuid.NewV4()
+		if err != nil {
+			return err
+		}
+		event.ID = id.String()
+
+		return a.repo.Save(ctx, event)
+	}
+
+	event.ID = env.ID
+	event.OccurredAt = env.Time
+	if event.Topic == "" {
+		event.Topic = env.Type
+	}
+
+	return a.repo.Save(ctx, event)
+}
+
+func (a *archiver) Cancel() error {
+	return nil
+}
@@ -0,0 +1,219 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package eventlog_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rodneyosodo/twiga/internal/events"
+	"github.com/rodneyosodo/twiga/internal/events/eventlog"
+	"github.com/rodneyosodo/twiga/internal/events/outbox"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errHandle = errors.New("failed to handle event")
+
+// repository keeps the events in memory, skipping the IDs it already has.
+type repository struct {
+	events []eventlog.Event
+}
+
+func (r *repository) Save(_ context.Context, events ...eventlog.Event) error {
+	for _, event := range events {
+		if r.has(event.ID) {
+			continue
+		}
+		event.Seq = uint64(len(r.events) + 1)
+		r.events = append(r.events, event)
+	}
+
+	return nil
+}
+
+func (r *repository) Retrieve(_ context.Context, q eventlog.Query) ([]eventlog.Event, error) {
+	ret := make([]eventlog.Event, 0)
+	for _, event := range r.events {
+		if uint64(len(ret)) == q.Limit {
+			break
+		}
+		if event.Seq > q.After && !event.OccurredAt.Before(q.From) && event.OccurredAt.Before(q.To) {
+			ret = append(ret, event)
+		}
+	}
+
+	return ret, nil
+}
+
+func (r *repository) has(id string) bool {
+	for _, event := range r.events {
+		if event.ID == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+// inbox keeps the claimed IDs in memory.
+type inbox struct {
+	claimed map[string]bool
+}
+
+func (i *inbox) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+func (i *inbox) Claim(_ context.Context, id string) (bool, error) {
+	if i.claimed[id] {
+		return false, nil
+	}
+	i.claimed[id] = true
+
+	return true, nil
+}
+
+func (i *inbox) Prune(_ context.Context, _ time.Time) (uint64, error) {
+	return 0, nil
+}
+
+// handler records the types of the events it handles and fails on the types in fail.
+type handler struct {
+	types []string
+	fail  map[string]bool
+}
+
+func (h *handler) Handle(_ context.Context, msg map[string]interface{}) error {
+	eventType, _ := msg["type"].(string)
+	if h.fail[eventType] {
+		return errHandle
+	}
+	h.types = append(h.types, eventType)
+
+	return nil
+}
+
+func (h *handler) Cancel() error {
+	return nil
+}
+
+func TestArchiver(t *testing.T) {
+	repo := &repository{}
+	archiver := eventlog.NewArchiver(repo)
+
+	msg := envelope(t, events.PostCreated, time.Now())
+	require.NoError(t, archiver.Handle(context.Background(), msg))
+	require.NoError(t, archiver.Handle(context.Background(), msg))
+	require.NoError(t, archiver.Handle(context.Background(), map[string]interface{}{"topic": events.PostCreated}))
+	require.NoError(t, archiver.Handle(context.Background(), map[string]interface{}{"topic": events.PostCreated}))
+
+	require.Len(t, repo.events, 3)
+	assert.Equal(t, msg["id"], repo.events[0].ID)
+	assert.Equal(t, events.PostCreated, repo.events[0].Topic)
+	assert.NotEqual(t, repo.events[1].ID, repo.events[2].ID)
+}
+
+func TestReplay(t *testing.T) {
+	start := time.Now().UTC().Add(-time.Hour)
+
+	repo := &repository{}
+	archiver := eventlog.NewArchiver(repo)
+	for i, eventType := range []string{events.PostCreated, events.LikeCreated, events.CommentCreated, events.FollowerCreated} {
+		require.NoError(t, archiver.Handle(context.Background(), envelope(t, eventType, start.Add(time.Duration(i)*time.Minute))))
+	}
+
+	cases := []struct {
+		desc     string
+		filter   eventlog.Filter
+		fail     map[string]bool
+		types    []string
+		replayed uint64
+		err      error
+	}{
+		{
+			desc:     "all events",
+			filter:   eventlog.Filter{From: start},
+			types:    []string{events.PostCreated, events.LikeCreated, events.CommentCreated, events.FollowerCreated},
+			replayed: 4,
+		},
+		{
+			desc:     "time range",
+			filter:   eventlog.Filter{From: start.Add(time.Minute), To: start.Add(3 * time.Minute)},
+			types:    []string{events.LikeCreated, events.CommentCreated},
+			replayed: 2,
+		},
+		{
+			desc:     "topic",
+			filter:   eventlog.Filter{Topic: "events.likes.*", From: start},
+			types:    []string{events.LikeCreated},
+			replayed: 1,
+		},
+		{
+			desc:     "failed event",
+			filter:   eventlog.Filter{From: start},
+			fail:     map[string]bool{events.CommentCreated: true},
+			types:    []string{events.PostCreated, events.LikeCreated},
+			replayed: 2,
+			err:      errHandle,
+		},
+		{
+			desc:   "empty range",
+			filter: eventlog.Filter{From: start, To: start},
+			err:    eventlog.ErrInvalidRange,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			h := &handler{fail: tc.fail}
+			replayed, err := eventlog.Replay(context.Background(), repo, h, tc.filter)
+			assert.ErrorIs(t, err, tc.err)
+			assert.Equal(t, tc.replayed, replayed)
+			assert.Equal(t, tc.types, h.types)
+		})
+	}
+}
+
+func TestReplayDeduplicated(t *testing.T) {
+	repo := &repository{}
+	archiver := eventlog.NewArchiver(repo)
+	handled := envelope(t, events.PostCreated, time.Now())
+	require.NoError(t, archiver.Handle(context.Background(), handled))
+	require.NoError(t, archiver.Handle(context.Background(), envelope(t, events.LikeCreated, time.Now())))
+
+	// The consumer handled the first event before the replay.
+	h := &handler{}
+	eh := outbox.Deduplicate(&inbox{claimed: map[string]bool{handled["id"].(string): true}}, h)
+
+	filter := eventlog.Filter{From: time.Now().Add(-time.Hour)}
+	for range 2 {
+		_, err := eventlog.Replay(context.Background(), repo, eh, filter)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, []string{events.LikeCreated}, h.types)
+
+	// Rebuilding handles every event again.
+	_, err := eventlog.Replay(context.Background(), repo, eh, eventlog.Filter{From: filter.From, Rebuild: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{events.LikeCreated, events.PostCreated, events.LikeCreated}, h.types)
+	assert.NotContains(t, repo.events[0].Payload, "rebuild", "the archived events should be left as they are")
+}
+
+func envelope(t *testing.T, eventType string, occurredAt time.Time) map[string]interface{} {
+	env, err := events.NewEnvelope(context.Background(), "/twiga/posts", eventType, events.PostEvent{ID: "post"})
+	require.NoError(t, err)
+	env.Time = occurredAt.UTC()
+	msg, err := env.Encode()
+	require.NoError(t, err)
+	msg["topic"] = eventType
+
+	return msg
+}
@@ -0,0 +1,37 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package postgres
+
+import (
+	_ "github.com/jackc/pgx/v5/stdlib" // required for SQL access
+	migrate "github.com/rubenv/sql-migrate"
+)
+
+func Migration() *migrate.MemoryMigrationSource {
+	return &migrate.MemoryMigrationSource{
+		Migrations: []*migrate.Migration{
+			{
+				Id: "eventlog_01",
+				Up: []string{
+					`CREATE TABLE IF NOT EXISTS events (
+						seq BIGSERIAL PRIMARY KEY,
+						id VARCHAR(254) UNIQUE NOT NULL,
+						topic VARCHAR(254) NOT NULL,
+						payload JSONB NOT NULL,
+						occurred_at TIMESTAMP NOT NULL,
+						archived_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+					)`,
+					`CREATE INDEX idx_events_occurred_at ON events(occurred_at, seq);`,
+				},
+				Down: []string{
+					`DROP TABLE IF EXISTS events`,
+				},
+			},
+		},
+	}
+}
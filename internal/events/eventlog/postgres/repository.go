@@ -0,0 +1,106 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/rodneyosodo/twiga/internal/events/eventlog"
+	"github.com/rodneyosodo/twiga/internal/postgres"
+)
+
+var _ eventlog.Repository = (*repository)(nil)
+
+type repository struct {
+	db postgres.Database
+}
+
+// NewRepository returns an event log kept in the events table.
+func NewRepository(db postgres.Database) eventlog.Repository {
+	return &repository{db: db}
+}
+
+func (r *repository) Save(ctx context.Context, events ...eventlog.Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	dEvents := make([]dbEvent, 0, len(events))
+	for _, event := range events {
+		payload, err := json.Marshal(event.Payload)
+		if err != nil {
+			return err
+		}
+		dEvents = append(dEvents, dbEvent{
+			ID:         event.ID,
+			Topic:      event.Topic,
+			Payload:    payload,
+			OccurredAt: event.OccurredAt,
+		})
+	}
+
+	query := `INSERT INTO events (id, topic, payload, occurred_at) VALUES (:id, :topic, :payload, :occurred_at)
+		ON CONFLICT (id) DO NOTHING`
+	_, err := r.db.NamedExecContext(ctx, query, dEvents)
+
+	return err
+}
+
+func (r *repository) Retrieve(ctx context.Context, q eventlog.Query) ([]eventlog.Event, error) {
+	query := `SELECT seq, id, topic, payload, occurred_at FROM events
+		WHERE occurred_at >= :from AND occurred_at < :to AND seq > :after
+		ORDER BY seq LIMIT :limit`
+
+	rows, err := r.db.NamedQueryContext(ctx, query, dbQuery{
+		From:  q.From,
+		To:    q.To,
+		After: q.After,
+		Limit: q.Limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]eventlog.Event, 0)
+	for rows.Next() {
+		var dEvent dbEvent
+		if err := rows.StructScan(&dEvent); err != nil {
+			return nil, err
+		}
+		event := eventlog.Event{
+			Seq:        dEvent.Seq,
+			ID:         dEvent.ID,
+			Topic:      dEvent.Topic,
+			OccurredAt: dEvent.OccurredAt,
+		}
+		if err := json.Unmarshal(dEvent.Payload, &event.Payload); err != nil {
+			return nil, err
+		}
+		items = append(items, event)
+	}
+
+	return items, nil
+}
+
+type dbEvent struct {
+	Seq        uint64    `db:"seq"`
+	ID         string    `db:"id"`
+	Topic      string    `db:"topic"`
+	Payload    []byte    `db:"payload"`
+	OccurredAt time.Time `db:"occurred_at"`
+}
+
+type dbQuery struct {
+	From  time.Time `db:"from"`
+	To    time.Time `db:"to"`
+	After uint64    `db:"after"`
+	Limit uint64    `db:"limit"`
+}
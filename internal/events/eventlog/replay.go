@@ -0,0 +1,104 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package eventlog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rodneyosodo/twiga/internal/events"
+)
+
+const defBatchSize = 100
+
+var ErrInvalidRange = errors.New("invalid time range")
+
+// Filter selects the events to replay: those that occurred in [From, To) and
+// whose subject matches the topic of the subscription they are replayed to.
+// Rebuild marks the events to be handled again by consumers that already did.
+type Filter struct {
+	Topic   string
+	From    time.Time
+	To      time.Time
+	Rebuild bool
+}
+
+// Replay hands the events of the log matching the filter to the handler, in
+// the order they were archived, and returns how many were handled. It stops at
+// the first event the handler fails, so the replay can be resumed from it.
+//
+// Replayed events keep their ID, so a handler wrapped with outbox.Deduplicate
+// skips the events it has already handled, unless they are replayed to rebuild
+// the state of the handler from scratch.
+func Replay(ctx context.Context, repo Repository, handler events.EventHandler, filter Filter) (uint64, error) {
+	if filter.To.IsZero() {
+		filter.To = time.Now().UTC()
+	}
+	if !filter.From.Before(filter.To) {
+		return 0, ErrInvalidRange
+	}
+	if filter.Topic == "" {
+		filter.Topic = events.SubjectAllEvents
+	}
+
+	q := Query{
+		From:  filter.From,
+		To:    filter.To,
+		Limit: defBatchSize,
+	}
+	replayed := uint64(0)
+	for {
+		page, err := repo.Retrieve(ctx, q)
+		if err != nil {
+			return replayed, err
+		}
+
+		for _, event := range page {
+			if !events.MatchTopic(filter.Topic, subject(event.Topic)) {
+				continue
+			}
+			payload := event.Payload
+			if filter.Rebuild {
+				payload = rebuild(payload)
+			}
+			if err := handler.Handle(ctx, payload); err != nil {
+				return replayed, fmt.Errorf("failed to replay event %s: %w", event.ID, err)
+			}
+			replayed++
+		}
+
+		if uint64(len(page)) < q.Limit {
+			return replayed, nil
+		}
+		q.After = page[len(page)-1].Seq
+	}
+}
+
+// rebuild returns a copy of the event with the rebuild extension set. Events
+// that are not enveloped are left as they are.
+func rebuild(payload map[string]interface{}) map[string]interface{} {
+	if _, err := events.Decode(payload); err != nil {
+		return payload
+	}
+
+	ret := make(map[string]interface{}, len(payload)+1)
+	for k, v := range payload {
+		ret[k] = v
+	}
+	ret["rebuild"] = true
+
+	return ret
+}
+
+// subject returns the subject the event was published to, which the topics of
+// the subscriptions are matched against.
+func subject(topic string) string {
+	return "events." + topic
+}
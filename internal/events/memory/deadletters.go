@@ -0,0 +1,97 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package memory
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/rodneyosodo/twiga/internal/events"
+)
+
+func (ps *pubsub) DeadLetters(_ context.Context, id, topic string, limit uint64) ([]events.DeadLetter, error) {
+	if id == "" {
+		return nil, ErrEmptyID
+	}
+	if topic == "" {
+		return nil, ErrEmptyTopic
+	}
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	letters := make([]events.DeadLetter, 0)
+	for _, dl := range ps.deadLetters[queueName(id, topic)] {
+		if uint64(len(letters)) >= limit {
+			break
+		}
+		letters = append(letters, toDeadLetter(dl))
+	}
+
+	return letters, nil
+}
+
+// Replay hands the dead-lettered events back to the subscription, which has to
+// exist since the events are not kept for subscriptions that are gone.
+func (ps *pubsub) Replay(_ context.Context, id, topic, messageID string) (uint64, error) {
+	if id == "" {
+		return 0, ErrEmptyID
+	}
+	if topic == "" {
+		return 0, ErrEmptyTopic
+	}
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	topic = formatTopic(topic)
+	sub, ok := ps.subscriptions[topic][id]
+	if !ok {
+		return 0, ErrNotSubscribed
+	}
+
+	queue := queueName(id, topic)
+	remaining := make([]deadLetter, 0, len(ps.deadLetters[queue]))
+	replayed := uint64(0)
+	for _, dl := range ps.deadLetters[queue] {
+		if messageID != "" && (dl.id != messageID || replayed > 0) {
+			remaining = append(remaining, dl)
+
+			continue
+		}
+
+		// Replayed events get a fresh set of retries.
+		msg := dl.message
+		msg.retries = 0
+		sub.push(msg)
+		replayed++
+	}
+	if messageID != "" && replayed == 0 {
+		return 0, ErrDeadLetterNotFound
+	}
+
+	if len(remaining) == 0 {
+		delete(ps.deadLetters, queue)
+	} else {
+		ps.deadLetters[queue] = remaining
+	}
+
+	return replayed, nil
+}
+
+func toDeadLetter(dl deadLetter) events.DeadLetter {
+	letter := events.DeadLetter{
+		ID:        dl.id,
+		Error:     dl.err,
+		Retries:   dl.retries,
+		Timestamp: dl.timestamp,
+	}
+	if err := json.Unmarshal(dl.body, &letter.Event); err != nil {
+		letter.Body = string(dl.body)
+	}
+
+	return letter
+}
@@ -0,0 +1,301 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/rodneyosodo/twiga/internal/events"
+)
+
+const chansPrefix = "events"
+
+var (
+	ErrNotSubscribed      = errors.New("not subscribed")
+	ErrEmptyTopic         = errors.New("empty topic")
+	ErrEmptyID            = errors.New("empty ID")
+	ErrDeadLetterNotFound = errors.New("dead letter not found")
+	ErrClosed             = errors.New("pubsub closed")
+)
+
+var _ events.PubSub = (*pubsub)(nil)
+
+// message is an event waiting to be handled by a subscription.
+type message struct {
+	id      string
+	subject string
+	body    []byte
+	headers map[string]string
+	retries uint64
+}
+
+type deadLetter struct {
+	message
+	err       string
+	timestamp time.Time
+}
+
+type pubsub struct {
+	logger        *slog.Logger
+	subscriptions map[string]map[string]*subscription
+	deadLetters   map[string][]deadLetter
+	closed        bool
+	mu            sync.Mutex
+}
+
+// NewPubSub returns a pubsub that delivers the events to the subscriptions of the
+// same process. Topics are matched like the routing keys of an AMQP topic exchange,
+// and events are neither persisted nor shared with other processes.
+func NewPubSub(logger *slog.Logger) events.PubSub {
+	return &pubsub{
+		logger:        logger,
+		subscriptions: make(map[string]map[string]*subscription),
+		deadLetters:   make(map[string][]deadLetter),
+	}
+}
+
+// Publish hands the trace context of the publishing span to the subscriptions
+// along with the event.
+func (ps *pubsub) Publish(ctx context.Context, topic string, msg map[string]interface{}) (err error) {
+	if topic == "" {
+		return ErrEmptyTopic
+	}
+	ctx, span := events.StartPublish(ctx, topic, msg)
+	defer func() {
+		events.EndSpan(span, err)
+	}()
+
+	msg["timestamp"] = time.Now().UnixNano()
+	msg["topic"] = topic
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	id, err := uuid.NewV4()
+	if err != nil {
+		return err
+	}
+
+	subject := formatTopic(fmt.Sprintf("%s.%s", chansPrefix, topic))
+	headers := events.InjectTrace(ctx)
+
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	if ps.closed {
+		return ErrClosed
+	}
+	for pattern, s := range ps.subscriptions {
+		if !events.MatchTopic(pattern, subject) {
+			continue
+		}
+		for _, sub := range s {
+			sub.push(message{id: id.String(), subject: subject, body: data, headers: headers})
+		}
+	}
+
+	return nil
+}
+
+func (ps *pubsub) Subscribe(ctx context.Context, cfg events.SubscriberConfig) error {
+	if cfg.ID == "" {
+		return ErrEmptyID
+	}
+	if cfg.Topic == "" {
+		return ErrEmptyTopic
+	}
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	if ps.closed {
+		return ErrClosed
+	}
+
+	cfg.Topic = formatTopic(cfg.Topic)
+	s, ok := ps.subscriptions[cfg.Topic]
+	if !ok {
+		s = make(map[string]*subscription)
+		ps.subscriptions[cfg.Topic] = s
+	}
+	if current, ok := s[cfg.ID]; ok {
+		if err := current.cancel(); err != nil {
+			return err
+		}
+	}
+
+	sub := newSubscription(ctx, cfg)
+	s[cfg.ID] = sub
+	go ps.handle(sub)
+
+	return nil
+}
+
+func (ps *pubsub) Unsubscribe(_ context.Context, id, topic string) error {
+	if id == "" {
+		return ErrEmptyID
+	}
+	if topic == "" {
+		return ErrEmptyTopic
+	}
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	topic = formatTopic(topic)
+	s, ok := ps.subscriptions[topic]
+	if !ok {
+		return ErrNotSubscribed
+	}
+
+	current, ok := s[id]
+	if !ok {
+		return ErrNotSubscribed
+	}
+	if err := current.cancel(); err != nil {
+		return err
+	}
+
+	delete(s, id)
+	if len(s) == 0 {
+		delete(ps.subscriptions, topic)
+	}
+
+	return nil
+}
+
+// Redeliver queues the event for the subscription only.
+func (ps *pubsub) Redeliver(ctx context.Context, id, topic string, msg map[string]interface{}) (err error) {
+	if id == "" {
+		return ErrEmptyID
+	}
+	if topic == "" {
+		return ErrEmptyTopic
+	}
+	ctx, span := events.StartPublish(ctx, topic, msg)
+	defer func() {
+		events.EndSpan(span, err)
+	}()
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	msgID, err := uuid.NewV4()
+	if err != nil {
+		return err
+	}
+	subject, _ := msg["topic"].(string)
+
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	if ps.closed {
+		return ErrClosed
+	}
+	sub, ok := ps.subscriptions[formatTopic(topic)][id]
+	if !ok {
+		return ErrNotSubscribed
+	}
+	sub.push(message{
+		id:      msgID.String(),
+		subject: formatTopic(fmt.Sprintf("%s.%s", chansPrefix, subject)),
+		body:    data,
+		headers: events.InjectTrace(ctx),
+	})
+
+	return nil
+}
+
+// Close stops every subscription. Events that were not handled yet are dropped.
+func (ps *pubsub) Close() error {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	ps.closed = true
+	for _, s := range ps.subscriptions {
+		for _, sub := range s {
+			sub.stop()
+		}
+	}
+	ps.subscriptions = make(map[string]map[string]*subscription)
+
+	return nil
+}
+
+// handle keeps handling events after failures so that one bad event does not stop the subscription.
+func (ps *pubsub) handle(sub *subscription) {
+	for {
+		msg, ok := sub.pop()
+		if !ok {
+			return
+		}
+		ps.process(sub, msg)
+	}
+}
+
+// process retries the event after the retry delay of the subscription, and
+// dead-letters it once it has exhausted its retries.
+func (ps *pubsub) process(sub *subscription, msg message) {
+	ctx, span := events.StartHandle(sub.ctx, msg.subject, msg.headers)
+	defer span.End()
+
+	var event map[string]interface{}
+	if err := json.Unmarshal(msg.body, &event); err != nil {
+		ps.logger.Warn(fmt.Sprintf("Failed to unmarshal received event: %s", err))
+		ps.deadLetter(sub, msg, err)
+
+		return
+	}
+
+	err := sub.cfg.Handler.Handle(ctx, event)
+	if err == nil {
+		return
+	}
+	events.FailSpan(span, err)
+
+	if msg.retries < sub.cfg.MaxRetries {
+		ps.logger.Warn(fmt.Sprintf("Failed to handle twiga event, retrying %d/%d: %s", msg.retries+1, sub.cfg.MaxRetries, err))
+		msg.retries++
+		time.AfterFunc(sub.cfg.RetryDelay, func() {
+			sub.push(msg)
+		})
+
+		return
+	}
+
+	ps.logger.Warn(fmt.Sprintf("Failed to handle twiga event, dead-lettering after %d retries: %s", msg.retries, err))
+	ps.deadLetter(sub, msg, err)
+}
+
+func (ps *pubsub) deadLetter(sub *subscription, msg message, cause error) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	queue := queueName(sub.cfg.ID, sub.cfg.Topic)
+	ps.deadLetters[queue] = append(ps.deadLetters[queue], deadLetter{
+		message:   msg,
+		err:       cause.Error(),
+		timestamp: time.Now().UTC(),
+	})
+}
+
+func formatTopic(topic string) string {
+	return strings.ReplaceAll(topic, ">", "#")
+}
+
+func queueName(id, topic string) string {
+	return fmt.Sprintf("%s-%s", formatTopic(topic), id)
+}
@@ -0,0 +1,264 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package memory_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rodneyosodo/twiga/internal/events"
+	"github.com/rodneyosodo/twiga/internal/events/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const timeout = time.Second
+
+var errHandle = errors.New("failed to handle event")
+
+type handler struct {
+	events   chan map[string]interface{}
+	traces   chan trace.SpanContext
+	fail     atomic.Bool
+	canceled atomic.Bool
+}
+
+func newHandler() *handler {
+	return &handler{
+		events: make(chan map[string]interface{}, 10),
+		traces: make(chan trace.SpanContext, 10),
+	}
+}
+
+func (h *handler) Handle(ctx context.Context, msg map[string]interface{}) error {
+	if h.fail.Load() {
+		return errHandle
+	}
+	h.traces <- trace.SpanContextFromContext(ctx)
+	h.events <- msg
+
+	return nil
+}
+
+func (h *handler) Cancel() error {
+	h.canceled.Store(true)
+
+	return nil
+}
+
+func (h *handler) received(t *testing.T) map[string]interface{} {
+	t.Helper()
+
+	select {
+	case msg := <-h.events:
+		return msg
+	case <-time.After(timeout):
+		t.Fatal("event was not delivered")
+
+		return nil
+	}
+}
+
+func TestPublish(t *testing.T) {
+	cases := []struct {
+		desc      string
+		topic     string
+		published string
+		delivered bool
+	}{
+		{desc: "all events", topic: events.SubjectAllEvents, published: events.PostContentUpdated, delivered: true},
+		{desc: "exact topic", topic: "events.posts.created", published: events.PostCreated, delivered: true},
+		{desc: "other topic", topic: "events.posts.created", published: events.PostDeleted},
+		{desc: "one word wildcard", topic: "events.posts.*", published: events.PostUpdated, delivered: true},
+		{desc: "one word wildcard on more words", topic: "events.posts.*", published: events.PostContentUpdated},
+		{desc: "many words wildcard", topic: "events.posts.#", published: events.PostContentUpdated, delivered: true},
+		{desc: "many words wildcard on no words", topic: "events.posts.updated.#", published: events.PostUpdated, delivered: true},
+		{desc: "nats wildcard", topic: "events.posts.>", published: events.PostContentUpdated, delivered: true},
+		{desc: "leading wildcard", topic: "events.#.created", published: events.LikeCreated, delivered: true},
+		{desc: "other prefix", topic: "events.comments.#", published: events.PostCreated},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			ps := memory.NewPubSub(slog.Default())
+			defer ps.Close()
+
+			h := newHandler()
+			err := ps.Subscribe(context.Background(), events.SubscriberConfig{ID: "test", Topic: tc.topic, Handler: h})
+			require.NoError(t, err)
+
+			err = ps.Publish(context.Background(), tc.published, map[string]interface{}{"id": "1"})
+			require.NoError(t, err)
+
+			if !tc.delivered {
+				select {
+				case msg := <-h.events:
+					t.Fatalf("unexpected event %v", msg)
+				case <-time.After(50 * time.Millisecond):
+				}
+
+				return
+			}
+			msg := h.received(t)
+			assert.Equal(t, "1", msg["id"])
+			assert.Equal(t, tc.published, msg["topic"])
+		})
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	ps := memory.NewPubSub(slog.Default())
+	defer ps.Close()
+
+	cases := []struct {
+		desc string
+		cfg  events.SubscriberConfig
+		err  error
+	}{
+		{desc: "valid subscription", cfg: events.SubscriberConfig{ID: "test", Topic: events.SubjectAllEvents, Handler: newHandler()}},
+		{desc: "same subscription", cfg: events.SubscriberConfig{ID: "test", Topic: events.SubjectAllEvents, Handler: newHandler()}},
+		{desc: "empty ID", cfg: events.SubscriberConfig{Topic: events.SubjectAllEvents, Handler: newHandler()}, err: memory.ErrEmptyID},
+		{desc: "empty topic", cfg: events.SubscriberConfig{ID: "test", Handler: newHandler()}, err: memory.ErrEmptyTopic},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := ps.Subscribe(context.Background(), tc.cfg)
+			assert.ErrorIs(t, err, tc.err)
+		})
+	}
+
+	require.NoError(t, ps.Close())
+	err := ps.Subscribe(context.Background(), events.SubscriberConfig{ID: "test", Topic: events.SubjectAllEvents, Handler: newHandler()})
+	assert.ErrorIs(t, err, memory.ErrClosed)
+}
+
+func TestUnsubscribe(t *testing.T) {
+	ps := memory.NewPubSub(slog.Default())
+	defer ps.Close()
+
+	h := newHandler()
+	err := ps.Subscribe(context.Background(), events.SubscriberConfig{ID: "test", Topic: "events.posts.>", Handler: h})
+	require.NoError(t, err)
+
+	err = ps.Unsubscribe(context.Background(), "test", "events.posts.#")
+	require.NoError(t, err)
+	assert.True(t, h.canceled.Load())
+
+	err = ps.Unsubscribe(context.Background(), "test", "events.posts.#")
+	assert.ErrorIs(t, err, memory.ErrNotSubscribed)
+
+	err = ps.Publish(context.Background(), events.PostCreated, map[string]interface{}{})
+	require.NoError(t, err)
+	select {
+	case msg := <-h.events:
+		t.Fatalf("unexpected event %v", msg)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestDeadLetters(t *testing.T) {
+	ps := memory.NewPubSub(slog.Default())
+	defer ps.Close()
+
+	h := newHandler()
+	h.fail.Store(true)
+	cfg := events.SubscriberConfig{
+		ID:         "test",
+		Topic:      events.SubjectAllEvents,
+		Handler:    h,
+		MaxRetries: 2,
+		RetryDelay: time.Millisecond,
+	}
+	require.NoError(t, ps.Subscribe(context.Background(), cfg))
+	require.NoError(t, ps.Publish(context.Background(), events.PostCreated, map[string]interface{}{"id": "1"}))
+
+	var letters []events.DeadLetter
+	require.Eventually(t, func() bool {
+		var err error
+		letters, err = ps.DeadLetters(context.Background(), cfg.ID, cfg.Topic, 10)
+
+		return err == nil && len(letters) == 1
+	}, timeout, time.Millisecond)
+	assert.Equal(t, cfg.MaxRetries, letters[0].Retries)
+	assert.Equal(t, errHandle.Error(), letters[0].Error)
+	assert.Equal(t, "1", letters[0].Event["id"])
+
+	_, err := ps.Replay(context.Background(), cfg.ID, cfg.Topic, "unknown")
+	assert.ErrorIs(t, err, memory.ErrDeadLetterNotFound)
+
+	h.fail.Store(false)
+	replayed, err := ps.Replay(context.Background(), cfg.ID, cfg.Topic, letters[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), replayed)
+	assert.Equal(t, "1", h.received(t)["id"])
+
+	letters, err = ps.DeadLetters(context.Background(), cfg.ID, cfg.Topic, 10)
+	require.NoError(t, err)
+	assert.Empty(t, letters)
+}
+
+func TestTracePropagation(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	parent := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1},
+		SpanID:     trace.SpanID{1},
+		TraceFlags: trace.FlagsSampled,
+	})
+	spanCtx := trace.ContextWithSpanContext(context.Background(), parent)
+
+	env, err := events.NewEnvelope(spanCtx, "/twiga/posts", events.PostCreated, events.PostEvent{ID: "post"})
+	require.NoError(t, err)
+	assert.NotEmpty(t, env.TraceParent)
+
+	cases := []struct {
+		desc string
+		ctx  context.Context
+		msg  func() map[string]interface{}
+	}{
+		{
+			desc: "span of the publisher",
+			ctx:  spanCtx,
+			msg: func() map[string]interface{} {
+				return map[string]interface{}{"id": "1"}
+			},
+		},
+		{
+			desc: "trace of the envelope",
+			ctx:  context.Background(),
+			msg: func() map[string]interface{} {
+				msg, err := env.Encode()
+				require.NoError(t, err)
+
+				return msg
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			ps := memory.NewPubSub(slog.Default())
+			defer ps.Close()
+
+			h := newHandler()
+			require.NoError(t, ps.Subscribe(context.Background(), events.SubscriberConfig{ID: "test", Topic: events.SubjectAllEvents, Handler: h}))
+			require.NoError(t, ps.Publish(tc.ctx, events.PostCreated, tc.msg()))
+
+			h.received(t)
+			assert.Equal(t, parent.TraceID(), (<-h.traces).TraceID())
+		})
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package memory
+
+import (
+	"context"
+	"sync"
+
+	"github.com/rodneyosodo/twiga/internal/events"
+)
+
+// subscription queues its events without bounds so that publishing never waits
+// for a slow handler.
+type subscription struct {
+	ctx     context.Context
+	cfg     events.SubscriberConfig
+	pending []message
+	stopped bool
+	mu      sync.Mutex
+	cond    *sync.Cond
+}
+
+func newSubscription(ctx context.Context, cfg events.SubscriberConfig) *subscription {
+	sub := &subscription{
+		ctx: ctx,
+		cfg: cfg,
+	}
+	sub.cond = sync.NewCond(&sub.mu)
+
+	return sub
+}
+
+func (sub *subscription) push(msg message) {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+
+	if sub.stopped {
+		return
+	}
+	sub.pending = append(sub.pending, msg)
+	sub.cond.Signal()
+}
+
+// pop waits for the next event and reports false once the subscription is stopped.
+func (sub *subscription) pop() (message, bool) {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+
+	for len(sub.pending) == 0 && !sub.stopped {
+		sub.cond.Wait()
+	}
+	if sub.stopped {
+		return message{}, false
+	}
+
+	msg := sub.pending[0]
+	sub.pending = sub.pending[1:]
+
+	return msg, true
+}
+
+func (sub *subscription) stop() {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+
+	sub.stopped = true
+	sub.pending = nil
+	sub.cond.Broadcast()
+}
+
+// cancel stops the subscription and cancels its handler.
+func (sub *subscription) cancel() error {
+	sub.stop()
+
+	return sub.cfg.Handler.Cancel()
+}
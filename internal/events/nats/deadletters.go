@@ -0,0 +1,129 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package nats
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/rodneyosodo/twiga/internal/events"
+)
+
+var ErrDeadLetterNotFound = errors.New("dead letter not found")
+
+// DeadLetters reads the dead-letter stream of the subscription by subject, which
+// leaves the events in the stream.
+func (ps *pubsub) DeadLetters(ctx context.Context, id, topic string, limit uint64) ([]events.DeadLetter, error) {
+	if id == "" {
+		return nil, ErrEmptyID
+	}
+	if topic == "" {
+		return nil, ErrEmptyTopic
+	}
+	stream, err := ps.js.Stream(ctx, deadLetterStream)
+	if err != nil {
+		return nil, err
+	}
+
+	subject := deadLetterPrefix + "." + consumerName(id, topic)
+	letters := make([]events.DeadLetter, 0)
+	for seq := uint64(1); uint64(len(letters)) < limit; {
+		msg, err := stream.GetMsg(ctx, seq, jetstream.WithGetMsgSubject(subject))
+		if errors.Is(err, jetstream.ErrMsgNotFound) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		letters = append(letters, toDeadLetter(msg))
+		seq = msg.Sequence + 1
+	}
+
+	return letters, nil
+}
+
+// Replay publishes the dead-lettered events to the replay stream of the
+// subscription and removes them from the dead-letter stream.
+func (ps *pubsub) Replay(ctx context.Context, id, topic, messageID string) (uint64, error) {
+	if id == "" {
+		return 0, ErrEmptyID
+	}
+	if topic == "" {
+		return 0, ErrEmptyTopic
+	}
+	stream, err := ps.js.Stream(ctx, deadLetterStream)
+	if err != nil {
+		return 0, err
+	}
+
+	name := consumerName(id, topic)
+	replayed := uint64(0)
+	for seq := uint64(1); ; {
+		msg, err := stream.GetMsg(ctx, seq, jetstream.WithGetMsgSubject(deadLetterPrefix+"."+name))
+		if errors.Is(err, jetstream.ErrMsgNotFound) {
+			break
+		}
+		if err != nil {
+			return replayed, err
+		}
+		seq = msg.Sequence + 1
+		if messageID != "" && msg.Header.Get(nats.MsgIdHdr) != messageID {
+			continue
+		}
+
+		if _, err := ps.js.PublishMsg(ctx, replay(msg, replayPrefix+"."+name), jetstream.WithExpectStream(replayStream)); err != nil {
+			return replayed, err
+		}
+		if err := stream.DeleteMsg(ctx, msg.Sequence); err != nil {
+			return replayed, err
+		}
+		replayed++
+
+		if messageID != "" {
+			break
+		}
+	}
+	if messageID != "" && replayed == 0 {
+		return 0, ErrDeadLetterNotFound
+	}
+
+	return replayed, nil
+}
+
+// replay returns the dead-lettered event with the headers it was published
+// with, such as its trace context.
+func replay(msg *jetstream.RawStreamMsg, subject string) *nats.Msg {
+	event := nats.NewMsg(subject)
+	for k, v := range msg.Header {
+		event.Header[k] = v
+	}
+	event.Header.Del(nats.MsgIdHdr)
+	event.Header.Del(retriesHeader)
+	event.Header.Del(errorHeader)
+	event.Data = msg.Data
+
+	return event
+}
+
+func toDeadLetter(msg *jetstream.RawStreamMsg) events.DeadLetter {
+	letter := events.DeadLetter{
+		ID:        msg.Header.Get(nats.MsgIdHdr),
+		Error:     msg.Header.Get(errorHeader),
+		Timestamp: msg.Time,
+	}
+	letter.Retries, _ = strconv.ParseUint(msg.Header.Get(retriesHeader), 10, 64)
+	if err := json.Unmarshal(msg.Data, &letter.Event); err != nil {
+		letter.Body = string(msg.Data)
+	}
+
+	return letter
+}
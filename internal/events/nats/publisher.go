@@ -0,0 +1,162 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package nats
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/rodneyosodo/twiga/internal/events"
+)
+
+const (
+	chansPrefix = "events"
+
+	// Events are kept in the stream until every consumer interested in them
+	// acknowledged them. Dead letters are kept until they are replayed, and
+	// replays go through a stream of their own so that only the subscription
+	// they are replayed to receives them.
+	streamName       = "events"
+	deadLetterStream = "events-dead-letter"
+	replayStream     = "events-replay"
+	deadLetterPrefix = "dead-letter"
+	replayPrefix     = "replay"
+
+	// JetStream drops the events published again with the ID of an event it
+	// stored within the window, such as those retried by the outbox relay.
+	duplicateWindow = 2 * time.Minute
+	setupTimeout    = 10 * time.Second
+)
+
+var _ events.Publisher = (*publisher)(nil)
+
+type publisher struct {
+	conn   *nats.Conn
+	js     jetstream.JetStream
+	prefix string
+}
+
+// NewPublisher returns a publisher that reconnects to the server whenever the
+// connection is lost. Events published meanwhile are buffered by the client.
+func NewPublisher(url string, logger *slog.Logger) (events.Publisher, error) {
+	return connect(url, logger)
+}
+
+func connect(url string, logger *slog.Logger) (*publisher, error) {
+	conn, err := nats.Connect(url,
+		nats.MaxReconnects(-1),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			if err != nil {
+				logger.Warn(fmt.Sprintf("Lost connection to NATS: %s", err))
+			}
+		}),
+		nats.ReconnectHandler(func(conn *nats.Conn) {
+			logger.Info("Reconnected to NATS at " + conn.ConnectedUrl())
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	js, err := jetstream.New(conn)
+	if err != nil {
+		conn.Close()
+
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), setupTimeout)
+	defer cancel()
+
+	streams := []jetstream.StreamConfig{
+		{
+			Name:       streamName,
+			Subjects:   []string{chansPrefix + ".>"},
+			Retention:  jetstream.InterestPolicy,
+			Duplicates: duplicateWindow,
+		},
+		{
+			Name:      deadLetterStream,
+			Subjects:  []string{deadLetterPrefix + ".>"},
+			Retention: jetstream.LimitsPolicy,
+		},
+		{
+			Name:      replayStream,
+			Subjects:  []string{replayPrefix + ".>"},
+			Retention: jetstream.InterestPolicy,
+		},
+	}
+	for _, cfg := range streams {
+		if _, err := js.CreateOrUpdateStream(ctx, cfg); err != nil {
+			conn.Close()
+
+			return nil, err
+		}
+	}
+
+	ret := &publisher{
+		conn:   conn,
+		js:     js,
+		prefix: chansPrefix,
+	}
+
+	return ret, nil
+}
+
+// Publish returns once the server stored the event, so a nil error means that
+// the event will not be lost. The trace context of the publishing span is
+// carried in the headers of the event.
+func (pub *publisher) Publish(ctx context.Context, topic string, msg map[string]interface{}) (err error) {
+	if topic == "" {
+		return ErrEmptyTopic
+	}
+	ctx, span := events.StartPublish(ctx, topic, msg)
+	defer func() {
+		events.EndSpan(span, err)
+	}()
+
+	msg["timestamp"] = time.Now().UnixNano()
+	msg["topic"] = topic
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	event := nats.NewMsg(fmt.Sprintf("%s.%s", pub.prefix, topic))
+	event.Data = data
+	for k, v := range events.InjectTrace(ctx) {
+		event.Header.Set(k, v)
+	}
+
+	opts := []jetstream.PublishOpt{jetstream.WithExpectStream(streamName)}
+	if id, ok := msg["id"].(string); ok && id != "" {
+		opts = append(opts, jetstream.WithMsgID(id))
+	}
+	_, err = pub.js.PublishMsg(ctx, event, opts...)
+
+	return err
+}
+
+func (pub *publisher) Close() error {
+	pub.conn.Close()
+
+	return nil
+}
+
+// formatTopic turns the AMQP wildcards into NATS ones. NATS only allows the
+// multi-word wildcard as the last word of a topic.
+func formatTopic(topic string) string {
+	return strings.ReplaceAll(topic, "#", ">")
+}
@@ -0,0 +1,361 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package nats
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/rodneyosodo/twiga/internal/events"
+)
+
+const (
+	SubjectAllEvents = "events.>"
+
+	retriesHeader = "X-Retries"
+	errorHeader   = "X-Error"
+
+	defMaxAckPending = 100
+	// defAckWait is how long the server waits for an event to be acknowledged
+	// before redelivering it. Events are reported in progress while they are
+	// handled, so it only bounds how long a crashed subscriber holds them.
+	defAckWait = 30 * time.Second
+)
+
+var (
+	ErrNotSubscribed = errors.New("not subscribed")
+	ErrEmptyTopic    = errors.New("empty topic")
+	ErrEmptyID       = errors.New("empty ID")
+)
+
+var _ events.PubSub = (*pubsub)(nil)
+
+type subscription struct {
+	cancel func() error
+}
+
+type pubsub struct {
+	*publisher
+	logger        *slog.Logger
+	subscriptions map[string]map[string]subscription
+	mu            sync.Mutex
+}
+
+// NewPubSub returns a pubsub whose subscriptions are durable consumers. Every
+// replica subscribed with the same ID and topic pulls from the same consumer,
+// so the events are shared between them like in a queue group.
+func NewPubSub(url string, logger *slog.Logger) (events.PubSub, error) {
+	pub, err := connect(url, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &pubsub{
+		publisher:     pub,
+		logger:        logger,
+		subscriptions: make(map[string]map[string]subscription),
+	}
+
+	return ret, nil
+}
+
+func (ps *pubsub) Subscribe(ctx context.Context, cfg events.SubscriberConfig) error {
+	if cfg.ID == "" {
+		return ErrEmptyID
+	}
+	if cfg.Topic == "" {
+		return ErrEmptyTopic
+	}
+	ps.mu.Lock()
+
+	cfg.Topic = formatTopic(cfg.Topic)
+	s, ok := ps.subscriptions[cfg.Topic]
+	if ok {
+		if _, ok := s[cfg.ID]; ok {
+			ps.mu.Unlock()
+			if err := ps.Unsubscribe(ctx, cfg.ID, cfg.Topic); err != nil {
+				return err
+			}
+
+			ps.mu.Lock()
+			s = ps.subscriptions[cfg.Topic]
+		}
+	}
+	defer ps.mu.Unlock()
+	if s == nil {
+		s = make(map[string]subscription)
+		ps.subscriptions[cfg.Topic] = s
+	}
+
+	name := consumerName(cfg.ID, cfg.Topic)
+	consumer, err := ps.consume(ctx, streamName, name, cfg.Topic, cfg)
+	if err != nil {
+		return err
+	}
+	replays, err := ps.consume(ctx, replayStream, name+"-"+replayPrefix, replayPrefix+"."+name, cfg)
+	if err != nil {
+		consumer.Stop()
+
+		return err
+	}
+
+	s[cfg.ID] = subscription{
+		cancel: func() error {
+			consumer.Stop()
+			replays.Stop()
+
+			return cfg.Handler.Cancel()
+		},
+	}
+
+	return nil
+}
+
+// consume creates the durable consumer, or updates it if it already exists, and
+// handles its events until it is stopped.
+func (ps *pubsub) consume(ctx context.Context, stream, name, subject string, cfg events.SubscriberConfig) (jetstream.ConsumeContext, error) {
+	consumer, err := ps.js.CreateOrUpdateConsumer(ctx, stream, jetstream.ConsumerConfig{
+		Durable:       name,
+		FilterSubject: subject,
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		AckWait:       defAckWait,
+		MaxAckPending: defMaxAckPending,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return consumer.Consume(func(msg jetstream.Msg) {
+		ps.process(ctx, msg, consumerName(cfg.ID, cfg.Topic), cfg)
+	}, jetstream.ConsumeErrHandler(func(_ jetstream.ConsumeContext, err error) {
+		ps.logger.Warn(fmt.Sprintf("Failed to consume twiga events of %s: %s", name, err))
+	}))
+}
+
+// Unsubscribe stops consuming but keeps the durable consumer, since other
+// replicas may still consume from it.
+func (ps *pubsub) Unsubscribe(_ context.Context, id, topic string) error {
+	if id == "" {
+		return ErrEmptyID
+	}
+	if topic == "" {
+		return ErrEmptyTopic
+	}
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	topic = formatTopic(topic)
+	s, ok := ps.subscriptions[topic]
+	if !ok {
+		return ErrNotSubscribed
+	}
+
+	current, ok := s[id]
+	if !ok {
+		return ErrNotSubscribed
+	}
+	if current.cancel != nil {
+		if err := current.cancel(); err != nil {
+			return err
+		}
+	}
+
+	delete(s, id)
+	if len(s) == 0 {
+		delete(ps.subscriptions, topic)
+	}
+
+	return nil
+}
+
+// Redeliver publishes the event to the replay stream of the subscription, which
+// only its replay consumer reads. The event is dropped by the server if the
+// subscription was never made.
+func (ps *pubsub) Redeliver(ctx context.Context, id, topic string, msg map[string]interface{}) (err error) {
+	if id == "" {
+		return ErrEmptyID
+	}
+	if topic == "" {
+		return ErrEmptyTopic
+	}
+	ctx, span := events.StartPublish(ctx, topic, msg)
+	defer func() {
+		events.EndSpan(span, err)
+	}()
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	event := nats.NewMsg(replayPrefix + "." + consumerName(id, topic))
+	event.Data = data
+	for k, v := range events.InjectTrace(ctx) {
+		event.Header.Set(k, v)
+	}
+	_, err = ps.js.PublishMsg(ctx, event, jetstream.WithExpectStream(replayStream))
+
+	return err
+}
+
+func (ps *pubsub) Close() error {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	for _, s := range ps.subscriptions {
+		for _, sub := range s {
+			if err := sub.cancel(); err != nil {
+				ps.logger.Warn(fmt.Sprintf("Failed to cancel twiga event handler: %s", err))
+			}
+		}
+	}
+	ps.subscriptions = make(map[string]map[string]subscription)
+
+	return ps.publisher.Close()
+}
+
+// process acknowledges the event once it is handled or dead-lettered. Failed
+// events are redelivered after the retry delay of the subscription, and also
+// when they can be neither acknowledged nor dead-lettered so that they are not lost.
+func (ps *pubsub) process(ctx context.Context, msg jetstream.Msg, name string, cfg events.SubscriberConfig) {
+	ctx, span := events.StartHandle(ctx, msg.Subject(), traceHeaders(msg.Headers()))
+	defer span.End()
+
+	var event map[string]interface{}
+	if err := json.Unmarshal(msg.Data(), &event); err != nil {
+		// Retrying cannot fix a malformed event.
+		ps.logger.Warn(fmt.Sprintf("Failed to unmarshal received event: %s", err))
+		ps.settle(msg, ps.deadLetter(ctx, msg, name, 0, err))
+
+		return
+	}
+
+	stop := ps.inProgress(msg)
+	err := cfg.Handler.Handle(ctx, event)
+	stop()
+	if err == nil {
+		ps.settle(msg, nil)
+
+		return
+	}
+	events.FailSpan(span, err)
+
+	retries := readRetries(msg)
+	if retries < cfg.MaxRetries {
+		ps.logger.Warn(fmt.Sprintf("Failed to handle twiga event, retrying %d/%d: %s", retries+1, cfg.MaxRetries, err))
+		if err := msg.NakWithDelay(cfg.RetryDelay); err != nil {
+			ps.logger.Error(fmt.Sprintf("Failed to retry twiga event: %s", err))
+		}
+
+		return
+	}
+
+	ps.logger.Warn(fmt.Sprintf("Failed to handle twiga event, dead-lettering after %d retries: %s", retries, err))
+	ps.settle(msg, ps.deadLetter(ctx, msg, name, retries, err))
+}
+
+// inProgress keeps reporting the event in progress until stopped, so that the
+// server does not redeliver it to another replica while it is still handled.
+func (ps *pubsub) inProgress(msg jetstream.Msg) (stop func()) {
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		ticker := time.NewTicker(defAckWait / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := msg.InProgress(); err != nil {
+					ps.logger.Warn(fmt.Sprintf("Failed to report twiga event in progress: %s", err))
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
+
+func (ps *pubsub) settle(msg jetstream.Msg, err error) {
+	if err != nil {
+		ps.logger.Error(fmt.Sprintf("Failed to move twiga event out of the stream, redelivering: %s", err))
+		if err := msg.Nak(); err != nil {
+			ps.logger.Error(fmt.Sprintf("Failed to redeliver twiga event: %s", err))
+		}
+
+		return
+	}
+	if err := msg.Ack(); err != nil {
+		ps.logger.Error(fmt.Sprintf("Failed to acknowledge twiga event: %s", err))
+	}
+}
+
+func (ps *pubsub) deadLetter(ctx context.Context, msg jetstream.Msg, name string, retries uint64, cause error) error {
+	id, err := uuid.NewV4()
+	if err != nil {
+		return err
+	}
+
+	// The headers of the event, such as its trace context, go along with it.
+	letter := nats.NewMsg(deadLetterPrefix + "." + name)
+	for k, v := range msg.Headers() {
+		letter.Header[k] = v
+	}
+	letter.Data = msg.Data()
+	letter.Header.Set(retriesHeader, strconv.FormatUint(retries, 10))
+	letter.Header.Set(errorHeader, cause.Error())
+	letter.Header.Set(nats.MsgIdHdr, id.String())
+	_, err = ps.js.PublishMsg(ctx, letter, jetstream.WithExpectStream(deadLetterStream))
+
+	return err
+}
+
+// traceHeaders returns the trace context carried by the headers of the event.
+func traceHeaders(headers nats.Header) map[string]string {
+	ret := make(map[string]string, len(headers))
+	for k := range headers {
+		ret[k] = headers.Get(k)
+	}
+
+	return ret
+}
+
+// readRetries counts the earlier deliveries of the event to the consumer.
+func readRetries(msg jetstream.Msg) uint64 {
+	meta, err := msg.Metadata()
+	if err != nil || meta.NumDelivered == 0 {
+		return 0
+	}
+
+	return meta.NumDelivered - 1
+}
+
+// consumerName returns the name of the durable consumer of the subscription,
+// which may not contain the dots and wildcards of the topic.
+func consumerName(id, topic string) string {
+	topic = strings.NewReplacer(".", "_", "*", "any", ">", "all").Replace(formatTopic(topic))
+
+	return fmt.Sprintf("%s-%s", topic, id)
+}
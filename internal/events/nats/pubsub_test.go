@@ -0,0 +1,198 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package nats_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/rodneyosodo/twiga/internal/events"
+	"github.com/rodneyosodo/twiga/internal/events/nats"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	timeout    = 10 * time.Second
+	topic      = "events.posts.created"
+	retryDelay = 10 * time.Millisecond
+)
+
+var errHandle = errors.New("failed to handle event")
+
+type handler struct {
+	events   chan map[string]interface{}
+	attempts atomic.Int64
+	// failures is the number of attempts left to fail.
+	failures atomic.Int64
+}
+
+func newHandler(failures int64) *handler {
+	h := &handler{events: make(chan map[string]interface{}, 10)}
+	h.failures.Store(failures)
+
+	return h
+}
+
+func (h *handler) Handle(_ context.Context, msg map[string]interface{}) error {
+	h.attempts.Add(1)
+	if h.failures.Add(-1) >= 0 {
+		return errHandle
+	}
+	h.events <- msg
+
+	return nil
+}
+
+func (h *handler) Cancel() error {
+	return nil
+}
+
+func (h *handler) received(t *testing.T) map[string]interface{} {
+	t.Helper()
+
+	select {
+	case msg := <-h.events:
+		return msg
+	case <-time.After(timeout):
+		t.Fatal("event was not delivered")
+
+		return nil
+	}
+}
+
+func newPubSub(t *testing.T) events.PubSub {
+	ps, err := nats.NewPubSub(url, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	require.NoError(t, err)
+	t.Cleanup(func() { ps.Close() })
+
+	return ps
+}
+
+func newID() string {
+	return uuid.Must(uuid.NewV4()).String()
+}
+
+func subscribe(t *testing.T, ps events.PubSub, id string, h *handler, maxRetries uint64) {
+	err := ps.Subscribe(context.Background(), events.SubscriberConfig{
+		ID:         id,
+		Topic:      topic,
+		Handler:    h,
+		MaxRetries: maxRetries,
+		RetryDelay: retryDelay,
+	})
+	require.NoError(t, err)
+}
+
+// publish publishes events with IDs of their own, since the server drops the
+// events published again with the same ID.
+func publish(t *testing.T, ps events.PubSub, n int) []string {
+	ids := make([]string, 0, n)
+	for range n {
+		id := newID()
+		require.NoError(t, ps.Publish(context.Background(), events.PostCreated, map[string]interface{}{"id": id}))
+		ids = append(ids, id)
+	}
+
+	return ids
+}
+
+// deadLetters waits for the subscription to have n dead-lettered events.
+func deadLetters(t *testing.T, ps events.PubSub, id string, n int) []events.DeadLetter {
+	t.Helper()
+
+	var letters []events.DeadLetter
+	require.Eventually(t, func() bool {
+		var err error
+		letters, err = ps.DeadLetters(context.Background(), id, topic, 100)
+		require.NoError(t, err)
+
+		return len(letters) == n
+	}, timeout, 50*time.Millisecond)
+
+	return letters
+}
+
+func TestDurableConsumer(t *testing.T) {
+	id := newID()
+	ps := newPubSub(t)
+	subscribe(t, ps, id, newHandler(0), 0)
+	require.NoError(t, ps.Unsubscribe(context.Background(), id, topic))
+
+	// The consumer outlives the subscription, so the events published meanwhile
+	// are delivered once subscribed again.
+	ids := publish(t, ps, 1)
+	h := newHandler(0)
+	subscribe(t, newPubSub(t), id, h, 0)
+	assert.Equal(t, ids[0], h.received(t)["id"])
+}
+
+func TestSharedConsumer(t *testing.T) {
+	id := newID()
+	first, second := newHandler(0), newHandler(0)
+	subscribe(t, newPubSub(t), id, first, 0)
+	subscribe(t, newPubSub(t), id, second, 0)
+
+	ids := publish(t, newPubSub(t), 10)
+
+	// Every event is handled by one of the subscribers only.
+	received := make([]interface{}, 0, len(ids))
+	for range ids {
+		select {
+		case msg := <-first.events:
+			received = append(received, msg["id"])
+		case msg := <-second.events:
+			received = append(received, msg["id"])
+		case <-time.After(timeout):
+			t.Fatal("event was not delivered")
+		}
+	}
+	assert.ElementsMatch(t, ids, received)
+	assert.Equal(t, int64(len(ids)), first.attempts.Load()+second.attempts.Load())
+}
+
+func TestDeadLetter(t *testing.T) {
+	id := newID()
+	ps := newPubSub(t)
+	h := newHandler(100)
+	subscribe(t, ps, id, h, 2)
+
+	ids := publish(t, ps, 1)
+
+	letters := deadLetters(t, ps, id, 1)
+	assert.NotEmpty(t, letters[0].ID)
+	assert.Equal(t, ids[0], letters[0].Event["id"])
+	assert.Equal(t, uint64(2), letters[0].Retries)
+	assert.Equal(t, errHandle.Error(), letters[0].Error)
+	assert.Equal(t, int64(3), h.attempts.Load(), "the event should be dead-lettered once its retries are exhausted")
+}
+
+func TestReplay(t *testing.T) {
+	id := newID()
+	ps := newPubSub(t)
+	h := newHandler(1)
+	subscribe(t, ps, id, h, 0)
+
+	ids := publish(t, ps, 1)
+	letters := deadLetters(t, ps, id, 1)
+
+	_, err := ps.Replay(context.Background(), id, topic, "unknown")
+	assert.ErrorIs(t, err, nats.ErrDeadLetterNotFound)
+
+	replayed, err := ps.Replay(context.Background(), id, topic, letters[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), replayed)
+	assert.Equal(t, ids[0], h.received(t)["id"])
+	deadLetters(t, ps, id, 0)
+}
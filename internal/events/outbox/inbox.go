@@ -0,0 +1,95 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package outbox
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rodneyosodo/twiga/internal/events"
+)
+
+// Inbox records the events a consumer handled.
+type Inbox interface {
+	// WithTx runs fn in a transaction that the writes made with the context passed to fn join.
+	WithTx(ctx context.Context, fn func(ctx context.Context) error) error
+	// Claim records the event and reports whether it was not recorded before.
+	Claim(ctx context.Context, id string) (bool, error)
+	// Prune forgets the events claimed before the given time and returns how many.
+	Prune(ctx context.Context, before time.Time) (uint64, error)
+}
+
+type afterCommitKey struct{}
+
+type afterCommit struct {
+	mu  sync.Mutex
+	fns []func(ctx context.Context)
+}
+
+// AfterCommit runs fn once the transaction of the event being handled is
+// committed, or right away outside of a handler returned by Deduplicate. Side
+// effects outside of the database, such as deliveries, are deferred with it so
+// that they neither hold the transaction open nor happen for events that are
+// rolled back and retried.
+func AfterCommit(ctx context.Context, fn func(ctx context.Context)) {
+	hooks, ok := ctx.Value(afterCommitKey{}).(*afterCommit)
+	if !ok {
+		fn(ctx)
+
+		return
+	}
+
+	hooks.mu.Lock()
+	defer hooks.mu.Unlock()
+	hooks.fns = append(hooks.fns, fn)
+}
+
+type deduplicator struct {
+	events.EventHandler
+	inbox Inbox
+}
+
+// Deduplicate returns a handler that skips the events already handled. The event
+// is claimed in the transaction the handler runs in, so a failed event is released
+// to be retried and a concurrent delivery of the same event waits for the first
+// one to finish. Events that are not enveloped are left to the handler, and so
+// are the events replayed to rebuild the state of the handler, which are handled
+// again. The handler records the event ID with what it writes, so a rebuilt event
+// only writes what is missing. The handler defers what must not run in the
+// transaction with AfterCommit.
+func Deduplicate(inbox Inbox, handler events.EventHandler) events.EventHandler {
+	return &deduplicator{EventHandler: handler, inbox: inbox}
+}
+
+func (d *deduplicator) Handle(ctx context.Context, event map[string]interface{}) error {
+	env, err := events.Decode(event)
+	if err != nil {
+		return d.EventHandler.Handle(ctx, event)
+	}
+
+	hooks := &afterCommit{}
+	err = d.inbox.WithTx(context.WithValue(ctx, afterCommitKey{}, hooks), func(ctx context.Context) error {
+		claimed, err := d.inbox.Claim(ctx, env.ID)
+		if err != nil || (!claimed && !env.Rebuild) {
+			return err
+		}
+
+		return d.EventHandler.Handle(ctx, event)
+	})
+	if err != nil {
+		return err
+	}
+
+	// The hooks get the context of the event, outside of the committed transaction.
+	for _, fn := range hooks.fns {
+		fn(ctx)
+	}
+
+	return nil
+}
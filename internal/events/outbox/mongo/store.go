@@ -0,0 +1,133 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package mongo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/rodneyosodo/twiga/internal/events/outbox"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// defLease must outlast publishing a batch so that the events are not claimed
+// again by another relay meanwhile.
+const defLease = time.Minute
+
+var _ outbox.Store = (*store)(nil)
+
+type store struct {
+	db *mongo.Collection
+}
+
+// NewStore returns an outbox kept in the collection. Transactions need the
+// server to run as a replica set; operations made with the context passed to
+// WithTx join the transaction whatever collection of the client they touch.
+func NewStore(db *mongo.Collection) outbox.Store {
+	return &store{db: db}
+}
+
+func (s *store) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if mongo.SessionFromContext(ctx) != nil {
+		return fn(ctx)
+	}
+
+	session, err := s.db.Database().Client().StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sc)
+	})
+
+	return err
+}
+
+func (s *store) Add(ctx context.Context, events ...outbox.Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	docs := make([]interface{}, 0, len(events))
+	for _, event := range events {
+		// The payload is kept as JSON so that it is published exactly as it was added.
+		payload, err := json.Marshal(event.Payload)
+		if err != nil {
+			return err
+		}
+		docs = append(docs, mongoEvent{
+			ID:        event.ID,
+			Topic:     event.Topic,
+			Payload:   string(payload),
+			CreatedAt: event.CreatedAt,
+		})
+	}
+
+	_, err := s.db.InsertMany(ctx, docs)
+
+	return err
+}
+
+// Pending claims the events one at a time, oldest first, by leasing them so
+// that concurrent relays do not publish the same events. Within a transaction
+// the claim conflicts with those of the other relays until it is committed.
+func (s *store) Pending(ctx context.Context, limit uint64) ([]outbox.Event, error) {
+	now := time.Now().UTC()
+	filter := bson.D{{Key: "$or", Value: bson.A{
+		bson.D{{Key: "leased_until", Value: bson.D{{Key: "$exists", Value: false}}}},
+		bson.D{{Key: "leased_until", Value: bson.D{{Key: "$lte", Value: now}}}},
+	}}}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "leased_until", Value: now.Add(defLease)}}}}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	items := make([]outbox.Event, 0)
+	for uint64(len(items)) < limit {
+		var mEvent mongoEvent
+		err := s.db.FindOneAndUpdate(ctx, filter, update, opts).Decode(&mEvent)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		event := outbox.Event{
+			ID:        mEvent.ID,
+			Topic:     mEvent.Topic,
+			CreatedAt: mEvent.CreatedAt,
+		}
+		if err := json.Unmarshal([]byte(mEvent.Payload), &event.Payload); err != nil {
+			return nil, err
+		}
+		items = append(items, event)
+	}
+
+	return items, nil
+}
+
+func (s *store) Remove(ctx context.Context, ids ...string) error {
+	_, err := s.db.DeleteMany(ctx, bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: ids}}}})
+
+	return err
+}
+
+type mongoEvent struct {
+	ID          string    `bson:"_id"`
+	Topic       string    `bson:"topic"`
+	Payload     string    `bson:"payload"`
+	CreatedAt   time.Time `bson:"created_at"`
+	LeasedUntil time.Time `bson:"leased_until,omitempty"`
+}
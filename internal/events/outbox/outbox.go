@@ -0,0 +1,51 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/rodneyosodo/twiga/internal/events"
+)
+
+// Event is an event waiting in the outbox to be published.
+type Event struct {
+	ID        string
+	Topic     string
+	Payload   map[string]interface{}
+	CreatedAt time.Time
+}
+
+// NewEvent returns the outbox event of the envelope, published to the topic of its type.
+func NewEvent(env events.Envelope) (Event, error) {
+	payload, err := env.Encode()
+	if err != nil {
+		return Event{}, err
+	}
+
+	return Event{
+		ID:        env.ID,
+		Topic:     env.Type,
+		Payload:   payload,
+		CreatedAt: env.Time,
+	}, nil
+}
+
+// Store keeps the events in the database of the service so that they are
+// written along with the change they describe.
+type Store interface {
+	// WithTx runs fn in a transaction that the writes made with the context passed to fn join.
+	WithTx(ctx context.Context, fn func(ctx context.Context) error) error
+	// Add stores the events, in the transaction carried by the context if any.
+	Add(ctx context.Context, events ...Event) error
+	// Pending returns up to limit events waiting to be published, oldest first.
+	Pending(ctx context.Context, limit uint64) ([]Event, error)
+	// Remove drops the events once they are published.
+	Remove(ctx context.Context, ids ...string) error
+}
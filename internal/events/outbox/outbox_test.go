@@ -0,0 +1,370 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package outbox_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/rodneyosodo/twiga/internal/events"
+	"github.com/rodneyosodo/twiga/internal/events/outbox"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// store keeps the events in memory. A failed transaction restores the events it started with.
+type store struct {
+	events []outbox.Event
+}
+
+func (s *store) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	saved := append([]outbox.Event{}, s.events...)
+	if err := fn(ctx); err != nil {
+		s.events = saved
+
+		return err
+	}
+
+	return nil
+}
+
+func (s *store) Add(_ context.Context, events ...outbox.Event) error {
+	s.events = append(s.events, events...)
+
+	return nil
+}
+
+func (s *store) Pending(_ context.Context, limit uint64) ([]outbox.Event, error) {
+	if uint64(len(s.events)) < limit {
+		return append([]outbox.Event{}, s.events...), nil
+	}
+
+	return append([]outbox.Event{}, s.events[:limit]...), nil
+}
+
+func (s *store) Remove(_ context.Context, ids ...string) error {
+	removed := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		removed[id] = true
+	}
+
+	kept := s.events[:0]
+	for _, event := range s.events {
+		if !removed[event.ID] {
+			kept = append(kept, event)
+		}
+	}
+	s.events = kept
+
+	return nil
+}
+
+// publisher records the topics it publishes and fails on the topics in fail.
+type publisher struct {
+	topics []string
+	fail   map[string]bool
+}
+
+func (p *publisher) Publish(_ context.Context, topic string, _ map[string]interface{}) error {
+	if p.fail[topic] {
+		return errors.New("broker unavailable")
+	}
+	p.topics = append(p.topics, topic)
+
+	return nil
+}
+
+func (p *publisher) Close() error {
+	return nil
+}
+
+func TestRelay(t *testing.T) {
+	cases := []struct {
+		desc      string
+		topics    []string
+		fail      map[string]bool
+		published []string
+		remaining []string
+		err       error
+	}{
+		{
+			desc:      "no events",
+			published: nil,
+			remaining: []string{},
+		},
+		{
+			desc:      "events in order",
+			topics:    []string{"posts.created", "posts.updated", "posts.deleted"},
+			published: []string{"posts.created", "posts.updated", "posts.deleted"},
+			remaining: []string{},
+		},
+		{
+			desc:      "more events than a batch",
+			topics:    topics(250),
+			published: topics(250),
+			remaining: []string{},
+		},
+		{
+			desc:      "failed publish",
+			topics:    []string{"posts.created", "posts.updated", "posts.deleted"},
+			fail:      map[string]bool{"posts.updated": true},
+			published: []string{"posts.created"},
+			remaining: []string{"posts.updated", "posts.deleted"},
+			err:       errors.New("broker unavailable"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			s := &store{}
+			for _, topic := range tc.topics {
+				env, err := events.NewEnvelope(context.Background(), "/twiga/posts", topic, events.PostEvent{})
+				require.NoError(t, err)
+				event, err := outbox.NewEvent(env)
+				require.NoError(t, err)
+				require.NoError(t, s.Add(context.Background(), event))
+			}
+			pub := &publisher{fail: tc.fail}
+
+			err := outbox.NewRelay(s, pub, time.Second, slog.New(slog.NewTextHandler(io.Discard, nil))).Relay(context.Background())
+			if tc.err != nil {
+				assert.ErrorContains(t, err, tc.err.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.published, pub.topics)
+
+			remaining := make([]string, 0, len(s.events))
+			for _, event := range s.events {
+				remaining = append(remaining, event.Topic)
+			}
+			assert.Equal(t, tc.remaining, remaining)
+		})
+	}
+}
+
+func topics(n int) []string {
+	ret := make([]string, 0, n)
+	for i := range n {
+		ret = append(ret, fmt.Sprintf("posts.%d", i))
+	}
+
+	return ret
+}
+
+// inbox keeps the claimed IDs in memory with the time of their claim. A failed
+// transaction releases its claims.
+type inbox struct {
+	claimed map[string]time.Time
+	tx      []string
+	commits int
+}
+
+func (i *inbox) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	i.tx = nil
+	if err := fn(ctx); err != nil {
+		for _, id := range i.tx {
+			delete(i.claimed, id)
+		}
+
+		return err
+	}
+	i.commits++
+
+	return nil
+}
+
+func (i *inbox) Claim(_ context.Context, id string) (bool, error) {
+	if _, ok := i.claimed[id]; ok {
+		return false, nil
+	}
+	i.claimed[id] = time.Now().UTC()
+	i.tx = append(i.tx, id)
+
+	return true, nil
+}
+
+func (i *inbox) Prune(_ context.Context, before time.Time) (uint64, error) {
+	var pruned uint64
+	for id, claimed := range i.claimed {
+		if claimed.Before(before) {
+			delete(i.claimed, id)
+			pruned++
+		}
+	}
+
+	return pruned, nil
+}
+
+// handler counts the events it handles and fails the first failures ones. Every
+// attempt defers a delivery with AfterCommit.
+type handler struct {
+	handled   int
+	failures  int
+	delivered int
+}
+
+func (h *handler) Handle(ctx context.Context, _ map[string]interface{}) error {
+	outbox.AfterCommit(ctx, func(context.Context) {
+		h.delivered++
+	})
+	if h.failures > 0 {
+		h.failures--
+
+		return errors.New("handler failed")
+	}
+	h.handled++
+
+	return nil
+}
+
+func (h *handler) Cancel() error {
+	return nil
+}
+
+func TestDeduplicate(t *testing.T) {
+	first := envelope(t)
+	second := envelope(t)
+
+	cases := []struct {
+		desc      string
+		events    []map[string]interface{}
+		failures  int
+		handled   int
+		delivered int
+		errs      int
+	}{
+		{
+			desc:      "distinct events",
+			events:    []map[string]interface{}{first, second},
+			handled:   2,
+			delivered: 2,
+		},
+		{
+			desc:      "duplicate event",
+			events:    []map[string]interface{}{first, first},
+			handled:   1,
+			delivered: 1,
+		},
+		{
+			desc:      "events without envelope",
+			events:    []map[string]interface{}{{"id": "1"}, {"id": "1"}},
+			handled:   2,
+			delivered: 2,
+		},
+		{
+			desc:      "rebuilt event",
+			events:    []map[string]interface{}{first, rebuilt(first)},
+			handled:   2,
+			delivered: 2,
+		},
+		{
+			desc:      "retried event",
+			events:    []map[string]interface{}{first, first},
+			failures:  1,
+			handled:   1,
+			delivered: 1,
+			errs:      1,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			h := &handler{failures: tc.failures}
+			eh := outbox.Deduplicate(&inbox{claimed: make(map[string]time.Time)}, h)
+
+			errs := 0
+			for _, event := range tc.events {
+				if err := eh.Handle(context.Background(), event); err != nil {
+					errs++
+				}
+			}
+			assert.Equal(t, tc.handled, h.handled)
+			assert.Equal(t, tc.delivered, h.delivered, "only the committed events should be delivered")
+			assert.Equal(t, tc.errs, errs)
+		})
+	}
+}
+
+// committer records how many transactions the inbox committed when its hook runs.
+type committer struct {
+	inbox   *inbox
+	commits int
+}
+
+func (c *committer) Handle(ctx context.Context, _ map[string]interface{}) error {
+	outbox.AfterCommit(ctx, func(context.Context) {
+		c.commits = c.inbox.commits
+	})
+
+	return nil
+}
+
+func (c *committer) Cancel() error {
+	return nil
+}
+
+func TestAfterCommit(t *testing.T) {
+	i := &inbox{claimed: make(map[string]time.Time)}
+	c := &committer{inbox: i}
+
+	require.NoError(t, outbox.Deduplicate(i, c).Handle(context.Background(), envelope(t)))
+	assert.Equal(t, 1, c.commits, "the hook should run once the transaction is committed")
+
+	// Outside of a handler the hook runs right away.
+	ran := false
+	outbox.AfterCommit(context.Background(), func(context.Context) {
+		ran = true
+	})
+	assert.True(t, ran)
+}
+
+func TestPrune(t *testing.T) {
+	first := envelope(t)
+	second := envelope(t)
+	i := &inbox{claimed: make(map[string]time.Time)}
+	h := &handler{}
+	eh := outbox.Deduplicate(i, h)
+	pruner := outbox.NewPruner(i, time.Hour, time.Hour, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	require.NoError(t, eh.Handle(context.Background(), first))
+	require.NoError(t, eh.Handle(context.Background(), second))
+	i.claimed[first["id"].(string)] = time.Now().UTC().Add(-2 * time.Hour)
+
+	require.NoError(t, pruner.Prune(context.Background()))
+	assert.Len(t, i.claimed, 1)
+
+	// A pruned event is handled again when it is delivered again.
+	require.NoError(t, eh.Handle(context.Background(), first))
+	require.NoError(t, eh.Handle(context.Background(), second))
+	assert.Equal(t, 3, h.handled)
+}
+
+func envelope(t *testing.T) map[string]interface{} {
+	env, err := events.NewEnvelope(context.Background(), "/twiga/posts", events.PostCreated, events.PostEvent{ID: "post"})
+	require.NoError(t, err)
+	msg, err := env.Encode()
+	require.NoError(t, err)
+
+	return msg
+}
+
+func rebuilt(event map[string]interface{}) map[string]interface{} {
+	ret := make(map[string]interface{}, len(event)+1)
+	for k, v := range event {
+		ret[k] = v
+	}
+	ret["rebuild"] = true
+
+	return ret
+}
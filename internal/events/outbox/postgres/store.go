@@ -0,0 +1,161 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/jackc/pgtype"
+	"github.com/rodneyosodo/twiga/internal/events/outbox"
+	"github.com/rodneyosodo/twiga/internal/postgres"
+)
+
+var (
+	_ outbox.Store = (*store)(nil)
+	_ outbox.Inbox = (*inbox)(nil)
+)
+
+type store struct {
+	db postgres.Database
+}
+
+// NewStore returns an outbox kept in the outbox table. The database must be
+// returned by postgres.NewDatabase for the events to join the transactions of the service.
+func NewStore(db postgres.Database) outbox.Store {
+	return &store{db: db}
+}
+
+func (s *store) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return postgres.WithTx(ctx, s.db, fn)
+}
+
+func (s *store) Add(ctx context.Context, events ...outbox.Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	dEvents := make([]dbEvent, 0, len(events))
+	for _, event := range events {
+		payload, err := json.Marshal(event.Payload)
+		if err != nil {
+			return err
+		}
+		dEvents = append(dEvents, dbEvent{
+			ID:        event.ID,
+			Topic:     event.Topic,
+			Payload:   payload,
+			CreatedAt: event.CreatedAt,
+		})
+	}
+
+	query := `INSERT INTO outbox (id, topic, payload, created_at) VALUES (:id, :topic, :payload, :created_at)`
+	_, err := s.db.NamedExecContext(ctx, query, dEvents)
+
+	return err
+}
+
+func (s *store) Pending(ctx context.Context, limit uint64) ([]outbox.Event, error) {
+	// Locked events are being published by another relay.
+	query := `SELECT id, topic, payload, created_at FROM outbox ORDER BY seq LIMIT :limit FOR UPDATE SKIP LOCKED`
+
+	rows, err := s.db.NamedQueryContext(ctx, query, dbFilter{Limit: limit})
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]outbox.Event, 0)
+	for rows.Next() {
+		var dEvent dbEvent
+		if err := rows.StructScan(&dEvent); err != nil {
+			return nil, err
+		}
+		event := outbox.Event{
+			ID:        dEvent.ID,
+			Topic:     dEvent.Topic,
+			CreatedAt: dEvent.CreatedAt,
+		}
+		if err := json.Unmarshal(dEvent.Payload, &event.Payload); err != nil {
+			return nil, err
+		}
+		items = append(items, event)
+	}
+
+	return items, nil
+}
+
+func (s *store) Remove(ctx context.Context, ids ...string) error {
+	var dIDs pgtype.TextArray
+	if err := dIDs.Set(ids); err != nil {
+		return err
+	}
+
+	query := `DELETE FROM outbox WHERE id = ANY(CAST(:ids AS UUID[]))`
+	_, err := s.db.NamedExecContext(ctx, query, dbFilter{IDs: dIDs})
+
+	return err
+}
+
+type inbox struct {
+	db postgres.Database
+}
+
+// NewInbox returns an inbox kept in the inbox table. The database must be
+// returned by postgres.NewDatabase for the handlers to join the transaction of the claim.
+func NewInbox(db postgres.Database) outbox.Inbox {
+	return &inbox{db: db}
+}
+
+func (i *inbox) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return postgres.WithTx(ctx, i.db, fn)
+}
+
+func (i *inbox) Claim(ctx context.Context, id string) (bool, error) {
+	query := `INSERT INTO inbox (id) VALUES (:id) ON CONFLICT (id) DO NOTHING`
+
+	result, err := i.db.NamedExecContext(ctx, query, dbEvent{ID: id})
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows > 0, nil
+}
+
+func (i *inbox) Prune(ctx context.Context, before time.Time) (uint64, error) {
+	query := `DELETE FROM inbox WHERE created_at < :before`
+
+	result, err := i.db.NamedExecContext(ctx, query, dbFilter{Before: before})
+	if err != nil {
+		return 0, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return uint64(rows), nil
+}
+
+type dbEvent struct {
+	ID        string    `db:"id"`
+	Topic     string    `db:"topic"`
+	Payload   []byte    `db:"payload"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+type dbFilter struct {
+	IDs    pgtype.TextArray `db:"ids"`
+	Limit  uint64           `db:"limit"`
+	Before time.Time        `db:"before"`
+}
@@ -0,0 +1,64 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+type Pruner struct {
+	inbox    Inbox
+	ttl      time.Duration
+	interval time.Duration
+	logger   *slog.Logger
+}
+
+// NewPruner returns a pruner that forgets the events claimed by the inbox more
+// than ttl ago, every interval. A forgotten event is handled again if it is
+// delivered again, so ttl must outlast the retries and replays of the events.
+func NewPruner(inbox Inbox, ttl, interval time.Duration, logger *slog.Logger) *Pruner {
+	return &Pruner{
+		inbox:    inbox,
+		ttl:      ttl,
+		interval: interval,
+		logger:   logger,
+	}
+}
+
+// Start prunes the inbox until the context is canceled.
+func (p *Pruner) Start(ctx context.Context) error {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := p.Prune(ctx); err != nil {
+				p.logger.Warn(fmt.Sprintf("Failed to prune inbox events: %s", err))
+			}
+		}
+	}
+}
+
+// Prune forgets the events claimed more than ttl ago.
+func (p *Pruner) Prune(ctx context.Context) error {
+	pruned, err := p.inbox.Prune(ctx, time.Now().UTC().Add(-p.ttl))
+	if err != nil {
+		return err
+	}
+	if pruned > 0 {
+		p.logger.Debug(fmt.Sprintf("Pruned %d inbox events", pruned))
+	}
+
+	return nil
+}
@@ -0,0 +1,98 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/rodneyosodo/twiga/internal/events"
+)
+
+const defBatchSize = 100
+
+type Relay struct {
+	store     Store
+	publisher events.Publisher
+	interval  time.Duration
+	batchSize uint64
+	logger    *slog.Logger
+}
+
+// NewRelay returns a relay that publishes the events of the store every interval.
+// An event is removed from the store only after the publisher accepted it, so
+// it may be published more than once but never lost.
+func NewRelay(store Store, pub events.Publisher, interval time.Duration, logger *slog.Logger) *Relay {
+	return &Relay{
+		store:     store,
+		publisher: pub,
+		interval:  interval,
+		batchSize: defBatchSize,
+		logger:    logger,
+	}
+}
+
+// Start publishes the pending events until the context is canceled.
+func (r *Relay) Start(ctx context.Context) error {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := r.Relay(ctx); err != nil {
+				r.logger.Warn(fmt.Sprintf("Failed to relay outbox events: %s", err))
+			}
+		}
+	}
+}
+
+// Relay publishes the pending events in batches until none is left. Events are
+// published in the order they were stored and the relay stops at the first
+// failure so that the remaining ones are not published out of order.
+func (r *Relay) Relay(ctx context.Context) error {
+	for {
+		var fetched int
+		var perr error
+
+		if err := r.store.WithTx(ctx, func(ctx context.Context) error {
+			fetched, perr = 0, nil
+			pending, err := r.store.Pending(ctx, r.batchSize)
+			if err != nil {
+				return err
+			}
+
+			ids := make([]string, 0, len(pending))
+			for _, event := range pending {
+				if perr = r.publisher.Publish(ctx, event.Topic, event.Payload); perr != nil {
+					break
+				}
+				ids = append(ids, event.ID)
+			}
+			fetched = len(pending)
+			if len(ids) == 0 {
+				return nil
+			}
+
+			return r.store.Remove(ctx, ids...)
+		}); err != nil {
+			return err
+		}
+
+		if perr != nil {
+			return perr
+		}
+		if uint64(fetched) < r.batchSize {
+			return nil
+		}
+	}
+}
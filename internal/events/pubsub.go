@@ -7,7 +7,13 @@
 
 package events
 
-import "context"
+import (
+	"context"
+	"time"
+)
+
+// SubjectAllEvents is the topic matching every event published by the services.
+const SubjectAllEvents = "events.#"
 
 type Publisher interface {
 	Publish(ctx context.Context, topic string, msg map[string]interface{}) error
@@ -19,10 +25,14 @@ type EventHandler interface {
 	Cancel() error
 }
 
+// SubscriberConfig configures a subscription. Events whose handling fails are
+// retried MaxRetries times, RetryDelay apart, before they are dead-lettered.
 type SubscriberConfig struct {
-	ID      string
-	Topic   string
-	Handler EventHandler
+	ID         string
+	Topic      string
+	Handler    EventHandler
+	MaxRetries uint64
+	RetryDelay time.Duration
 }
 
 type Subscriber interface {
@@ -31,7 +41,34 @@ type Subscriber interface {
 	Close() error
 }
 
+// DeadLetter is an event that a subscription could not handle.
+// Events that are not valid JSON are kept as the raw body.
+type DeadLetter struct {
+	ID        string                 `json:"id"`
+	Event     map[string]interface{} `json:"event,omitempty"`
+	Body      string                 `json:"body,omitempty"`
+	Error     string                 `json:"error"`
+	Retries   uint64                 `json:"retries"`
+	Timestamp time.Time              `json:"timestamp"`
+}
+
+type DeadLetterQueue interface {
+	// DeadLetters returns up to limit dead-lettered events of the subscription, oldest first, without removing them.
+	DeadLetters(ctx context.Context, id, topic string, limit uint64) ([]DeadLetter, error)
+	// Replay moves the dead-lettered event back to the subscription, or all of them when messageID is empty.
+	Replay(ctx context.Context, id, topic, messageID string) (uint64, error)
+}
+
+// Redeliverer hands events to a single subscription, such as when they are
+// replayed from the event log, without delivering them to the other subscriptions.
+type Redeliverer interface {
+	// Redeliver delivers the event, as it was received, to the subscription.
+	Redeliver(ctx context.Context, id, topic string, msg map[string]interface{}) error
+}
+
 type PubSub interface {
 	Publisher
 	Subscriber
+	DeadLetterQueue
+	Redeliverer
 }
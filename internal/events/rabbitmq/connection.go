@@ -0,0 +1,199 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package rabbitmq
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	kitprometheus "github.com/go-kit/kit/metrics/prometheus"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const (
+	minBackoff = 500 * time.Millisecond
+	maxBackoff = 30 * time.Second
+)
+
+var ErrNotConnected = errors.New("not connected to the broker")
+
+var (
+	disconnects = kitprometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Namespace: "events",
+		Subsystem: "rabbitmq",
+		Name:      "disconnects_total",
+		Help:      "Number of times the connection to the broker was lost.",
+	}, []string{})
+	reconnects = kitprometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Namespace: "events",
+		Subsystem: "rabbitmq",
+		Name:      "reconnects_total",
+		Help:      "Number of attempts to reconnect to the broker.",
+	}, []string{"result"})
+	connected = kitprometheus.NewGaugeFrom(stdprometheus.GaugeOpts{
+		Namespace: "events",
+		Subsystem: "rabbitmq",
+		Name:      "connected",
+		Help:      "Whether the connection to the broker is up.",
+	}, []string{})
+)
+
+// connection supervises the connection to the broker and its channel. Whenever
+// either is closed by the broker, it reconnects with exponential backoff and
+// calls setup on the new channel to declare what the broker may have lost.
+type connection struct {
+	url     string
+	logger  *slog.Logger
+	setup   func(ch *amqp.Channel) error
+	mu      sync.RWMutex
+	conn    *amqp.Connection
+	channel *amqp.Channel
+	closed  bool
+}
+
+func dial(url string, logger *slog.Logger, setup func(ch *amqp.Channel) error) (*connection, error) {
+	c := &connection{
+		url:    url,
+		logger: logger,
+		setup:  setup,
+	}
+	if err := c.connect(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (c *connection) connect() error {
+	conn, err := amqp.Dial(c.url)
+	if err != nil {
+		return err
+	}
+	ch, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+
+		return err
+	}
+	if err := c.setup(ch); err != nil {
+		conn.Close()
+
+		return err
+	}
+
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+
+		return conn.Close()
+	}
+	c.conn = conn
+	c.channel = ch
+	c.mu.Unlock()
+	connected.Set(1)
+
+	go c.supervise(conn.NotifyClose(make(chan *amqp.Error, 1)), ch.NotifyClose(make(chan *amqp.Error, 1)))
+
+	return nil
+}
+
+// supervise waits for the connection or the channel to close and reconnects
+// until it succeeds or the connection is closed on purpose.
+func (c *connection) supervise(connClosed, chClosed <-chan *amqp.Error) {
+	var reason *amqp.Error
+	select {
+	case reason = <-connClosed:
+	case reason = <-chClosed:
+	}
+
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+
+		return
+	}
+	// A closed channel leaves the connection open, so it is closed as well
+	// and both are reopened together.
+	conn := c.conn
+	c.conn = nil
+	c.channel = nil
+	c.mu.Unlock()
+	conn.Close()
+
+	connected.Set(0)
+	disconnects.Add(1)
+	c.logger.Warn(fmt.Sprintf("Lost connection to the broker: %v", reason))
+
+	backoff := minBackoff
+	for {
+		time.Sleep(backoff)
+		if c.isClosed() {
+			return
+		}
+
+		err := c.connect()
+		if err == nil {
+			reconnects.With("result", "success").Add(1)
+			c.logger.Info("Reconnected to the broker")
+
+			return
+		}
+		reconnects.With("result", "failure").Add(1)
+		c.logger.Warn(fmt.Sprintf("Failed to reconnect to the broker, retrying in %s: %s", backoff, err))
+
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+// Channel returns the current channel, or ErrNotConnected while reconnecting.
+func (c *connection) Channel() (*amqp.Channel, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.channel == nil {
+		return nil, ErrNotConnected
+	}
+
+	return c.channel, nil
+}
+
+// OpenChannel opens a new channel on the current connection.
+func (c *connection) OpenChannel() (*amqp.Channel, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.conn == nil {
+		return nil, ErrNotConnected
+	}
+
+	return c.conn.Channel()
+}
+
+func (c *connection) isClosed() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.closed
+}
+
+func (c *connection) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closed = true
+	if c.conn == nil {
+		return nil
+	}
+	connected.Set(0)
+
+	return c.conn.Close()
+}
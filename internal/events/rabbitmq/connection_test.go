@@ -0,0 +1,112 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package rabbitmq_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/rodneyosodo/twiga/internal/events"
+	"github.com/rodneyosodo/twiga/internal/events/rabbitmq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// reconnectTimeout outlasts the backoff between reconnection attempts, which
+// grows while the broker is down.
+const reconnectTimeout = time.Minute
+
+// stopBroker stops the broker and waits for the publishers to notice.
+func stopBroker(t *testing.T, pubs ...events.Publisher) {
+	require.NoError(t, pool.Client.StopContainer(container.Container.ID, 10))
+
+	for _, pub := range pubs {
+		require.Eventually(t, func() bool {
+			err := pub.Publish(context.Background(), events.PostCreated, map[string]interface{}{"id": "lost"})
+
+			return errors.Is(err, rabbitmq.ErrNotConnected)
+		}, timeout, 50*time.Millisecond, "publishing during the outage should fail with ErrNotConnected")
+	}
+}
+
+func startBroker(t *testing.T) {
+	require.NoError(t, pool.Client.StartContainer(container.Container.ID, nil))
+	require.NoError(t, waitForBroker())
+}
+
+// published waits for the publisher to reconnect and publishes the event.
+func published(t *testing.T, pub events.Publisher, id string) {
+	require.Eventually(t, func() bool {
+		return pub.Publish(context.Background(), events.PostCreated, map[string]interface{}{"id": id}) == nil
+	}, reconnectTimeout, 100*time.Millisecond, "publishing should resume once reconnected")
+}
+
+// await waits for the event, skipping the ones published while the broker was stopping.
+func (h *handler) await(t *testing.T, id string) {
+	t.Helper()
+
+	deadline := time.After(timeout)
+	for {
+		select {
+		case msg := <-h.events:
+			if msg["id"] == id {
+				return
+			}
+		case <-deadline:
+			t.Fatalf("event %s was not delivered", id)
+		}
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	ps := newPubSub(t)
+	pub, err := rabbitmq.NewPublisher(url, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	require.NoError(t, err)
+	t.Cleanup(func() { pub.Close() })
+
+	h := newHandler(0)
+	subscribe(t, ps, h, 0)
+
+	publish(t, ps, "before")
+	assert.Equal(t, "before", h.received(t)["id"])
+
+	stopBroker(t, ps, pub)
+	startBroker(t)
+
+	// The subscription is consumed again on the new channel, and the events
+	// published by both reconnected clients reach it.
+	published(t, ps, "pubsub")
+	h.await(t, "pubsub")
+	published(t, pub, "publisher")
+	h.await(t, "publisher")
+}
+
+func TestUnsubscribeWhileDisconnected(t *testing.T) {
+	ps := newPubSub(t)
+
+	h := newHandler(0)
+	id := subscribe(t, ps, h, 0)
+
+	stopBroker(t, ps)
+	require.NoError(t, ps.Unsubscribe(context.Background(), id, topic), "unsubscribing during the outage should succeed")
+	startBroker(t)
+
+	// The subscription is not restored once reconnected.
+	published(t, ps, "after")
+	select {
+	case msg := <-h.events:
+		if msg["id"] == "after" {
+			t.Fatalf("unexpected event %v", msg)
+		}
+	case <-time.After(500 * time.Millisecond):
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package rabbitmq
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/rodneyosodo/twiga/internal/events"
+)
+
+var ErrDeadLetterNotFound = errors.New("dead letter not found")
+
+// DeadLetters reads the dead-letter queue with unacknowledged gets on a channel of
+// its own. Closing the channel puts the events back in their original order.
+func (ps *pubsub) DeadLetters(_ context.Context, id, topic string, limit uint64) ([]events.DeadLetter, error) {
+	if id == "" {
+		return nil, ErrEmptyID
+	}
+	if topic == "" {
+		return nil, ErrEmptyTopic
+	}
+	ch, err := ps.OpenChannel()
+	if err != nil {
+		return nil, err
+	}
+	defer ch.Close()
+
+	queue := queueName(id, topic) + deadLetterSuffix
+	letters := make([]events.DeadLetter, 0)
+	for uint64(len(letters)) < limit {
+		d, ok, err := ch.Get(queue, false)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+		letters = append(letters, toDeadLetter(d))
+	}
+
+	return letters, nil
+}
+
+// Replay only scans the events dead-lettered when it starts, so that events
+// failing again meanwhile are not replayed twice. The events it skips are put
+// back in their original order.
+func (ps *pubsub) Replay(ctx context.Context, id, topic, messageID string) (uint64, error) {
+	if id == "" {
+		return 0, ErrEmptyID
+	}
+	if topic == "" {
+		return 0, ErrEmptyTopic
+	}
+	ch, err := ps.OpenChannel()
+	if err != nil {
+		return 0, err
+	}
+	defer ch.Close()
+	if err := ch.Confirm(false); err != nil {
+		return 0, err
+	}
+
+	queue := queueName(id, topic)
+	dlq, err := ch.QueueDeclarePassive(queue+deadLetterSuffix, true, false, false, false, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	var replayed, skipped uint64
+	for range dlq.Messages {
+		d, ok, err := ch.Get(dlq.Name, false)
+		if err != nil {
+			return replayed, err
+		}
+		if !ok {
+			break
+		}
+		if messageID != "" && d.MessageId != messageID {
+			skipped = d.DeliveryTag
+
+			continue
+		}
+
+		// Replayed events get a fresh set of retries.
+		msg := republish(d)
+		delete(msg.Headers, retriesHeader)
+		delete(msg.Headers, errorHeader)
+		if err := publishConfirmed(ctx, ch, "", queue, msg); err != nil {
+			return replayed, err
+		}
+		if err := d.Ack(false); err != nil {
+			return replayed, err
+		}
+		replayed++
+
+		if messageID != "" {
+			break
+		}
+	}
+	if skipped > 0 {
+		if err := ch.Nack(skipped, true, true); err != nil {
+			return replayed, err
+		}
+	}
+	if messageID != "" && replayed == 0 {
+		return 0, ErrDeadLetterNotFound
+	}
+
+	return replayed, nil
+}
+
+func toDeadLetter(d amqp.Delivery) events.DeadLetter {
+	letter := events.DeadLetter{
+		ID:        d.MessageId,
+		Retries:   readRetries(d.Headers),
+		Timestamp: d.Timestamp,
+	}
+	letter.Error, _ = d.Headers[errorHeader].(string)
+	if err := json.Unmarshal(d.Body, &letter.Event); err != nil {
+		letter.Body = string(d.Body)
+	}
+
+	return letter
+}
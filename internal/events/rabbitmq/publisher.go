@@ -11,6 +11,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"strings"
 	"time"
 
@@ -21,39 +22,45 @@ import (
 var _ events.Publisher = (*publisher)(nil)
 
 type publisher struct {
-	conn     *amqp.Connection
-	channel  *amqp.Channel
+	*connection
 	prefix   string
 	exchange string
 }
 
-func NewPublisher(url string) (events.Publisher, error) {
-	conn, err := amqp.Dial(url)
-	if err != nil {
-		return nil, err
-	}
-	ch, err := conn.Channel()
+// NewPublisher returns a publisher that reconnects to the broker whenever the
+// connection is lost. Events published meanwhile fail with ErrNotConnected.
+func NewPublisher(url string, logger *slog.Logger) (events.Publisher, error) {
+	conn, err := dial(url, logger, func(ch *amqp.Channel) error {
+		if err := ch.ExchangeDeclare(exchangeName, amqp.ExchangeTopic, true, false, false, false, nil); err != nil {
+			return err
+		}
+
+		return ch.Confirm(false)
+	})
 	if err != nil {
 		return nil, err
 	}
-	if err := ch.ExchangeDeclare(exchangeName, amqp.ExchangeTopic, true, false, false, false, nil); err != nil {
-		return nil, err
-	}
 
 	ret := &publisher{
-		conn:     conn,
-		channel:  ch,
-		prefix:   chansPrefix,
-		exchange: exchangeName,
+		connection: conn,
+		prefix:     chansPrefix,
+		exchange:   exchangeName,
 	}
 
 	return ret, nil
 }
 
-func (pub *publisher) Publish(ctx context.Context, topic string, msg map[string]interface{}) error {
+// Publish carries the trace context of the publishing span in the headers of
+// the event, so that its handling joins the same trace.
+func (pub *publisher) Publish(ctx context.Context, topic string, msg map[string]interface{}) (err error) {
 	if topic == "" {
 		return ErrEmptyTopic
 	}
+	ctx, span := events.StartPublish(ctx, topic, msg)
+	defer func() {
+		events.EndSpan(span, err)
+	}()
+
 	msg["timestamp"] = time.Now().UnixNano()
 	msg["topic"] = topic
 
@@ -66,27 +73,49 @@ func (pub *publisher) Publish(ctx context.Context, topic string, msg map[string]
 
 	subject = formatTopic(subject)
 
-	err = pub.channel.PublishWithContext(
-		ctx,
-		pub.exchange,
-		subject,
-		false,
-		false,
-		amqp.Publishing{
-			Headers:     amqp.Table{},
-			ContentType: "application/octet-stream",
-			AppId:       "twiga-publisher",
-			Body:        data,
-		})
+	return pub.publish(ctx, pub.exchange, subject, data)
+}
+
+// publish waits for the broker to confirm the event. The broker confirms it
+// once it has taken responsibility for it, so a nil error means that the event
+// will not be lost.
+func (pub *publisher) publish(ctx context.Context, exchange, key string, data []byte) error {
+	headers := amqp.Table{}
+	for k, v := range events.InjectTrace(ctx) {
+		headers[k] = v
+	}
+
+	ch, err := pub.Channel()
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return publishConfirmed(ctx, ch, exchange, key, amqp.Publishing{
+		Headers:      headers,
+		ContentType:  "application/octet-stream",
+		DeliveryMode: amqp.Persistent,
+		AppId:        "twiga-publisher",
+		Body:         data,
+	})
 }
 
-func (pub *publisher) Close() error {
-	return pub.conn.Close()
+// publishConfirmed publishes the event on a channel in confirm mode and waits
+// for the broker to confirm it.
+func publishConfirmed(ctx context.Context, ch *amqp.Channel, exchange, key string, msg amqp.Publishing) error {
+	confirmation, err := ch.PublishWithDeferredConfirmWithContext(ctx, exchange, key, false, false, msg)
+	if err != nil {
+		return err
+	}
+
+	acked, err := confirmation.WaitContext(ctx)
+	if err != nil {
+		return err
+	}
+	if !acked {
+		return ErrNotConfirmed
+	}
+
+	return nil
 }
 
 func formatTopic(topic string) string {
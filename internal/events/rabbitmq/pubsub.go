@@ -13,28 +13,47 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"strconv"
 	"sync"
+	"time"
 
+	"github.com/gofrs/uuid"
 	amqp "github.com/rabbitmq/amqp091-go"
 	"github.com/rodneyosodo/twiga/internal/events"
 )
 
 const (
-	SubjectAllEvents = "events.#"
+	SubjectAllEvents = events.SubjectAllEvents
 
 	exchangeName = "events"
 	chansPrefix  = "events"
+
+	// Events that exhaust their retries are published to the dead-letter exchange
+	// with the subscription queue as routing key, so each subscription has its own
+	// dead-letter queue. Retries wait in a queue of their own until they expire
+	// and go back to the subscription queue.
+	deadLetterExchange = "events.dead-letter"
+	deadLetterSuffix   = ".dead-letter"
+	retrySuffix        = ".retry"
+
+	retriesHeader = "x-retries"
+	errorHeader   = "x-error"
+
+	defPrefetch = 100
 )
 
 var (
 	ErrNotSubscribed = errors.New("not subscribed")
 	ErrEmptyTopic    = errors.New("empty topic")
 	ErrEmptyID       = errors.New("empty ID")
+	ErrNotConfirmed  = errors.New("event not confirmed by the broker")
 )
 
 var _ events.PubSub = (*pubsub)(nil)
 
 type subscription struct {
+	ctx    context.Context
+	cfg    events.SubscriberConfig
 	cancel func() error
 }
 
@@ -45,23 +64,11 @@ type pubsub struct {
 	mu            sync.Mutex
 }
 
+// NewPubSub returns a pubsub that reconnects to the broker whenever the connection
+// is lost and then re-establishes every subscription.
 func NewPubSub(url string, logger *slog.Logger) (events.PubSub, error) {
-	conn, err := amqp.Dial(url)
-	if err != nil {
-		return nil, err
-	}
-	ch, err := conn.Channel()
-	if err != nil {
-		return nil, err
-	}
-	if err := ch.ExchangeDeclare(exchangeName, amqp.ExchangeTopic, true, false, false, false, nil); err != nil {
-		return nil, err
-	}
-
 	ret := &pubsub{
 		publisher: publisher{
-			conn:     conn,
-			channel:  ch,
 			exchange: exchangeName,
 			prefix:   chansPrefix,
 		},
@@ -69,9 +76,45 @@ func NewPubSub(url string, logger *slog.Logger) (events.PubSub, error) {
 		subscriptions: make(map[string]map[string]subscription),
 	}
 
+	conn, err := dial(url, logger, ret.setup)
+	if err != nil {
+		return nil, err
+	}
+	ret.connection = conn
+
 	return ret, nil
 }
 
+// setup declares the exchanges on a new channel and consumes the queues of the subscriptions.
+func (ps *pubsub) setup(ch *amqp.Channel) error {
+	if err := ch.ExchangeDeclare(exchangeName, amqp.ExchangeTopic, true, false, false, false, nil); err != nil {
+		return err
+	}
+	if err := ch.ExchangeDeclare(deadLetterExchange, amqp.ExchangeDirect, true, false, false, false, nil); err != nil {
+		return err
+	}
+	// Unacknowledged events are bounded so that a slow handler does not buffer the whole queue.
+	if err := ch.Qos(defPrefetch, 0, false); err != nil {
+		return err
+	}
+	if err := ch.Confirm(false); err != nil {
+		return err
+	}
+
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	for _, s := range ps.subscriptions {
+		for _, sub := range s {
+			if err := ps.consume(sub.ctx, ch, sub.cfg); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
 func (ps *pubsub) Subscribe(ctx context.Context, cfg events.SubscriberConfig) error {
 	if cfg.ID == "" {
 		return ErrEmptyID
@@ -100,26 +143,24 @@ func (ps *pubsub) Subscribe(ctx context.Context, cfg events.SubscriberConfig) er
 		ps.subscriptions[cfg.Topic] = s
 	}
 
-	clientID := fmt.Sprintf("%s-%s", cfg.Topic, cfg.ID)
-
-	queue, err := ps.channel.QueueDeclare(clientID, true, false, false, false, nil)
+	ch, err := ps.Channel()
 	if err != nil {
 		return err
 	}
-
-	if err := ps.channel.QueueBind(queue.Name, cfg.Topic, ps.exchange, false, nil); err != nil {
+	if err := ps.consume(ctx, ch, cfg); err != nil {
 		return err
 	}
 
-	msgs, err := ps.channel.Consume(queue.Name, clientID, true, false, false, false, nil)
-	if err != nil {
-		return err
-	}
-	go ps.handle(ctx, msgs, cfg.Handler)
+	clientID := queueName(cfg.ID, cfg.Topic)
 	s[cfg.ID] = subscription{
+		ctx: ctx,
+		cfg: cfg,
 		cancel: func() error {
-			if err := ps.channel.Cancel(clientID, false); err != nil {
-				return err
+			// The consumer is already gone if the channel was lost.
+			if ch, err := ps.Channel(); err == nil {
+				if err := ch.Cancel(clientID, false); err != nil {
+					return err
+				}
 			}
 
 			return cfg.Handler.Cancel()
@@ -129,6 +170,31 @@ func (ps *pubsub) Subscribe(ctx context.Context, cfg events.SubscriberConfig) er
 	return nil
 }
 
+// consume declares the queues of the subscription and handles its events until the channel closes.
+func (ps *pubsub) consume(ctx context.Context, ch *amqp.Channel, cfg events.SubscriberConfig) error {
+	clientID := queueName(cfg.ID, cfg.Topic)
+
+	queue, err := ch.QueueDeclare(clientID, true, false, false, false, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := ch.QueueBind(queue.Name, cfg.Topic, ps.exchange, false, nil); err != nil {
+		return err
+	}
+	if err := declareRetries(ch, queue.Name); err != nil {
+		return err
+	}
+
+	msgs, err := ch.Consume(queue.Name, clientID, false, false, false, false, nil)
+	if err != nil {
+		return err
+	}
+	go ps.handle(ctx, msgs, queue.Name, cfg)
+
+	return nil
+}
+
 func (ps *pubsub) Unsubscribe(ctx context.Context, id, topic string) error {
 	if id == "" {
 		return ErrEmptyID
@@ -154,7 +220,15 @@ func (ps *pubsub) Unsubscribe(ctx context.Context, id, topic string) error {
 			return err
 		}
 	}
-	if err := ps.channel.QueueUnbind(topic, topic, exchangeName, nil); err != nil {
+	// While reconnecting there is nothing to unbind from, and the
+	// subscription is not restored once it is removed below.
+	ch, err := ps.Channel()
+	switch {
+	case err == nil:
+		if err := ch.QueueUnbind(queueName(id, topic), topic, exchangeName, nil); err != nil {
+			return err
+		}
+	case !errors.Is(err, ErrNotConnected):
 		return err
 	}
 
@@ -166,18 +240,186 @@ func (ps *pubsub) Unsubscribe(ctx context.Context, id, topic string) error {
 	return nil
 }
 
-func (ps *pubsub) handle(ctx context.Context, deliveries <-chan amqp.Delivery, h events.EventHandler) {
+// Redeliver publishes the event through the default exchange, which routes it
+// straight to the queue of the subscription. The event is dropped by the broker
+// if the queue was never declared.
+func (ps *pubsub) Redeliver(ctx context.Context, id, topic string, msg map[string]interface{}) (err error) {
+	if id == "" {
+		return ErrEmptyID
+	}
+	if topic == "" {
+		return ErrEmptyTopic
+	}
+	ctx, span := events.StartPublish(ctx, topic, msg)
+	defer func() {
+		events.EndSpan(span, err)
+	}()
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	return ps.publish(ctx, "", queueName(id, formatTopic(topic)), data)
+}
+
+// declareRetries declares the retry and dead-letter queues of the subscription queue.
+func declareRetries(ch *amqp.Channel, queue string) error {
+	dlq, err := ch.QueueDeclare(queue+deadLetterSuffix, true, false, false, false, nil)
+	if err != nil {
+		return err
+	}
+	if err := ch.QueueBind(dlq.Name, queue, deadLetterExchange, false, nil); err != nil {
+		return err
+	}
+
+	// The default exchange routes expired retries back to the subscription queue.
+	args := amqp.Table{
+		"x-dead-letter-exchange":    "",
+		"x-dead-letter-routing-key": queue,
+	}
+	if _, err := ch.QueueDeclare(queue+retrySuffix, true, false, false, false, args); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// handle keeps consuming after failures so that one bad event does not stop the subscription.
+func (ps *pubsub) handle(ctx context.Context, deliveries <-chan amqp.Delivery, queue string, cfg events.SubscriberConfig) {
 	for d := range deliveries {
-		var msg map[string]interface{}
-		if err := json.Unmarshal(d.Body, &msg); err != nil {
-			ps.logger.Warn(fmt.Sprintf("Failed to unmarshal received event: %s", err))
+		ps.process(ctx, d, queue, cfg)
+	}
+}
+
+// process acknowledges the event once it is handled, or once the broker confirmed
+// its retry or dead-letter. It is requeued when it can be neither retried nor
+// dead-lettered so that it is not lost.
+func (ps *pubsub) process(ctx context.Context, d amqp.Delivery, queue string, cfg events.SubscriberConfig) {
+	ctx, span := events.StartHandle(ctx, d.RoutingKey, traceHeaders(d.Headers))
+	defer span.End()
+
+	var msg map[string]interface{}
+	if err := json.Unmarshal(d.Body, &msg); err != nil {
+		// Retrying cannot fix a malformed event.
+		ps.logger.Warn(fmt.Sprintf("Failed to unmarshal received event: %s", err))
+		ps.settle(d, ps.deadLetter(ctx, d, queue, err))
 
-			return
+		return
+	}
+
+	err := cfg.Handler.Handle(ctx, msg)
+	if err == nil {
+		ps.settle(d, nil)
+
+		return
+	}
+	events.FailSpan(span, err)
+
+	retries := readRetries(d.Headers)
+	if retries < cfg.MaxRetries {
+		ps.logger.Warn(fmt.Sprintf("Failed to handle twiga event, retrying %d/%d: %s", retries+1, cfg.MaxRetries, err))
+		ps.settle(d, ps.retry(ctx, d, queue, retries+1, cfg.RetryDelay))
+
+		return
+	}
+
+	ps.logger.Warn(fmt.Sprintf("Failed to handle twiga event, dead-lettering after %d retries: %s", retries, err))
+	ps.settle(d, ps.deadLetter(ctx, d, queue, err))
+}
+
+func (ps *pubsub) settle(d amqp.Delivery, err error) {
+	if err != nil {
+		ps.logger.Error(fmt.Sprintf("Failed to move twiga event out of the queue, requeueing: %s", err))
+		if err := d.Nack(false, true); err != nil {
+			ps.logger.Error(fmt.Sprintf("Failed to requeue twiga event: %s", err))
 		}
-		if err := h.Handle(ctx, msg); err != nil {
-			ps.logger.Warn(fmt.Sprintf("Failed to handle twiga event: %s", err))
 
-			return
+		return
+	}
+	if err := d.Ack(false); err != nil {
+		ps.logger.Error(fmt.Sprintf("Failed to acknowledge twiga event: %s", err))
+	}
+}
+
+func (ps *pubsub) retry(ctx context.Context, d amqp.Delivery, queue string, retries uint64, delay time.Duration) error {
+	msg := republish(d)
+	msg.Headers[retriesHeader] = int64(retries)
+	msg.Expiration = strconv.FormatInt(delay.Milliseconds(), 10)
+
+	ch, err := ps.Channel()
+	if err != nil {
+		return err
+	}
+
+	return publishConfirmed(ctx, ch, "", queue+retrySuffix, msg)
+}
+
+func (ps *pubsub) deadLetter(ctx context.Context, d amqp.Delivery, queue string, cause error) error {
+	msg := republish(d)
+	msg.Headers[errorHeader] = cause.Error()
+	if msg.MessageId == "" {
+		id, err := uuid.NewV4()
+		if err != nil {
+			return err
+		}
+		msg.MessageId = id.String()
+	}
+	msg.Timestamp = time.Now().UTC()
+
+	ch, err := ps.Channel()
+	if err != nil {
+		return err
+	}
+
+	return publishConfirmed(ctx, ch, deadLetterExchange, queue, msg)
+}
+
+// republish returns a persistent copy of the delivered event.
+func republish(d amqp.Delivery) amqp.Publishing {
+	headers := make(amqp.Table, len(d.Headers)+1)
+	for k, v := range d.Headers {
+		headers[k] = v
+	}
+
+	return amqp.Publishing{
+		Headers:      headers,
+		ContentType:  d.ContentType,
+		DeliveryMode: amqp.Persistent,
+		MessageId:    d.MessageId,
+		Timestamp:    d.Timestamp,
+		AppId:        d.AppId,
+		Body:         d.Body,
+	}
+}
+
+func readRetries(headers amqp.Table) uint64 {
+	switch v := headers[retriesHeader].(type) {
+	case int64:
+		if v > 0 {
+			return uint64(v)
+		}
+	case int32:
+		if v > 0 {
+			return uint64(v)
 		}
 	}
+
+	return 0
+}
+
+// traceHeaders returns the trace context carried by the headers of the delivery.
+func traceHeaders(headers amqp.Table) map[string]string {
+	ret := make(map[string]string, len(headers))
+	for k, v := range headers {
+		if s, ok := v.(string); ok {
+			ret[k] = s
+		}
+	}
+
+	return ret
+}
+
+func queueName(id, topic string) string {
+	return fmt.Sprintf("%s-%s", formatTopic(topic), id)
 }
@@ -0,0 +1,203 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package rabbitmq_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/rodneyosodo/twiga/internal/events"
+	"github.com/rodneyosodo/twiga/internal/events/rabbitmq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	timeout    = 10 * time.Second
+	topic      = "events.posts.created"
+	retryDelay = 10 * time.Millisecond
+)
+
+var errHandle = errors.New("failed to handle event")
+
+type handler struct {
+	events   chan map[string]interface{}
+	attempts atomic.Int64
+	// failures is the number of attempts left to fail.
+	failures atomic.Int64
+}
+
+func newHandler(failures int64) *handler {
+	h := &handler{events: make(chan map[string]interface{}, 10)}
+	h.failures.Store(failures)
+
+	return h
+}
+
+func (h *handler) Handle(_ context.Context, msg map[string]interface{}) error {
+	h.attempts.Add(1)
+	if h.failures.Add(-1) >= 0 {
+		return errHandle
+	}
+	h.events <- msg
+
+	return nil
+}
+
+func (h *handler) Cancel() error {
+	return nil
+}
+
+func (h *handler) received(t *testing.T) map[string]interface{} {
+	t.Helper()
+
+	select {
+	case msg := <-h.events:
+		return msg
+	case <-time.After(timeout):
+		t.Fatal("event was not delivered")
+
+		return nil
+	}
+}
+
+func newPubSub(t *testing.T) events.PubSub {
+	ps, err := rabbitmq.NewPubSub(url, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	require.NoError(t, err)
+	t.Cleanup(func() { ps.Close() })
+
+	return ps
+}
+
+// subscribe subscribes the handler with an ID of its own, since the queues outlive the tests.
+func subscribe(t *testing.T, ps events.PubSub, h *handler, maxRetries uint64) string {
+	id := uuid.Must(uuid.NewV4()).String()
+	err := ps.Subscribe(context.Background(), events.SubscriberConfig{
+		ID:         id,
+		Topic:      topic,
+		Handler:    h,
+		MaxRetries: maxRetries,
+		RetryDelay: retryDelay,
+	})
+	require.NoError(t, err)
+
+	return id
+}
+
+func publish(t *testing.T, ps events.PubSub, ids ...string) {
+	for _, id := range ids {
+		require.NoError(t, ps.Publish(context.Background(), events.PostCreated, map[string]interface{}{"id": id}))
+	}
+}
+
+// deadLetters waits for the subscription to have n dead-lettered events.
+func deadLetters(t *testing.T, ps events.PubSub, id string, n int) []events.DeadLetter {
+	t.Helper()
+
+	var letters []events.DeadLetter
+	require.Eventually(t, func() bool {
+		var err error
+		letters, err = ps.DeadLetters(context.Background(), id, topic, 100)
+		require.NoError(t, err)
+
+		return len(letters) == n
+	}, timeout, 50*time.Millisecond)
+
+	return letters
+}
+
+func ids(letters []events.DeadLetter) []interface{} {
+	ret := make([]interface{}, 0, len(letters))
+	for _, letter := range letters {
+		ret = append(ret, letter.Event["id"])
+	}
+
+	return ret
+}
+
+func TestRetry(t *testing.T) {
+	ps := newPubSub(t)
+	h := newHandler(2)
+	id := subscribe(t, ps, h, 3)
+
+	publish(t, ps, "1")
+
+	msg := h.received(t)
+	assert.Equal(t, "1", msg["id"])
+	assert.Equal(t, int64(3), h.attempts.Load(), "the event should be handled once retried twice")
+
+	letters, err := ps.DeadLetters(context.Background(), id, topic, 10)
+	require.NoError(t, err)
+	assert.Empty(t, letters)
+}
+
+func TestDeadLetter(t *testing.T) {
+	ps := newPubSub(t)
+	h := newHandler(100)
+	id := subscribe(t, ps, h, 2)
+
+	publish(t, ps, "1")
+
+	letters := deadLetters(t, ps, id, 1)
+	assert.NotEmpty(t, letters[0].ID)
+	assert.Equal(t, "1", letters[0].Event["id"])
+	assert.Equal(t, uint64(2), letters[0].Retries)
+	assert.Equal(t, errHandle.Error(), letters[0].Error)
+	assert.Equal(t, int64(3), h.attempts.Load(), "the event should be dead-lettered once its retries are exhausted")
+
+	// Listing leaves the events in the dead-letter queue.
+	deadLetters(t, ps, id, 1)
+}
+
+func TestReplay(t *testing.T) {
+	ps := newPubSub(t)
+	h := newHandler(3)
+	id := subscribe(t, ps, h, 0)
+
+	publish(t, ps, "1", "2", "3")
+	letters := deadLetters(t, ps, id, 3)
+	require.Equal(t, []interface{}{"1", "2", "3"}, ids(letters))
+
+	_, err := ps.Replay(context.Background(), id, topic, "unknown")
+	assert.ErrorIs(t, err, rabbitmq.ErrDeadLetterNotFound)
+	assert.Equal(t, []interface{}{"1", "2", "3"}, ids(deadLetters(t, ps, id, 3)), "the events should be left in order")
+
+	replayed, err := ps.Replay(context.Background(), id, topic, letters[1].ID)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), replayed)
+	assert.Equal(t, "2", h.received(t)["id"])
+	assert.Equal(t, []interface{}{"1", "3"}, ids(deadLetters(t, ps, id, 2)), "the skipped events should be left in order")
+
+	replayed, err = ps.Replay(context.Background(), id, topic, "")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), replayed)
+	assert.ElementsMatch(t, []interface{}{"1", "3"}, []interface{}{h.received(t)["id"], h.received(t)["id"]})
+	deadLetters(t, ps, id, 0)
+}
+
+func TestReplayFailingAgain(t *testing.T) {
+	ps := newPubSub(t)
+	h := newHandler(100)
+	id := subscribe(t, ps, h, 0)
+
+	publish(t, ps, "1", "2")
+	deadLetters(t, ps, id, 2)
+
+	// The replayed events fail again and are dead-lettered while replaying,
+	// but they are only replayed once.
+	replayed, err := ps.Replay(context.Background(), id, topic, "")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), replayed)
+	deadLetters(t, ps, id, 2)
+}
@@ -0,0 +1,138 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package events
+
+import "time"
+
+// Types of the events published by the services.
+const (
+	PostCreated           = "posts.created"
+	PostUpdated           = "posts.updated"
+	PostContentUpdated    = "posts.updated.content"
+	PostTagsUpdated       = "posts.updated.tags"
+	PostImageUpdated      = "posts.updated.image"
+	PostVisibilityUpdated = "posts.updated.visibility"
+	PostDeleted           = "posts.deleted"
+
+	CommentCreated = "comments.created"
+	CommentUpdated = "comments.updated"
+	CommentDeleted = "comments.deleted"
+
+	LikeCreated = "likes.created"
+	LikeDeleted = "likes.deleted"
+
+	ShareCreated = "shares.created"
+	ShareDeleted = "shares.deleted"
+
+	FollowerCreated = "followers.created"
+
+	PreferencesCreated = "preferences.created"
+	PreferencesUpdated = "preferences.updated"
+	PreferencesDeleted = "preferences.deleted"
+)
+
+// PostEvent is the data of the posts events. Partial updates only carry the
+// ID and the owner of the post and the updated fields, and deletions only the
+// ID and the owner. Visibility is thus only set on posts.created, posts.updated
+// and posts.updated.visibility, see HasVisibility.
+type PostEvent struct {
+	ID         string    `json:"id"`
+	UserID     string    `json:"user_id"`
+	Title      string    `json:"title"`
+	Content    string    `json:"content"`
+	Tags       []string  `json:"tags"`
+	ImageURL   string    `json:"image_url"`
+	Visibility bool      `json:"visibility"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+func (PostEvent) SchemaVersion() uint64 {
+	return 1
+}
+
+// HasVisibility reports whether the events of the type carry the visibility of
+// the post, which decodes as false on the others.
+func HasVisibility(eventType string) bool {
+	switch eventType {
+	case PostCreated, PostUpdated, PostVisibilityUpdated:
+		return true
+	default:
+		return false
+	}
+}
+
+// CommentEvent is the data of the comments events. Deletions only carry the ID
+// and the owner. Creations also carry the author of the post, which events
+// published before it was added lack.
+type CommentEvent struct {
+	ID          string    `json:"id"`
+	PostID      string    `json:"post_id"`
+	PostOwnerID string    `json:"post_owner_id,omitempty"`
+	UserID      string    `json:"user_id"`
+	Content     string    `json:"content"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (CommentEvent) SchemaVersion() uint64 {
+	return 1
+}
+
+// LikeEvent is the data of the likes events. Deletions only carry the post and
+// the owner, and creations also the author of the post like CommentEvent.
+type LikeEvent struct {
+	PostID      string    `json:"post_id"`
+	PostOwnerID string    `json:"post_owner_id,omitempty"`
+	UserID      string    `json:"user_id"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func (LikeEvent) SchemaVersion() uint64 {
+	return 1
+}
+
+// ShareEvent is the data of the shares events. Deletions only carry the ID and
+// the owner, and creations also the author of the post like CommentEvent.
+type ShareEvent struct {
+	ID          string    `json:"id"`
+	PostID      string    `json:"post_id"`
+	PostOwnerID string    `json:"post_owner_id,omitempty"`
+	UserID      string    `json:"user_id"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func (ShareEvent) SchemaVersion() uint64 {
+	return 1
+}
+
+// FollowerEvent is the data of the followers events.
+type FollowerEvent struct {
+	ID         string    `json:"id"`
+	FollowerID string    `json:"follower_id"`
+	FolloweeID string    `json:"followee_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func (FollowerEvent) SchemaVersion() uint64 {
+	return 1
+}
+
+// PreferencesEvent is the data of the preferences events. Deletions only carry the user.
+type PreferencesEvent struct {
+	ID           string    `json:"id"`
+	UserID       string    `json:"user_id"`
+	EmailEnabled bool      `json:"email_enabled"`
+	PushEnabled  bool      `json:"push_enabled"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+func (PreferencesEvent) SchemaVersion() uint64 {
+	return 1
+}
@@ -0,0 +1,40 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package events
+
+import "strings"
+
+// MatchTopic reports whether the subject matches the topic of a subscription
+// like the routing key of an AMQP topic exchange, where * matches exactly one
+// word and # or > matches zero or more words.
+func MatchTopic(topic, subject string) bool {
+	topic = strings.ReplaceAll(topic, ">", "#")
+
+	return match(strings.Split(topic, "."), strings.Split(subject, "."))
+}
+
+func match(pattern, subject []string) bool {
+	if len(pattern) == 0 {
+		return len(subject) == 0
+	}
+
+	switch pattern[0] {
+	case "#":
+		for i := 0; i <= len(subject); i++ {
+			if match(pattern[1:], subject[i:]) {
+				return true
+			}
+		}
+
+		return false
+	case "*":
+		return len(subject) > 0 && match(pattern[1:], subject[1:])
+	default:
+		return len(subject) > 0 && pattern[0] == subject[0] && match(pattern[1:], subject[1:])
+	}
+}
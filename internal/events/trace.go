@@ -0,0 +1,96 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package events
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	traceParentKey = "traceparent"
+	traceStateKey  = "tracestate"
+)
+
+// The tracer is resolved through the global provider, which the services set
+// once they have connected to Jaeger.
+var tracer = otel.Tracer("github.com/rodneyosodo/twiga/internal/events")
+
+// InjectTrace returns the W3C trace context of the span in the context as the
+// headers of a message.
+func InjectTrace(ctx context.Context) map[string]string {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+
+	return carrier
+}
+
+// ExtractTrace returns the context continuing the trace carried by the headers of a message.
+func ExtractTrace(ctx context.Context, headers map[string]string) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(headers))
+}
+
+// StartPublish starts the span of publishing the event to the topic. Events
+// relayed from the outbox are published outside of the request that created
+// them, so their span continues the trace recorded in their envelope, if any.
+func StartPublish(ctx context.Context, topic string, msg map[string]interface{}) (context.Context, trace.Span) {
+	if headers := envelopeTrace(msg); len(headers) > 0 {
+		ctx = ExtractTrace(ctx, headers)
+	}
+
+	return tracer.Start(ctx, "publish_event", trace.WithSpanKind(trace.SpanKindProducer), trace.WithAttributes(
+		attribute.String("topic", topic),
+		attribute.String("event_id", stringValue(msg["id"])),
+	))
+}
+
+// StartHandle starts the span of handling an event received on the topic, as
+// a child of the span that published it.
+func StartHandle(ctx context.Context, topic string, headers map[string]string) (context.Context, trace.Span) {
+	ctx = ExtractTrace(ctx, headers)
+
+	return tracer.Start(ctx, "handle_event", trace.WithSpanKind(trace.SpanKindConsumer), trace.WithAttributes(
+		attribute.String("topic", topic),
+	))
+}
+
+// EndSpan records the error, if any, and ends the span.
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		FailSpan(span, err)
+	}
+	span.End()
+}
+
+// FailSpan records the error on the span and marks it as failed.
+func FailSpan(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+func envelopeTrace(msg map[string]interface{}) map[string]string {
+	headers := make(map[string]string)
+	for _, key := range []string{traceParentKey, traceStateKey} {
+		if value := stringValue(msg[key]); value != "" {
+			headers[key] = value
+		}
+	}
+
+	return headers
+}
+
+func stringValue(v interface{}) string {
+	s, _ := v.(string)
+
+	return s
+}
@@ -0,0 +1,93 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package netguard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+var (
+	// internalPrefixes are the ranges that are not public although they are
+	// neither private, loopback nor link-local.
+	internalPrefixes = []netip.Prefix{
+		netip.MustParsePrefix("100.64.0.0/10"), // Shared address space of carrier-grade NAT.
+		netip.MustParsePrefix("192.0.0.0/24"),  // IETF protocol assignments.
+		netip.MustParsePrefix("198.18.0.0/15"), // Benchmarking.
+		netip.MustParsePrefix("64:ff9b::/96"),  // NAT64, which may translate to internal IPv4 addresses.
+	}
+
+	// ErrInternalAddress indicates that a URL resolves to an address that is not public.
+	ErrInternalAddress = errors.New("url must not resolve to a loopback, private, link-local or internal address")
+)
+
+// NewHTTPClient returns a client for URLs registered by users, such as webhooks and
+// push subscriptions. It refuses to connect to internal addresses, including after
+// redirects and when the host resolves to another address since it was registered.
+func NewHTTPClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !publicAddr(addrPort.Addr()) {
+				return fmt.Errorf("%w: %s", ErrInternalAddress, addrPort.Addr())
+			}
+
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// A proxy would connect on behalf of the client, bypassing the check.
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+	}
+}
+
+// CheckHost rejects hosts that resolve to internal addresses, such as localhost,
+// the hosts of the other services and the metadata endpoints of cloud providers.
+func CheckHost(ctx context.Context, host string) error {
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return fmt.Errorf("failed to resolve url host: %w", err)
+	}
+	for _, addr := range addrs {
+		if !publicAddr(addr) {
+			return ErrInternalAddress
+		}
+	}
+
+	return nil
+}
+
+func publicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, prefix := range internalPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -6,7 +6,16 @@
 // http://www.apache.org/licenses/LICENSE-2.0
 package postgres
 
-import "context"
+import (
+	"context"
+	"fmt"
+)
+
+// CursorFilter returns the condition selecting the rows after the cursor bound to
+// :cursor_created_at and :cursor_id when rows are ordered by created_at and key descending.
+func CursorFilter(key string) string {
+	return fmt.Sprintf("(created_at, %s) < (:cursor_created_at, :cursor_id)", key)
+}
 
 func Total(ctx context.Context, db Database, query string, params interface{}) (uint64, error) {
 	rows, err := db.NamedQueryContext(ctx, query, params)
@@ -51,42 +51,42 @@ func (d *database) NamedQueryContext(ctx context.Context, query string, args int
 	ctx, span := d.addSpanTags(ctx, query)
 	defer span.End()
 
-	return d.db.NamedQueryContext(ctx, query, args)
+	return sqlx.NamedQueryContext(ctx, d.ext(ctx), query, args)
 }
 
 func (d *database) NamedExecContext(ctx context.Context, query string, args interface{}) (sql.Result, error) {
 	ctx, span := d.addSpanTags(ctx, query)
 	defer span.End()
 
-	return d.db.NamedExecContext(ctx, query, args)
+	return sqlx.NamedExecContext(ctx, d.ext(ctx), query, args)
 }
 
 func (d *database) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
 	ctx, span := d.addSpanTags(ctx, query)
 	defer span.End()
 
-	return d.db.ExecContext(ctx, query, args...)
+	return d.ext(ctx).ExecContext(ctx, query, args...)
 }
 
 func (d *database) QueryRowxContext(ctx context.Context, query string, args ...interface{}) *sqlx.Row {
 	ctx, span := d.addSpanTags(ctx, query)
 	defer span.End()
 
-	return d.db.QueryRowxContext(ctx, query, args...)
+	return d.ext(ctx).QueryRowxContext(ctx, query, args...)
 }
 
 func (d *database) QueryxContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Rows, error) {
 	ctx, span := d.addSpanTags(ctx, query)
 	defer span.End()
 
-	return d.db.QueryxContext(ctx, query, args...)
+	return d.ext(ctx).QueryxContext(ctx, query, args...)
 }
 
 func (d database) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
 	ctx, span := d.addSpanTags(ctx, query)
 	defer span.End()
 
-	return d.db.QueryContext(ctx, query, args...)
+	return d.ext(ctx).QueryContext(ctx, query, args...)
 }
 
 func (d database) BeginTxx(ctx context.Context, opts *sql.TxOptions) (*sqlx.Tx, error) {
@@ -96,6 +96,15 @@ func (d database) BeginTxx(ctx context.Context, opts *sql.TxOptions) (*sqlx.Tx,
 	return d.db.BeginTxx(ctx, opts)
 }
 
+// ext returns the transaction started by WithTx, if any, so that the query joins it.
+func (d *database) ext(ctx context.Context) sqlx.ExtContext {
+	if tx, ok := ctx.Value(txKey{}).(*sqlx.Tx); ok {
+		return tx
+	}
+
+	return d.db
+}
+
 func (d *database) addSpanTags(ctx context.Context, query string) (context.Context, trace.Span) {
 	operation := strings.Replace(strings.Split(query, " ")[0], "(", "", 1)
 
@@ -8,7 +8,12 @@
 package api
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
+	"sort"
 	"strconv"
 	"time"
 
@@ -17,7 +22,9 @@ import (
 	"github.com/gorilla/websocket"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	iapi "github.com/rodneyosodo/twiga/internal/api"
+	"github.com/rodneyosodo/twiga/internal/cursor"
 	"github.com/rodneyosodo/twiga/notifications"
+	"github.com/rodneyosodo/twiga/notifications/hub"
 )
 
 type entityfield string
@@ -29,11 +36,17 @@ const (
 	visibilityField entityfield = "visibility"
 	allFields       entityfield = "all"
 
-	bufferSize   = 1024
-	defaultLimit = 100
-	defaultSleep = 5 * time.Second
+	bufferSize     = 1024
+	defaultLimit   = 100
+	maxMessageSize = 512
+	writeWait      = 10 * time.Second
+	pongWait       = 60 * time.Second
 )
 
+// pingPeriod is how often idle connections are kept alive. It is a variable so
+// that tests do not have to wait for it.
+var pingPeriod = pongWait * 9 / 10
+
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  bufferSize,
 	WriteBufferSize: bufferSize,
@@ -42,20 +55,25 @@ var upgrader = websocket.Upgrader{
 	},
 }
 
-func Endpoints(router *gin.Engine, svc notifications.Service) {
+func Endpoints(router *gin.Engine, svc notifications.Service, h *hub.Hub) {
 	router.GET("/notifications", getNotifications(svc))
+	router.GET("/notifications/stream", streamHandler(svc, h))
+	router.GET("/notifications/settings", getSetting(svc))
+	router.PATCH("/notifications/settings/digest", updateDigest(svc))
+	router.POST("/notifications/subscriptions", createSubscription(svc))
+	router.DELETE("/notifications/subscriptions/:id", deleteSubscription(svc))
 	router.GET("/notifications/:id", getNotification(svc))
 	router.POST("/notifications/:id/read", readNotification(svc))
 	router.POST("/notifications/read", readAllNotifications(svc))
 	router.DELETE("/notifications/:id", deleteNotification(svc))
 
-	router.GET("/ws", wsHandler(svc))
+	router.GET("/ws", wsHandler(svc, h))
 
 	router.GET("/version", iapi.GinVersion("notifications"))
 	router.GET("/metrics", ginprom.PromHandler(promhttp.Handler()))
 }
 
-func wsHandler(svc notifications.Service) func(ctx *gin.Context) {
+func wsHandler(svc notifications.Service, h *hub.Hub) func(ctx *gin.Context) {
 	return func(ctx *gin.Context) {
 		token := iapi.GinExtractToken(ctx)
 		if token == "" {
@@ -73,6 +91,17 @@ func wsHandler(svc notifications.Service) func(ctx *gin.Context) {
 			ctx.JSON(http.StatusBadRequest, gin.H{
 				"error": err.Error(),
 			})
+
+			return
+		}
+
+		userID, err := svc.IdentifyUser(ctx, token)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+
+			return
 		}
 
 		conn, err := upgrader.Upgrade(ctx.Writer, ctx.Request, nil)
@@ -83,14 +112,96 @@ func wsHandler(svc notifications.Service) func(ctx *gin.Context) {
 		}
 		defer conn.Close()
 
+		// Registering before reading the stored notifications ensures the ones
+		// created in between are pushed instead of lost.
+		client := h.Register(userID)
+		defer h.Unregister(client)
+
 		pm := notifications.Page{
-			Offset:   0,
 			Limit:    defaultLimit,
 			Category: notifications.ToCategory(category),
 			IsRead:   &isRead,
 		}
+		stored, err := retrieveStored(ctx, svc, token, pm)
+		if err != nil {
+			return
+		}
+		for _, n := range stored {
+			if err := writeJSON(conn, n); err != nil {
+				return
+			}
+		}
+
+		done := make(chan struct{})
+		go readPump(conn, done)
+		writePump(conn, client, pm, done)
+	}
+}
+
+// readPump discards messages from the client so that pongs keep extending the
+// read deadline. done is closed once the client disconnects or stops answering pings.
+func readPump(conn *websocket.Conn, done chan<- struct{}) {
+	defer close(done)
+
+	conn.SetReadLimit(maxMessageSize)
+	if err := conn.SetReadDeadline(time.Now().Add(pongWait)); err != nil {
+		return
+	}
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		if _, _, err := conn.NextReader(); err != nil {
+			return
+		}
+	}
+}
+
+// writePump writes the notifications pushed by the hub and pings the client
+// until either side goes away.
+func writePump(conn *websocket.Conn, client *hub.Client, page notifications.Page, done <-chan struct{}) {
+	ticker := time.NewTicker(pingPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case n, ok := <-client.Send():
+			if !ok {
+				_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "client too slow"), time.Now().Add(writeWait))
+
+				return
+			}
+			if !matches(page, n) {
+				continue
+			}
+			if err := writeJSON(conn, n); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func streamHandler(svc notifications.Service, h *hub.Hub) func(ctx *gin.Context) {
+	return func(ctx *gin.Context) {
+		token := iapi.GinExtractToken(ctx)
+		if token == "" {
+			ctx.JSON(http.StatusUnauthorized, gin.H{
+				"error": "Unauthorized",
+			})
+
+			return
+		}
 
-		notifs, err := svc.RetrieveAllNotifications(ctx, token, pm)
+		category := ctx.DefaultQuery("category", "")
+		strIsRead := ctx.DefaultQuery("is_read", "false")
+		isRead, err := strconv.ParseBool(strIsRead)
 		if err != nil {
 			ctx.JSON(http.StatusBadRequest, gin.H{
 				"error": err.Error(),
@@ -99,28 +210,36 @@ func wsHandler(svc notifications.Service) func(ctx *gin.Context) {
 			return
 		}
 
-		for notifs.Total > uint64(len(notifs.Notifications)) {
-			pm.Offset += defaultLimit
-			newNotifs, err := svc.RetrieveAllNotifications(ctx, token, pm)
-			if err != nil {
+		pm := notifications.Page{
+			Limit:    defaultLimit,
+			Category: notifications.ToCategory(category),
+			IsRead:   &isRead,
+			After:    ctx.GetHeader("Last-Event-ID"),
+		}
+		if pm.After != "" {
+			if _, err := cursor.Decode(pm.After); err != nil {
 				ctx.JSON(http.StatusBadRequest, gin.H{
 					"error": err.Error(),
 				})
 
 				return
 			}
-			notifs.Notifications = append(notifs.Notifications, newNotifs.Notifications...)
 		}
 
-		for _, n := range notifs.Notifications {
-			if err := conn.WriteJSON(n); err != nil {
-				ctx.AbortWithError(http.StatusInternalServerError, err)
+		userID, err := svc.IdentifyUser(ctx, token)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
 
-				return
-			}
+			return
 		}
 
-		userID, err := svc.IdentifyUser(ctx, token)
+		client := h.Register(userID)
+		defer h.Unregister(client)
+
+		// A reconnecting client only gets what was stored after its last event.
+		stored, err := retrieveStored(ctx, svc, token, pm)
 		if err != nil {
 			ctx.JSON(http.StatusBadRequest, gin.H{
 				"error": err.Error(),
@@ -129,22 +248,105 @@ func wsHandler(svc notifications.Service) func(ctx *gin.Context) {
 			return
 		}
 
-		for {
-			newNotif := svc.GetNewNotification(ctx, userID)
-			if newNotif.ID == "" {
-				goto sleep
+		ctx.Header("Content-Type", "text/event-stream")
+		ctx.Header("Cache-Control", "no-cache")
+		ctx.Header("Connection", "keep-alive")
+		ctx.Header("X-Accel-Buffering", "no")
+		ctx.Status(http.StatusOK)
+
+		// Events are sent from the least recently updated so that the last
+		// event ID is always the newest one.
+		sort.Slice(stored, func(i, j int) bool {
+			if stored[i].UpdatedAt.Equal(stored[j].UpdatedAt) {
+				return stored[i].ID < stored[j].ID
+			}
+
+			return stored[i].UpdatedAt.Before(stored[j].UpdatedAt)
+		})
+		for _, n := range stored {
+			if err := writeEvent(ctx.Writer, n); err != nil {
+				return
 			}
-			if err := conn.WriteJSON(newNotif); err != nil {
-				ctx.AbortWithError(http.StatusInternalServerError, err)
+		}
+		ctx.Writer.Flush()
 
-				break
+		ticker := time.NewTicker(pingPeriod)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Request.Context().Done():
+				return
+			case n, ok := <-client.Send():
+				if !ok {
+					return
+				}
+				if !matches(pm, n) {
+					continue
+				}
+				if err := writeEvent(ctx.Writer, n); err != nil {
+					return
+				}
+			case <-ticker.C:
+				if _, err := io.WriteString(ctx.Writer, ": ping\n\n"); err != nil {
+					return
+				}
 			}
-		sleep:
-			time.Sleep(defaultSleep)
+			ctx.Writer.Flush()
 		}
 	}
 }
 
+// retrieveStored walks all the pages of stored notifications matching the page filters, newest first.
+func retrieveStored(ctx context.Context, svc notifications.Service, token string, page notifications.Page) ([]notifications.Notification, error) {
+	var stored []notifications.Notification
+	for {
+		notifs, err := svc.RetrieveAllNotifications(ctx, token, page)
+		if err != nil {
+			return nil, err
+		}
+		stored = append(stored, notifs.Notifications...)
+
+		if notifs.NextCursor == "" {
+			return stored, nil
+		}
+		page.Cursor = notifs.NextCursor
+	}
+}
+
+// writeEvent writes the notification as a server-sent event whose ID is the
+// cursor of the last update of the notification, to be sent back by the client
+// as Last-Event-ID. Aggregated notifications are sent again when they are updated.
+func writeEvent(w io.Writer, n notifications.Notification) error {
+	data, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %s\nevent: notification\ndata: %s\n\n", cursor.Encode(n.UpdatedAt, n.ID), data)
+
+	return err
+}
+
+func writeJSON(conn *websocket.Conn, n notifications.Notification) error {
+	if err := conn.SetWriteDeadline(time.Now().Add(writeWait)); err != nil {
+		return err
+	}
+
+	return conn.WriteJSON(n)
+}
+
+// matches reports whether a pushed notification satisfies the filters of the connection.
+func matches(page notifications.Page, n notifications.Notification) bool {
+	if page.Category != notifications.Empty && page.Category != n.Category {
+		return false
+	}
+	if page.IsRead != nil && *page.IsRead != n.IsRead {
+		return false
+	}
+
+	return true
+}
+
 func getNotification(svc notifications.Service) func(ctx *gin.Context) {
 	return func(ctx *gin.Context) {
 		token := iapi.GinExtractToken(ctx)
@@ -213,7 +415,8 @@ func getNotifications(svc notifications.Service) func(ctx *gin.Context) {
 		page := notifications.Page{
 			Offset:   offset,
 			Limit:    limit,
-			UserID:   ctx.DefaultQuery("user_id", ""),
+			Cursor:   ctx.DefaultQuery("cursor", ""),
+			ActorID:  ctx.DefaultQuery("actor_id", ""),
 			Category: notifications.ToCategory(category),
 			IsRead:   &isRead,
 		}
@@ -303,7 +506,7 @@ func readAllNotifications(svc notifications.Service) func(ctx *gin.Context) {
 		page := notifications.Page{
 			Offset:   offset,
 			Limit:    limit,
-			UserID:   ctx.DefaultQuery("user_id", ""),
+			ActorID:  ctx.DefaultQuery("actor_id", ""),
 			Category: notifications.ToCategory(category),
 			IsRead:   &isRead,
 		}
@@ -355,3 +558,126 @@ func deleteNotification(svc notifications.Service) func(ctx *gin.Context) {
 		})
 	}
 }
+
+func getSetting(svc notifications.Service) func(ctx *gin.Context) {
+	return func(ctx *gin.Context) {
+		token := iapi.GinExtractToken(ctx)
+		if token == "" {
+			ctx.JSON(http.StatusUnauthorized, gin.H{
+				"error": "Unauthorized",
+			})
+
+			return
+		}
+
+		setting, err := svc.RetrieveSetting(ctx, token)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+
+			return
+		}
+
+		ctx.JSON(http.StatusOK, setting)
+	}
+}
+
+func updateDigest(svc notifications.Service) func(ctx *gin.Context) {
+	return func(ctx *gin.Context) {
+		token := iapi.GinExtractToken(ctx)
+		if token == "" {
+			ctx.JSON(http.StatusUnauthorized, gin.H{
+				"error": "Unauthorized",
+			})
+
+			return
+		}
+
+		var setting notifications.Setting
+		if err := ctx.BindJSON(&setting); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+
+			return
+		}
+
+		setting, err := svc.UpdateDigest(ctx, token, setting.Digest)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+
+			return
+		}
+
+		ctx.JSON(http.StatusOK, setting)
+	}
+}
+
+func createSubscription(svc notifications.Service) func(ctx *gin.Context) {
+	return func(ctx *gin.Context) {
+		token := iapi.GinExtractToken(ctx)
+		if token == "" {
+			ctx.JSON(http.StatusUnauthorized, gin.H{
+				"error": "Unauthorized",
+			})
+
+			return
+		}
+
+		var subscription notifications.Subscription
+		if err := ctx.BindJSON(&subscription); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+
+			return
+		}
+
+		subscription, err := svc.CreateSubscription(ctx, token, subscription)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+
+			return
+		}
+
+		ctx.JSON(http.StatusCreated, subscription)
+	}
+}
+
+func deleteSubscription(svc notifications.Service) func(ctx *gin.Context) {
+	return func(ctx *gin.Context) {
+		token := iapi.GinExtractToken(ctx)
+		if token == "" {
+			ctx.JSON(http.StatusUnauthorized, gin.H{
+				"error": "Unauthorized",
+			})
+
+			return
+		}
+		id := ctx.Param("id")
+		if id == "" {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error": "ID is required",
+			})
+
+			return
+		}
+
+		if err := svc.DeleteSubscription(ctx, token, id); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+
+			return
+		}
+
+		ctx.JSON(http.StatusNoContent, gin.H{
+			"message": "Subscription removed",
+		})
+	}
+}
@@ -0,0 +1,195 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package api_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rodneyosodo/twiga/internal/cursor"
+	"github.com/rodneyosodo/twiga/notifications"
+	"github.com/rodneyosodo/twiga/notifications/api"
+	"github.com/rodneyosodo/twiga/notifications/hub"
+	"github.com/rodneyosodo/twiga/notifications/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	token  = "token"
+	userID = "alice"
+)
+
+// event is a server-sent event, or a comment when only comment is set.
+type event struct {
+	id      string
+	name    string
+	data    string
+	comment string
+}
+
+func newServer(t *testing.T) (*httptest.Server, *mocks.Service, *hub.Hub) {
+	gin.SetMode(gin.TestMode)
+
+	svc := mocks.NewService(t)
+	h := hub.New(nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	router := gin.New()
+	api.Endpoints(router, svc, h)
+
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	return server, svc, h
+}
+
+// stream opens the notification stream, which is closed once the test ends.
+func stream(t *testing.T, url, lastEventID string) *http.Response {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url+"/notifications/stream", http.NoBody)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+token)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+
+	return resp
+}
+
+// readEvent reads the lines of the stream up to the next blank line.
+func readEvent(t *testing.T, r *bufio.Reader) event {
+	var e event
+	for {
+		line, err := r.ReadString('\n')
+		require.NoError(t, err)
+
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "":
+			return e
+		case strings.HasPrefix(line, ": "):
+			e.comment = strings.TrimPrefix(line, ": ")
+		case strings.HasPrefix(line, "id: "):
+			e.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			e.name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			e.data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+func assertNotification(t *testing.T, expected notifications.Notification, e event) {
+	assert.Equal(t, cursor.Encode(expected.UpdatedAt, expected.ID), e.id)
+	assert.Equal(t, "notification", e.name)
+
+	var n notifications.Notification
+	require.NoError(t, json.Unmarshal([]byte(e.data), &n))
+	assert.Equal(t, expected.ID, n.ID)
+}
+
+func generateNotification(id string, createdAt time.Time) notifications.Notification {
+	return notifications.Notification{
+		ID:          id,
+		RecipientID: userID,
+		Category:    notifications.Like,
+		CreatedAt:   createdAt,
+		UpdatedAt:   createdAt,
+	}
+}
+
+func TestStreamReplay(t *testing.T) {
+	t.Cleanup(api.SetPingPeriod(time.Hour))
+	server, svc, h := newServer(t)
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	last := generateNotification("0", now)
+	first := generateNotification("1", now.Add(time.Second))
+	second := generateNotification("2", now.Add(2*time.Second))
+	third := generateNotification("3", now.Add(3*time.Second))
+	// An aggregated notification is replayed from its last update.
+	aggregated := generateNotification("6", now.Add(-time.Hour))
+	aggregated.UpdatedAt = now.Add(4 * time.Second)
+	lastEventID := cursor.Encode(last.UpdatedAt, last.ID)
+
+	svc.On("IdentifyUser", mock.Anything, token).Return(userID, nil)
+	svc.On("RetrieveAllNotifications", mock.Anything, token, mock.MatchedBy(func(page notifications.Page) bool {
+		return page.After == lastEventID && page.Cursor == ""
+	})).Return(notifications.NotificationsPage{
+		Page:          notifications.Page{NextCursor: "next"},
+		Notifications: []notifications.Notification{third, second},
+	}, nil)
+	svc.On("RetrieveAllNotifications", mock.Anything, token, mock.MatchedBy(func(page notifications.Page) bool {
+		return page.After == lastEventID && page.Cursor == "next"
+	})).Return(notifications.NotificationsPage{
+		Notifications: []notifications.Notification{first, aggregated},
+	}, nil)
+
+	resp := stream(t, server.URL, lastEventID)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	r := bufio.NewReader(resp.Body)
+	for _, expected := range []notifications.Notification{first, second, third, aggregated} {
+		assertNotification(t, expected, readEvent(t, r))
+	}
+
+	// The client is registered once the headers are sent, so the pushed
+	// notifications reach the stream. Read ones do not match the default filter.
+	read := generateNotification("4", now.Add(5*time.Second))
+	read.IsRead = true
+	pushed := generateNotification("5", now.Add(6*time.Second))
+	h.Deliver(read)
+	h.Deliver(pushed)
+
+	assertNotification(t, pushed, readEvent(t, r))
+}
+
+func TestStreamKeepalive(t *testing.T) {
+	t.Cleanup(api.SetPingPeriod(10 * time.Millisecond))
+	server, svc, _ := newServer(t)
+
+	svc.On("IdentifyUser", mock.Anything, token).Return(userID, nil)
+	svc.On("RetrieveAllNotifications", mock.Anything, token, mock.Anything).Return(notifications.NotificationsPage{}, nil)
+
+	resp := stream(t, server.URL, "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	r := bufio.NewReader(resp.Body)
+	for i := 0; i < 2; i++ {
+		assert.Equal(t, event{comment: "ping"}, readEvent(t, r))
+	}
+}
+
+func TestStreamInvalidRequest(t *testing.T) {
+	server, _, _ := newServer(t)
+
+	resp := stream(t, server.URL, "invalid")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode, "an invalid Last-Event-ID should be rejected")
+
+	resp, err := http.Get(server.URL + "/notifications/stream")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
@@ -0,0 +1,18 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package api
+
+import "time"
+
+// SetPingPeriod shortens the keepalive of idle connections for the duration of a test.
+func SetPingPeriod(period time.Duration) (restore func()) {
+	previous := pingPeriod
+	pingPeriod = period
+
+	return func() { pingPeriod = previous }
+}
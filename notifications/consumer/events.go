@@ -12,98 +12,176 @@ import (
 
 	"github.com/rodneyosodo/twiga/internal/events"
 	"github.com/rodneyosodo/twiga/notifications"
+	"github.com/rodneyosodo/twiga/users/proto"
 )
 
 const (
-	postCreated = "posts.created"
-	postUpdated = "posts.updated"
-	postDeleted = "posts.deleted"
-
-	commentCreated = "comments.created"
-	commentUpdated = "comments.updated"
-	commentDeleted = "comments.deleted"
-
-	likeCreated = "likes.created"
-	likeDeleted = "likes.deleted"
-
-	shareCreated = "shares.created"
-	shareDeleted = "shares.deleted"
-
-	followCreated = "followers.created"
+	defLimit      = 100
+	followContent = "started following you"
 )
 
 type eventHandler struct {
 	notifications.Service
+	users proto.UsersServiceClient
+	posts notifications.PostsClient
 }
 
-func NewEventHandler(svc notifications.Service) events.EventHandler {
-	return &eventHandler{Service: svc}
+// NewEventHandler returns an event handler that stores a notification for every
+// user concerned by an event. Updates and deletions are not notified. Preference
+// events drop the delivery preferences cached for the user. The owners of posts
+// are carried by the events about them, and are only looked up with the posts
+// client for the events published before; without one, these are not notified.
+func NewEventHandler(svc notifications.Service, users proto.UsersServiceClient, posts notifications.PostsClient) events.EventHandler {
+	return &eventHandler{Service: svc, users: users, posts: posts}
 }
 
-func (eh *eventHandler) Handle(ctx context.Context, event map[string]interface{}) error {
-	var notification notifications.Notification
-
-	switch event["topic"] {
-	case postCreated, postUpdated:
-		notification = decodeEntityCreatedEvent(event, notifications.Post)
-	case postDeleted:
-		notification = decodeEntityDeletedEvent(event, notifications.Post)
-	case commentCreated, commentUpdated:
-		notification = decodeEntityCreatedEvent(event, notifications.Comment)
-	case commentDeleted:
-		notification = decodeEntityDeletedEvent(event, notifications.Comment)
-	case likeCreated:
-		notification = decodeEntityCreatedEvent(event, notifications.Like)
-	case likeDeleted:
-		notification = decodeEntityDeletedEvent(event, notifications.Like)
-	case shareCreated:
-		notification = decodeEntityCreatedEvent(event, notifications.Share)
-	case shareDeleted:
-		notification = decodeEntityDeletedEvent(event, notifications.Share)
-	case followCreated:
-		notification = decodeFollowCreatedEvent(event)
+func (eh *eventHandler) Handle(ctx context.Context, msg map[string]interface{}) error {
+	env, err := events.Decode(msg)
+	if err != nil {
+		return err
 	}
 
-	if _, err := eh.CreateNotification(ctx, notification); err != nil {
+	notification, recipients, err := eh.decode(ctx, env)
+	if err != nil || notification.Category == notifications.Empty {
 		return err
 	}
+	notification.EventID = env.ID
+
+	for _, recipient := range recipients {
+		// Users are not notified about their own actions.
+		if recipient == "" || recipient == notification.ActorID {
+			continue
+		}
+		notification.RecipientID = recipient
+		if _, err := eh.CreateNotification(ctx, notification); err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
 
-func (eh *eventHandler) Cancel() error {
-	return nil
-}
-
-func decodeEntityCreatedEvent(event map[string]interface{}, category notifications.Category) notifications.Notification {
-	return notifications.Notification{
-		UserID:   read(event, "user_id", ""),
-		Category: category,
-		Content:  read(event, "content", ""),
+// decode returns the notification of the event along with the users to notify:
+// the followers of the author for new posts, the owner of the post for comments,
+// likes and shares, and the followee for follows. The notification is empty for
+// the events that are not notified.
+func (eh *eventHandler) decode(ctx context.Context, env events.Envelope) (notifications.Notification, []string, error) {
+	switch env.Type {
+	case events.PreferencesCreated, events.PreferencesUpdated, events.PreferencesDeleted:
+		data, err := events.DecodeData[events.PreferencesEvent](env)
+		if err != nil {
+			return notifications.Notification{}, nil, err
+		}
+
+		return notifications.Notification{}, nil, eh.InvalidateSetting(ctx, data.UserID)
+	case events.PostCreated:
+		data, err := events.DecodeData[events.PostEvent](env)
+		if err != nil {
+			return notifications.Notification{}, nil, err
+		}
+		followers, err := eh.retrieveFollowers(ctx, data.UserID)
+
+		return notifications.Notification{
+			ActorID:  data.UserID,
+			PostID:   data.ID,
+			Category: notifications.Post,
+			Content:  data.Content,
+		}, followers, err
+	case events.CommentCreated:
+		data, err := events.DecodeData[events.CommentEvent](env)
+		if err != nil {
+			return notifications.Notification{}, nil, err
+		}
+		owner, err := eh.postOwner(ctx, data.PostID, data.PostOwnerID)
+
+		return notifications.Notification{
+			ActorID:  data.UserID,
+			PostID:   data.PostID,
+			Category: notifications.Comment,
+			Content:  data.Content,
+		}, owner, err
+	case events.LikeCreated:
+		data, err := events.DecodeData[events.LikeEvent](env)
+		if err != nil {
+			return notifications.Notification{}, nil, err
+		}
+		owner, err := eh.postOwner(ctx, data.PostID, data.PostOwnerID)
+
+		return notifications.Notification{
+			ActorID:  data.UserID,
+			PostID:   data.PostID,
+			Category: notifications.Like,
+		}, owner, err
+	case events.ShareCreated:
+		data, err := events.DecodeData[events.ShareEvent](env)
+		if err != nil {
+			return notifications.Notification{}, nil, err
+		}
+		owner, err := eh.postOwner(ctx, data.PostID, data.PostOwnerID)
+
+		return notifications.Notification{
+			ActorID:  data.UserID,
+			PostID:   data.PostID,
+			Category: notifications.Share,
+		}, owner, err
+	case events.FollowerCreated:
+		data, err := events.DecodeData[events.FollowerEvent](env)
+		if err != nil {
+			return notifications.Notification{}, nil, err
+		}
+
+		return notifications.Notification{
+			ActorID:  data.FollowerID,
+			Category: notifications.Follow,
+			Content:  followContent,
+		}, []string{data.FolloweeID}, nil
+	default:
+		return notifications.Notification{}, nil, nil
 	}
 }
 
-func decodeEntityDeletedEvent(event map[string]interface{}, category notifications.Category) notifications.Notification {
-	return notifications.Notification{
-		UserID:   read(event, "user_id", ""),
-		Category: category,
-		Content:  read(event, "id", ""),
+// postOwner returns the owner carried by the event, looking it up for the events
+// published before owners were carried.
+func (eh *eventHandler) postOwner(ctx context.Context, postID, ownerID string) ([]string, error) {
+	if ownerID != "" {
+		return []string{ownerID}, nil
+	}
+	if eh.posts == nil {
+		return nil, nil
 	}
+	owner, err := eh.posts.RetrievePostOwner(ctx, postID)
+	if err != nil {
+		return nil, err
+	}
+
+	return []string{owner}, nil
 }
 
-func decodeFollowCreatedEvent(event map[string]interface{}) notifications.Notification {
-	return notifications.Notification{
-		UserID:   read(event, "follower_id", ""),
-		Category: notifications.Follow,
-		Content:  read(event, "followee_id", ""),
-	}
+func (eh *eventHandler) Cancel() error {
+	return nil
 }
 
-func read(event map[string]interface{}, key, def string) string {
-	val, ok := event[key].(string)
-	if !ok {
-		return def
+// retrieveFollowers walks all the follower pages of the user using cursors.
+func (eh *eventHandler) retrieveFollowers(ctx context.Context, userID string) ([]string, error) {
+	if userID == "" {
+		return nil, nil
 	}
 
-	return val
+	var userIDs []string
+
+	req := &proto.GetUserFollowersRequest{Id: userID, Offset: 0, Limit: defLimit}
+	for {
+		resp, err := eh.users.GetUserFollowers(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range resp.GetFollowings() {
+			userIDs = append(userIDs, f.GetFollowerId())
+		}
+
+		if resp.GetNextCursor() == "" {
+			return userIDs, nil
+		}
+		req.Cursor = resp.GetNextCursor()
+	}
 }
@@ -0,0 +1,162 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package consumer_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rodneyosodo/twiga/internal/events"
+	"github.com/rodneyosodo/twiga/notifications"
+	"github.com/rodneyosodo/twiga/notifications/consumer"
+	"github.com/rodneyosodo/twiga/notifications/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func encode(t *testing.T, eventType string, data events.Data) map[string]interface{} {
+	env, err := events.NewEnvelope(context.Background(), "/twiga/users", eventType, data)
+	require.NoError(t, err)
+	msg, err := env.Encode()
+	require.NoError(t, err)
+
+	return msg
+}
+
+func TestHandlePreferences(t *testing.T) {
+	for _, eventType := range []string{events.PreferencesCreated, events.PreferencesUpdated, events.PreferencesDeleted} {
+		t.Run(eventType, func(t *testing.T) {
+			svc := mocks.NewService(t)
+			svc.On("InvalidateSetting", mock.Anything, "user").Return(nil).Once()
+
+			handler := consumer.NewEventHandler(svc, nil, mocks.NewPostsClient(t))
+			err := handler.Handle(context.Background(), encode(t, eventType, events.PreferencesEvent{UserID: "user"}))
+			assert.NoError(t, err)
+			svc.AssertNotCalled(t, "CreateNotification", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestHandlePostOwner(t *testing.T) {
+	cases := []struct {
+		desc      string
+		eventType string
+		data      events.Data
+		category  notifications.Category
+		owner     string
+		carried   bool
+		notified  bool
+	}{
+		{
+			desc:      "comment with owner",
+			eventType: events.CommentCreated,
+			data:      events.CommentEvent{ID: "comment", PostID: "post", PostOwnerID: "owner", UserID: "actor", Content: "nice"},
+			category:  notifications.Comment,
+			owner:     "owner",
+			carried:   true,
+			notified:  true,
+		},
+		{
+			desc:      "like with owner",
+			eventType: events.LikeCreated,
+			data:      events.LikeEvent{PostID: "post", PostOwnerID: "owner", UserID: "actor"},
+			category:  notifications.Like,
+			owner:     "owner",
+			carried:   true,
+			notified:  true,
+		},
+		{
+			desc:      "share with owner",
+			eventType: events.ShareCreated,
+			data:      events.ShareEvent{ID: "share", PostID: "post", PostOwnerID: "owner", UserID: "actor"},
+			category:  notifications.Share,
+			owner:     "owner",
+			carried:   true,
+			notified:  true,
+		},
+		{
+			desc:      "own post with owner",
+			eventType: events.LikeCreated,
+			data:      events.LikeEvent{PostID: "post", PostOwnerID: "actor", UserID: "actor"},
+			owner:     "actor",
+			carried:   true,
+		},
+		{
+			desc:      "comment",
+			eventType: events.CommentCreated,
+			data:      events.CommentEvent{ID: "comment", PostID: "post", UserID: "actor", Content: "nice"},
+			category:  notifications.Comment,
+			owner:     "owner",
+			notified:  true,
+		},
+		{
+			desc:      "like",
+			eventType: events.LikeCreated,
+			data:      events.LikeEvent{PostID: "post", UserID: "actor"},
+			category:  notifications.Like,
+			owner:     "owner",
+			notified:  true,
+		},
+		{
+			desc:      "share",
+			eventType: events.ShareCreated,
+			data:      events.ShareEvent{ID: "share", PostID: "post", UserID: "actor"},
+			category:  notifications.Share,
+			owner:     "owner",
+			notified:  true,
+		},
+		{
+			desc:      "own post",
+			eventType: events.LikeCreated,
+			data:      events.LikeEvent{PostID: "post", UserID: "actor"},
+			owner:     "actor",
+		},
+		{
+			desc:      "deleted post",
+			eventType: events.LikeCreated,
+			data:      events.LikeEvent{PostID: "post", UserID: "actor"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			svc := mocks.NewService(t)
+			posts := mocks.NewPostsClient(t)
+			if !tc.carried {
+				posts.On("RetrievePostOwner", mock.Anything, "post").Return(tc.owner, nil).Once()
+			}
+			if tc.notified {
+				svc.On("CreateNotification", mock.Anything, mock.MatchedBy(func(n notifications.Notification) bool {
+					return n.RecipientID == tc.owner && n.ActorID == "actor" && n.PostID == "post" && n.Category == tc.category
+				})).Return(notifications.Notification{}, nil).Once()
+			}
+
+			handler := consumer.NewEventHandler(svc, nil, posts)
+			require.NoError(t, handler.Handle(context.Background(), encode(t, tc.eventType, tc.data)))
+			if !tc.notified {
+				svc.AssertNotCalled(t, "CreateNotification", mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestHandleFollower(t *testing.T) {
+	data := events.FollowerEvent{ID: "follow", FollowerID: "follower", FolloweeID: "followee"}
+	msg := encode(t, events.FollowerCreated, data)
+
+	// The notification records the event so that a replay does not notify the followee again.
+	svc := mocks.NewService(t)
+	svc.On("CreateNotification", mock.Anything, mock.MatchedBy(func(n notifications.Notification) bool {
+		return n.RecipientID == "followee" && n.ActorID == "follower" && n.Category == notifications.Follow &&
+			n.Content == "started following you" && n.EventID == msg["id"]
+	})).Return(notifications.Notification{}, nil).Once()
+
+	handler := consumer.NewEventHandler(svc, nil, nil)
+	require.NoError(t, handler.Handle(context.Background(), msg))
+}
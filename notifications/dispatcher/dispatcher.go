@@ -0,0 +1,51 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package dispatcher
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/rodneyosodo/twiga/notifications"
+)
+
+type Dispatcher struct {
+	svc      notifications.Service
+	interval time.Duration
+	logger   *slog.Logger
+}
+
+// New returns a dispatcher that sends the queued emails that are due every interval.
+// The interval bounds how late an email can be sent after it is due.
+func New(svc notifications.Service, interval time.Duration, logger *slog.Logger) *Dispatcher {
+	return &Dispatcher{
+		svc:      svc,
+		interval: interval,
+		logger:   logger,
+	}
+}
+
+// Start sends the due emails until the context is canceled.
+func (d *Dispatcher) Start(ctx context.Context) error {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			// Failed emails are rescheduled by the service and retried on a later tick.
+			if err := d.svc.ProcessDeliveries(ctx); err != nil {
+				d.logger.Warn(fmt.Sprintf("Failed to send emails: %s", err))
+			}
+		}
+	}
+}
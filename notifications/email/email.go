@@ -0,0 +1,280 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package email
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/rodneyosodo/twiga/notifications"
+	"github.com/rodneyosodo/twiga/users/proto"
+)
+
+//go:embed templates
+var templatesFS embed.FS
+
+var (
+	// categories lists the categories in the order they appear in digests.
+	categories = []notifications.Category{
+		notifications.Post,
+		notifications.Follow,
+		notifications.Like,
+		notifications.Comment,
+		notifications.Share,
+	}
+
+	subjects = map[notifications.Category]string{
+		notifications.Post:    "%s published a new post",
+		notifications.Follow:  "%s started following you",
+		notifications.Like:    "%s liked your post",
+		notifications.Comment: "%s commented on your post",
+		notifications.Share:   "%s shared your post",
+	}
+
+	errUnknownCategory = errors.New("unknown notification category")
+	errEmptyEmail      = errors.New("recipient has no email address")
+)
+
+var _ notifications.Mailer = (*mailer)(nil)
+
+type Config struct {
+	Host     string `env:"HOST"     envDefault:"localhost"`
+	Port     string `env:"PORT"     envDefault:"1025"`
+	Username string `env:"USERNAME" envDefault:""`
+	Password string `env:"PASSWORD" envDefault:""`
+	From     string `env:"FROM"     envDefault:"Twiga <noreply@twiga.local>"`
+}
+
+type templates struct {
+	html *htmltemplate.Template
+	text *texttemplate.Template
+}
+
+type mailer struct {
+	cfg       Config
+	from      *mail.Address
+	users     proto.UsersServiceClient
+	templates map[notifications.Category]templates
+	digest    templates
+}
+
+type data struct {
+	Subject      string
+	Actor        string
+	Notification notifications.Notification
+}
+
+type digestData struct {
+	Subject string
+	Total   int
+	Groups  []digestGroup
+}
+
+type digestGroup struct {
+	Title string
+	Lines []string
+}
+
+// New returns a mailer that emails notifications and digests to their recipients
+// through the SMTP server, rendering the templates of their category.
+func New(cfg Config, users proto.UsersServiceClient) (notifications.Mailer, error) {
+	from, err := mail.ParseAddress(cfg.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sender address: %w", err)
+	}
+
+	m := &mailer{
+		cfg:       cfg,
+		from:      from,
+		users:     users,
+		templates: make(map[notifications.Category]templates),
+	}
+
+	for _, category := range categories {
+		tmpl, err := parseTemplates(strings.ToLower(category.String()))
+		if err != nil {
+			return nil, err
+		}
+		m.templates[category] = tmpl
+	}
+	if m.digest, err = parseTemplates("digest"); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+func parseTemplates(name string) (templates, error) {
+	html, err := htmltemplate.ParseFS(templatesFS, "templates/layout.html", "templates/"+name+".html")
+	if err != nil {
+		return templates{}, err
+	}
+	text, err := texttemplate.ParseFS(templatesFS, "templates/"+name+".txt")
+	if err != nil {
+		return templates{}, err
+	}
+
+	return templates{html: html, text: text}, nil
+}
+
+func (m *mailer) Notify(ctx context.Context, notification notifications.Notification) error {
+	tmpl, ok := m.templates[notification.Category]
+	if !ok {
+		return errUnknownCategory
+	}
+
+	to, err := m.email(ctx, notification.RecipientID)
+	if err != nil {
+		return err
+	}
+
+	d := data{
+		Actor:        m.actor(ctx, notification.ActorID),
+		Notification: notification,
+	}
+	d.Subject = fmt.Sprintf(subjects[notification.Category], d.Actor)
+
+	msg, err := m.message(to, d.Subject, tmpl, d)
+	if err != nil {
+		return err
+	}
+
+	return m.send(to, msg)
+}
+
+func (m *mailer) SendDigest(ctx context.Context, recipientID string, digest notifications.Digest, items map[notifications.Category][]notifications.Notification) error {
+	to, err := m.email(ctx, recipientID)
+	if err != nil {
+		return err
+	}
+
+	d := digestData{Subject: fmt.Sprintf("Your %s Twiga digest", digest)}
+	actors := make(map[string]string)
+	for _, category := range categories {
+		if len(items[category]) == 0 {
+			continue
+		}
+
+		group := digestGroup{Title: category.String() + "s"}
+		for _, n := range items[category] {
+			if _, ok := actors[n.ActorID]; !ok {
+				actors[n.ActorID] = m.actor(ctx, n.ActorID)
+			}
+			actor := actors[n.ActorID]
+			if n.ActorCount > 1 {
+				actor = fmt.Sprintf("%s and %d others", actor, n.ActorCount-1)
+			}
+			group.Lines = append(group.Lines, fmt.Sprintf(subjects[category], actor))
+		}
+		d.Total += len(group.Lines)
+		d.Groups = append(d.Groups, group)
+	}
+	if d.Total == 0 {
+		return nil
+	}
+
+	msg, err := m.message(to, d.Subject, m.digest, d)
+	if err != nil {
+		return err
+	}
+
+	return m.send(to, msg)
+}
+
+func (m *mailer) email(ctx context.Context, userID string) (string, error) {
+	resp, err := m.users.GetUserEmail(ctx, &proto.GetUserEmailRequest{Id: userID})
+	if err != nil {
+		return "", err
+	}
+	if resp.GetEmail() == "" {
+		return "", errEmptyEmail
+	}
+
+	return resp.GetEmail(), nil
+}
+
+// actor returns the username of the actor, falling back to the ID when it cannot be retrieved.
+func (m *mailer) actor(ctx context.Context, id string) string {
+	resp, err := m.users.GetUserByID(ctx, &proto.GetUserByIDRequest{Id: id})
+	if err != nil || resp.GetUsername() == "" {
+		return id
+	}
+
+	return resp.GetUsername()
+}
+
+func (m *mailer) message(to, subject string, tmpl templates, d interface{}) ([]byte, error) {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+
+	parts := []struct {
+		contentType string
+		execute     func(buf *bytes.Buffer) error
+	}{
+		{"text/plain", func(buf *bytes.Buffer) error { return tmpl.text.Execute(buf, d) }},
+		{"text/html", func(buf *bytes.Buffer) error { return tmpl.html.ExecuteTemplate(buf, "layout", d) }},
+	}
+	for _, p := range parts {
+		var buf bytes.Buffer
+		if err := p.execute(&buf); err != nil {
+			return nil, err
+		}
+
+		pw, err := w.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {p.contentType + "; charset=UTF-8"},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qw := quotedprintable.NewWriter(pw)
+		if _, err := qw.Write(buf.Bytes()); err != nil {
+			return nil, err
+		}
+		if err := qw.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", m.from.String())
+	fmt.Fprintf(&msg, "To: %s\r\n", to)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("UTF-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", w.Boundary())
+	msg.Write(body.Bytes())
+
+	return msg.Bytes(), nil
+}
+
+func (m *mailer) send(to string, msg []byte) error {
+	var auth smtp.Auth
+	if m.cfg.Username != "" {
+		auth = smtp.PlainAuth("", m.cfg.Username, m.cfg.Password, m.cfg.Host)
+	}
+
+	return smtp.SendMail(net.JoinHostPort(m.cfg.Host, m.cfg.Port), auth, m.from.Address, []string{to}, msg)
+}
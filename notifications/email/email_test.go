@@ -0,0 +1,393 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package email_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/rodneyosodo/twiga/notifications"
+	"github.com/rodneyosodo/twiga/notifications/email"
+	"github.com/rodneyosodo/twiga/users/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+type usersClient struct {
+	proto.UsersServiceClient
+	emails map[string]string
+	names  map[string]string
+}
+
+func (c usersClient) GetUserEmail(_ context.Context, in *proto.GetUserEmailRequest, _ ...grpc.CallOption) (*proto.GetUserEmailResponse, error) {
+	email, ok := c.emails[in.GetId()]
+	if !ok {
+		return nil, errors.New("user not found")
+	}
+
+	return &proto.GetUserEmailResponse{Email: email}, nil
+}
+
+func (c usersClient) GetUserByID(_ context.Context, in *proto.GetUserByIDRequest, _ ...grpc.CallOption) (*proto.GetUserByIDResponse, error) {
+	name, ok := c.names[in.GetId()]
+	if !ok {
+		return nil, errors.New("user not found")
+	}
+
+	return &proto.GetUserByIDResponse{Id: in.GetId(), Username: name}, nil
+}
+
+// smtpServer is a minimal SMTP stand-in that records the messages it accepts
+// and rejects the first failures transactions with a temporary error.
+type smtpServer struct {
+	listener net.Listener
+	mu       sync.Mutex
+	failures int
+	messages []string
+}
+
+func newSMTPServer(t *testing.T, failures int) *smtpServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	s := &smtpServer{listener: listener, failures: failures}
+	go s.serve()
+	t.Cleanup(func() {
+		listener.Close()
+	})
+
+	return s
+}
+
+func (s *smtpServer) port() string {
+	_, port, _ := net.SplitHostPort(s.listener.Addr().String())
+
+	return port
+}
+
+func (s *smtpServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *smtpServer) handle(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	reply := func(line string) {
+		fmt.Fprintf(conn, "%s\r\n", line)
+	}
+
+	reply("220 localhost ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+
+		cmd := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(cmd, "MAIL FROM"):
+			s.mu.Lock()
+			fail := s.failures > 0
+			if fail {
+				s.failures--
+			}
+			s.mu.Unlock()
+
+			if fail {
+				reply("451 try again later")
+
+				continue
+			}
+			reply("250 OK")
+		case strings.HasPrefix(cmd, "RCPT TO"), strings.HasPrefix(cmd, "RSET"), strings.HasPrefix(cmd, "NOOP"):
+			reply("250 OK")
+		case cmd == "DATA":
+			reply("354 end data with <CR><LF>.<CR><LF>")
+
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(l)
+			}
+
+			s.mu.Lock()
+			s.messages = append(s.messages, data.String())
+			s.mu.Unlock()
+			reply("250 OK")
+		case cmd == "QUIT":
+			reply("221 bye")
+
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
+
+func (s *smtpServer) received() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string{}, s.messages...)
+}
+
+func TestNotify(t *testing.T) {
+	users := usersClient{
+		emails: map[string]string{"recipient": "recipient@example.com", "noemail": ""},
+		names:  map[string]string{"actor": "alice"},
+	}
+
+	cases := []struct {
+		desc         string
+		failures     int
+		notification notifications.Notification
+		subject      string
+		err          error
+	}{
+		{
+			desc:     "like",
+			failures: 0,
+			notification: notifications.Notification{
+				ActorID:     "actor",
+				RecipientID: "recipient",
+				Category:    notifications.Like,
+			},
+			subject: "alice liked your post",
+		},
+		{
+			desc:     "comment from unknown actor",
+			failures: 0,
+			notification: notifications.Notification{
+				ActorID:     "unknown",
+				RecipientID: "recipient",
+				Category:    notifications.Comment,
+				Content:     "nice post",
+			},
+			subject: "unknown commented on your post",
+		},
+		{
+			desc:     "follow",
+			failures: 0,
+			notification: notifications.Notification{
+				ActorID:     "actor",
+				RecipientID: "recipient",
+				Category:    notifications.Follow,
+			},
+			subject: "alice started following you",
+		},
+		{
+			desc:     "temporary failure is left to the delivery queue",
+			failures: 1,
+			notification: notifications.Notification{
+				ActorID:     "actor",
+				RecipientID: "recipient",
+				Category:    notifications.Share,
+			},
+			err: errors.New("try again later"),
+		},
+		{
+			desc: "unknown recipient",
+			notification: notifications.Notification{
+				ActorID:     "actor",
+				RecipientID: "unknown",
+				Category:    notifications.Like,
+			},
+			err: errors.New("user not found"),
+		},
+		{
+			desc: "recipient without email",
+			notification: notifications.Notification{
+				ActorID:     "actor",
+				RecipientID: "noemail",
+				Category:    notifications.Like,
+			},
+			err: errors.New("recipient has no email address"),
+		},
+		{
+			desc: "unknown category",
+			notification: notifications.Notification{
+				ActorID:     "actor",
+				RecipientID: "recipient",
+			},
+			err: errors.New("unknown notification category"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			server := newSMTPServer(t, tc.failures)
+			mailer, err := email.New(email.Config{
+				Host: "127.0.0.1",
+				Port: server.port(),
+				From: "Twiga <noreply@twiga.local>",
+			}, users)
+			require.NoError(t, err)
+
+			err = mailer.Notify(context.Background(), tc.notification)
+			if tc.err != nil {
+				assert.ErrorContains(t, err, tc.err.Error())
+				assert.Empty(t, server.received())
+
+				return
+			}
+			require.NoError(t, err)
+
+			received := server.received()
+			require.Len(t, received, 1)
+
+			msg, err := mail.ReadMessage(strings.NewReader(received[0]))
+			require.NoError(t, err)
+			assert.Equal(t, "recipient@example.com", msg.Header.Get("To"))
+			subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+			require.NoError(t, err)
+			assert.Equal(t, tc.subject, subject)
+
+			mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+			require.NoError(t, err)
+			assert.Equal(t, "multipart/alternative", mediaType)
+
+			contentTypes := []string{}
+			mr := multipart.NewReader(msg.Body, params["boundary"])
+			for {
+				part, err := mr.NextPart()
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				require.NoError(t, err)
+				body, err := io.ReadAll(part)
+				require.NoError(t, err)
+				assert.Contains(t, string(body), strings.SplitN(tc.subject, " ", 2)[0])
+				if tc.notification.Content != "" {
+					assert.Contains(t, string(body), tc.notification.Content)
+				}
+				contentTypes = append(contentTypes, part.Header.Get("Content-Type"))
+			}
+			assert.Equal(t, []string{"text/plain; charset=UTF-8", "text/html; charset=UTF-8"}, contentTypes)
+		})
+	}
+}
+
+func TestSendDigest(t *testing.T) {
+	users := usersClient{
+		emails: map[string]string{"recipient": "recipient@example.com"},
+		names:  map[string]string{"alice": "alice", "bob": "bob"},
+	}
+
+	cases := []struct {
+		desc        string
+		recipientID string
+		items       map[notifications.Category][]notifications.Notification
+		lines       []string
+		err         error
+	}{
+		{
+			desc:        "grouped notifications",
+			recipientID: "recipient",
+			items: map[notifications.Category][]notifications.Notification{
+				notifications.Like: {
+					{ActorID: "alice", Category: notifications.Like},
+					{ActorID: "bob", Category: notifications.Like, ActorCount: 13},
+				},
+				notifications.Follow: {
+					{ActorID: "bob", Category: notifications.Follow},
+				},
+			},
+			lines: []string{"Follows (1)", "bob started following you", "Likes (2)", "alice liked your post", "bob and 12 others liked your post"},
+		},
+		{
+			desc:        "no notifications",
+			recipientID: "recipient",
+			items:       map[notifications.Category][]notifications.Notification{},
+		},
+		{
+			desc:        "unknown recipient",
+			recipientID: "unknown",
+			items: map[notifications.Category][]notifications.Notification{
+				notifications.Like: {{ActorID: "alice", Category: notifications.Like}},
+			},
+			err: errors.New("user not found"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			server := newSMTPServer(t, 0)
+			mailer, err := email.New(email.Config{
+				Host: "127.0.0.1",
+				Port: server.port(),
+				From: "Twiga <noreply@twiga.local>",
+			}, users)
+			require.NoError(t, err)
+
+			err = mailer.SendDigest(context.Background(), tc.recipientID, notifications.DailyDigest, tc.items)
+			if tc.err != nil {
+				assert.ErrorContains(t, err, tc.err.Error())
+				assert.Empty(t, server.received())
+
+				return
+			}
+			require.NoError(t, err)
+
+			received := server.received()
+			if len(tc.lines) == 0 {
+				assert.Empty(t, received)
+
+				return
+			}
+			require.Len(t, received, 1)
+
+			msg, err := mail.ReadMessage(strings.NewReader(received[0]))
+			require.NoError(t, err)
+			subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+			require.NoError(t, err)
+			assert.Equal(t, "Your daily Twiga digest", subject)
+
+			_, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+			require.NoError(t, err)
+			part, err := multipart.NewReader(msg.Body, params["boundary"]).NextPart()
+			require.NoError(t, err)
+			body, err := io.ReadAll(part)
+			require.NoError(t, err)
+
+			// Groups follow the category order regardless of the map order.
+			text := string(body)
+			offset := 0
+			for _, line := range tc.lines {
+				idx := strings.Index(text[offset:], line)
+				require.GreaterOrEqual(t, idx, 0, line)
+				offset += idx + len(line)
+			}
+		})
+	}
+}
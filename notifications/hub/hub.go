@@ -0,0 +1,177 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package hub
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/rodneyosodo/twiga/notifications"
+)
+
+const (
+	channel    = "twiga.notifications"
+	sendBuffer = 64
+)
+
+var _ notifications.Notifier = (*Hub)(nil)
+
+// Client is a connection waiting for the notifications addressed to the users it is registered for.
+type Client struct {
+	keys []string
+	send chan notifications.Notification
+}
+
+// Send returns the notifications to be written to the connection. It is closed
+// once the client is unregistered or dropped for not keeping up with the hub.
+func (c *Client) Send() <-chan notifications.Notification {
+	return c.send
+}
+
+// Hub pushes notifications to the clients connected to this replica. Notifications
+// are published over Redis so that every replica delivers them to its own clients.
+type Hub struct {
+	redis   *redis.Client
+	logger  *slog.Logger
+	mu      sync.Mutex
+	clients map[string]map[*Client]struct{}
+}
+
+func New(client *redis.Client, logger *slog.Logger) *Hub {
+	return &Hub{
+		redis:   client,
+		logger:  logger,
+		clients: make(map[string]map[*Client]struct{}),
+	}
+}
+
+func (h *Hub) Notify(ctx context.Context, notification notifications.Notification) error {
+	data, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+
+	return h.redis.Publish(ctx, channel, data).Err()
+}
+
+// Start delivers the notifications published by any replica until ctx is done.
+func (h *Hub) Start(ctx context.Context) error {
+	sub := h.redis.Subscribe(ctx, channel)
+	defer sub.Close()
+
+	if _, err := sub.Receive(ctx); err != nil {
+		return fmt.Errorf("failed to subscribe to notifications: %w", err)
+	}
+
+	msgs := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			h.close()
+
+			return nil
+		case msg, ok := <-msgs:
+			if !ok {
+				h.close()
+
+				return nil
+			}
+
+			var notification notifications.Notification
+			if err := json.Unmarshal([]byte(msg.Payload), &notification); err != nil {
+				h.logger.Warn(fmt.Sprintf("Failed to unmarshal notification: %s", err))
+
+				continue
+			}
+			h.Deliver(notification)
+		}
+	}
+}
+
+// Register returns a client that receives the notifications addressed to the given users.
+func (h *Hub) Register(userIDs ...string) *Client {
+	c := &Client{
+		keys: userIDs,
+		send: make(chan notifications.Notification, sendBuffer),
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, key := range c.keys {
+		clients, ok := h.clients[key]
+		if !ok {
+			clients = make(map[*Client]struct{})
+			h.clients[key] = clients
+		}
+		clients[c] = struct{}{}
+	}
+
+	return c
+}
+
+// Unregister stops delivering notifications to the client. It is safe to call
+// on a client that has already been dropped.
+func (h *Hub) Unregister(c *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.remove(c)
+}
+
+// Deliver hands the notification to the clients of this replica registered for its
+// recipient. Clients whose buffer is full are dropped rather than blocking the others.
+func (h *Hub) Deliver(notification notifications.Notification) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for c := range h.clients[notification.RecipientID] {
+		select {
+		case c.send <- notification:
+		default:
+			h.logger.Warn(fmt.Sprintf("Dropping slow client of user %s", notification.RecipientID))
+			h.remove(c)
+		}
+	}
+}
+
+func (h *Hub) remove(c *Client) {
+	registered := false
+	for _, key := range c.keys {
+		clients, ok := h.clients[key]
+		if !ok {
+			continue
+		}
+		if _, ok := clients[c]; ok {
+			registered = true
+			delete(clients, c)
+		}
+		if len(clients) == 0 {
+			delete(h.clients, key)
+		}
+	}
+
+	if registered {
+		close(c.send)
+	}
+}
+
+func (h *Hub) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, clients := range h.clients {
+		for c := range clients {
+			h.remove(c)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package hub_test
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/rodneyosodo/twiga/notifications"
+	"github.com/rodneyosodo/twiga/notifications/hub"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	alice = "alice"
+	bob   = "bob"
+)
+
+func newHub() *hub.Hub {
+	return hub.New(nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+// received drains the notifications already buffered for the client and
+// reports whether its channel has been closed.
+func received(c *hub.Client) ([]notifications.Notification, bool) {
+	var notifs []notifications.Notification
+	for {
+		select {
+		case n, ok := <-c.Send():
+			if !ok {
+				return notifs, true
+			}
+			notifs = append(notifs, n)
+		default:
+			return notifs, false
+		}
+	}
+}
+
+func TestDeliver(t *testing.T) {
+	h := newHub()
+
+	first := h.Register(alice)
+	second := h.Register(alice)
+	other := h.Register(bob)
+	both := h.Register(alice, bob)
+
+	notification := notifications.Notification{ID: "1", RecipientID: alice}
+	h.Deliver(notification)
+
+	for desc, c := range map[string]*hub.Client{"first": first, "second": second, "both": both} {
+		notifs, closed := received(c)
+		assert.Equal(t, []notifications.Notification{notification}, notifs, desc)
+		assert.False(t, closed, desc)
+	}
+
+	notifs, closed := received(other)
+	assert.Empty(t, notifs)
+	assert.False(t, closed)
+
+	h.Deliver(notifications.Notification{ID: "2", RecipientID: "unknown"})
+	for _, c := range []*hub.Client{first, second, other, both} {
+		notifs, _ := received(c)
+		assert.Empty(t, notifs)
+	}
+}
+
+func TestDeliverDropsSlowClient(t *testing.T) {
+	h := newHub()
+
+	slow := h.Register(alice)
+	fast := h.Register(alice)
+
+	const total = 100
+	var delivered []notifications.Notification
+	for i := 0; i < total; i++ {
+		h.Deliver(notifications.Notification{ID: fmt.Sprint(i), RecipientID: alice})
+
+		notifs, closed := received(fast)
+		assert.False(t, closed, "a client keeping up should not be dropped")
+		delivered = append(delivered, notifs...)
+	}
+	assert.Len(t, delivered, total)
+
+	notifs, closed := received(slow)
+	assert.True(t, closed, "a client not keeping up should be dropped")
+	assert.NotEmpty(t, notifs, "a dropped client should still get what was buffered")
+	assert.Less(t, len(notifs), total)
+
+	assert.NotPanics(t, func() { h.Unregister(slow) }, "unregistering a dropped client should be safe")
+
+	h.Deliver(notifications.Notification{ID: "last", RecipientID: alice})
+	notifs, _ = received(fast)
+	assert.Len(t, notifs, 1, "dropping a client should not affect the others")
+}
+
+func TestUnregister(t *testing.T) {
+	h := newHub()
+
+	c := h.Register(alice, bob)
+	h.Unregister(c)
+
+	notifs, closed := received(c)
+	assert.Empty(t, notifs)
+	assert.True(t, closed)
+
+	assert.NotPanics(t, func() {
+		h.Deliver(notifications.Notification{ID: "1", RecipientID: alice})
+		h.Deliver(notifications.Notification{ID: "2", RecipientID: bob})
+		h.Unregister(c)
+	})
+}
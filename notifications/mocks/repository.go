@@ -7,6 +7,8 @@ import (
 
 	notifications "github.com/rodneyosodo/twiga/notifications"
 	mock "github.com/stretchr/testify/mock"
+
+	time "time"
 )
 
 // Repository is an autogenerated mock type for the Repository type
@@ -14,6 +16,92 @@ type Repository struct {
 	mock.Mock
 }
 
+// AggregateNotification provides a mock function with given fields: ctx, notification, since
+func (_m *Repository) AggregateNotification(ctx context.Context, notification notifications.Notification, since time.Time) (notifications.Notification, error) {
+	ret := _m.Called(ctx, notification, since)
+
+	if len(ret) == 0 {
+		panic("no return value specified for AggregateNotification")
+	}
+
+	var r0 notifications.Notification
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, notifications.Notification, time.Time) (notifications.Notification, error)); ok {
+		return rf(ctx, notification, since)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, notifications.Notification, time.Time) notifications.Notification); ok {
+		r0 = rf(ctx, notification, since)
+	} else {
+		r0 = ret.Get(0).(notifications.Notification)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, notifications.Notification, time.Time) error); ok {
+		r1 = rf(ctx, notification, since)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ClaimDeliveries provides a mock function with given fields: ctx, channel, limit, lease
+func (_m *Repository) ClaimDeliveries(ctx context.Context, channel string, limit uint64, lease time.Duration) ([]notifications.Delivery, error) {
+	ret := _m.Called(ctx, channel, limit, lease)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ClaimDeliveries")
+	}
+
+	var r0 []notifications.Delivery
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, uint64, time.Duration) ([]notifications.Delivery, error)); ok {
+		return rf(ctx, channel, limit, lease)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, uint64, time.Duration) []notifications.Delivery); ok {
+		r0 = rf(ctx, channel, limit, lease)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]notifications.Delivery)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, uint64, time.Duration) error); ok {
+		r1 = rf(ctx, channel, limit, lease)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ClaimDigest provides a mock function with given fields: ctx, userID, before, notificationIDs
+func (_m *Repository) ClaimDigest(ctx context.Context, userID string, before time.Time, notificationIDs []string) (bool, error) {
+	ret := _m.Called(ctx, userID, before, notificationIDs)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ClaimDigest")
+	}
+
+	var r0 bool
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Time, []string) (bool, error)); ok {
+		return rf(ctx, userID, before, notificationIDs)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Time, []string) bool); ok {
+		r0 = rf(ctx, userID, before, notificationIDs)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, time.Time, []string) error); ok {
+		r1 = rf(ctx, userID, before, notificationIDs)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // CreateNotification provides a mock function with given fields: ctx, notification
 func (_m *Repository) CreateNotification(ctx context.Context, notification notifications.Notification) (notifications.Notification, error) {
 	ret := _m.Called(ctx, notification)
@@ -60,6 +148,24 @@ func (_m *Repository) DeleteNotification(ctx context.Context, id string) error {
 	return r0
 }
 
+// DeleteSubscription provides a mock function with given fields: ctx, userID, id
+func (_m *Repository) DeleteSubscription(ctx context.Context, userID string, id string) error {
+	ret := _m.Called(ctx, userID, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteSubscription")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, userID, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // ReadAllNotifications provides a mock function with given fields: ctx, page
 func (_m *Repository) ReadAllNotifications(ctx context.Context, page notifications.Page) error {
 	ret := _m.Called(ctx, page)
@@ -124,6 +230,64 @@ func (_m *Repository) RetrieveAllNotifications(ctx context.Context, page notific
 	return r0, r1
 }
 
+// RetrieveDueSettings provides a mock function with given fields: ctx, digest, before
+func (_m *Repository) RetrieveDueSettings(ctx context.Context, digest notifications.Digest, before time.Time) ([]notifications.Setting, error) {
+	ret := _m.Called(ctx, digest, before)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RetrieveDueSettings")
+	}
+
+	var r0 []notifications.Setting
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, notifications.Digest, time.Time) ([]notifications.Setting, error)); ok {
+		return rf(ctx, digest, before)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, notifications.Digest, time.Time) []notifications.Setting); ok {
+		r0 = rf(ctx, digest, before)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]notifications.Setting)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, notifications.Digest, time.Time) error); ok {
+		r1 = rf(ctx, digest, before)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RetrieveEventNotification provides a mock function with given fields: ctx, eventID, recipientID
+func (_m *Repository) RetrieveEventNotification(ctx context.Context, eventID string, recipientID string) (notifications.Notification, error) {
+	ret := _m.Called(ctx, eventID, recipientID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RetrieveEventNotification")
+	}
+
+	var r0 notifications.Notification
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) (notifications.Notification, error)); ok {
+		return rf(ctx, eventID, recipientID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) notifications.Notification); ok {
+		r0 = rf(ctx, eventID, recipientID)
+	} else {
+		r0 = ret.Get(0).(notifications.Notification)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, eventID, recipientID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // RetrieveNotification provides a mock function with given fields: ctx, id
 func (_m *Repository) RetrieveNotification(ctx context.Context, id string) (notifications.Notification, error) {
 	ret := _m.Called(ctx, id)
@@ -152,6 +316,186 @@ func (_m *Repository) RetrieveNotification(ctx context.Context, id string) (noti
 	return r0, r1
 }
 
+// RetrieveSetting provides a mock function with given fields: ctx, userID
+func (_m *Repository) RetrieveSetting(ctx context.Context, userID string) (notifications.Setting, error) {
+	ret := _m.Called(ctx, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RetrieveSetting")
+	}
+
+	var r0 notifications.Setting
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (notifications.Setting, error)); ok {
+		return rf(ctx, userID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) notifications.Setting); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		r0 = ret.Get(0).(notifications.Setting)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RetrieveSubscriptions provides a mock function with given fields: ctx, userID
+func (_m *Repository) RetrieveSubscriptions(ctx context.Context, userID string) ([]notifications.Subscription, error) {
+	ret := _m.Called(ctx, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RetrieveSubscriptions")
+	}
+
+	var r0 []notifications.Subscription
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) ([]notifications.Subscription, error)); ok {
+		return rf(ctx, userID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) []notifications.Subscription); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]notifications.Subscription)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RetrieveUndigested provides a mock function with given fields: ctx, recipientID, limit
+func (_m *Repository) RetrieveUndigested(ctx context.Context, recipientID string, limit uint64) ([]notifications.Notification, error) {
+	ret := _m.Called(ctx, recipientID, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RetrieveUndigested")
+	}
+
+	var r0 []notifications.Notification
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, uint64) ([]notifications.Notification, error)); ok {
+		return rf(ctx, recipientID, limit)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, uint64) []notifications.Notification); ok {
+		r0 = rf(ctx, recipientID, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]notifications.Notification)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, uint64) error); ok {
+		r1 = rf(ctx, recipientID, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SaveDelivery provides a mock function with given fields: ctx, delivery
+func (_m *Repository) SaveDelivery(ctx context.Context, delivery notifications.Delivery) error {
+	ret := _m.Called(ctx, delivery)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SaveDelivery")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, notifications.Delivery) error); ok {
+		r0 = rf(ctx, delivery)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SaveSetting provides a mock function with given fields: ctx, setting
+func (_m *Repository) SaveSetting(ctx context.Context, setting notifications.Setting) (notifications.Setting, error) {
+	ret := _m.Called(ctx, setting)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SaveSetting")
+	}
+
+	var r0 notifications.Setting
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, notifications.Setting) (notifications.Setting, error)); ok {
+		return rf(ctx, setting)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, notifications.Setting) notifications.Setting); ok {
+		r0 = rf(ctx, setting)
+	} else {
+		r0 = ret.Get(0).(notifications.Setting)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, notifications.Setting) error); ok {
+		r1 = rf(ctx, setting)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SaveSubscription provides a mock function with given fields: ctx, subscription
+func (_m *Repository) SaveSubscription(ctx context.Context, subscription notifications.Subscription) (notifications.Subscription, error) {
+	ret := _m.Called(ctx, subscription)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SaveSubscription")
+	}
+
+	var r0 notifications.Subscription
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, notifications.Subscription) (notifications.Subscription, error)); ok {
+		return rf(ctx, subscription)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, notifications.Subscription) notifications.Subscription); ok {
+		r0 = rf(ctx, subscription)
+	} else {
+		r0 = ret.Get(0).(notifications.Subscription)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, notifications.Subscription) error); ok {
+		r1 = rf(ctx, subscription)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// WithTx provides a mock function with given fields: ctx, fn
+func (_m *Repository) WithTx(ctx context.Context, fn func(context.Context) error) error {
+	ret := _m.Called(ctx, fn)
+
+	if len(ret) == 0 {
+		panic("no return value specified for WithTx")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, func(context.Context) error) error); ok {
+		r0 = rf(ctx, fn)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // NewRepository creates a new instance of Repository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewRepository(t interface {
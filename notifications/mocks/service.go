@@ -42,6 +42,34 @@ func (_m *Service) CreateNotification(ctx context.Context, notification notifica
 	return r0, r1
 }
 
+// CreateSubscription provides a mock function with given fields: ctx, token, subscription
+func (_m *Service) CreateSubscription(ctx context.Context, token string, subscription notifications.Subscription) (notifications.Subscription, error) {
+	ret := _m.Called(ctx, token, subscription)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateSubscription")
+	}
+
+	var r0 notifications.Subscription
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, notifications.Subscription) (notifications.Subscription, error)); ok {
+		return rf(ctx, token, subscription)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, notifications.Subscription) notifications.Subscription); ok {
+		r0 = rf(ctx, token, subscription)
+	} else {
+		r0 = ret.Get(0).(notifications.Subscription)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, notifications.Subscription) error); ok {
+		r1 = rf(ctx, token, subscription)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // DeleteNotification provides a mock function with given fields: ctx, token, id
 func (_m *Service) DeleteNotification(ctx context.Context, token string, id string) error {
 	ret := _m.Called(ctx, token, id)
@@ -60,19 +88,19 @@ func (_m *Service) DeleteNotification(ctx context.Context, token string, id stri
 	return r0
 }
 
-// GetNewNotification provides a mock function with given fields: ctx, userID
-func (_m *Service) GetNewNotification(ctx context.Context, userID string) notifications.Notification {
-	ret := _m.Called(ctx, userID)
+// DeleteSubscription provides a mock function with given fields: ctx, token, id
+func (_m *Service) DeleteSubscription(ctx context.Context, token string, id string) error {
+	ret := _m.Called(ctx, token, id)
 
 	if len(ret) == 0 {
-		panic("no return value specified for GetNewNotification")
+		panic("no return value specified for DeleteSubscription")
 	}
 
-	var r0 notifications.Notification
-	if rf, ok := ret.Get(0).(func(context.Context, string) notifications.Notification); ok {
-		r0 = rf(ctx, userID)
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(ctx, token, id)
 	} else {
-		r0 = ret.Get(0).(notifications.Notification)
+		r0 = ret.Error(0)
 	}
 
 	return r0
@@ -106,6 +134,42 @@ func (_m *Service) IdentifyUser(ctx context.Context, token string) (string, erro
 	return r0, r1
 }
 
+// InvalidateSetting provides a mock function with given fields: ctx, userID
+func (_m *Service) InvalidateSetting(ctx context.Context, userID string) error {
+	ret := _m.Called(ctx, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for InvalidateSetting")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ProcessDeliveries provides a mock function with given fields: ctx
+func (_m *Service) ProcessDeliveries(ctx context.Context) error {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ProcessDeliveries")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // ReadAllNotifications provides a mock function with given fields: ctx, token, page
 func (_m *Service) ReadAllNotifications(ctx context.Context, token string, page notifications.Page) error {
 	ret := _m.Called(ctx, token, page)
@@ -198,6 +262,80 @@ func (_m *Service) RetrieveNotification(ctx context.Context, token string, id st
 	return r0, r1
 }
 
+// RetrieveSetting provides a mock function with given fields: ctx, token
+func (_m *Service) RetrieveSetting(ctx context.Context, token string) (notifications.Setting, error) {
+	ret := _m.Called(ctx, token)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RetrieveSetting")
+	}
+
+	var r0 notifications.Setting
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (notifications.Setting, error)); ok {
+		return rf(ctx, token)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) notifications.Setting); ok {
+		r0 = rf(ctx, token)
+	} else {
+		r0 = ret.Get(0).(notifications.Setting)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, token)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SendDigests provides a mock function with given fields: ctx, digest
+func (_m *Service) SendDigests(ctx context.Context, digest notifications.Digest) error {
+	ret := _m.Called(ctx, digest)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SendDigests")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, notifications.Digest) error); ok {
+		r0 = rf(ctx, digest)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateDigest provides a mock function with given fields: ctx, token, digest
+func (_m *Service) UpdateDigest(ctx context.Context, token string, digest notifications.Digest) (notifications.Setting, error) {
+	ret := _m.Called(ctx, token, digest)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateDigest")
+	}
+
+	var r0 notifications.Setting
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, notifications.Digest) (notifications.Setting, error)); ok {
+		return rf(ctx, token, digest)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, notifications.Digest) notifications.Setting); ok {
+		r0 = rf(ctx, token, digest)
+	} else {
+		r0 = ret.Get(0).(notifications.Setting)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, notifications.Digest) error); ok {
+		r1 = rf(ctx, token, digest)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // NewService creates a new instance of Service. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewService(t interface {
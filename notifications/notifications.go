@@ -10,9 +10,17 @@ package notifications
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"time"
 )
 
+var (
+	// ErrNotFound is returned when the notification does not exist.
+	ErrNotFound = errors.New("notification not found")
+	// ErrSettingNotFound is returned when the user has no stored setting.
+	ErrSettingNotFound = errors.New("setting not found")
+)
+
 type Category uint8
 
 const (
@@ -51,24 +59,50 @@ func (c Category) MarshalJSON() ([]byte, error) {
 	return json.Marshal(c.String())
 }
 
+func (c *Category) UnmarshalJSON(data []byte) error {
+	var category string
+	if err := json.Unmarshal(data, &category); err != nil {
+		return err
+	}
+	*c = ToCategory(category)
+
+	return nil
+}
+
+// MaxRecentActors is the number of actors kept on an aggregated notification.
+const MaxRecentActors = 3
+
+// Notification is sent to the recipient about the actions of other users.
+// Notifications about the same post are aggregated, in which case ActorID is
+// the latest actor, Actors the most recent ones first and ActorCount all of them.
+// EventID is the event the notification was created or aggregated for, which is
+// recorded so that the event does not notify the recipient again when it is replayed.
 type Notification struct {
-	ID        string    `json:"id"`
-	UserID    string    `json:"user_id"`
-	Category  Category  `json:"category"`
-	Content   string    `json:"content"`
-	IsRead    bool      `json:"is_read"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID          string    `json:"id"`
+	ActorID     string    `json:"actor_id"`
+	RecipientID string    `json:"recipient_id"`
+	PostID      string    `json:"post_id,omitempty"`
+	Category    Category  `json:"category"`
+	Content     string    `json:"content"`
+	ActorCount  uint64    `json:"actor_count"`
+	Actors      []string  `json:"actors"`
+	IsRead      bool      `json:"is_read"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	EventID     string    `json:"-"`
 }
 
 type Page struct {
-	Total    uint64   `db:"total"              json:"total"`
-	Offset   uint64   `db:"offset"             json:"offset"`
-	Limit    uint64   `db:"limit"              json:"limit"`
-	Category Category `db:"category,omitempty" json:"category,omitempty"`
-	UserID   string   `db:"user_id,omitempty"  json:"user_id,omitempty"`
-	IDs      []string `db:"ids,omitempty"      json:"ids,omitempty"`
-	IsRead   *bool    `db:"is_read,omitempty"  json:"is_read,omitempty"`
+	Total       uint64   `db:"total"                  json:"total"`
+	Offset      uint64   `db:"offset"                 json:"offset"`
+	Limit       uint64   `db:"limit"                  json:"limit"`
+	Cursor      string   `db:"-"                      json:"cursor,omitempty"`
+	NextCursor  string   `db:"-"                      json:"next_cursor,omitempty"`
+	After       string   `db:"-"                      json:"after,omitempty"`
+	Category    Category `db:"category,omitempty"     json:"category,omitempty"`
+	ActorID     string   `db:"actor_id,omitempty"     json:"actor_id,omitempty"`
+	RecipientID string   `db:"recipient_id,omitempty" json:"recipient_id,omitempty"`
+	IsRead      *bool    `db:"is_read,omitempty"      json:"is_read,omitempty"`
 }
 
 type NotificationsPage struct {
@@ -91,11 +125,70 @@ func (page NotificationsPage) MarshalJSON() ([]byte, error) {
 	return json.Marshal(a)
 }
 
+const (
+	EmailChannel  = "email"
+	DigestChannel = "digest"
+
+	PendingStatus   = "pending"
+	DeliveredStatus = "delivered"
+	FailedStatus    = "failed"
+)
+
+// Delivery records the outcome of sending a notification through a channel.
+// Pending deliveries are queued until their next attempt is due.
+type Delivery struct {
+	NotificationID string    `json:"notification_id"`
+	Channel        string    `json:"channel"`
+	Status         string    `json:"status"`
+	Attempts       uint64    `json:"attempts"`
+	Error          string    `json:"error,omitempty"`
+	NextAttemptAt  time.Time `json:"next_attempt_at"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// Subscription is a browser push subscription as returned by PushManager.subscribe.
+type Subscription struct {
+	ID        string           `json:"id"`
+	UserID    string           `json:"user_id"`
+	Endpoint  string           `json:"endpoint"`
+	Keys      SubscriptionKeys `json:"keys"`
+	CreatedAt time.Time        `json:"created_at"`
+}
+
+type SubscriptionKeys struct {
+	P256dh string `json:"p256dh"`
+	Auth   string `json:"auth"`
+}
+
+// Digest is how often a user wants to receive notification emails.
+type Digest string
+
+const (
+	ImmediateDigest Digest = "immediate"
+	DailyDigest     Digest = "daily"
+	WeeklyDigest    Digest = "weekly"
+)
+
+// Period returns the time between two digests, zero for immediate emails.
+func (d Digest) Period() time.Duration {
+	switch d {
+	case DailyDigest:
+		return 24 * time.Hour
+	case WeeklyDigest:
+		return 7 * 24 * time.Hour
+	default:
+		return 0
+	}
+}
+
 type Setting struct {
 	ID             string    `json:"id"`
 	UserID         string    `json:"user_id"`
 	IsEmailEnabled bool      `json:"is_email_enabled"`
 	IsPushEnabled  bool      `json:"is_push_enabled"`
+	Digest         Digest    `json:"digest"`
+	DigestedAt     time.Time `json:"digested_at"`
 	CreatedAt      time.Time `json:"created_at"`
 	UpdatedAt      time.Time `json:"updated_at"`
 }
@@ -122,19 +215,85 @@ func (page SettingsPage) MarshalJSON() ([]byte, error) {
 
 //go:generate mockery --name Repository --output=./mocks --filename repository.go --quiet
 type Repository interface {
+	// WithTx runs fn in a transaction that the writes made with the context passed to fn join.
+	WithTx(ctx context.Context, fn func(ctx context.Context) error) error
 	CreateNotification(ctx context.Context, notification Notification) (Notification, error)
+	// RetrieveEventNotification returns the notification the event was recorded
+	// for the recipient with, or ErrNotFound when it was not.
+	RetrieveEventNotification(ctx context.Context, eventID, recipientID string) (Notification, error)
+	// AggregateNotification adds the actor to the latest unread notification created
+	// after since with the same recipient, category and post, and bumps its update
+	// time. It returns ErrNotFound when there is none. In a transaction, notifications
+	// with the same recipient, category and post are not aggregated elsewhere until the
+	// transaction ends, so that a concurrent one does not create one meanwhile.
+	AggregateNotification(ctx context.Context, notification Notification, since time.Time) (Notification, error)
 	RetrieveNotification(ctx context.Context, id string) (Notification, error)
 	RetrieveAllNotifications(ctx context.Context, page Page) (NotificationsPage, error)
 	ReadNotification(ctx context.Context, userID, id string) error
 	ReadAllNotifications(ctx context.Context, page Page) error
 	DeleteNotification(ctx context.Context, id string) error
+	SaveDelivery(ctx context.Context, delivery Delivery) error
+	// ClaimDeliveries returns up to limit pending deliveries of the channel that are
+	// due, postponing their next attempt by the lease so that no one else claims
+	// them while they are attempted.
+	ClaimDeliveries(ctx context.Context, channel string, limit uint64, lease time.Duration) ([]Delivery, error)
+
+	SaveSetting(ctx context.Context, setting Setting) (Setting, error)
+	RetrieveSetting(ctx context.Context, userID string) (Setting, error)
+	// RetrieveDueSettings returns the settings of the users with the digest
+	// whose last digest was sent before the given time.
+	RetrieveDueSettings(ctx context.Context, digest Digest, before time.Time) ([]Setting, error)
+	// RetrieveUndigested returns up to limit unread notifications of the recipient
+	// that were neither emailed, queued to be emailed nor included in a digest,
+	// oldest first.
+	RetrieveUndigested(ctx context.Context, recipientID string, limit uint64) ([]Notification, error)
+	// ClaimDigest closes the digest period of the user and records the notifications
+	// included in the digest as pending digest deliveries. It returns false without
+	// recording anything when the digest was already claimed after the given time.
+	ClaimDigest(ctx context.Context, userID string, before time.Time, notificationIDs []string) (bool, error)
+
+	// SaveSubscription stores the subscription, moving it to the user when the endpoint is already registered.
+	SaveSubscription(ctx context.Context, subscription Subscription) (Subscription, error)
+	RetrieveSubscriptions(ctx context.Context, userID string) ([]Subscription, error)
+	DeleteSubscription(ctx context.Context, userID, id string) error
+}
+
+//go:generate mockery --name Notifier --output=./mocks --filename notifier.go --quiet
+type Notifier interface {
+	// Notify delivers a stored notification through the channel, such as
+	// the connected clients or the email address of the recipient.
+	Notify(ctx context.Context, notification Notification) error
+}
+
+//go:generate mockery --name Mailer --output=./mocks --filename mailer.go --quiet
+type Mailer interface {
+	Notifier
+	// SendDigest emails the recipient a summary of the notifications grouped by category.
+	SendDigest(ctx context.Context, recipientID string, digest Digest, notifications map[Category][]Notification) error
+}
+
+//go:generate mockery --name PostsClient --output=./mocks --filename posts.go --quiet
+type PostsClient interface {
+	// RetrievePostOwner returns the author of the post, or an empty ID once the
+	// post has been deleted.
+	RetrievePostOwner(ctx context.Context, postID string) (string, error)
 }
 
 //go:generate mockery --name Service --output=./mocks --filename service.go --quiet
 type Service interface {
 	CreateNotification(ctx context.Context, notification Notification) (Notification, error)
 	IdentifyUser(ctx context.Context, token string) (string, error)
-	GetNewNotification(ctx context.Context, userID string) Notification
+	// InvalidateSetting drops the cached delivery preferences of the user.
+	InvalidateSetting(ctx context.Context, userID string) error
+	RetrieveSetting(ctx context.Context, token string) (Setting, error)
+	UpdateDigest(ctx context.Context, token string, digest Digest) (Setting, error)
+	// SendDigests emails the users with the digest that are due one a summary
+	// of their unread notifications.
+	SendDigests(ctx context.Context, digest Digest) error
+	// ProcessDeliveries sends the queued emails that are due.
+	ProcessDeliveries(ctx context.Context) error
+	CreateSubscription(ctx context.Context, token string, subscription Subscription) (Subscription, error)
+	DeleteSubscription(ctx context.Context, token string, id string) error
 	RetrieveNotification(ctx context.Context, token string, id string) (Notification, error)
 	RetrieveAllNotifications(ctx context.Context, token string, page Page) (NotificationsPage, error)
 	ReadNotification(ctx context.Context, token string, id string) error
@@ -0,0 +1,72 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package posts
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/rodneyosodo/twiga/notifications"
+)
+
+var _ notifications.PostsClient = (*client)(nil)
+
+type client struct {
+	url        string
+	token      string
+	httpClient *http.Client
+}
+
+type post struct {
+	UserID string `json:"user_id"`
+}
+
+// NewClient returns a client of the admin endpoints of the Post Service, which
+// it authenticates to with the admin token.
+func NewClient(url, token string, timeout time.Duration) notifications.PostsClient {
+	return &client{
+		url:        strings.TrimSuffix(url, "/"),
+		token:      token,
+		httpClient: &http.Client{Timeout: timeout},
+	}
+}
+
+func (c *client) RetrievePostOwner(ctx context.Context, postID string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/admin/posts/%s", c.url, url.PathEscape(postID)), http.NoBody)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return "", nil
+	default:
+		return "", errors.New("failed to retrieve post: " + resp.Status)
+	}
+
+	var p post
+	if err := json.NewDecoder(resp.Body).Decode(&p); err != nil {
+		return "", err
+	}
+
+	return p.UserID, nil
+}
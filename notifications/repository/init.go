@@ -34,6 +34,150 @@ func Migration() *migrate.MemoryMigrationSource {
 					`DROP TABLE IF EXISTS notifications CASCADE`,
 				},
 			},
+			{
+				Id: "notifications_02",
+				Up: []string{
+					`ALTER TABLE notifications RENAME COLUMN user_id TO actor_id`,
+					`ALTER INDEX idx_notifications_user_id RENAME TO idx_notifications_actor_id`,
+					// Existing rows hold the actor instead of the recipient, which cannot be
+					// resolved, so they are kept without one and are not listed to anybody.
+					`ALTER TABLE notifications ADD COLUMN recipient_id UUID`,
+					`CREATE INDEX idx_notifications_recipient_id ON notifications(recipient_id);`,
+				},
+				Down: []string{
+					`DROP INDEX IF EXISTS idx_notifications_recipient_id`,
+					`ALTER TABLE notifications DROP COLUMN recipient_id`,
+					`ALTER INDEX idx_notifications_actor_id RENAME TO idx_notifications_user_id`,
+					`ALTER TABLE notifications RENAME COLUMN actor_id TO user_id`,
+				},
+			},
+			{
+				Id: "notifications_03",
+				Up: []string{
+					`CREATE TABLE IF NOT EXISTS deliveries (
+						notification_id UUID NOT NULL,
+						channel VARCHAR(32) NOT NULL,
+						status VARCHAR(32) NOT NULL,
+						error TEXT NOT NULL DEFAULT '',
+						created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+						updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+						PRIMARY KEY (notification_id, channel),
+						FOREIGN KEY (notification_id) REFERENCES notifications(id) ON DELETE CASCADE
+					)`,
+				},
+				Down: []string{
+					`DROP TABLE IF EXISTS deliveries`,
+				},
+			},
+			{
+				Id: "notifications_04",
+				Up: []string{
+					`CREATE TABLE IF NOT EXISTS settings (
+						user_id UUID PRIMARY KEY NOT NULL,
+						digest VARCHAR(32) NOT NULL DEFAULT 'immediate',
+						digested_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+						created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+						updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+					)`,
+					`CREATE INDEX idx_settings_digest ON settings(digest, digested_at);`,
+				},
+				Down: []string{
+					`DROP TABLE IF EXISTS settings`,
+				},
+			},
+			{
+				Id: "notifications_05",
+				Up: []string{
+					`ALTER TABLE notifications ADD COLUMN post_id VARCHAR(254) NOT NULL DEFAULT ''`,
+					`ALTER TABLE notifications ADD COLUMN actor_count BIGINT NOT NULL DEFAULT 1`,
+					`ALTER TABLE notifications ADD COLUMN actors TEXT[] NOT NULL DEFAULT '{}'`,
+					`UPDATE notifications SET actors = ARRAY[actor_id::TEXT]`,
+					`CREATE INDEX idx_notifications_aggregate ON notifications(recipient_id, category, post_id, created_at);`,
+				},
+				Down: []string{
+					`DROP INDEX IF EXISTS idx_notifications_aggregate`,
+					`ALTER TABLE notifications DROP COLUMN actors`,
+					`ALTER TABLE notifications DROP COLUMN actor_count`,
+					`ALTER TABLE notifications DROP COLUMN post_id`,
+				},
+			},
+			{
+				Id: "notifications_06",
+				Up: []string{
+					`CREATE TABLE IF NOT EXISTS subscriptions (
+						id UUID PRIMARY KEY NOT NULL DEFAULT gen_random_uuid(),
+						user_id UUID NOT NULL,
+						endpoint TEXT NOT NULL UNIQUE CHECK (endpoint <> ''),
+						p256dh TEXT NOT NULL CHECK (p256dh <> ''),
+						auth TEXT NOT NULL CHECK (auth <> ''),
+						created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+					)`,
+					`CREATE INDEX idx_subscriptions_user_id ON subscriptions(user_id);`,
+				},
+				Down: []string{
+					`DROP TABLE IF EXISTS subscriptions`,
+				},
+			},
+			{
+				Id: "notifications_07",
+				Up: []string{
+					`CREATE TABLE IF NOT EXISTS inbox (
+						id VARCHAR(254) PRIMARY KEY,
+						created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+					)`,
+				},
+				Down: []string{
+					`DROP TABLE IF EXISTS inbox`,
+				},
+			},
+			{
+				Id: "notifications_08",
+				Up: []string{
+					`ALTER TABLE deliveries ADD COLUMN attempts BIGINT NOT NULL DEFAULT 0`,
+					`ALTER TABLE deliveries ADD COLUMN next_attempt_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP`,
+					`CREATE INDEX idx_deliveries_status_next_attempt_at ON deliveries(status, next_attempt_at);`,
+				},
+				Down: []string{
+					`DROP INDEX IF EXISTS idx_deliveries_status_next_attempt_at`,
+					`ALTER TABLE deliveries DROP COLUMN next_attempt_at`,
+					`ALTER TABLE deliveries DROP COLUMN attempts`,
+				},
+			},
+			{
+				Id: "notifications_09",
+				Up: []string{
+					`CREATE INDEX idx_inbox_created_at ON inbox(created_at);`,
+				},
+				Down: []string{
+					`DROP INDEX IF EXISTS idx_inbox_created_at`,
+				},
+			},
+			{
+				Id: "notifications_10",
+				Up: []string{
+					`CREATE INDEX idx_notifications_recipient_updated_at ON notifications(recipient_id, updated_at, id);`,
+				},
+				Down: []string{
+					`DROP INDEX IF EXISTS idx_notifications_recipient_updated_at`,
+				},
+			},
+			{
+				Id: "notifications_11",
+				Up: []string{
+					`CREATE TABLE IF NOT EXISTS notification_events (
+						event_id VARCHAR(254) NOT NULL,
+						recipient_id UUID NOT NULL,
+						notification_id UUID NOT NULL,
+						created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+						PRIMARY KEY (event_id, recipient_id),
+						FOREIGN KEY (notification_id) REFERENCES notifications(id) ON DELETE CASCADE
+					)`,
+					`CREATE INDEX idx_notification_events_notification_id ON notification_events(notification_id);`,
+				},
+				Down: []string{
+					`DROP TABLE IF EXISTS notification_events`,
+				},
+			},
 		},
 	}
 }
@@ -15,6 +15,7 @@ import (
 	"time"
 
 	"github.com/jackc/pgtype"
+	"github.com/rodneyosodo/twiga/internal/cursor"
 	"github.com/rodneyosodo/twiga/internal/postgres"
 	"github.com/rodneyosodo/twiga/notifications"
 )
@@ -29,10 +30,20 @@ func NewRepository(db postgres.Database) *repository {
 	return &repository{db}
 }
 
+func (r *repository) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return postgres.WithTx(ctx, r.Database, fn)
+}
+
 func (r *repository) CreateNotification(ctx context.Context, notification notifications.Notification) (notifications.Notification, error) {
-	query := `INSERT INTO notifications (user_id, category, content, is_read)
-			VALUES (:user_id, :category, :content, :is_read)
-			RETURNING *`
+	query := `WITH created AS (
+				INSERT INTO notifications (actor_id, recipient_id, post_id, category, content, is_read, actor_count, actors)
+				VALUES (:actor_id, :recipient_id, :post_id, :category, :content, :is_read, 1, ARRAY[CAST(:actor_id AS TEXT)])
+				RETURNING *
+			), recorded AS (
+				INSERT INTO notification_events (event_id, recipient_id, notification_id)
+				SELECT CAST(:event_id AS TEXT), recipient_id, id FROM created WHERE CAST(:event_id AS TEXT) <> ''
+			)
+			SELECT * FROM created`
 	dNotification, err := toDBNotification(notification)
 	if err != nil {
 		return notifications.Notification{}, err
@@ -54,6 +65,89 @@ func (r *repository) CreateNotification(ctx context.Context, notification notifi
 	return dNotification.toNotification(), nil
 }
 
+func (r *repository) AggregateNotification(ctx context.Context, notification notifications.Notification, since time.Time) (notifications.Notification, error) {
+	dNotification, err := toDBNotification(notification)
+	if err != nil {
+		return notifications.Notification{}, err
+	}
+
+	// The lock is held until the transaction ends, so concurrent notifications
+	// about the same post wait for the one that creates the aggregate.
+	lock := `SELECT pg_advisory_xact_lock(hashtext(CAST(:recipient_id AS TEXT) || '/' || CAST(:category AS TEXT) || '/' || CAST(:post_id AS TEXT)))`
+	if _, err := r.NamedExecContext(ctx, lock, dNotification); err != nil {
+		return notifications.Notification{}, err
+	}
+
+	// An actor that is already among the recent actors moves to the front without being counted again.
+	query := fmt.Sprintf(`WITH aggregated AS (
+				UPDATE notifications SET
+					actor_id = :actor_id,
+					content = :content,
+					actor_count = actor_count + CASE WHEN CAST(:actor_id AS TEXT) = ANY(actors) THEN 0 ELSE 1 END,
+					actors = (ARRAY[CAST(:actor_id AS TEXT)] || array_remove(actors, CAST(:actor_id AS TEXT)))[1:%d],
+					updated_at = CURRENT_TIMESTAMP
+				WHERE id = (
+					SELECT id FROM notifications
+					WHERE recipient_id = :recipient_id AND category = :category AND post_id = :post_id
+					AND is_read = FALSE AND created_at >= :since
+					ORDER BY created_at DESC LIMIT 1
+				)
+				RETURNING *
+			), recorded AS (
+				INSERT INTO notification_events (event_id, recipient_id, notification_id)
+				SELECT CAST(:event_id AS TEXT), recipient_id, id FROM aggregated WHERE CAST(:event_id AS TEXT) <> ''
+			)
+			SELECT * FROM aggregated`, notifications.MaxRecentActors)
+	dAggregate := dbAggregate{
+		dbNotification: dNotification,
+		Since:          since,
+	}
+
+	rows, err := r.NamedQueryContext(ctx, query, dAggregate)
+	if err != nil {
+		return notifications.Notification{}, err
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		var dNotification dbNotification
+		if err := rows.StructScan(&dNotification); err != nil {
+			return notifications.Notification{}, err
+		}
+
+		return dNotification.toNotification(), nil
+	}
+
+	return notifications.Notification{}, notifications.ErrNotFound
+}
+
+func (r *repository) RetrieveEventNotification(ctx context.Context, eventID, recipientID string) (notifications.Notification, error) {
+	query := `SELECT n.* FROM notifications n
+			JOIN notification_events e ON e.notification_id = n.id
+			WHERE e.event_id = :event_id AND e.recipient_id = :recipient_id`
+	dNotification := dbNotification{
+		EventID:     eventID,
+		RecipientID: pgtype.Text{String: recipientID, Status: pgtype.Present},
+	}
+
+	rows, err := r.NamedQueryContext(ctx, query, dNotification)
+	if err != nil {
+		return notifications.Notification{}, err
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		var dNotification dbNotification
+		if err := rows.StructScan(&dNotification); err != nil {
+			return notifications.Notification{}, err
+		}
+
+		return dNotification.toNotification(), nil
+	}
+
+	return notifications.Notification{}, notifications.ErrNotFound
+}
+
 func (r *repository) RetrieveNotification(ctx context.Context, id string) (notifications.Notification, error) {
 	query := `SELECT * FROM notifications WHERE id = :id`
 	dNotification := dbNotification{
@@ -75,34 +169,42 @@ func (r *repository) RetrieveNotification(ctx context.Context, id string) (notif
 		return dNotification.toNotification(), nil
 	}
 
-	return notifications.Notification{}, errors.New("notification not found")
+	return notifications.Notification{}, notifications.ErrNotFound
 }
 
 func (r *repository) RetrieveAllNotifications(ctx context.Context, page notifications.Page) (npage notifications.NotificationsPage, err error) {
+	dPage, err := toDBPage(page)
+	if err != nil {
+		return notifications.NotificationsPage{}, err
+	}
+
 	filter := ""
 	filters := []string{}
-	if page.UserID != "" {
-		filters = append(filters, fmt.Sprintf("user_id = '%s'", page.UserID))
+	if page.ActorID != "" {
+		filters = append(filters, "actor_id = :actor_id")
+	}
+	if page.RecipientID != "" {
+		filters = append(filters, "recipient_id = :recipient_id")
 	}
 	if page.Category.String() != "" {
-		filters = append(filters, fmt.Sprintf("category = '%s'", page.Category.String()))
+		filters = append(filters, "category = :category")
 	}
 	if page.IsRead != nil {
-		filters = append(filters, fmt.Sprintf("is_read = %t", *page.IsRead))
+		filters = append(filters, "is_read = :is_read")
 	}
-	if page.IDs != nil {
-		filters = append(filters, fmt.Sprintf("user_id IN ('%s')", strings.Join(page.IDs, "','")))
+	if page.After != "" {
+		filters = append(filters, "(updated_at, id) > (:after_updated_at, :after_id)")
 	}
 	if len(filters) > 0 {
 		filter = fmt.Sprintf("WHERE %s", strings.Join(filters, " AND "))
 	}
-
-	query := fmt.Sprintf(`SELECT * FROM notifications %s ORDER BY created_at DESC LIMIT :limit OFFSET :offset`, filter)
-
-	dPage := notifications.NotificationsPage{
-		Page: page,
+	cfilter := filter
+	if page.Cursor != "" {
+		cfilter = fmt.Sprintf("WHERE %s", strings.Join(append(filters, postgres.CursorFilter("id")), " AND "))
 	}
 
+	query := fmt.Sprintf(`SELECT * FROM notifications %s ORDER BY created_at DESC, id DESC LIMIT :limit OFFSET :offset`, cfilter)
+
 	rows, err := r.NamedQueryContext(ctx, query, dPage)
 	if err != nil {
 		return notifications.NotificationsPage{}, err
@@ -118,29 +220,36 @@ func (r *repository) RetrieveAllNotifications(ctx context.Context, page notifica
 
 		items = append(items, dNotification.toNotification())
 	}
+	items, next := cursor.Trim(items, page.Limit, func(n notifications.Notification) cursor.Cursor {
+		return cursor.Cursor{CreatedAt: n.CreatedAt, ID: n.ID}
+	})
 
-	totalQuery := fmt.Sprintf(`SELECT COUNT(*) FROM notifications %s`, filter)
+	total := uint64(0)
+	if page.Cursor == "" {
+		totalQuery := fmt.Sprintf(`SELECT COUNT(*) FROM notifications %s`, filter)
 
-	total, err := postgres.Total(ctx, r.Database, totalQuery, dPage)
-	if err != nil {
-		return notifications.NotificationsPage{}, err
+		total, err = postgres.Total(ctx, r.Database, totalQuery, dPage)
+		if err != nil {
+			return notifications.NotificationsPage{}, err
+		}
 	}
 
 	return notifications.NotificationsPage{
 		Page: notifications.Page{
-			Limit:  page.Limit,
-			Offset: page.Offset,
-			Total:  total,
+			Limit:      page.Limit,
+			Offset:     page.Offset,
+			Total:      total,
+			NextCursor: next,
 		},
 		Notifications: items,
 	}, nil
 }
 
 func (r *repository) ReadNotification(ctx context.Context, userID, id string) error {
-	query := `UPDATE notifications SET is_read = TRUE WHERE id = :id AND user_id = :user_id`
+	query := `UPDATE notifications SET is_read = TRUE WHERE id = :id AND recipient_id = :recipient_id`
 	dNotification := dbNotification{
-		ID:     id,
-		UserID: userID,
+		ID:          id,
+		RecipientID: pgtype.Text{String: userID, Status: pgtype.Present},
 	}
 
 	result, err := r.NamedExecContext(ctx, query, dNotification)
@@ -157,20 +266,21 @@ func (r *repository) ReadNotification(ctx context.Context, userID, id string) er
 func (r *repository) ReadAllNotifications(ctx context.Context, page notifications.Page) error {
 	filter := ""
 	filters := []string{}
-	if page.UserID != "" {
-		filters = append(filters, fmt.Sprintf("user_id = '%s'", page.UserID))
+	if page.ActorID != "" {
+		filters = append(filters, "actor_id = :actor_id")
+	}
+	if page.RecipientID != "" {
+		filters = append(filters, "recipient_id = :recipient_id")
 	}
 	if page.Category.String() != "" {
-		filters = append(filters, fmt.Sprintf("category = '%s'", page.Category.String()))
+		filters = append(filters, "category = :category")
 	}
 	if len(filters) > 0 {
 		filter = fmt.Sprintf("WHERE %s", strings.Join(filters, " AND "))
 	}
 
 	query := fmt.Sprintf(`UPDATE notifications SET is_read = TRUE %s`, filter)
-	dPage := notifications.NotificationsPage{
-		Page: page,
-	}
+	dPage := dbPage{Page: page, Category: page.Category.String()}
 
 	result, err := r.NamedExecContext(ctx, query, dPage)
 	if err != nil {
@@ -200,25 +310,137 @@ func (r *repository) DeleteNotification(ctx context.Context, id string) error {
 	return nil
 }
 
+func (r *repository) SaveDelivery(ctx context.Context, delivery notifications.Delivery) error {
+	query := `INSERT INTO deliveries (notification_id, channel, status, attempts, error, next_attempt_at)
+			VALUES (:notification_id, :channel, :status, :attempts, :error, :next_attempt_at)
+			ON CONFLICT (notification_id, channel)
+			DO UPDATE SET status = EXCLUDED.status, attempts = EXCLUDED.attempts, error = EXCLUDED.error,
+				next_attempt_at = EXCLUDED.next_attempt_at, updated_at = CURRENT_TIMESTAMP`
+
+	if _, err := r.NamedExecContext(ctx, query, toDBDelivery(delivery)); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *repository) ClaimDeliveries(ctx context.Context, channel string, limit uint64, lease time.Duration) ([]notifications.Delivery, error) {
+	// Locked rows are skipped so that concurrent dispatchers claim disjoint deliveries.
+	query := fmt.Sprintf(`UPDATE deliveries SET next_attempt_at = :until
+			WHERE (notification_id, channel) IN (
+				SELECT notification_id, channel FROM deliveries
+				WHERE channel = :channel AND status = '%s' AND next_attempt_at <= :now
+				ORDER BY next_attempt_at LIMIT :limit
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING *`, notifications.PendingStatus)
+	now := time.Now().UTC()
+	dClaim := dbClaim{
+		Channel: channel,
+		Now:     now,
+		Until:   now.Add(lease),
+		Limit:   limit,
+	}
+
+	rows, err := r.NamedQueryContext(ctx, query, dClaim)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]notifications.Delivery, 0)
+	for rows.Next() {
+		var dDelivery dbDelivery
+		if err := rows.StructScan(&dDelivery); err != nil {
+			return nil, err
+		}
+
+		items = append(items, dDelivery.toDelivery())
+	}
+
+	return items, nil
+}
+
+type dbDelivery struct {
+	NotificationID string    `db:"notification_id"`
+	Channel        string    `db:"channel"`
+	Status         string    `db:"status"`
+	Attempts       uint64    `db:"attempts"`
+	Error          string    `db:"error"`
+	NextAttemptAt  time.Time `db:"next_attempt_at"`
+	CreatedAt      time.Time `db:"created_at"`
+	UpdatedAt      time.Time `db:"updated_at"`
+}
+
+type dbClaim struct {
+	Channel string    `db:"channel"`
+	Now     time.Time `db:"now"`
+	Until   time.Time `db:"until"`
+	Limit   uint64    `db:"limit"`
+}
+
+func (d dbDelivery) toDelivery() notifications.Delivery {
+	return notifications.Delivery{
+		NotificationID: d.NotificationID,
+		Channel:        d.Channel,
+		Status:         d.Status,
+		Attempts:       d.Attempts,
+		Error:          d.Error,
+		NextAttemptAt:  d.NextAttemptAt,
+		CreatedAt:      d.CreatedAt,
+		UpdatedAt:      d.UpdatedAt,
+	}
+}
+
+func toDBDelivery(d notifications.Delivery) dbDelivery {
+	return dbDelivery{
+		NotificationID: d.NotificationID,
+		Channel:        d.Channel,
+		Status:         d.Status,
+		Attempts:       d.Attempts,
+		Error:          d.Error,
+		NextAttemptAt:  d.NextAttemptAt,
+	}
+}
+
 type dbNotification struct {
-	ID        string      `db:"id"`
-	UserID    string      `db:"user_id"`
-	Category  string      `db:"category"`
-	Content   string      `db:"content"`
-	IsRead    pgtype.Bool `db:"is_read"`
-	CreatedAt time.Time   `db:"created_at"`
-	UpdatedAt time.Time   `db:"updated_at"`
+	ID          string           `db:"id"`
+	ActorID     string           `db:"actor_id"`
+	RecipientID pgtype.Text      `db:"recipient_id"`
+	PostID      string           `db:"post_id"`
+	Category    string           `db:"category"`
+	Content     string           `db:"content"`
+	ActorCount  uint64           `db:"actor_count"`
+	Actors      pgtype.TextArray `db:"actors"`
+	IsRead      pgtype.Bool      `db:"is_read"`
+	CreatedAt   time.Time        `db:"created_at"`
+	UpdatedAt   time.Time        `db:"updated_at"`
+	EventID     string           `db:"event_id"`
+}
+
+type dbAggregate struct {
+	dbNotification
+	Since time.Time `db:"since"`
 }
 
 func (n dbNotification) toNotification() notifications.Notification {
+	actors := make([]string, 0, len(n.Actors.Elements))
+	for _, actor := range n.Actors.Elements {
+		actors = append(actors, actor.String)
+	}
+
 	return notifications.Notification{
-		ID:        n.ID,
-		UserID:    n.UserID,
-		Category:  notifications.ToCategory(n.Category),
-		Content:   n.Content,
-		IsRead:    n.IsRead.Bool,
-		CreatedAt: n.CreatedAt,
-		UpdatedAt: n.UpdatedAt,
+		ID:          n.ID,
+		ActorID:     n.ActorID,
+		RecipientID: n.RecipientID.String,
+		PostID:      n.PostID,
+		Category:    notifications.ToCategory(n.Category),
+		Content:     n.Content,
+		ActorCount:  n.ActorCount,
+		Actors:      actors,
+		IsRead:      n.IsRead.Bool,
+		CreatedAt:   n.CreatedAt,
+		UpdatedAt:   n.UpdatedAt,
 	}
 }
 
@@ -228,13 +450,29 @@ func toDBNotification(n notifications.Notification) (dbNotification, error) {
 		return dbNotification{}, err
 	}
 
+	var actors pgtype.TextArray
+	if err := actors.Set(n.Actors); err != nil {
+		return dbNotification{}, err
+	}
+
+	// Notifications stored before recipients were resolved have none.
+	recipientID := pgtype.Text{Status: pgtype.Null}
+	if n.RecipientID != "" {
+		recipientID = pgtype.Text{String: n.RecipientID, Status: pgtype.Present}
+	}
+
 	return dbNotification{
-		ID:        n.ID,
-		UserID:    n.UserID,
-		Category:  n.Category.String(),
-		Content:   n.Content,
-		IsRead:    isRead,
-		CreatedAt: n.CreatedAt,
-		UpdatedAt: n.UpdatedAt,
+		ID:          n.ID,
+		ActorID:     n.ActorID,
+		RecipientID: recipientID,
+		PostID:      n.PostID,
+		Category:    n.Category.String(),
+		Content:     n.Content,
+		ActorCount:  n.ActorCount,
+		Actors:      actors,
+		IsRead:      isRead,
+		CreatedAt:   n.CreatedAt,
+		UpdatedAt:   n.UpdatedAt,
+		EventID:     n.EventID,
 	}, nil
 }
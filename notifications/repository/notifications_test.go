@@ -12,6 +12,7 @@ import (
 	"errors"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/0x6flab/namegenerator"
 	"github.com/gofrs/uuid"
@@ -42,45 +43,60 @@ func TestCreateNotification(t *testing.T) {
 		{
 			desc: "valid notification",
 			notification: notifications.Notification{
-				UserID:   uuid.Must(uuid.NewV4()).String(),
-				Category: notifications.Post,
-				Content:  namegen.Generate(),
+				ActorID:     uuid.Must(uuid.NewV4()).String(),
+				RecipientID: uuid.Must(uuid.NewV4()).String(),
+				Category:    notifications.Post,
+				Content:     namegen.Generate(),
 			},
 			err: nil,
 		},
 		{
-			desc: "empty user id",
+			desc: "empty recipient id",
 			notification: notifications.Notification{
-				UserID:   "",
-				Category: notifications.Post,
-				Content:  namegen.Generate(),
+				ActorID:     uuid.Must(uuid.NewV4()).String(),
+				RecipientID: "",
+				Category:    notifications.Post,
+				Content:     namegen.Generate(),
+			},
+			err: errors.New("invalid input syntax for type uuid"),
+		},
+		{
+			desc: "empty actor id",
+			notification: notifications.Notification{
+				ActorID:     "",
+				RecipientID: uuid.Must(uuid.NewV4()).String(),
+				Category:    notifications.Post,
+				Content:     namegen.Generate(),
 			},
 			err: errors.New("invalid input syntax for type uuid"),
 		},
 		{
 			desc: "empty content",
 			notification: notifications.Notification{
-				UserID:   uuid.Must(uuid.NewV4()).String(),
-				Category: notifications.Post,
-				Content:  "",
+				ActorID:     uuid.Must(uuid.NewV4()).String(),
+				RecipientID: uuid.Must(uuid.NewV4()).String(),
+				Category:    notifications.Post,
+				Content:     "",
 			},
 			err: nil,
 		},
 		{
-			desc: "malformed user id",
+			desc: "malformed recipient id",
 			notification: notifications.Notification{
-				UserID:   malformedID,
-				Category: notifications.Post,
-				Content:  namegen.Generate(),
+				ActorID:     uuid.Must(uuid.NewV4()).String(),
+				RecipientID: malformedID,
+				Category:    notifications.Post,
+				Content:     namegen.Generate(),
 			},
 			err: errors.New("invalid input syntax for type uuid"),
 		},
 		{
-			desc: "unknown user id",
+			desc: "unknown recipient id",
 			notification: notifications.Notification{
-				UserID:   invalidID,
-				Category: notifications.Post,
-				Content:  namegen.Generate(),
+				ActorID:     uuid.Must(uuid.NewV4()).String(),
+				RecipientID: invalidID,
+				Category:    notifications.Post,
+				Content:     namegen.Generate(),
 			},
 			err: nil,
 		},
@@ -101,9 +117,187 @@ func TestCreateNotification(t *testing.T) {
 			}
 			assert.NoError(t, err)
 			assert.NotEmpty(t, saved.ID)
-			assert.Equal(t, tc.notification.UserID, saved.UserID)
+			assert.Equal(t, tc.notification.ActorID, saved.ActorID)
+			assert.Equal(t, tc.notification.RecipientID, saved.RecipientID)
+		})
+	}
+}
+
+func TestAggregateNotification(t *testing.T) {
+	t.Cleanup(func() {
+		_, err := db.Exec("DELETE FROM notifications")
+		require.NoError(t, err)
+	})
+	repo := repository.NewRepository(db)
+
+	actors := make([]string, 4)
+	for i := range actors {
+		actors[i] = uuid.Must(uuid.NewV4()).String()
+	}
+	notification := notifications.Notification{
+		ActorID:     actors[0],
+		RecipientID: uuid.Must(uuid.NewV4()).String(),
+		PostID:      namegen.Generate(),
+		Category:    notifications.Like,
+	}
+	saved, err := repo.CreateNotification(context.Background(), notification)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), saved.ActorCount)
+	assert.Equal(t, []string{actors[0]}, saved.Actors)
+
+	since := time.Now().UTC().Add(-time.Hour)
+
+	// The cases run in order since each one aggregates into the same notification.
+	cases := []struct {
+		desc    string
+		actorID string
+		postID  string
+		since   time.Time
+		count   uint64
+		actors  []string
+		err     error
+	}{
+		{
+			desc:    "new actor",
+			actorID: actors[1],
+			postID:  notification.PostID,
+			since:   since,
+			count:   2,
+			actors:  []string{actors[1], actors[0]},
+		},
+		{
+			desc:    "repeated actor",
+			actorID: actors[0],
+			postID:  notification.PostID,
+			since:   since,
+			count:   2,
+			actors:  []string{actors[0], actors[1]},
+		},
+		{
+			desc:    "third actor",
+			actorID: actors[2],
+			postID:  notification.PostID,
+			since:   since,
+			count:   3,
+			actors:  []string{actors[2], actors[0], actors[1]},
+		},
+		{
+			desc:    "more actors than kept",
+			actorID: actors[3],
+			postID:  notification.PostID,
+			since:   since,
+			count:   4,
+			actors:  []string{actors[3], actors[2], actors[0]},
+		},
+		{
+			desc:    "other post",
+			actorID: actors[1],
+			postID:  namegen.Generate(),
+			since:   since,
+			err:     notifications.ErrNotFound,
+		},
+		{
+			desc:    "outside window",
+			actorID: actors[1],
+			postID:  notification.PostID,
+			since:   time.Now().UTC().Add(time.Hour),
+			err:     notifications.ErrNotFound,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			n := notification
+			n.ActorID = tc.actorID
+			n.PostID = tc.postID
+
+			aggregated, err := repo.AggregateNotification(context.Background(), n, tc.since)
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, saved.ID, aggregated.ID)
+			assert.Equal(t, saved.CreatedAt, aggregated.CreatedAt)
+			assert.True(t, aggregated.UpdatedAt.After(saved.UpdatedAt), "aggregating should bump the update time")
+			assert.Equal(t, tc.actorID, aggregated.ActorID)
+			assert.Equal(t, tc.count, aggregated.ActorCount)
+			assert.Equal(t, tc.actors, aggregated.Actors)
+		})
+	}
+}
+
+func TestRetrieveEventNotification(t *testing.T) {
+	t.Cleanup(func() {
+		_, err := db.Exec("DELETE FROM notifications")
+		require.NoError(t, err)
+	})
+	repo := repository.NewRepository(db)
+
+	notification := notifications.Notification{
+		ActorID:     uuid.Must(uuid.NewV4()).String(),
+		RecipientID: uuid.Must(uuid.NewV4()).String(),
+		PostID:      namegen.Generate(),
+		Category:    notifications.Like,
+		EventID:     uuid.Must(uuid.NewV4()).String(),
+	}
+	saved, err := repo.CreateNotification(context.Background(), notification)
+	require.NoError(t, err)
+
+	aggregated := notification
+	aggregated.ActorID = uuid.Must(uuid.NewV4()).String()
+	aggregated.EventID = uuid.Must(uuid.NewV4()).String()
+	_, err = repo.AggregateNotification(context.Background(), aggregated, time.Now().UTC().Add(-time.Hour))
+	require.NoError(t, err)
+
+	cases := []struct {
+		desc        string
+		eventID     string
+		recipientID string
+		err         error
+	}{
+		{
+			desc:        "created for the event",
+			eventID:     notification.EventID,
+			recipientID: notification.RecipientID,
+		},
+		{
+			desc:        "aggregated for the event",
+			eventID:     aggregated.EventID,
+			recipientID: notification.RecipientID,
+		},
+		{
+			desc:        "other recipient",
+			eventID:     notification.EventID,
+			recipientID: uuid.Must(uuid.NewV4()).String(),
+			err:         notifications.ErrNotFound,
+		},
+		{
+			desc:        "unknown event",
+			eventID:     uuid.Must(uuid.NewV4()).String(),
+			recipientID: notification.RecipientID,
+			err:         notifications.ErrNotFound,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			n, err := repo.RetrieveEventNotification(context.Background(), tc.eventID, tc.recipientID)
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, saved.ID, n.ID)
 		})
 	}
+
+	// Deleting the notification forgets its events, so replaying them notifies the recipient again.
+	require.NoError(t, repo.DeleteNotification(context.Background(), saved.ID))
+	_, err = repo.RetrieveEventNotification(context.Background(), notification.EventID, notification.RecipientID)
+	assert.ErrorIs(t, err, notifications.ErrNotFound)
 }
 
 func TestRetrieveNotification(t *testing.T) {
@@ -114,9 +308,10 @@ func TestRetrieveNotification(t *testing.T) {
 	repo := repository.NewRepository(db)
 
 	notification := notifications.Notification{
-		UserID:   uuid.Must(uuid.NewV4()).String(),
-		Category: notifications.Post,
-		Content:  namegen.Generate(),
+		ActorID:     uuid.Must(uuid.NewV4()).String(),
+		RecipientID: uuid.Must(uuid.NewV4()).String(),
+		Category:    notifications.Post,
+		Content:     namegen.Generate(),
 	}
 	notification, err := repo.CreateNotification(context.Background(), notification)
 	require.NoError(t, err)
@@ -177,9 +372,10 @@ func TestRetrieveAllNotifications(t *testing.T) {
 	saved := make([]notifications.Notification, num)
 	for i := range num {
 		n := notifications.Notification{
-			UserID:   uuid.Must(uuid.NewV4()).String(),
-			Category: notifications.Post,
-			Content:  namegen.Generate(),
+			ActorID:     uuid.Must(uuid.NewV4()).String(),
+			RecipientID: uuid.Must(uuid.NewV4()).String(),
+			Category:    notifications.Post,
+			Content:     namegen.Generate(),
 		}
 		if i == 0 {
 			n.IsRead = true
@@ -246,11 +442,11 @@ func TestRetrieveAllNotifications(t *testing.T) {
 			err: nil,
 		},
 		{
-			desc: "for userID",
+			desc: "for recipient",
 			page: notifications.Page{
-				Offset: 0,
-				Limit:  10,
-				UserID: saved[0].UserID,
+				Offset:      0,
+				Limit:       10,
+				RecipientID: saved[0].RecipientID,
 			},
 			response: notifications.NotificationsPage{
 				Page: notifications.Page{
@@ -322,9 +518,10 @@ func TestReadNotification(t *testing.T) {
 	repo := repository.NewRepository(db)
 
 	notification := notifications.Notification{
-		UserID:   uuid.Must(uuid.NewV4()).String(),
-		Category: notifications.Post,
-		Content:  namegen.Generate(),
+		ActorID:     uuid.Must(uuid.NewV4()).String(),
+		RecipientID: uuid.Must(uuid.NewV4()).String(),
+		Category:    notifications.Post,
+		Content:     namegen.Generate(),
 	}
 	notification, err := repo.CreateNotification(context.Background(), notification)
 	require.NoError(t, err)
@@ -358,7 +555,7 @@ func TestReadNotification(t *testing.T) {
 
 	for _, tc := range cases {
 		t.Run(tc.desc, func(t *testing.T) {
-			err := repo.ReadNotification(context.Background(), notification.UserID, tc.id)
+			err := repo.ReadNotification(context.Background(), notification.RecipientID, tc.id)
 			switch {
 			case tc.err != nil:
 				assert.ErrorContains(t, err, tc.err.Error())
@@ -382,9 +579,10 @@ func TestReadAllNotifications(t *testing.T) {
 	saved := make([]notifications.Notification, num)
 	for i := range num {
 		n := notifications.Notification{
-			UserID:   uuid.Must(uuid.NewV4()).String(),
-			Category: notifications.Post,
-			Content:  namegen.Generate(),
+			ActorID:     uuid.Must(uuid.NewV4()).String(),
+			RecipientID: uuid.Must(uuid.NewV4()).String(),
+			Category:    notifications.Post,
+			Content:     namegen.Generate(),
 		}
 
 		n, err := repo.CreateNotification(context.Background(), n)
@@ -422,11 +620,11 @@ func TestReadAllNotifications(t *testing.T) {
 			err: nil,
 		},
 		{
-			desc: "for userID",
+			desc: "for recipient",
 			page: notifications.Page{
-				Offset: 0,
-				Limit:  10,
-				UserID: saved[0].UserID,
+				Offset:      0,
+				Limit:       10,
+				RecipientID: saved[0].RecipientID,
 			},
 			err: nil,
 		},
@@ -466,9 +664,10 @@ func TestDeleteNotification(t *testing.T) {
 	repo := repository.NewRepository(db)
 
 	notification := notifications.Notification{
-		UserID:   uuid.Must(uuid.NewV4()).String(),
-		Category: notifications.Post,
-		Content:  namegen.Generate(),
+		ActorID:     uuid.Must(uuid.NewV4()).String(),
+		RecipientID: uuid.Must(uuid.NewV4()).String(),
+		Category:    notifications.Post,
+		Content:     namegen.Generate(),
 	}
 	notification, err := repo.CreateNotification(context.Background(), notification)
 	require.NoError(t, err)
@@ -514,3 +713,113 @@ func TestDeleteNotification(t *testing.T) {
 		})
 	}
 }
+
+func TestRetrieveAllNotificationsCursor(t *testing.T) {
+	t.Cleanup(func() {
+		_, err := db.Exec("DELETE FROM notifications")
+		require.NoError(t, err)
+	})
+	repo := repository.NewRepository(db)
+
+	recipientID := uuid.Must(uuid.NewV4()).String()
+	num := 5
+	for range num {
+		_, err := repo.CreateNotification(context.Background(), notifications.Notification{
+			ActorID:     uuid.Must(uuid.NewV4()).String(),
+			RecipientID: recipientID,
+			Category:    notifications.Post,
+			Content:     namegen.Generate(),
+		})
+		require.NoError(t, err)
+	}
+
+	page := notifications.Page{Limit: 2, RecipientID: recipientID}
+	seen := map[string]bool{}
+	for pages := 0; ; pages++ {
+		n, err := repo.RetrieveAllNotifications(context.Background(), page)
+		require.NoError(t, err)
+		if page.Cursor == "" {
+			assert.Equal(t, uint64(num), n.Total, "offset pages should be counted")
+		} else {
+			assert.Zero(t, n.Total, "cursor pages should not be counted")
+		}
+
+		for _, notification := range n.Notifications {
+			assert.False(t, seen[notification.ID], "notifications should not repeat across pages")
+			seen[notification.ID] = true
+		}
+		if pages == 0 {
+			// Notifications created while paging must not shift the following pages.
+			_, err := repo.CreateNotification(context.Background(), notifications.Notification{
+				ActorID:     uuid.Must(uuid.NewV4()).String(),
+				RecipientID: recipientID,
+				Category:    notifications.Post,
+				Content:     namegen.Generate(),
+			})
+			require.NoError(t, err)
+		}
+
+		if n.NextCursor == "" {
+			break
+		}
+		page.Cursor = n.NextCursor
+	}
+	assert.Len(t, seen, num)
+
+	n, err := repo.RetrieveAllNotifications(context.Background(), notifications.Page{Limit: 2, RecipientID: recipientID})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(num+1), n.Total)
+}
+
+func TestClaimDeliveries(t *testing.T) {
+	t.Cleanup(func() {
+		_, err := db.Exec("DELETE FROM notifications")
+		require.NoError(t, err)
+	})
+	repo := repository.NewRepository(db)
+
+	now := time.Now().UTC()
+	newDelivery := func(status string, nextAttemptAt time.Time) notifications.Delivery {
+		notification, err := repo.CreateNotification(context.Background(), notifications.Notification{
+			ActorID:     uuid.Must(uuid.NewV4()).String(),
+			RecipientID: uuid.Must(uuid.NewV4()).String(),
+			Category:    notifications.Like,
+		})
+		require.NoError(t, err)
+
+		delivery := notifications.Delivery{
+			NotificationID: notification.ID,
+			Channel:        notifications.EmailChannel,
+			Status:         status,
+			NextAttemptAt:  nextAttemptAt,
+		}
+		require.NoError(t, repo.SaveDelivery(context.Background(), delivery))
+
+		return delivery
+	}
+	due := newDelivery(notifications.PendingStatus, now.Add(-time.Minute))
+	newDelivery(notifications.PendingStatus, now.Add(time.Hour))
+	newDelivery(notifications.DeliveredStatus, now.Add(-time.Minute))
+
+	claimed, err := repo.ClaimDeliveries(context.Background(), notifications.EmailChannel, 10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	assert.Equal(t, due.NotificationID, claimed[0].NotificationID)
+
+	// Claimed deliveries are leased and not claimed again until the lease expires.
+	claimed, err = repo.ClaimDeliveries(context.Background(), notifications.EmailChannel, 10, time.Minute)
+	require.NoError(t, err)
+	assert.Empty(t, claimed)
+
+	due.Status = notifications.PendingStatus
+	due.Attempts = 1
+	due.Error = "try again later"
+	due.NextAttemptAt = now.Add(-time.Second)
+	require.NoError(t, repo.SaveDelivery(context.Background(), due))
+
+	claimed, err = repo.ClaimDeliveries(context.Background(), notifications.EmailChannel, 10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	assert.Equal(t, due.Attempts, claimed[0].Attempts)
+	assert.Equal(t, due.Error, claimed[0].Error)
+}
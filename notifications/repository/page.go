@@ -0,0 +1,54 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+package repository
+
+import (
+	"time"
+
+	"github.com/rodneyosodo/twiga/internal/cursor"
+	"github.com/rodneyosodo/twiga/notifications"
+)
+
+type dbPage struct {
+	notifications.Page
+	Category        string    `db:"category"`
+	CursorCreatedAt time.Time `db:"cursor_created_at"`
+	CursorID        string    `db:"cursor_id"`
+	AfterUpdatedAt  time.Time `db:"after_updated_at"`
+	AfterID         string    `db:"after_id"`
+}
+
+// toDBPage binds the page cursors and reads one row past the limit
+// so that the repository can tell whether a next page exists.
+func toDBPage(page notifications.Page) (dbPage, error) {
+	dPage := dbPage{Page: page, Category: page.Category.String()}
+	dPage.Limit = page.Limit + 1
+
+	// Streams resume after the last notification they sent, by update time.
+	if page.After != "" {
+		c, err := cursor.Decode(page.After)
+		if err != nil {
+			return dbPage{}, err
+		}
+		dPage.AfterUpdatedAt = c.CreatedAt
+		dPage.AfterID = c.ID
+	}
+
+	if page.Cursor == "" {
+		return dPage, nil
+	}
+
+	c, err := cursor.Decode(page.Cursor)
+	if err != nil {
+		return dbPage{}, err
+	}
+	dPage.Offset = 0
+	dPage.CursorCreatedAt = c.CreatedAt
+	dPage.CursorID = c.ID
+
+	return dPage, nil
+}
@@ -0,0 +1,198 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rodneyosodo/twiga/notifications"
+)
+
+func (r *repository) SaveSetting(ctx context.Context, setting notifications.Setting) (notifications.Setting, error) {
+	// Changing the digest restarts its period so that the first digest
+	// does not include everything received before the change.
+	query := `INSERT INTO settings (user_id, digest)
+			VALUES (:user_id, :digest)
+			ON CONFLICT (user_id)
+			DO UPDATE SET digest = EXCLUDED.digest,
+				digested_at = CASE WHEN settings.digest = EXCLUDED.digest THEN settings.digested_at ELSE CURRENT_TIMESTAMP END,
+				updated_at = CURRENT_TIMESTAMP
+			RETURNING *`
+
+	rows, err := r.NamedQueryContext(ctx, query, toDBSetting(setting))
+	if err != nil {
+		return notifications.Setting{}, err
+	}
+	defer rows.Close()
+
+	var dSetting dbSetting
+	if rows.Next() {
+		if err := rows.StructScan(&dSetting); err != nil {
+			return notifications.Setting{}, err
+		}
+	}
+
+	return dSetting.toSetting(), nil
+}
+
+func (r *repository) RetrieveSetting(ctx context.Context, userID string) (notifications.Setting, error) {
+	query := `SELECT * FROM settings WHERE user_id = :user_id`
+
+	rows, err := r.NamedQueryContext(ctx, query, dbSetting{UserID: userID})
+	if err != nil {
+		return notifications.Setting{}, err
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		var dSetting dbSetting
+		if err := rows.StructScan(&dSetting); err != nil {
+			return notifications.Setting{}, err
+		}
+
+		return dSetting.toSetting(), nil
+	}
+
+	return notifications.Setting{}, notifications.ErrSettingNotFound
+}
+
+func (r *repository) RetrieveDueSettings(ctx context.Context, digest notifications.Digest, before time.Time) ([]notifications.Setting, error) {
+	query := `SELECT * FROM settings WHERE digest = :digest AND digested_at < :digested_at ORDER BY digested_at`
+	dSetting := dbSetting{
+		Digest:     string(digest),
+		DigestedAt: before,
+	}
+
+	rows, err := r.NamedQueryContext(ctx, query, dSetting)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	settings := make([]notifications.Setting, 0)
+	for rows.Next() {
+		var dSetting dbSetting
+		if err := rows.StructScan(&dSetting); err != nil {
+			return nil, err
+		}
+
+		settings = append(settings, dSetting.toSetting())
+	}
+
+	return settings, nil
+}
+
+func (r *repository) RetrieveUndigested(ctx context.Context, recipientID string, limit uint64) ([]notifications.Notification, error) {
+	query := fmt.Sprintf(`SELECT * FROM notifications n
+			WHERE n.recipient_id = :recipient_id AND n.is_read = FALSE
+			AND NOT EXISTS (
+				SELECT 1 FROM deliveries d
+				WHERE d.notification_id = n.id AND d.channel IN ('%s', '%s') AND d.status IN ('%s', '%s')
+			)
+			ORDER BY n.created_at, n.id LIMIT :limit`, notifications.EmailChannel, notifications.DigestChannel, notifications.DeliveredStatus, notifications.PendingStatus)
+	dPage := notifications.Page{
+		RecipientID: recipientID,
+		Limit:       limit,
+	}
+
+	rows, err := r.NamedQueryContext(ctx, query, dPage)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]notifications.Notification, 0)
+	for rows.Next() {
+		var dNotification dbNotification
+		if err := rows.StructScan(&dNotification); err != nil {
+			return nil, err
+		}
+
+		items = append(items, dNotification.toNotification())
+	}
+
+	return items, nil
+}
+
+func (r *repository) ClaimDigest(ctx context.Context, userID string, before time.Time, notificationIDs []string) (claimed bool, err error) {
+	tx, err := r.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if err != nil {
+			err = errors.Join(err, tx.Rollback())
+		}
+	}()
+
+	// Claiming the period locks the setting, so a concurrent scheduler waits
+	// for this claim and then finds that the digest is no longer due.
+	query := `UPDATE settings SET digested_at = CURRENT_TIMESTAMP WHERE user_id = :user_id AND digested_at < :digested_at`
+	result, err := tx.NamedExecContext(ctx, query, dbSetting{UserID: userID, DigestedAt: before})
+	if err != nil {
+		return false, err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return false, tx.Rollback()
+	}
+
+	if len(notificationIDs) > 0 {
+		query = `INSERT INTO deliveries (notification_id, channel, status)
+			VALUES (:notification_id, :channel, :status)
+			ON CONFLICT (notification_id, channel) DO UPDATE SET status = EXCLUDED.status, error = '', updated_at = CURRENT_TIMESTAMP`
+
+		deliveries := make([]dbDelivery, 0, len(notificationIDs))
+		for _, id := range notificationIDs {
+			deliveries = append(deliveries, dbDelivery{
+				NotificationID: id,
+				Channel:        notifications.DigestChannel,
+				Status:         notifications.PendingStatus,
+			})
+		}
+		if _, err = tx.NamedExecContext(ctx, query, deliveries); err != nil {
+			return false, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+type dbSetting struct {
+	UserID     string    `db:"user_id"`
+	Digest     string    `db:"digest"`
+	DigestedAt time.Time `db:"digested_at"`
+	CreatedAt  time.Time `db:"created_at"`
+	UpdatedAt  time.Time `db:"updated_at"`
+}
+
+func (s dbSetting) toSetting() notifications.Setting {
+	return notifications.Setting{
+		UserID:     s.UserID,
+		Digest:     notifications.Digest(s.Digest),
+		DigestedAt: s.DigestedAt,
+		CreatedAt:  s.CreatedAt,
+		UpdatedAt:  s.UpdatedAt,
+	}
+}
+
+func toDBSetting(s notifications.Setting) dbSetting {
+	return dbSetting{
+		UserID:     s.UserID,
+		Digest:     string(s.Digest),
+		DigestedAt: s.DigestedAt,
+		CreatedAt:  s.CreatedAt,
+		UpdatedAt:  s.UpdatedAt,
+	}
+}
@@ -0,0 +1,162 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/rodneyosodo/twiga/notifications"
+	"github.com/rodneyosodo/twiga/notifications/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSaveSetting(t *testing.T) {
+	t.Cleanup(func() {
+		_, err := db.Exec("DELETE FROM settings")
+		require.NoError(t, err)
+	})
+	repo := repository.NewRepository(db)
+
+	userID := uuid.Must(uuid.NewV4()).String()
+
+	cases := []struct {
+		desc    string
+		setting notifications.Setting
+		err     error
+	}{
+		{
+			desc:    "new setting",
+			setting: notifications.Setting{UserID: userID, Digest: notifications.DailyDigest},
+			err:     nil,
+		},
+		{
+			desc:    "existing setting",
+			setting: notifications.Setting{UserID: userID, Digest: notifications.WeeklyDigest},
+			err:     nil,
+		},
+		{
+			desc:    "malformed user id",
+			setting: notifications.Setting{UserID: malformedID, Digest: notifications.DailyDigest},
+			err:     errors.New("invalid input syntax for type uuid"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			setting, err := repo.SaveSetting(context.Background(), tc.setting)
+			if tc.err != nil {
+				assert.ErrorContains(t, err, tc.err.Error())
+
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.setting.Digest, setting.Digest)
+
+			saved, err := repo.RetrieveSetting(context.Background(), tc.setting.UserID)
+			require.NoError(t, err)
+			assert.Equal(t, setting, saved)
+		})
+	}
+
+	_, err := repo.RetrieveSetting(context.Background(), invalidID)
+	assert.ErrorContains(t, err, "setting not found")
+}
+
+func TestClaimDigest(t *testing.T) {
+	t.Cleanup(func() {
+		_, err := db.Exec("DELETE FROM notifications")
+		require.NoError(t, err)
+		_, err = db.Exec("DELETE FROM settings")
+		require.NoError(t, err)
+	})
+	repo := repository.NewRepository(db)
+
+	userID := uuid.Must(uuid.NewV4()).String()
+	_, err := repo.SaveSetting(context.Background(), notifications.Setting{UserID: userID, Digest: notifications.DailyDigest})
+	require.NoError(t, err)
+
+	ids := []string{}
+	for i := 0; i < 2; i++ {
+		n, err := repo.CreateNotification(context.Background(), notifications.Notification{
+			ActorID:     uuid.Must(uuid.NewV4()).String(),
+			RecipientID: userID,
+			Category:    notifications.Like,
+		})
+		require.NoError(t, err)
+		ids = append(ids, n.ID)
+	}
+
+	due, err := repo.RetrieveDueSettings(context.Background(), notifications.DailyDigest, time.Now().UTC().Add(time.Hour))
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+	assert.Equal(t, userID, due[0].UserID)
+
+	// The cases run in order: a claimed digest is not due again until its
+	// next period, and the notifications of a failed one go in the next digest.
+	cases := []struct {
+		desc       string
+		before     time.Time
+		status     string
+		claimed    bool
+		undigested int
+	}{
+		{
+			desc:       "due digest",
+			before:     time.Now().UTC().Add(time.Hour),
+			claimed:    true,
+			undigested: 0,
+		},
+		{
+			desc:       "digest not due",
+			before:     time.Now().UTC().Add(-time.Hour),
+			claimed:    false,
+			undigested: 0,
+		},
+		{
+			desc:       "failed digest",
+			before:     time.Now().UTC().Add(-time.Hour),
+			status:     notifications.FailedStatus,
+			claimed:    false,
+			undigested: 2,
+		},
+		{
+			desc:       "next digest after a failed one",
+			before:     time.Now().UTC().Add(time.Hour),
+			claimed:    true,
+			undigested: 0,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			if tc.status != "" {
+				for _, id := range ids {
+					require.NoError(t, repo.SaveDelivery(context.Background(), notifications.Delivery{
+						NotificationID: id,
+						Channel:        notifications.DigestChannel,
+						Status:         tc.status,
+						NextAttemptAt:  time.Now().UTC(),
+					}))
+				}
+			}
+
+			claimed, err := repo.ClaimDigest(context.Background(), userID, tc.before, ids)
+			require.NoError(t, err)
+			assert.Equal(t, tc.claimed, claimed)
+
+			undigested, err := repo.RetrieveUndigested(context.Background(), userID, 10)
+			require.NoError(t, err)
+			assert.Len(t, undigested, tc.undigested)
+		})
+	}
+}
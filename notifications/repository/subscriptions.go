@@ -0,0 +1,108 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/rodneyosodo/twiga/notifications"
+)
+
+func (r *repository) SaveSubscription(ctx context.Context, subscription notifications.Subscription) (notifications.Subscription, error) {
+	query := `INSERT INTO subscriptions (user_id, endpoint, p256dh, auth)
+			VALUES (:user_id, :endpoint, :p256dh, :auth)
+			ON CONFLICT (endpoint)
+			DO UPDATE SET user_id = EXCLUDED.user_id, p256dh = EXCLUDED.p256dh, auth = EXCLUDED.auth
+			RETURNING *`
+
+	rows, err := r.NamedQueryContext(ctx, query, toDBSubscription(subscription))
+	if err != nil {
+		return notifications.Subscription{}, err
+	}
+	defer rows.Close()
+
+	var dSubscription dbSubscription
+	if rows.Next() {
+		if err := rows.StructScan(&dSubscription); err != nil {
+			return notifications.Subscription{}, err
+		}
+	}
+
+	return dSubscription.toSubscription(), nil
+}
+
+func (r *repository) RetrieveSubscriptions(ctx context.Context, userID string) ([]notifications.Subscription, error) {
+	query := `SELECT * FROM subscriptions WHERE user_id = :user_id ORDER BY created_at`
+
+	rows, err := r.NamedQueryContext(ctx, query, dbSubscription{UserID: userID})
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subscriptions := make([]notifications.Subscription, 0)
+	for rows.Next() {
+		var dSubscription dbSubscription
+		if err := rows.StructScan(&dSubscription); err != nil {
+			return nil, err
+		}
+
+		subscriptions = append(subscriptions, dSubscription.toSubscription())
+	}
+
+	return subscriptions, nil
+}
+
+func (r *repository) DeleteSubscription(ctx context.Context, userID, id string) error {
+	query := `DELETE FROM subscriptions WHERE id = :id AND user_id = :user_id`
+
+	result, err := r.NamedExecContext(ctx, query, dbSubscription{ID: id, UserID: userID})
+	if err != nil {
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return errors.New("subscription not found")
+	}
+
+	return nil
+}
+
+type dbSubscription struct {
+	ID        string    `db:"id"`
+	UserID    string    `db:"user_id"`
+	Endpoint  string    `db:"endpoint"`
+	P256dh    string    `db:"p256dh"`
+	Auth      string    `db:"auth"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+func (s dbSubscription) toSubscription() notifications.Subscription {
+	return notifications.Subscription{
+		ID:       s.ID,
+		UserID:   s.UserID,
+		Endpoint: s.Endpoint,
+		Keys: notifications.SubscriptionKeys{
+			P256dh: s.P256dh,
+			Auth:   s.Auth,
+		},
+		CreatedAt: s.CreatedAt,
+	}
+}
+
+func toDBSubscription(s notifications.Subscription) dbSubscription {
+	return dbSubscription{
+		ID:        s.ID,
+		UserID:    s.UserID,
+		Endpoint:  s.Endpoint,
+		P256dh:    s.Keys.P256dh,
+		Auth:      s.Keys.Auth,
+		CreatedAt: s.CreatedAt,
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/gofrs/uuid"
+	"github.com/rodneyosodo/twiga/notifications"
+	"github.com/rodneyosodo/twiga/notifications/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSaveSubscription(t *testing.T) {
+	t.Cleanup(func() {
+		_, err := db.Exec("DELETE FROM subscriptions")
+		require.NoError(t, err)
+	})
+	repo := repository.NewRepository(db)
+
+	userID := uuid.Must(uuid.NewV4()).String()
+	otherUserID := uuid.Must(uuid.NewV4()).String()
+	endpoint := "https://push.example.com/" + namegen.Generate()
+	keys := notifications.SubscriptionKeys{P256dh: namegen.Generate(), Auth: namegen.Generate()}
+
+	cases := []struct {
+		desc         string
+		subscription notifications.Subscription
+		err          error
+	}{
+		{
+			desc:         "new subscription",
+			subscription: notifications.Subscription{UserID: userID, Endpoint: endpoint, Keys: keys},
+			err:          nil,
+		},
+		{
+			desc:         "endpoint registered by another user",
+			subscription: notifications.Subscription{UserID: otherUserID, Endpoint: endpoint, Keys: keys},
+			err:          nil,
+		},
+		{
+			desc:         "empty endpoint",
+			subscription: notifications.Subscription{UserID: userID, Keys: keys},
+			err:          errors.New("violates check constraint"),
+		},
+		{
+			desc:         "malformed user id",
+			subscription: notifications.Subscription{UserID: malformedID, Endpoint: endpoint, Keys: keys},
+			err:          errors.New("invalid input syntax for type uuid"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			saved, err := repo.SaveSubscription(context.Background(), tc.subscription)
+			if tc.err != nil {
+				assert.ErrorContains(t, err, tc.err.Error())
+
+				return
+			}
+			require.NoError(t, err)
+			assert.NotEmpty(t, saved.ID)
+			assert.Equal(t, tc.subscription.UserID, saved.UserID)
+			assert.Equal(t, tc.subscription.Keys, saved.Keys)
+
+			subscriptions, err := repo.RetrieveSubscriptions(context.Background(), tc.subscription.UserID)
+			require.NoError(t, err)
+			assert.Equal(t, []notifications.Subscription{saved}, subscriptions)
+		})
+	}
+
+	// The endpoint moved to the other user.
+	subscriptions, err := repo.RetrieveSubscriptions(context.Background(), userID)
+	require.NoError(t, err)
+	assert.Empty(t, subscriptions)
+}
+
+func TestDeleteSubscription(t *testing.T) {
+	t.Cleanup(func() {
+		_, err := db.Exec("DELETE FROM subscriptions")
+		require.NoError(t, err)
+	})
+	repo := repository.NewRepository(db)
+
+	subscription, err := repo.SaveSubscription(context.Background(), notifications.Subscription{
+		UserID:   uuid.Must(uuid.NewV4()).String(),
+		Endpoint: "https://push.example.com/" + namegen.Generate(),
+		Keys:     notifications.SubscriptionKeys{P256dh: namegen.Generate(), Auth: namegen.Generate()},
+	})
+	require.NoError(t, err)
+
+	cases := []struct {
+		desc   string
+		userID string
+		id     string
+		err    error
+	}{
+		{
+			desc:   "another user's subscription",
+			userID: invalidID,
+			id:     subscription.ID,
+			err:    errors.New("subscription not found"),
+		},
+		{
+			desc:   "valid subscription",
+			userID: subscription.UserID,
+			id:     subscription.ID,
+			err:    nil,
+		},
+		{
+			desc:   "deleted subscription",
+			userID: subscription.UserID,
+			id:     subscription.ID,
+			err:    errors.New("subscription not found"),
+		},
+		{
+			desc:   "malformed id",
+			userID: subscription.UserID,
+			id:     malformedID,
+			err:    errors.New("invalid input syntax for type uuid"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := repo.DeleteSubscription(context.Background(), tc.userID, tc.id)
+			if tc.err != nil {
+				assert.ErrorContains(t, err, tc.err.Error())
+
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/rodneyosodo/twiga/notifications"
+)
+
+var digests = []notifications.Digest{notifications.DailyDigest, notifications.WeeklyDigest}
+
+type Scheduler struct {
+	svc      notifications.Service
+	interval time.Duration
+	logger   *slog.Logger
+}
+
+// New returns a scheduler that checks for due digests every interval.
+// The interval bounds how late a digest can be sent after its period ends.
+func New(svc notifications.Service, interval time.Duration, logger *slog.Logger) *Scheduler {
+	return &Scheduler{
+		svc:      svc,
+		interval: interval,
+		logger:   logger,
+	}
+}
+
+// Start sends the due digests until the context is canceled.
+func (s *Scheduler) Start(ctx context.Context) error {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			s.send(ctx)
+		}
+	}
+}
+
+func (s *Scheduler) send(ctx context.Context) {
+	for _, digest := range digests {
+		// Failed digests stay due and are retried on the next tick.
+		if err := s.svc.SendDigests(ctx, digest); err != nil {
+			s.logger.Warn(fmt.Sprintf("Failed to send %s digests: %s", digest, err))
+		}
+	}
+}
@@ -10,99 +10,359 @@ package notifications
 import (
 	"context"
 	"errors"
+	"net/url"
+	"sync"
+	"time"
 
 	"github.com/rodneyosodo/twiga/internal/cache"
+	"github.com/rodneyosodo/twiga/internal/events/outbox"
+	"github.com/rodneyosodo/twiga/internal/netguard"
 	"github.com/rodneyosodo/twiga/users/proto"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
-const defLimit = 100
+const (
+	maxDigestSize  = 100
+	maxBackoffStep = 16
+	defBatchSize   = 100
+	defConcurrency = 10
+	// defLease must outlast an attempt so that a claimed delivery is not claimed again while in flight.
+	defLease = time.Minute
+)
+
+// CacheNamespace holds the cached delivery settings of the users.
+var CacheNamespace = cache.Namespace{Name: "settings", Version: 1}
 
 var _ Service = (*service)(nil)
 
 type service struct {
-	repo   Repository
-	users  proto.UsersServiceClient
-	cacher cache.Cacher
+	repo       Repository
+	users      proto.UsersServiceClient
+	notifier   Notifier
+	pusher     Notifier
+	mailer     Mailer
+	cacher     cache.Cacher[Setting]
+	window     time.Duration
+	maxRetries uint64
+	retryDelay time.Duration
 }
 
-func NewService(repo Repository, users proto.UsersServiceClient, cacher cache.Cacher) Service {
+// NewService returns the notifications service. The notifier delivers notifications
+// to connected clients and the pusher to the browser push subscriptions of the recipient.
+// Notifications about the same post are aggregated for the given window after the
+// first one; a zero window disables it. Emails are queued and failed ones are retried
+// up to maxRetries times, doubling the delay between attempts starting from retryDelay.
+func NewService(repo Repository, users proto.UsersServiceClient, notifier, pusher Notifier, mailer Mailer, cacher cache.Cacher[Setting], window time.Duration, maxRetries uint64, retryDelay time.Duration) Service {
 	return &service{
-		repo:   repo,
-		users:  users,
-		cacher: cacher,
+		repo:       repo,
+		users:      users,
+		notifier:   notifier,
+		pusher:     pusher,
+		mailer:     mailer,
+		cacher:     cacher,
+		window:     window,
+		maxRetries: maxRetries,
+		retryDelay: retryDelay,
 	}
 }
 
 func (s *service) CreateNotification(ctx context.Context, notification Notification) (Notification, error) {
+	// Events replayed to rebuild the notifications only notify the
+	// recipients they did not already notify, and are not delivered again.
+	if notification.EventID != "" {
+		stored, err := s.repo.RetrieveEventNotification(ctx, notification.EventID, notification.RecipientID)
+		switch {
+		case err == nil:
+			return stored, nil
+		case !errors.Is(err, ErrNotFound):
+			return Notification{}, err
+		}
+	}
+
+	if s.window > 0 && notification.PostID != "" {
+		return s.aggregate(ctx, notification)
+	}
+
 	notification, err := s.repo.CreateNotification(ctx, notification)
 	if err != nil {
 		return Notification{}, err
 	}
 
-	if err := s.cacher.Add(ctx, notification.ID, notification); err != nil {
-		return Notification{}, errors.New("failed to cache notification")
+	s.dispatch(ctx, notification)
+
+	return notification, nil
+}
+
+// aggregate adds the notification to the recent one about the same post, or creates
+// it when there is none. Both happen in one transaction so that concurrent
+// notifications about the post end up in a single one.
+func (s *service) aggregate(ctx context.Context, notification Notification) (Notification, error) {
+	aggregated := false
+	err := s.repo.WithTx(ctx, func(ctx context.Context) error {
+		saved, err := s.repo.AggregateNotification(ctx, notification, time.Now().UTC().Add(-s.window))
+		switch {
+		case err == nil:
+			aggregated = true
+		case errors.Is(err, ErrNotFound):
+			saved, err = s.repo.CreateNotification(ctx, notification)
+			if err != nil {
+				return err
+			}
+		default:
+			return err
+		}
+		notification = saved
+
+		return nil
+	})
+	if err != nil {
+		return Notification{}, err
+	}
+
+	if aggregated {
+		// The recipient was already emailed about the notification,
+		// so only the connected clients get the aggregated form.
+		s.push(ctx, notification)
+
+		return notification, nil
 	}
+	s.dispatch(ctx, notification)
 
 	return notification, nil
 }
 
-func (s *service) IdentifyUser(ctx context.Context, token string) (string, error) {
-	resp, err := s.users.IdentifyUser(ctx, &proto.IdentifyUserRequest{Token: token})
+// push publishes the notification to the connected clients of the recipient.
+// The push preference only covers Web Push, so the clients always get it.
+func (s *service) push(ctx context.Context, notification Notification) {
+	outbox.AfterCommit(ctx, func(ctx context.Context) {
+		_ = s.notifier.Notify(ctx, notification)
+	})
+}
+
+// dispatch delivers the notification through the channels enabled by the recipient.
+// Failures are not fatal since the notification is already stored and clients
+// get it from the list once they reconnect. Pushes wait for the event handled to
+// be committed and emails are only queued, to be sent by ProcessDeliveries.
+func (s *service) dispatch(ctx context.Context, notification Notification) {
+	s.push(ctx, notification)
+
+	setting, err := s.userSetting(ctx, notification.RecipientID)
 	if err != nil {
-		return "", err
+		return
+	}
+
+	if setting.IsPushEnabled {
+		outbox.AfterCommit(ctx, func(ctx context.Context) {
+			_ = s.pusher.Notify(ctx, notification)
+		})
+	}
+	// Users with a daily or weekly digest get the notification with the next one.
+	if setting.IsEmailEnabled && setting.Digest.Period() == 0 {
+		_ = s.repo.SaveDelivery(ctx, Delivery{
+			NotificationID: notification.ID,
+			Channel:        EmailChannel,
+			Status:         PendingStatus,
+			NextAttemptAt:  time.Now().UTC(),
+		})
 	}
+}
 
-	req := &proto.GetUserFollowersRequest{Id: resp.GetId(), Offset: 0, Limit: defLimit}
-	followers, err := s.users.GetUserFollowers(ctx, req)
+func (s *service) ProcessDeliveries(ctx context.Context) error {
+	deliveries, err := s.repo.ClaimDeliveries(ctx, EmailChannel, defBatchSize, defLease)
 	if err != nil {
-		return "", err
+		return err
 	}
-	for followers.Total > uint64(len(followers.Followings)) {
-		req.Offset += defLimit
-		resp, err := s.users.GetUserFollowers(ctx, req)
-		if err != nil {
-			return "", err
-		}
-		followers.Followings = append(followers.Followings, resp.Followings...)
+
+	// Every delivery is attempted even when others fail.
+	var (
+		mu   sync.Mutex
+		errs error
+	)
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(defConcurrency)
+	for _, delivery := range deliveries {
+		g.Go(func() error {
+			if err := s.attempt(ctx, delivery); err != nil {
+				mu.Lock()
+				errs = errors.Join(errs, err)
+				mu.Unlock()
+			}
+
+			return nil
+		})
 	}
+	_ = g.Wait()
+
+	return errs
+}
 
-	userIDs := make([]string, 0, len(followers.Followings))
-	for _, f := range followers.Followings {
-		userIDs = append(userIDs, f.FolloweeId)
+// attempt emails the notification of the delivery and records the outcome,
+// scheduling the next attempt with exponential backoff until the retries are exhausted.
+func (s *service) attempt(ctx context.Context, delivery Delivery) error {
+	notification, err := s.repo.RetrieveNotification(ctx, delivery.NotificationID)
+	if err != nil {
+		return err
 	}
 
-	if err := s.cacher.Add(ctx, resp.GetId(), userIDs); err != nil {
-		return "", errors.New("failed to cache followers")
+	delivery.Attempts++
+	err = s.mailer.Notify(ctx, notification)
+	switch {
+	case err == nil:
+		delivery.Status = DeliveredStatus
+		delivery.Error = ""
+	case delivery.Attempts > s.maxRetries:
+		delivery.Status = FailedStatus
+		delivery.Error = err.Error()
+	default:
+		step := min(delivery.Attempts-1, maxBackoffStep)
+		delivery.Status = PendingStatus
+		delivery.Error = err.Error()
+		delivery.NextAttemptAt = time.Now().UTC().Add(s.retryDelay << step)
 	}
 
-	return resp.GetId(), nil
+	return s.repo.SaveDelivery(ctx, delivery)
 }
 
-func (s *service) GetNewNotification(ctx context.Context, userID string) Notification {
-	ids, ok := s.cacher.Get(ctx, userID).([]interface{})
-	if !ok {
-		return Notification{}
+func (s *service) InvalidateSetting(ctx context.Context, userID string) error {
+	return s.cacher.Remove(ctx, userID)
+}
+
+func (s *service) RetrieveSetting(ctx context.Context, token string) (Setting, error) {
+	userID, err := s.IdentifyUser(ctx, token)
+	if err != nil {
+		return Setting{}, err
 	}
 
-	for _, id := range ids {
-		id, ok := id.(string)
-		if !ok {
-			continue
+	return s.userSetting(ctx, userID)
+}
+
+func (s *service) UpdateDigest(ctx context.Context, token string, digest Digest) (Setting, error) {
+	if digest != ImmediateDigest && digest.Period() == 0 {
+		return Setting{}, errors.New("digest must be one of immediate, daily or weekly")
+	}
+	userID, err := s.IdentifyUser(ctx, token)
+	if err != nil {
+		return Setting{}, err
+	}
+
+	if _, err := s.repo.SaveSetting(ctx, Setting{UserID: userID, Digest: digest}); err != nil {
+		return Setting{}, err
+	}
+	if err := s.InvalidateSetting(ctx, userID); err != nil {
+		return Setting{}, err
+	}
+
+	return s.userSetting(ctx, userID)
+}
+
+func (s *service) SendDigests(ctx context.Context, digest Digest) error {
+	period := digest.Period()
+	if period == 0 {
+		return errors.New("digest must be daily or weekly")
+	}
+	before := time.Now().UTC().Add(-period)
+
+	settings, err := s.repo.RetrieveDueSettings(ctx, digest, before)
+	if err != nil {
+		return err
+	}
+
+	// A failed digest does not hold back the digests of the other users.
+	var errs error
+	for _, setting := range settings {
+		if err := s.sendDigest(ctx, setting.UserID, digest, before); err != nil {
+			errs = errors.Join(errs, err)
 		}
+	}
+
+	return errs
+}
 
-		n, ok := s.cacher.Get(ctx, id).(Notification)
-		if ok {
-			defer s.cacher.Remove(ctx, id)
+// sendDigest emails the user the unread notifications that were neither emailed
+// nor included in a previous digest, grouped by category. The period is closed
+// even when there is nothing to send so that the user is not checked again until the next one.
+func (s *service) sendDigest(ctx context.Context, userID string, digest Digest, before time.Time) error {
+	setting, err := s.userSetting(ctx, userID)
+	if err != nil {
+		return err
+	}
 
-			return n
+	var items []Notification
+	if setting.IsEmailEnabled && setting.Digest == digest {
+		items, err = s.repo.RetrieveUndigested(ctx, userID, maxDigestSize)
+		if err != nil {
+			return err
 		}
 	}
 
-	return Notification{}
+	ids := make([]string, 0, len(items))
+	grouped := make(map[Category][]Notification)
+	for _, n := range items {
+		ids = append(ids, n.ID)
+		grouped[n.Category] = append(grouped[n.Category], n)
+	}
+
+	// The digest is claimed before it is sent so that the email is not sent while
+	// the transaction is open, and is never sent twice by concurrent schedulers.
+	claimed, err := s.repo.ClaimDigest(ctx, userID, before, ids)
+	if err != nil || !claimed || len(items) == 0 {
+		return err
+	}
+
+	// A failed digest is not retried on its own: its notifications are
+	// recorded as failed and are included in the next digest instead.
+	sendErr := s.mailer.SendDigest(ctx, userID, digest, grouped)
+	delivery := Delivery{Channel: DigestChannel, Status: DeliveredStatus, Attempts: 1}
+	if sendErr != nil {
+		delivery.Status = FailedStatus
+		delivery.Error = sendErr.Error()
+	}
+
+	errs := sendErr
+	for _, id := range ids {
+		delivery.NotificationID = id
+		delivery.NextAttemptAt = time.Now().UTC()
+		errs = errors.Join(errs, s.repo.SaveDelivery(ctx, delivery))
+	}
+
+	return errs
+}
+
+func (s *service) CreateSubscription(ctx context.Context, token string, subscription Subscription) (Subscription, error) {
+	if subscription.Endpoint == "" || subscription.Keys.P256dh == "" || subscription.Keys.Auth == "" {
+		return Subscription{}, errors.New("endpoint and keys are required")
+	}
+	// Push services are public and only reachable over https, so any other
+	// endpoint would have the service make requests on behalf of the user.
+	u, err := url.Parse(subscription.Endpoint)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return Subscription{}, errors.New("endpoint must be an absolute https url")
+	}
+	if err := netguard.CheckHost(ctx, u.Hostname()); err != nil {
+		return Subscription{}, err
+	}
+	userID, err := s.IdentifyUser(ctx, token)
+	if err != nil {
+		return Subscription{}, err
+	}
+	subscription.UserID = userID
+
+	return s.repo.SaveSubscription(ctx, subscription)
+}
+
+func (s *service) DeleteSubscription(ctx context.Context, token string, id string) error {
+	userID, err := s.IdentifyUser(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	return s.repo.DeleteSubscription(ctx, userID, id)
 }
 
 func (s *service) RetrieveNotification(ctx context.Context, token string, id string) (Notification, error) {
-	userID, err := s.identifyUser(ctx, token)
+	userID, err := s.IdentifyUser(ctx, token)
 	if err != nil {
 		return Notification{}, err
 	}
@@ -110,7 +370,7 @@ func (s *service) RetrieveNotification(ctx context.Context, token string, id str
 	if err != nil {
 		return Notification{}, err
 	}
-	if n.UserID != userID {
+	if n.RecipientID != userID {
 		return Notification{}, errors.New("unauthorized")
 	}
 
@@ -118,34 +378,17 @@ func (s *service) RetrieveNotification(ctx context.Context, token string, id str
 }
 
 func (s *service) RetrieveAllNotifications(ctx context.Context, token string, page Page) (NotificationsPage, error) {
-	userID, err := s.identifyUser(ctx, token)
+	userID, err := s.IdentifyUser(ctx, token)
 	if err != nil {
 		return NotificationsPage{}, err
 	}
-	followers, err := s.users.GetUserFollowers(ctx, &proto.GetUserFollowersRequest{Id: userID, Offset: 0, Limit: defLimit})
-	if err != nil {
-		return NotificationsPage{}, err
-	}
-	for followers.Total > defLimit {
-		resp, err := s.users.GetUserFollowers(ctx, &proto.GetUserFollowersRequest{Id: userID, Offset: defLimit, Limit: defLimit})
-		if err != nil {
-			return NotificationsPage{}, err
-		}
-		followers.Followings = append(followers.Followings, resp.Followings...)
-		followers.Total += resp.Total
-	}
-
-	userIDs := make([]string, 0, len(followers.Followings))
-	for _, f := range followers.Followings {
-		userIDs = append(userIDs, f.FolloweeId)
-	}
-	page.IDs = userIDs
+	page.RecipientID = userID
 
 	return s.repo.RetrieveAllNotifications(ctx, page)
 }
 
 func (s *service) ReadNotification(ctx context.Context, token string, id string) error {
-	userID, err := s.identifyUser(ctx, token)
+	userID, err := s.IdentifyUser(ctx, token)
 	if err != nil {
 		return err
 	}
@@ -154,17 +397,17 @@ func (s *service) ReadNotification(ctx context.Context, token string, id string)
 }
 
 func (s *service) ReadAllNotifications(ctx context.Context, token string, page Page) error {
-	userID, err := s.identifyUser(ctx, token)
+	userID, err := s.IdentifyUser(ctx, token)
 	if err != nil {
 		return err
 	}
-	page.UserID = userID
+	page.RecipientID = userID
 
 	return s.repo.ReadAllNotifications(ctx, page)
 }
 
 func (s *service) DeleteNotification(ctx context.Context, token string, id string) error {
-	userID, err := s.identifyUser(ctx, token)
+	userID, err := s.IdentifyUser(ctx, token)
 	if err != nil {
 		return err
 	}
@@ -172,14 +415,14 @@ func (s *service) DeleteNotification(ctx context.Context, token string, id strin
 	if err != nil {
 		return err
 	}
-	if n.UserID != userID {
+	if n.RecipientID != userID {
 		return errors.New("unauthorized")
 	}
 
 	return s.repo.DeleteNotification(ctx, id)
 }
 
-func (s *service) identifyUser(ctx context.Context, token string) (string, error) {
+func (s *service) IdentifyUser(ctx context.Context, token string) (string, error) {
 	resp, err := s.users.IdentifyUser(ctx, &proto.IdentifyUserRequest{Token: token})
 	if err != nil {
 		return "", err
@@ -187,3 +430,36 @@ func (s *service) identifyUser(ctx context.Context, token string) (string, error
 
 	return resp.GetId(), nil
 }
+
+// userSetting returns the delivery preferences of the user from the cache,
+// falling back to the users service and the stored digest. Users without
+// preferences get push only and users without a digest get immediate emails.
+func (s *service) userSetting(ctx context.Context, userID string) (Setting, error) {
+	if cached, ok, err := s.cacher.Get(ctx, userID); err == nil && ok {
+		return cached, nil
+	}
+
+	setting := Setting{UserID: userID, IsPushEnabled: true, Digest: ImmediateDigest}
+	resp, err := s.users.GetUserPreferences(ctx, &proto.GetUserPreferencesRequest{Id: userID})
+	switch {
+	case err == nil:
+		setting.IsEmailEnabled = resp.GetEmailNotifications()
+		setting.IsPushEnabled = resp.GetPushNotifications()
+	case status.Code(err) != codes.NotFound:
+		return Setting{}, err
+	}
+
+	stored, err := s.repo.RetrieveSetting(ctx, userID)
+	switch {
+	case err == nil:
+		setting.Digest = stored.Digest
+		setting.DigestedAt = stored.DigestedAt
+	case !errors.Is(err, ErrSettingNotFound):
+		return Setting{}, err
+	}
+
+	// The preferences are usable even when they cannot be cached.
+	_ = s.cacher.Add(ctx, userID, setting, 0)
+
+	return setting, nil
+}
@@ -0,0 +1,519 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package notifications_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rodneyosodo/twiga/internal/cache"
+	"github.com/rodneyosodo/twiga/internal/netguard"
+	"github.com/rodneyosodo/twiga/notifications"
+	"github.com/rodneyosodo/twiga/notifications/mocks"
+	"github.com/rodneyosodo/twiga/users/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	recipient  = "recipient"
+	maxRetries = 2
+	retryDelay = time.Minute
+)
+
+// usersClient answers with the preferences set for the user and counts the lookups.
+type usersClient struct {
+	proto.UsersServiceClient
+	mu      sync.Mutex
+	prefs   *proto.GetUserPreferencesResponse
+	err     error
+	lookups int
+}
+
+func (c *usersClient) GetUserPreferences(_ context.Context, _ *proto.GetUserPreferencesRequest, _ ...grpc.CallOption) (*proto.GetUserPreferencesResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.lookups++
+
+	return c.prefs, c.err
+}
+
+func (c *usersClient) IdentifyUser(_ context.Context, _ *proto.IdentifyUserRequest, _ ...grpc.CallOption) (*proto.IdentifyUserResponse, error) {
+	return &proto.IdentifyUserResponse{Id: recipient}, nil
+}
+
+func (c *usersClient) set(prefs *proto.GetUserPreferencesResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.prefs = prefs
+}
+
+type deps struct {
+	repo     *mocks.Repository
+	users    *usersClient
+	notifier *mocks.Notifier
+	pusher   *mocks.Notifier
+	mailer   *mocks.Mailer
+}
+
+func newService(t *testing.T, users *usersClient) (notifications.Service, deps) {
+	d := deps{
+		repo:     mocks.NewRepository(t),
+		users:    users,
+		notifier: mocks.NewNotifier(t),
+		pusher:   mocks.NewNotifier(t),
+		mailer:   mocks.NewMailer(t),
+	}
+	cacher := cache.NewMemory[notifications.Setting](100, time.Minute)
+
+	return notifications.NewService(d.repo, d.users, d.notifier, d.pusher, d.mailer, cacher, 0, maxRetries, retryDelay), d
+}
+
+func TestCreateNotificationPreferences(t *testing.T) {
+	cases := []struct {
+		desc   string
+		prefs  *proto.GetUserPreferencesResponse
+		err    error
+		digest notifications.Digest
+		push   bool
+		email  bool
+	}{
+		{
+			desc:  "push and email",
+			prefs: &proto.GetUserPreferencesResponse{PushNotifications: true, EmailNotifications: true},
+			push:  true,
+			email: true,
+		},
+		{
+			desc:  "push only",
+			prefs: &proto.GetUserPreferencesResponse{PushNotifications: true},
+			push:  true,
+		},
+		{
+			desc:  "email only",
+			prefs: &proto.GetUserPreferencesResponse{EmailNotifications: true},
+			email: true,
+		},
+		{
+			desc:  "nothing enabled",
+			prefs: &proto.GetUserPreferencesResponse{},
+		},
+		{
+			desc:   "email with a daily digest",
+			prefs:  &proto.GetUserPreferencesResponse{EmailNotifications: true},
+			digest: notifications.DailyDigest,
+		},
+		{
+			desc: "no preferences",
+			err:  status.Error(codes.NotFound, "preferences not found"),
+			push: true,
+		},
+		{
+			desc: "unavailable preferences",
+			err:  status.Error(codes.Unavailable, "users service unavailable"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			svc, d := newService(t, &usersClient{prefs: tc.prefs, err: tc.err})
+
+			notification := notifications.Notification{ActorID: "actor", RecipientID: recipient, Category: notifications.Like}
+			saved := notification
+			saved.ID = "notification"
+			d.repo.On("CreateNotification", mock.Anything, notification).Return(saved, nil)
+
+			if tc.err == nil || status.Code(tc.err) == codes.NotFound {
+				if tc.digest != "" {
+					d.repo.On("RetrieveSetting", mock.Anything, recipient).Return(notifications.Setting{UserID: recipient, Digest: tc.digest}, nil)
+				} else {
+					d.repo.On("RetrieveSetting", mock.Anything, recipient).Return(notifications.Setting{}, notifications.ErrSettingNotFound)
+				}
+			}
+			// Connected clients get the notification whatever the push preference.
+			d.notifier.On("Notify", mock.Anything, saved).Return(nil)
+			if tc.push {
+				d.pusher.On("Notify", mock.Anything, saved).Return(nil)
+			}
+			if tc.email {
+				d.repo.On("SaveDelivery", mock.Anything, mock.MatchedBy(func(delivery notifications.Delivery) bool {
+					return delivery.NotificationID == saved.ID && delivery.Channel == notifications.EmailChannel &&
+						delivery.Status == notifications.PendingStatus && !delivery.NextAttemptAt.IsZero()
+				})).Return(nil)
+			}
+
+			created, err := svc.CreateNotification(context.Background(), notification)
+			require.NoError(t, err, "delivery failures should not fail the notification")
+			assert.Equal(t, saved, created)
+
+			d.notifier.AssertNumberOfCalls(t, "Notify", 1)
+			if !tc.push {
+				d.pusher.AssertNotCalled(t, "Notify", mock.Anything, mock.Anything)
+			}
+			if !tc.email {
+				d.repo.AssertNotCalled(t, "SaveDelivery", mock.Anything, mock.Anything)
+			}
+			d.mailer.AssertNotCalled(t, "Notify", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestCreateNotificationAggregation(t *testing.T) {
+	cases := []struct {
+		desc       string
+		aggregated notifications.Notification
+		err        error
+		created    bool
+	}{
+		{
+			desc:       "aggregated",
+			aggregated: notifications.Notification{ID: "notification", RecipientID: recipient, PostID: "post", Category: notifications.Like, ActorCount: 2},
+		},
+		{
+			desc:    "nothing to aggregate",
+			err:     notifications.ErrNotFound,
+			created: true,
+		},
+		{
+			desc: "failed aggregation",
+			err:  errors.New("connection refused"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			d := deps{
+				repo:     mocks.NewRepository(t),
+				users:    &usersClient{prefs: &proto.GetUserPreferencesResponse{}},
+				notifier: mocks.NewNotifier(t),
+				pusher:   mocks.NewNotifier(t),
+				mailer:   mocks.NewMailer(t),
+			}
+			cacher := cache.NewMemory[notifications.Setting](100, time.Minute)
+			svc := notifications.NewService(d.repo, d.users, d.notifier, d.pusher, d.mailer, cacher, time.Hour, maxRetries, retryDelay)
+
+			notification := notifications.Notification{ActorID: "actor", RecipientID: recipient, PostID: "post", Category: notifications.Like}
+			saved := notification
+			saved.ID = "notification"
+
+			d.repo.On("WithTx", mock.Anything, mock.Anything).Return(func(ctx context.Context, fn func(context.Context) error) error {
+				return fn(ctx)
+			})
+			d.repo.On("AggregateNotification", mock.Anything, notification, mock.Anything).Return(tc.aggregated, tc.err)
+			if tc.created {
+				d.repo.On("CreateNotification", mock.Anything, notification).Return(saved, nil)
+				d.repo.On("RetrieveSetting", mock.Anything, recipient).Return(notifications.Setting{}, notifications.ErrSettingNotFound)
+			}
+			if tc.err == nil || tc.created {
+				d.notifier.On("Notify", mock.Anything, mock.Anything).Return(nil)
+			}
+
+			created, err := svc.CreateNotification(context.Background(), notification)
+			switch {
+			case tc.created:
+				require.NoError(t, err)
+				assert.Equal(t, saved, created)
+			case tc.err != nil:
+				assert.ErrorIs(t, err, tc.err)
+				d.notifier.AssertNotCalled(t, "Notify", mock.Anything, mock.Anything)
+			default:
+				require.NoError(t, err)
+				assert.Equal(t, tc.aggregated, created)
+			}
+			if !tc.created {
+				d.repo.AssertNotCalled(t, "CreateNotification", mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestCreateNotificationReplay(t *testing.T) {
+	cases := []struct {
+		desc    string
+		stored  notifications.Notification
+		err     error
+		created bool
+	}{
+		{
+			desc:   "event already notified",
+			stored: notifications.Notification{ID: "notification", RecipientID: recipient, Category: notifications.Follow},
+		},
+		{
+			desc:    "event not notified yet",
+			err:     notifications.ErrNotFound,
+			created: true,
+		},
+		{
+			desc: "failed lookup",
+			err:  errors.New("connection refused"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			svc, d := newService(t, &usersClient{prefs: &proto.GetUserPreferencesResponse{}})
+
+			notification := notifications.Notification{ActorID: "actor", RecipientID: recipient, Category: notifications.Follow, EventID: "event"}
+			saved := notification
+			saved.ID = "notification"
+
+			d.repo.On("RetrieveEventNotification", mock.Anything, "event", recipient).Return(tc.stored, tc.err)
+			if tc.created {
+				d.repo.On("CreateNotification", mock.Anything, notification).Return(saved, nil)
+				d.repo.On("RetrieveSetting", mock.Anything, recipient).Return(notifications.Setting{}, notifications.ErrSettingNotFound)
+				d.notifier.On("Notify", mock.Anything, saved).Return(nil)
+			}
+
+			created, err := svc.CreateNotification(context.Background(), notification)
+			switch {
+			case tc.created:
+				require.NoError(t, err)
+				assert.Equal(t, saved, created)
+			case tc.err != nil:
+				assert.ErrorIs(t, err, tc.err)
+			default:
+				require.NoError(t, err)
+				assert.Equal(t, tc.stored, created)
+			}
+			if !tc.created {
+				d.repo.AssertNotCalled(t, "CreateNotification", mock.Anything, mock.Anything)
+				d.notifier.AssertNotCalled(t, "Notify", mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestInvalidateSetting(t *testing.T) {
+	users := &usersClient{prefs: &proto.GetUserPreferencesResponse{PushNotifications: true}}
+	svc, d := newService(t, users)
+	ctx := context.Background()
+
+	notification := notifications.Notification{ActorID: "actor", RecipientID: recipient, Category: notifications.Follow}
+	d.repo.On("CreateNotification", mock.Anything, notification).Return(notification, nil)
+	d.repo.On("RetrieveSetting", mock.Anything, recipient).Return(notifications.Setting{}, notifications.ErrSettingNotFound)
+	d.notifier.On("Notify", mock.Anything, notification).Return(nil)
+	d.pusher.On("Notify", mock.Anything, notification).Return(nil).Twice()
+
+	for i := 0; i < 2; i++ {
+		_, err := svc.CreateNotification(ctx, notification)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 1, users.lookups, "preferences should be cached")
+
+	// Disabling push only takes effect once the cached preferences are invalidated.
+	users.set(&proto.GetUserPreferencesResponse{})
+	require.NoError(t, svc.InvalidateSetting(ctx, recipient))
+
+	_, err := svc.CreateNotification(ctx, notification)
+	require.NoError(t, err)
+	assert.Equal(t, 2, users.lookups, "invalidated preferences should be fetched again")
+	d.notifier.AssertNumberOfCalls(t, "Notify", 3)
+	d.pusher.AssertNumberOfCalls(t, "Notify", 2)
+}
+
+func TestProcessDeliveries(t *testing.T) {
+	cases := []struct {
+		desc     string
+		attempts uint64
+		err      error
+		status   string
+		backoff  time.Duration
+	}{
+		{
+			desc:   "sent",
+			status: notifications.DeliveredStatus,
+		},
+		{
+			desc:    "first failure",
+			err:     errors.New("try again later"),
+			status:  notifications.PendingStatus,
+			backoff: retryDelay,
+		},
+		{
+			desc:     "second failure",
+			attempts: 1,
+			err:      errors.New("try again later"),
+			status:   notifications.PendingStatus,
+			backoff:  2 * retryDelay,
+		},
+		{
+			desc:     "retries exhausted",
+			attempts: maxRetries,
+			err:      errors.New("try again later"),
+			status:   notifications.FailedStatus,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			svc, d := newService(t, &usersClient{})
+
+			notification := notifications.Notification{ID: "notification", RecipientID: recipient, Category: notifications.Like}
+			delivery := notifications.Delivery{
+				NotificationID: notification.ID,
+				Channel:        notifications.EmailChannel,
+				Status:         notifications.PendingStatus,
+				Attempts:       tc.attempts,
+			}
+			d.repo.On("ClaimDeliveries", mock.Anything, notifications.EmailChannel, mock.Anything, mock.Anything).Return([]notifications.Delivery{delivery}, nil)
+			d.repo.On("RetrieveNotification", mock.Anything, notification.ID).Return(notification, nil)
+			d.mailer.On("Notify", mock.Anything, notification).Return(tc.err)
+
+			var saved notifications.Delivery
+			d.repo.On("SaveDelivery", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+				saved = args.Get(1).(notifications.Delivery)
+			}).Return(nil)
+
+			start := time.Now().UTC()
+			require.NoError(t, svc.ProcessDeliveries(context.Background()))
+
+			assert.Equal(t, tc.status, saved.Status)
+			assert.Equal(t, tc.attempts+1, saved.Attempts)
+			if tc.err != nil {
+				assert.Equal(t, tc.err.Error(), saved.Error)
+			}
+			if tc.backoff > 0 {
+				assert.WithinDuration(t, start.Add(tc.backoff), saved.NextAttemptAt, time.Second)
+			}
+		})
+	}
+}
+
+func TestSendDigests(t *testing.T) {
+	cases := []struct {
+		desc    string
+		claimed bool
+		sendErr error
+		status  string
+	}{
+		{
+			desc:    "sent",
+			claimed: true,
+			status:  notifications.DeliveredStatus,
+		},
+		{
+			desc:    "failed",
+			claimed: true,
+			sendErr: errors.New("smtp unavailable"),
+			status:  notifications.FailedStatus,
+		},
+		{
+			desc: "claimed by another scheduler",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			svc, d := newService(t, &usersClient{prefs: &proto.GetUserPreferencesResponse{EmailNotifications: true}})
+
+			setting := notifications.Setting{UserID: recipient, Digest: notifications.DailyDigest}
+			items := []notifications.Notification{
+				{ID: "like", RecipientID: recipient, Category: notifications.Like},
+				{ID: "comment", RecipientID: recipient, Category: notifications.Comment},
+			}
+			d.repo.On("RetrieveDueSettings", mock.Anything, notifications.DailyDigest, mock.Anything).Return([]notifications.Setting{setting}, nil)
+			d.repo.On("RetrieveSetting", mock.Anything, recipient).Return(setting, nil)
+			d.repo.On("RetrieveUndigested", mock.Anything, recipient, mock.Anything).Return(items, nil)
+			d.repo.On("ClaimDigest", mock.Anything, recipient, mock.Anything, []string{"like", "comment"}).Return(tc.claimed, nil)
+			if tc.claimed {
+				d.mailer.On("SendDigest", mock.Anything, recipient, notifications.DailyDigest, mock.Anything).Return(tc.sendErr)
+			}
+
+			saved := make(map[string]notifications.Delivery)
+			d.repo.On("SaveDelivery", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+				delivery := args.Get(1).(notifications.Delivery)
+				saved[delivery.NotificationID] = delivery
+			}).Return(nil).Maybe()
+
+			err := svc.SendDigests(context.Background(), notifications.DailyDigest)
+			if tc.sendErr != nil {
+				assert.ErrorContains(t, err, tc.sendErr.Error())
+			} else {
+				require.NoError(t, err)
+			}
+
+			if !tc.claimed {
+				d.mailer.AssertNotCalled(t, "SendDigest", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+				assert.Empty(t, saved)
+
+				return
+			}
+			require.Len(t, saved, len(items))
+			for _, n := range items {
+				assert.Equal(t, notifications.DigestChannel, saved[n.ID].Channel)
+				assert.Equal(t, tc.status, saved[n.ID].Status)
+			}
+		})
+	}
+}
+
+func TestCreateSubscription(t *testing.T) {
+	keys := notifications.SubscriptionKeys{P256dh: "p256dh", Auth: "auth"}
+
+	cases := []struct {
+		desc     string
+		endpoint string
+		err      error
+	}{
+		{
+			desc:     "public https endpoint",
+			endpoint: "https://203.0.113.10/push",
+		},
+		{
+			desc:     "http endpoint",
+			endpoint: "http://203.0.113.10/push",
+			err:      errors.New("endpoint must be an absolute https url"),
+		},
+		{
+			desc:     "relative endpoint",
+			endpoint: "/push",
+			err:      errors.New("endpoint must be an absolute https url"),
+		},
+		{
+			desc:     "loopback endpoint",
+			endpoint: "https://127.0.0.1/push",
+			err:      netguard.ErrInternalAddress,
+		},
+		{
+			desc:     "metadata endpoint",
+			endpoint: "https://169.254.169.254/latest",
+			err:      netguard.ErrInternalAddress,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			svc, d := newService(t, &usersClient{})
+
+			subscription := notifications.Subscription{Endpoint: tc.endpoint, Keys: keys}
+			if tc.err == nil {
+				saved := subscription
+				saved.UserID = recipient
+				d.repo.On("SaveSubscription", mock.Anything, saved).Return(saved, nil)
+			}
+
+			_, err := svc.CreateSubscription(context.Background(), "token", subscription)
+			if tc.err != nil {
+				assert.ErrorContains(t, err, tc.err.Error())
+				d.repo.AssertNotCalled(t, "SaveSubscription", mock.Anything, mock.Anything)
+
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
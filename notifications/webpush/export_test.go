@@ -0,0 +1,23 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package webpush
+
+import (
+	"net/http"
+	"time"
+)
+
+// AllowInternalAddresses lets the pushers created during a test reach the local push service.
+func AllowInternalAddresses() (restore func()) {
+	previous := newHTTPClient
+	newHTTPClient = func(timeout time.Duration) *http.Client {
+		return &http.Client{Timeout: timeout}
+	}
+
+	return func() { newHTTPClient = previous }
+}
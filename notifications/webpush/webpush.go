@@ -0,0 +1,128 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package webpush
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	wp "github.com/SherClockHolmes/webpush-go"
+	"github.com/rodneyosodo/twiga/internal/netguard"
+	"github.com/rodneyosodo/twiga/notifications"
+)
+
+var (
+	_ notifications.Notifier = (*pusher)(nil)
+	_ notifications.Notifier = (*noop)(nil)
+
+	// ErrMissingKeys indicates that web push is not configured.
+	ErrMissingKeys = errors.New("VAPID public and private keys are required")
+
+	// newHTTPClient returns the client for the endpoints of the subscriptions,
+	// which are registered by users and so must not reach internal addresses.
+	newHTTPClient = netguard.NewHTTPClient
+)
+
+type Config struct {
+	VAPIDPublicKey  string        `env:"VAPID_PUBLIC_KEY"  envDefault:""`
+	VAPIDPrivateKey string        `env:"VAPID_PRIVATE_KEY" envDefault:""`
+	Subscriber      string        `env:"SUBSCRIBER"        envDefault:"mailto:noreply@twiga.local"`
+	TTL             time.Duration `env:"TTL"               envDefault:"24h"`
+	Timeout         time.Duration `env:"TIMEOUT"           envDefault:"10s"`
+}
+
+type pusher struct {
+	cfg        Config
+	repo       notifications.Repository
+	httpClient *http.Client
+}
+
+// New returns a notifier that sends notifications to the browser push subscriptions
+// of their recipients. Payloads are encrypted as described in RFC 8291 and requests
+// are signed with the VAPID keys. Subscriptions that no longer exist are removed.
+func New(cfg Config, repo notifications.Repository) (notifications.Notifier, error) {
+	if cfg.VAPIDPublicKey == "" || cfg.VAPIDPrivateKey == "" {
+		return nil, ErrMissingKeys
+	}
+
+	return &pusher{
+		cfg:        cfg,
+		repo:       repo,
+		httpClient: newHTTPClient(cfg.Timeout),
+	}, nil
+}
+
+type noop struct{}
+
+// NewNoop returns a notifier that drops every notification, used when web push is not configured.
+func NewNoop() notifications.Notifier {
+	return noop{}
+}
+
+func (noop) Notify(context.Context, notifications.Notification) error {
+	return nil
+}
+
+func (p *pusher) Notify(ctx context.Context, notification notifications.Notification) error {
+	subscriptions, err := p.repo.RetrieveSubscriptions(ctx, notification.RecipientID)
+	if err != nil {
+		return err
+	}
+	if len(subscriptions) == 0 {
+		return nil
+	}
+
+	payload, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+
+	// Every subscription is tried so that one failing browser does not prevent the others.
+	var errs error
+	for _, subscription := range subscriptions {
+		if err := p.send(ctx, subscription, payload); err != nil {
+			errs = errors.Join(errs, err)
+		}
+	}
+
+	return errs
+}
+
+func (p *pusher) send(ctx context.Context, subscription notifications.Subscription, payload []byte) error {
+	resp, err := wp.SendNotificationWithContext(ctx, payload, &wp.Subscription{
+		Endpoint: subscription.Endpoint,
+		Keys: wp.Keys{
+			Auth:   subscription.Keys.Auth,
+			P256dh: subscription.Keys.P256dh,
+		},
+	}, &wp.Options{
+		HTTPClient:      p.httpClient,
+		Subscriber:      p.cfg.Subscriber,
+		TTL:             int(p.cfg.TTL.Seconds()),
+		VAPIDPublicKey:  p.cfg.VAPIDPublicKey,
+		VAPIDPrivateKey: p.cfg.VAPIDPrivateKey,
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		// The browser unsubscribed or the subscription expired.
+		return p.repo.DeleteSubscription(ctx, subscription.UserID, subscription.ID)
+	case resp.StatusCode >= http.StatusBadRequest:
+		return fmt.Errorf("failed to push notification to %s: %s", subscription.Endpoint, resp.Status)
+	default:
+		return nil
+	}
+}
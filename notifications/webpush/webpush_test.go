@@ -0,0 +1,297 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package webpush_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	wp "github.com/SherClockHolmes/webpush-go"
+	"github.com/rodneyosodo/twiga/internal/netguard"
+	"github.com/rodneyosodo/twiga/notifications"
+	"github.com/rodneyosodo/twiga/notifications/mocks"
+	"github.com/rodneyosodo/twiga/notifications/webpush"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/hkdf"
+)
+
+// pushService is a local push service stand-in that answers every endpoint
+// with the status of its path and records the requests it receives.
+type pushService struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []pushRequest
+}
+
+type pushRequest struct {
+	header http.Header
+	body   []byte
+}
+
+var statuses = map[string]int{
+	"/ok":      http.StatusCreated,
+	"/gone":    http.StatusGone,
+	"/missing": http.StatusNotFound,
+	"/error":   http.StatusInternalServerError,
+}
+
+func newPushService(t *testing.T) *pushService {
+	ps := &pushService{}
+	ps.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+
+			return
+		}
+
+		ps.mu.Lock()
+		ps.requests = append(ps.requests, pushRequest{header: r.Header.Clone(), body: body})
+		ps.mu.Unlock()
+
+		w.WriteHeader(statuses[r.URL.Path])
+	}))
+	t.Cleanup(ps.Close)
+
+	return ps
+}
+
+func (ps *pushService) received() []pushRequest {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	return append([]pushRequest{}, ps.requests...)
+}
+
+// browser holds the keys a browser generates when subscribing.
+type browser struct {
+	key  *ecdh.PrivateKey
+	auth []byte
+}
+
+func newBrowser(t *testing.T) browser {
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	auth := make([]byte, 16)
+	_, err = rand.Read(auth)
+	require.NoError(t, err)
+
+	return browser{key: key, auth: auth}
+}
+
+func (b browser) keys() notifications.SubscriptionKeys {
+	return notifications.SubscriptionKeys{
+		P256dh: base64.RawURLEncoding.EncodeToString(b.key.PublicKey().Bytes()),
+		Auth:   base64.RawURLEncoding.EncodeToString(b.auth),
+	}
+}
+
+// decrypt reverses the aes128gcm content encoding of RFC 8188 with the keys derived as in RFC 8291.
+func (b browser) decrypt(t *testing.T, body []byte) []byte {
+	salt, keyLen := body[:16], int(body[20])
+	serverKey, ciphertext := body[21:21+keyLen], body[21+keyLen:]
+
+	serverPublic, err := ecdh.P256().NewPublicKey(serverKey)
+	require.NoError(t, err)
+	secret, err := b.key.ECDH(serverPublic)
+	require.NoError(t, err)
+
+	info := append([]byte("WebPush: info\x00"), b.key.PublicKey().Bytes()...)
+	info = append(info, serverKey...)
+	ikm := derive(t, secret, b.auth, info, 32)
+	cek := derive(t, ikm, salt, []byte("Content-Encoding: aes128gcm\x00"), 16)
+	nonce := derive(t, ikm, salt, []byte("Content-Encoding: nonce\x00"), 12)
+
+	block, err := aes.NewCipher(cek)
+	require.NoError(t, err)
+	gcm, err := cipher.NewGCM(block)
+	require.NoError(t, err)
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	require.NoError(t, err)
+
+	// The last record ends with a 0x02 delimiter followed by zero padding.
+	plaintext = bytes.TrimRight(plaintext, "\x00")
+	require.NotEmpty(t, plaintext)
+	require.Equal(t, byte(0x02), plaintext[len(plaintext)-1])
+
+	return plaintext[:len(plaintext)-1]
+}
+
+func derive(t *testing.T, secret, salt, info []byte, length int) []byte {
+	key := make([]byte, length)
+	_, err := io.ReadFull(hkdf.New(sha256.New, secret, salt, info), key)
+	require.NoError(t, err)
+
+	return key
+}
+
+func TestNew(t *testing.T) {
+	_, err := webpush.New(webpush.Config{}, mocks.NewRepository(t))
+	assert.ErrorIs(t, err, webpush.ErrMissingKeys)
+
+	assert.NoError(t, webpush.NewNoop().Notify(context.Background(), notifications.Notification{}), "a disabled notifier should drop notifications")
+}
+
+func TestNotify(t *testing.T) {
+	t.Cleanup(webpush.AllowInternalAddresses())
+
+	privateKey, publicKey, err := wp.GenerateVAPIDKeys()
+	require.NoError(t, err)
+
+	notification := notifications.Notification{
+		ID:          "notification",
+		ActorID:     "actor",
+		RecipientID: "recipient",
+		Category:    notifications.Like,
+		ActorCount:  1,
+		Actors:      []string{"actor"},
+	}
+
+	cases := []struct {
+		desc     string
+		paths    []string
+		repoErr  error
+		pruned   []string
+		received int
+		err      error
+	}{
+		{
+			desc:     "delivered",
+			paths:    []string{"/ok"},
+			received: 1,
+		},
+		{
+			desc:     "delivered to every subscription",
+			paths:    []string{"/ok", "/ok"},
+			received: 2,
+		},
+		{
+			desc:     "no subscriptions",
+			paths:    []string{},
+			received: 0,
+		},
+		{
+			desc:     "gone subscription is pruned",
+			paths:    []string{"/gone", "/ok"},
+			pruned:   []string{"/gone"},
+			received: 2,
+		},
+		{
+			desc:     "missing subscription is pruned",
+			paths:    []string{"/missing"},
+			pruned:   []string{"/missing"},
+			received: 1,
+		},
+		{
+			desc:     "push service error",
+			paths:    []string{"/error", "/ok"},
+			received: 2,
+			err:      errors.New("500 Internal Server Error"),
+		},
+		{
+			desc:    "repository error",
+			repoErr: errors.New("connection refused"),
+			err:     errors.New("connection refused"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			ps := newPushService(t)
+			repo := mocks.NewRepository(t)
+			pusher, err := webpush.New(webpush.Config{
+				VAPIDPublicKey:  publicKey,
+				VAPIDPrivateKey: privateKey,
+				Subscriber:      "mailto:noreply@twiga.local",
+				TTL:             time.Hour,
+				Timeout:         time.Second,
+			}, repo)
+			require.NoError(t, err)
+
+			b := newBrowser(t)
+			subscriptions := make([]notifications.Subscription, 0, len(tc.paths))
+			for i, path := range tc.paths {
+				subscriptions = append(subscriptions, notifications.Subscription{
+					ID:       path + string(rune('a'+i)),
+					UserID:   notification.RecipientID,
+					Endpoint: ps.URL + path,
+					Keys:     b.keys(),
+				})
+			}
+			repo.On("RetrieveSubscriptions", mock.Anything, notification.RecipientID).Return(subscriptions, tc.repoErr)
+			for _, s := range subscriptions {
+				for _, path := range tc.pruned {
+					if strings.HasSuffix(s.Endpoint, path) {
+						repo.On("DeleteSubscription", mock.Anything, s.UserID, s.ID).Return(nil)
+					}
+				}
+			}
+
+			err = pusher.Notify(context.Background(), notification)
+			if tc.err != nil {
+				assert.ErrorContains(t, err, tc.err.Error())
+			} else {
+				require.NoError(t, err)
+			}
+
+			received := ps.received()
+			require.Len(t, received, tc.received)
+			for _, r := range received {
+				assert.Equal(t, "aes128gcm", r.header.Get("Content-Encoding"))
+				assert.Equal(t, "3600", r.header.Get("TTL"))
+				assert.True(t, strings.HasPrefix(r.header.Get("Authorization"), "vapid t="))
+				assert.Contains(t, r.header.Get("Authorization"), "k="+publicKey)
+
+				var pushed notifications.Notification
+				require.NoError(t, json.Unmarshal(b.decrypt(t, r.body), &pushed))
+				assert.Equal(t, notification, pushed)
+			}
+		})
+	}
+}
+
+func TestNotifyInternalAddress(t *testing.T) {
+	privateKey, publicKey, err := wp.GenerateVAPIDKeys()
+	require.NoError(t, err)
+
+	ps := newPushService(t)
+	repo := mocks.NewRepository(t)
+	pusher, err := webpush.New(webpush.Config{
+		VAPIDPublicKey:  publicKey,
+		VAPIDPrivateKey: privateKey,
+		Subscriber:      "mailto:noreply@twiga.local",
+		TTL:             time.Hour,
+		Timeout:         time.Second,
+	}, repo)
+	require.NoError(t, err)
+
+	repo.On("RetrieveSubscriptions", mock.Anything, "recipient").Return([]notifications.Subscription{
+		{ID: "subscription", UserID: "recipient", Endpoint: ps.URL + "/ok", Keys: newBrowser(t).keys()},
+	}, nil)
+
+	err = pusher.Notify(context.Background(), notifications.Notification{ID: "notification", RecipientID: "recipient", Category: notifications.Like})
+	assert.ErrorIs(t, err, netguard.ErrInternalAddress, "subscriptions should not reach internal addresses")
+	assert.Empty(t, ps.received())
+}
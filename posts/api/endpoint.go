@@ -8,8 +8,10 @@
 package api
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/chenjiandongx/ginprom"
 	"github.com/gin-gonic/gin"
@@ -57,6 +59,39 @@ func Endpoints(router *gin.Engine, svc posts.Service) {
 	router.GET("/metrics", ginprom.PromHandler(promhttp.Handler()))
 }
 
+// AdminEndpoints registers the endpoints the other services call with the admin
+// token. Nothing is registered without one.
+func AdminEndpoints(router *gin.Engine, svc posts.Service, token string) {
+	if token == "" {
+		return
+	}
+
+	admin := router.Group("/admin", iapi.GinAdminAuth(token))
+	admin.GET("/posts/:id", getPostAsAdmin(svc))
+}
+
+func getPostAsAdmin(svc posts.Service) func(ctx *gin.Context) {
+	return func(ctx *gin.Context) {
+		post, err := svc.RetrievePost(ctx, ctx.Param("id"))
+		switch {
+		case errors.Is(err, posts.ErrNotFound):
+			ctx.JSON(http.StatusNotFound, gin.H{
+				"error": err.Error(),
+			})
+
+			return
+		case err != nil:
+			ctx.JSON(http.StatusInternalServerError, gin.H{
+				"error": err.Error(),
+			})
+
+			return
+		}
+
+		ctx.JSON(http.StatusOK, post)
+	}
+}
+
 func createPost(svc posts.Service) func(ctx *gin.Context) {
 	return func(ctx *gin.Context) {
 		token := iapi.GinExtractToken(ctx)
@@ -156,9 +191,13 @@ func getPosts(svc posts.Service) func(ctx *gin.Context) {
 			Offset:     offset,
 			Limit:      limit,
 			Visibility: visibility,
+			Cursor:     ctx.DefaultQuery("cursor", ""),
 			UserID:     ctx.DefaultQuery("user_id", ""),
 			Tag:        ctx.DefaultQuery("tags", ""),
 		}
+		if userIDs := ctx.DefaultQuery("user_ids", ""); userIDs != "" {
+			page.UserIDs = strings.Split(userIDs, ",")
+		}
 
 		posts, err := svc.RetrieveAllPosts(ctx, token, page)
 		if err != nil {
@@ -365,6 +404,7 @@ func getComments(svc posts.Service) func(ctx *gin.Context) {
 		page := posts.Page{
 			Offset: offset,
 			Limit:  limit,
+			Cursor: ctx.DefaultQuery("cursor", ""),
 			UserID: ctx.DefaultQuery("user_id", ""),
 			PostID: postID,
 		}
@@ -505,7 +545,9 @@ func getLikes(svc posts.Service) func(ctx *gin.Context) {
 		page := posts.Page{
 			Offset: offset,
 			Limit:  limit,
+			Cursor: ctx.DefaultQuery("cursor", ""),
 			UserID: ctx.DefaultQuery("user_id", ""),
+			PostID: ctx.Param("id"),
 		}
 
 		likes, err := svc.RetrieveAllLikes(ctx, token, page)
@@ -612,7 +654,9 @@ func getShares(svc posts.Service) func(ctx *gin.Context) {
 		page := posts.Page{
 			Offset: offset,
 			Limit:  limit,
+			Cursor: ctx.DefaultQuery("cursor", ""),
 			UserID: ctx.DefaultQuery("user_id", ""),
+			PostID: ctx.Param("id"),
 		}
 
 		shares, err := svc.RetrieveAllShares(ctx, token, page)
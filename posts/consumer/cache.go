@@ -0,0 +1,52 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package consumer
+
+import (
+	"github.com/rodneyosodo/twiga/internal/events"
+)
+
+// PostKeys returns the cached posts changed by the event. Posts are cached
+// with their comments, likes and shares, so their events change the post too.
+// Deletions of comments and shares do not carry the post, so the service
+// drops it from the cache itself.
+func PostKeys(env events.Envelope) ([]string, error) {
+	switch env.Type {
+	case events.PostCreated, events.PostUpdated, events.PostContentUpdated, events.PostTagsUpdated,
+		events.PostImageUpdated, events.PostVisibilityUpdated, events.PostDeleted:
+		data, err := events.DecodeData[events.PostEvent](env)
+		if err != nil {
+			return nil, err
+		}
+
+		return []string{data.ID}, nil
+	case events.CommentCreated, events.CommentUpdated, events.CommentDeleted:
+		data, err := events.DecodeData[events.CommentEvent](env)
+		if err != nil {
+			return nil, err
+		}
+
+		return []string{data.PostID}, nil
+	case events.LikeCreated, events.LikeDeleted:
+		data, err := events.DecodeData[events.LikeEvent](env)
+		if err != nil {
+			return nil, err
+		}
+
+		return []string{data.PostID}, nil
+	case events.ShareCreated, events.ShareDeleted:
+		data, err := events.DecodeData[events.ShareEvent](env)
+		if err != nil {
+			return nil, err
+		}
+
+		return []string{data.PostID}, nil
+	default:
+		return nil, nil
+	}
+}
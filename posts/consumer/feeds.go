@@ -0,0 +1,186 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rodneyosodo/twiga/internal/events"
+	"github.com/rodneyosodo/twiga/posts"
+	"github.com/rodneyosodo/twiga/users/proto"
+)
+
+const (
+	defBatchSize  = 100
+	defRetryDelay = 100 * time.Millisecond
+)
+
+type feedHandler struct {
+	repo       posts.Repository
+	users      proto.UsersServiceClient
+	batchSize  uint64
+	maxRetries uint64
+	threshold  uint64
+}
+
+// NewFeedHandler returns an event handler that writes new posts into the feeds
+// of the author's followers and removes them once the post is deleted or made private.
+// Posts by authors with more than threshold followers are not fanned out since
+// the users service merges them into feeds at read time; a zero threshold fans out every post.
+func NewFeedHandler(repo posts.Repository, users proto.UsersServiceClient, batchSize, maxRetries, threshold uint64) events.EventHandler {
+	if batchSize == 0 {
+		batchSize = defBatchSize
+	}
+
+	return &feedHandler{
+		repo:       repo,
+		users:      users,
+		batchSize:  batchSize,
+		maxRetries: maxRetries,
+		threshold:  threshold,
+	}
+}
+
+func (fh *feedHandler) Handle(ctx context.Context, msg map[string]interface{}) error {
+	env, err := events.Decode(msg)
+	if err != nil {
+		return err
+	}
+	data, err := events.DecodeData[events.PostEvent](env)
+	if err != nil {
+		return err
+	}
+	postID := data.ID
+	if postID == "" {
+		return nil
+	}
+
+	topic := env.Type
+	switch {
+	case topic == events.PostCreated:
+		// Creations always carry the visibility, see events.HasVisibility.
+		if data.Visibility {
+			err = fh.fanOut(ctx, postID, data.UserID)
+		}
+	case topic == events.PostUpdated || topic == events.PostVisibilityUpdated:
+		// Only these updates can change the visibility, and feed entries do
+		// not hold anything else about the post.
+		err = fh.sync(ctx, postID)
+	case topic == events.PostDeleted:
+		err = fh.remove(ctx, postID)
+	default:
+		return nil
+	}
+
+	// Failed updates are retried by the subscription, so they must be idempotent.
+	if err != nil {
+		return fmt.Errorf("failed to update feeds for post %s on %s: %w", postID, topic, err)
+	}
+
+	return nil
+}
+
+func (fh *feedHandler) Cancel() error {
+	return nil
+}
+
+// sync reconciles the feeds with the current state of the post since update
+// events do not always carry the author or the visibility of the post.
+func (fh *feedHandler) sync(ctx context.Context, postID string) error {
+	post, err := fh.repo.RetrieveByID(ctx, postID)
+	if err != nil {
+		if errors.Is(err, posts.ErrNotFound) {
+			return fh.remove(ctx, postID)
+		}
+
+		return err
+	}
+
+	if !post.Visibility {
+		return fh.remove(ctx, postID)
+	}
+
+	return fh.fanOut(ctx, post.ID, post.UserID)
+}
+
+func (fh *feedHandler) fanOut(ctx context.Context, postID, userID string) error {
+	if userID == "" {
+		return nil
+	}
+
+	// The first page carries the follower count; the following ones are read
+	// with its cursor so that new follows do not shift the pages.
+	req := &proto.GetUserFollowersRequest{Id: userID, Offset: 0, Limit: fh.batchSize}
+	for {
+		var followers *proto.GetUserFollowersResponse
+		if err := fh.retry(ctx, func() (err error) {
+			followers, err = fh.users.GetUserFollowers(ctx, req)
+
+			return err
+		}); err != nil {
+			return err
+		}
+		if req.GetCursor() == "" && fh.threshold > 0 && followers.GetTotal() > fh.threshold {
+			return nil
+		}
+
+		userIDs := make([]string, 0, len(followers.GetFollowings()))
+		for _, f := range followers.GetFollowings() {
+			userIDs = append(userIDs, f.GetFollowerId())
+		}
+
+		if len(userIDs) > 0 {
+			feed := &proto.CreateFeedRequest{PostId: postID, UserIds: userIDs, AuthorId: userID}
+			if err := fh.retry(ctx, func() error {
+				_, err := fh.users.CreateFeed(ctx, feed)
+
+				return err
+			}); err != nil {
+				return err
+			}
+		}
+
+		if followers.GetNextCursor() == "" {
+			return nil
+		}
+		req.Cursor = followers.GetNextCursor()
+	}
+}
+
+func (fh *feedHandler) remove(ctx context.Context, postID string) error {
+	return fh.retry(ctx, func() error {
+		_, err := fh.users.DeleteFeed(ctx, &proto.DeleteFeedRequest{PostId: postID})
+
+		return err
+	})
+}
+
+// retry calls fn until it succeeds or maxRetries is exhausted, doubling the delay between attempts.
+func (fh *feedHandler) retry(ctx context.Context, fn func() error) error {
+	delay := defRetryDelay
+
+	var err error
+	for attempt := uint64(0); ; attempt++ {
+		if err = fn(); err == nil {
+			return nil
+		}
+		if attempt >= fh.maxRetries {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+			delay *= 2
+		}
+	}
+}
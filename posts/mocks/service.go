@@ -198,6 +198,34 @@ func (_m *Service) DeleteShare(ctx context.Context, token string, postID string)
 	return r0
 }
 
+// IdentifyUser provides a mock function with given fields: ctx, token
+func (_m *Service) IdentifyUser(ctx context.Context, token string) (string, error) {
+	ret := _m.Called(ctx, token)
+
+	if len(ret) == 0 {
+		panic("no return value specified for IdentifyUser")
+	}
+
+	var r0 string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (string, error)); ok {
+		return rf(ctx, token)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) string); ok {
+		r0 = rf(ctx, token)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, token)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // RetrieveAllComments provides a mock function with given fields: ctx, token, page
 func (_m *Service) RetrieveAllComments(ctx context.Context, token string, page posts.Page) (posts.CommentsPage, error) {
 	ret := _m.Called(ctx, token, page)
@@ -338,6 +366,34 @@ func (_m *Service) RetrieveCommentByID(ctx context.Context, token string, id str
 	return r0, r1
 }
 
+// RetrievePost provides a mock function with given fields: ctx, id
+func (_m *Service) RetrievePost(ctx context.Context, id string) (posts.Post, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RetrievePost")
+	}
+
+	var r0 posts.Post
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (posts.Post, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) posts.Post); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(posts.Post)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // RetrievePostByID provides a mock function with given fields: ctx, token, id
 func (_m *Service) RetrievePostByID(ctx context.Context, token string, id string) (posts.Post, error) {
 	ret := _m.Called(ctx, token, id)
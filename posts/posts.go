@@ -10,11 +10,18 @@ package posts
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"time"
 )
 
+// ErrNotFound is returned when the post does not exist.
+var ErrNotFound = errors.New("post not found")
+
+// Comment is a comment embedded in a post. The post is only set on comments
+// retrieved by their ID.
 type Comment struct {
 	ID        string    `bson:"id,omitempty" json:"id"`
+	PostID    string    `bson:"-"            json:"post_id,omitempty"`
 	Content   string    `bson:"content"      json:"content"`
 	CreatedAt time.Time `bson:"created_at"   json:"created_at"`
 	UpdateAt  time.Time `bson:"updated_at"   json:"updated_at"`
@@ -66,8 +73,11 @@ func (page LikesPage) MarshalJSON() ([]byte, error) {
 	return json.Marshal(a)
 }
 
+// Share is a share embedded in a post. The post is only set on shares
+// retrieved by their ID.
 type Share struct {
 	ID        string    `bson:"id,omitempty" json:"id"`
+	PostID    string    `bson:"-"            json:"post_id,omitempty"`
 	UserID    string    `bson:"user_id"      json:"user_id"`
 	CreatedAt time.Time `bson:"created_at"   json:"created_at"`
 }
@@ -129,14 +139,17 @@ func (post Post) MarshalJSON() ([]byte, error) {
 }
 
 type Page struct {
-	Total      uint64 `db:"total"                json:"total"`
-	Offset     uint64 `db:"offset"               json:"offset"`
-	Limit      uint64 `db:"limit"                json:"limit"`
-	Tag        string `db:"tag,omitempty"        json:"tag,omitempty"`
-	PostID     string `db:"post_id,omitempty"    json:"post_id,omitempty"`
-	Visibility bool   `db:"visibility,omitempty" json:"visibility,omitempty"`
-	UserID     string `db:"user_id,omitempty"    json:"user_id,omitempty"`
-	CommentID  string `db:"comment_id,omitempty" json:"comment_id,omitempty"`
+	Total      uint64   `db:"total"                 json:"total"`
+	Offset     uint64   `db:"offset"                json:"offset"`
+	Limit      uint64   `db:"limit"                 json:"limit"`
+	Cursor     string   `db:"cursor,omitempty"      json:"cursor,omitempty"`
+	NextCursor string   `db:"next_cursor,omitempty" json:"next_cursor,omitempty"`
+	Tag        string   `db:"tag,omitempty"         json:"tag,omitempty"`
+	PostID     string   `db:"post_id,omitempty"     json:"post_id,omitempty"`
+	Visibility bool     `db:"visibility,omitempty"  json:"visibility,omitempty"`
+	UserID     string   `db:"user_id,omitempty"     json:"user_id,omitempty"`
+	UserIDs    []string `db:"user_ids,omitempty"    json:"user_ids,omitempty"`
+	CommentID  string   `db:"comment_id,omitempty"  json:"comment_id,omitempty"`
 }
 
 type PostsPage struct {
@@ -189,8 +202,14 @@ type Repository interface { //nolint:interfacebloat
 
 //go:generate mockery --name Service --output=./mocks --filename service.go --quiet
 type Service interface { //nolint:interfacebloat
+	// IdentifyUser returns the ID of the user the token belongs to.
+	IdentifyUser(ctx context.Context, token string) (string, error)
+
 	CreatePost(ctx context.Context, token string, post Post) (Post, error)
 	RetrievePostByID(ctx context.Context, token string, id string) (Post, error)
+	// RetrievePost returns the post to the other services, which are trusted
+	// and not identified.
+	RetrievePost(ctx context.Context, id string) (Post, error)
 	RetrieveAllPosts(ctx context.Context, token string, page Page) (PostsPage, error)
 	UpdatePost(ctx context.Context, token string, post Post) (Post, error)
 	UpdatePostContent(ctx context.Context, token string, post Post) (Post, error)
@@ -9,36 +9,46 @@ package producer
 
 import (
 	"context"
-	"encoding/json"
 
 	"github.com/rodneyosodo/twiga/internal/events"
+	"github.com/rodneyosodo/twiga/internal/events/outbox"
 	"github.com/rodneyosodo/twiga/posts"
 )
 
+const source = "/twiga/posts"
+
 var _ posts.Service = (*eventStore)(nil)
 
 type eventStore struct {
-	publisher events.Publisher
-	svc       posts.Service
+	outbox outbox.Store
+	svc    posts.Service
 }
 
-func NewEventStore(pub events.Publisher, svc posts.Service) posts.Service {
+// NewEventStore returns a service that records an event for every change in the
+// outbox, in the same transaction as the change, for the relay to publish.
+func NewEventStore(store outbox.Store, svc posts.Service) posts.Service {
 	return &eventStore{
-		publisher: pub,
-		svc:       svc,
+		outbox: store,
+		svc:    svc,
 	}
 }
 
+func (e *eventStore) IdentifyUser(ctx context.Context, token string) (string, error) {
+	return e.svc.IdentifyUser(ctx, token)
+}
+
 func (e *eventStore) CreatePost(ctx context.Context, token string, post posts.Post) (posts.Post, error) {
-	post, err := e.svc.CreatePost(ctx, token, post)
+	err := e.outbox.WithTx(ctx, func(ctx context.Context) (err error) {
+		if post, err = e.svc.CreatePost(ctx, token, post); err != nil {
+			return err
+		}
+
+		return e.add(ctx, events.PostCreated, postEvent(post))
+	})
 	if err != nil {
 		return posts.Post{}, err
 	}
 
-	if err := Publish(ctx, e.publisher, "posts.created", post); err != nil {
-		return post, err
-	}
-
 	return post, nil
 }
 
@@ -46,97 +56,141 @@ func (e *eventStore) RetrievePostByID(ctx context.Context, token string, id stri
 	return e.svc.RetrievePostByID(ctx, token, id)
 }
 
+func (e *eventStore) RetrievePost(ctx context.Context, id string) (posts.Post, error) {
+	return e.svc.RetrievePost(ctx, id)
+}
+
 func (e *eventStore) RetrieveAllPosts(ctx context.Context, token string, page posts.Page) (posts.PostsPage, error) {
 	return e.svc.RetrieveAllPosts(ctx, token, page)
 }
 
 func (e *eventStore) UpdatePost(ctx context.Context, token string, post posts.Post) (posts.Post, error) {
-	post, err := e.svc.UpdatePost(ctx, token, post)
+	err := e.outbox.WithTx(ctx, func(ctx context.Context) (err error) {
+		if post, err = e.svc.UpdatePost(ctx, token, post); err != nil {
+			return err
+		}
+		data := postEvent(post)
+		if data.UserID, err = e.svc.IdentifyUser(ctx, token); err != nil {
+			return err
+		}
+
+		return e.add(ctx, events.PostUpdated, data)
+	})
 	if err != nil {
 		return posts.Post{}, err
 	}
 
-	if err := Publish(ctx, e.publisher, "posts.updated", post); err != nil {
-		return post, err
-	}
-
 	return post, nil
 }
 
 func (e *eventStore) UpdatePostContent(ctx context.Context, token string, post posts.Post) (posts.Post, error) {
-	post, err := e.svc.UpdatePostContent(ctx, token, post)
+	err := e.outbox.WithTx(ctx, func(ctx context.Context) (err error) {
+		if post, err = e.svc.UpdatePostContent(ctx, token, post); err != nil {
+			return err
+		}
+		data := postEvent(post)
+		if data.UserID, err = e.svc.IdentifyUser(ctx, token); err != nil {
+			return err
+		}
+
+		return e.add(ctx, events.PostContentUpdated, data)
+	})
 	if err != nil {
 		return posts.Post{}, err
 	}
 
-	if err := Publish(ctx, e.publisher, "posts.updated.content", post); err != nil {
-		return post, err
-	}
-
 	return post, nil
 }
 
 func (e *eventStore) UpdatePostTags(ctx context.Context, token string, post posts.Post) (posts.Post, error) {
-	post, err := e.svc.UpdatePostTags(ctx, token, post)
+	err := e.outbox.WithTx(ctx, func(ctx context.Context) (err error) {
+		if post, err = e.svc.UpdatePostTags(ctx, token, post); err != nil {
+			return err
+		}
+		data := postEvent(post)
+		if data.UserID, err = e.svc.IdentifyUser(ctx, token); err != nil {
+			return err
+		}
+
+		return e.add(ctx, events.PostTagsUpdated, data)
+	})
 	if err != nil {
 		return posts.Post{}, err
 	}
 
-	if err := Publish(ctx, e.publisher, "posts.updated.tags", post); err != nil {
-		return post, err
-	}
-
 	return post, nil
 }
 
 func (e *eventStore) UpdatePostImageURL(ctx context.Context, token string, post posts.Post) (posts.Post, error) {
-	post, err := e.svc.UpdatePostImageURL(ctx, token, post)
+	err := e.outbox.WithTx(ctx, func(ctx context.Context) (err error) {
+		if post, err = e.svc.UpdatePostImageURL(ctx, token, post); err != nil {
+			return err
+		}
+		data := postEvent(post)
+		if data.UserID, err = e.svc.IdentifyUser(ctx, token); err != nil {
+			return err
+		}
+
+		return e.add(ctx, events.PostImageUpdated, data)
+	})
 	if err != nil {
 		return posts.Post{}, err
 	}
 
-	if err := Publish(ctx, e.publisher, "posts.updated.image", post); err != nil {
-		return post, err
-	}
-
 	return post, nil
 }
 
 func (e *eventStore) UpdatePostVisibility(ctx context.Context, token string, post posts.Post) (posts.Post, error) {
-	post, err := e.svc.UpdatePostVisibility(ctx, token, post)
+	err := e.outbox.WithTx(ctx, func(ctx context.Context) (err error) {
+		if post, err = e.svc.UpdatePostVisibility(ctx, token, post); err != nil {
+			return err
+		}
+		data := postEvent(post)
+		if data.UserID, err = e.svc.IdentifyUser(ctx, token); err != nil {
+			return err
+		}
+
+		return e.add(ctx, events.PostVisibilityUpdated, data)
+	})
 	if err != nil {
 		return posts.Post{}, err
 	}
 
-	if err := Publish(ctx, e.publisher, "posts.updated.visibility", post); err != nil {
-		return post, err
-	}
-
 	return post, nil
 }
 
 func (e *eventStore) DeletePost(ctx context.Context, token string, id string) error {
-	if err := e.svc.DeletePost(ctx, token, id); err != nil {
-		return err
-	}
-
-	if err := Publish(ctx, e.publisher, "posts.deleted", posts.Post{ID: id}); err != nil {
-		return err
-	}
-
-	return nil
+	return e.outbox.WithTx(ctx, func(ctx context.Context) error {
+		if err := e.svc.DeletePost(ctx, token, id); err != nil {
+			return err
+		}
+		// Only their owner can delete them, so the user is the owner.
+		userID, err := e.svc.IdentifyUser(ctx, token)
+		if err != nil {
+			return err
+		}
+
+		return e.add(ctx, events.PostDeleted, events.PostEvent{ID: id, UserID: userID})
+	})
 }
 
 func (e *eventStore) CreateComment(ctx context.Context, token string, postID string, comment posts.Comment) (posts.Comment, error) {
-	comment, err := e.svc.CreateComment(ctx, token, postID, comment)
+	err := e.outbox.WithTx(ctx, func(ctx context.Context) (err error) {
+		if comment, err = e.svc.CreateComment(ctx, token, postID, comment); err != nil {
+			return err
+		}
+
+		data := commentEvent(postID, comment)
+		if data.PostOwnerID, err = e.postOwner(ctx, postID); err != nil {
+			return err
+		}
+
+		return e.add(ctx, events.CommentCreated, data)
+	})
 	if err != nil {
 		return posts.Comment{}, err
 	}
 
-	if err := Publish(ctx, e.publisher, "comments.created", comment); err != nil {
-		return comment, err
-	}
-
 	return comment, nil
 }
 
@@ -149,40 +203,52 @@ func (e *eventStore) RetrieveAllComments(ctx context.Context, token string, page
 }
 
 func (e *eventStore) UpdateComment(ctx context.Context, token string, comment posts.Comment) (posts.Comment, error) {
-	comment, err := e.svc.UpdateComment(ctx, token, comment)
+	err := e.outbox.WithTx(ctx, func(ctx context.Context) (err error) {
+		if comment, err = e.svc.UpdateComment(ctx, token, comment); err != nil {
+			return err
+		}
+
+		return e.add(ctx, events.CommentUpdated, commentEvent(comment.PostID, comment))
+	})
 	if err != nil {
 		return posts.Comment{}, err
 	}
 
-	if err := Publish(ctx, e.publisher, "comments.updated", comment); err != nil {
-		return comment, err
-	}
-
 	return comment, nil
 }
 
 func (e *eventStore) DeleteComment(ctx context.Context, token string, id string) error {
-	if err := e.svc.DeleteComment(ctx, token, id); err != nil {
-		return err
-	}
-
-	if err := Publish(ctx, e.publisher, "comments.deleted", posts.Comment{ID: id}); err != nil {
-		return err
-	}
-
-	return nil
+	return e.outbox.WithTx(ctx, func(ctx context.Context) error {
+		if err := e.svc.DeleteComment(ctx, token, id); err != nil {
+			return err
+		}
+		// Only their owner can delete them, so the user is the owner.
+		userID, err := e.svc.IdentifyUser(ctx, token)
+		if err != nil {
+			return err
+		}
+
+		return e.add(ctx, events.CommentDeleted, events.CommentEvent{ID: id, UserID: userID})
+	})
 }
 
 func (e *eventStore) CreateLike(ctx context.Context, token string, postID string, like posts.Like) (posts.Like, error) {
-	like, err := e.svc.CreateLike(ctx, token, postID, like)
+	err := e.outbox.WithTx(ctx, func(ctx context.Context) (err error) {
+		if like, err = e.svc.CreateLike(ctx, token, postID, like); err != nil {
+			return err
+		}
+
+		data := likeEvent(postID, like)
+		if data.PostOwnerID, err = e.postOwner(ctx, postID); err != nil {
+			return err
+		}
+
+		return e.add(ctx, events.LikeCreated, data)
+	})
 	if err != nil {
 		return posts.Like{}, err
 	}
 
-	if err := Publish(ctx, e.publisher, "likes.created", like); err != nil {
-		return like, err
-	}
-
 	return like, nil
 }
 
@@ -191,27 +257,37 @@ func (e *eventStore) RetrieveAllLikes(ctx context.Context, token string, page po
 }
 
 func (e *eventStore) DeleteLike(ctx context.Context, token, postID string) error {
-	if err := e.svc.DeleteLike(ctx, token, postID); err != nil {
-		return err
-	}
-
-	if err := Publish(ctx, e.publisher, "likes.deleted", posts.Post{ID: postID}); err != nil {
-		return err
-	}
-
-	return nil
+	return e.outbox.WithTx(ctx, func(ctx context.Context) error {
+		if err := e.svc.DeleteLike(ctx, token, postID); err != nil {
+			return err
+		}
+		// Only their owner can delete them, so the user is the owner.
+		userID, err := e.svc.IdentifyUser(ctx, token)
+		if err != nil {
+			return err
+		}
+
+		return e.add(ctx, events.LikeDeleted, events.LikeEvent{PostID: postID, UserID: userID})
+	})
 }
 
 func (e *eventStore) CreateShare(ctx context.Context, token string, postID string, share posts.Share) (posts.Share, error) {
-	share, err := e.svc.CreateShare(ctx, token, postID, share)
+	err := e.outbox.WithTx(ctx, func(ctx context.Context) (err error) {
+		if share, err = e.svc.CreateShare(ctx, token, postID, share); err != nil {
+			return err
+		}
+
+		data := shareEvent(postID, share)
+		if data.PostOwnerID, err = e.postOwner(ctx, postID); err != nil {
+			return err
+		}
+
+		return e.add(ctx, events.ShareCreated, data)
+	})
 	if err != nil {
 		return posts.Share{}, err
 	}
 
-	if err := Publish(ctx, e.publisher, "shares.created", share); err != nil {
-		return share, err
-	}
-
 	return share, nil
 }
 
@@ -220,31 +296,82 @@ func (e *eventStore) RetrieveAllShares(ctx context.Context, token string, page p
 }
 
 func (e *eventStore) DeleteShare(ctx context.Context, token string, id string) error {
-	if err := e.svc.DeleteShare(ctx, token, id); err != nil {
-		return err
+	return e.outbox.WithTx(ctx, func(ctx context.Context) error {
+		if err := e.svc.DeleteShare(ctx, token, id); err != nil {
+			return err
+		}
+		// Only their owner can delete them, so the user is the owner.
+		userID, err := e.svc.IdentifyUser(ctx, token)
+		if err != nil {
+			return err
+		}
+
+		return e.add(ctx, events.ShareDeleted, events.ShareEvent{ID: id, UserID: userID})
+	})
+}
+
+// postOwner returns the author of the post, so that consumers notify them
+// without asking the Post Service.
+func (e *eventStore) postOwner(ctx context.Context, postID string) (string, error) {
+	post, err := e.svc.RetrievePost(ctx, postID)
+	if err != nil {
+		return "", err
 	}
 
-	if err := Publish(ctx, e.publisher, "shares.deleted", posts.Share{ID: id}); err != nil {
+	return post.UserID, nil
+}
+
+func (e *eventStore) add(ctx context.Context, eventType string, data events.Data) error {
+	env, err := events.NewEnvelope(ctx, source, eventType, data)
+	if err != nil {
+		return err
+	}
+	event, err := outbox.NewEvent(env)
+	if err != nil {
 		return err
 	}
 
-	return nil
+	return e.outbox.Add(ctx, event)
 }
 
-type entity interface {
-	posts.Post | posts.Comment | posts.Like | posts.Share
+func postEvent(post posts.Post) events.PostEvent {
+	return events.PostEvent{
+		ID:         post.ID,
+		UserID:     post.UserID,
+		Title:      post.Title,
+		Content:    post.Content,
+		Tags:       post.Tags,
+		ImageURL:   post.ImageURL,
+		Visibility: post.Visibility,
+		CreatedAt:  post.CreatedAt,
+		UpdatedAt:  post.UpdatedAt,
+	}
 }
 
-func Publish[E entity](ctx context.Context, pub events.Publisher, topic string, entity E) error {
-	jsonEntity, err := json.Marshal(entity)
-	if err != nil {
-		return err
+func commentEvent(postID string, comment posts.Comment) events.CommentEvent {
+	return events.CommentEvent{
+		ID:        comment.ID,
+		PostID:    postID,
+		UserID:    comment.UserID,
+		Content:   comment.Content,
+		CreatedAt: comment.CreatedAt,
+		UpdatedAt: comment.UpdateAt,
 	}
+}
 
-	var payload map[string]interface{}
-	if err := json.Unmarshal(jsonEntity, &payload); err != nil {
-		return err
+func likeEvent(postID string, like posts.Like) events.LikeEvent {
+	return events.LikeEvent{
+		PostID:    postID,
+		UserID:    like.UserID,
+		CreatedAt: like.CreatedAt,
 	}
+}
 
-	return pub.Publish(ctx, topic, payload)
+func shareEvent(postID string, share posts.Share) events.ShareEvent {
+	return events.ShareEvent{
+		ID:        share.ID,
+		PostID:    postID,
+		UserID:    share.UserID,
+		CreatedAt: share.CreatedAt,
+	}
 }
@@ -13,6 +13,7 @@ import (
 	"time"
 
 	"github.com/gofrs/uuid"
+	icursor "github.com/rodneyosodo/twiga/internal/cursor"
 	"github.com/rodneyosodo/twiga/posts"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -56,6 +57,10 @@ func (r *repository) RetrieveByID(ctx context.Context, id string) (posts.Post, e
 	}
 
 	if err = r.db.FindOne(ctx, bson.D{{Key: "_id", Value: objID}}).Decode(&post); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return post, posts.ErrNotFound
+		}
+
 		return post, err
 	}
 
@@ -63,7 +68,10 @@ func (r *repository) RetrieveByID(ctx context.Context, id string) (posts.Post, e
 }
 
 func (r *repository) RetrieveAll(ctx context.Context, page posts.Page) (posts.PostsPage, error) {
-	filter, opts := getFilter(page)
+	filter, opts, err := getFilter(page)
+	if err != nil {
+		return posts.PostsPage{}, err
+	}
 
 	cursor, err := r.db.Find(ctx, filter, opts)
 	if err != nil {
@@ -79,12 +87,17 @@ func (r *repository) RetrieveAll(ctx context.Context, page posts.Page) (posts.Po
 		}
 		postsPage.Posts = append(postsPage.Posts, post)
 	}
+	postsPage.Posts, postsPage.NextCursor = icursor.Trim(postsPage.Posts, page.Limit, func(p posts.Post) icursor.Cursor {
+		return icursor.Cursor{CreatedAt: p.CreatedAt, ID: p.ID}
+	})
 
-	total, err := r.db.CountDocuments(ctx, filter)
-	if err != nil {
-		return posts.PostsPage{}, err
+	if page.Cursor == "" {
+		total, err := r.db.CountDocuments(ctx, filter)
+		if err != nil {
+			return posts.PostsPage{}, err
+		}
+		postsPage.Total = uint64(total)
 	}
-	postsPage.Total = uint64(total)
 	postsPage.Offset = page.Offset
 	postsPage.Limit = page.Limit
 
@@ -108,7 +121,7 @@ func (r *repository) Update(ctx context.Context, post posts.Post) (posts.Post, e
 		return post, err
 	}
 	if result.MatchedCount == 0 {
-		return post, errors.New("post not found")
+		return post, posts.ErrNotFound
 	}
 
 	return post, nil
@@ -127,7 +140,7 @@ func (r *repository) UpdateContent(ctx context.Context, post posts.Post) (posts.
 		return post, err
 	}
 	if result.MatchedCount == 0 {
-		return post, errors.New("post not found")
+		return post, posts.ErrNotFound
 	}
 
 	return post, nil
@@ -146,7 +159,7 @@ func (r *repository) UpdateTags(ctx context.Context, post posts.Post) (posts.Pos
 		return post, err
 	}
 	if result.MatchedCount == 0 {
-		return post, errors.New("post not found")
+		return post, posts.ErrNotFound
 	}
 
 	return post, nil
@@ -165,7 +178,7 @@ func (r *repository) UpdateImageURL(ctx context.Context, post posts.Post) (posts
 		return post, err
 	}
 	if result.MatchedCount == 0 {
-		return post, errors.New("post not found")
+		return post, posts.ErrNotFound
 	}
 
 	return post, nil
@@ -184,7 +197,7 @@ func (r *repository) UpdateVisibility(ctx context.Context, post posts.Post) (pos
 		return post, err
 	}
 	if result.MatchedCount == 0 {
-		return post, errors.New("post not found")
+		return post, posts.ErrNotFound
 	}
 
 	return post, nil
@@ -200,7 +213,7 @@ func (r *repository) Delete(ctx context.Context, id string) error {
 		return err
 	}
 	if result.DeletedCount == 0 {
-		return errors.New("post not found")
+		return posts.ErrNotFound
 	}
 
 	return nil
@@ -242,8 +255,10 @@ func (r *repository) RetrieveCommentByID(ctx context.Context, id string) (posts.
 	if len(result.Comments) == 0 {
 		return posts.Comment{}, errors.New("comment not found")
 	}
+	comment := result.Comments[0]
+	comment.PostID = result.ID
 
-	return result.Comments[0], nil
+	return comment, nil
 }
 
 func (r *repository) RetrieveAllComments(ctx context.Context, page posts.Page) (posts.CommentsPage, error) { //nolint:dupl
@@ -251,37 +266,21 @@ func (r *repository) RetrieveAllComments(ctx context.Context, page posts.Page) (
 		return posts.CommentsPage{}, errors.New("post ID is required")
 	}
 
-	objID, err := primitive.ObjectIDFromHex(page.PostID)
-	if err != nil {
-		return posts.CommentsPage{}, err
-	}
-	filter := bson.D{{Key: "_id", Value: objID}}
-
-	opts := options.Find()
-	opts.SetProjection(bson.D{{Key: "comments", Value: bson.D{{Key: "$slice", Value: bson.A{page.Offset, page.Limit}}}}})
-	opts.SetSort(primitive.D{{Key: "comments.created_at", Value: -1}})
-
-	cursor, err := r.db.Find(ctx, filter, opts)
-	if err != nil {
+	var commentsPage posts.CommentsPage
+	if err := r.retrieveEmbedded(ctx, page, "comments", "id", &commentsPage.Comments); err != nil {
 		return posts.CommentsPage{}, err
 	}
-	defer cursor.Close(ctx)
+	commentsPage.Comments, commentsPage.NextCursor = icursor.Trim(commentsPage.Comments, page.Limit, func(c posts.Comment) icursor.Cursor {
+		return icursor.Cursor{CreatedAt: c.CreatedAt, ID: c.ID}
+	})
 
-	var commentsPage posts.CommentsPage
-	for cursor.Next(ctx) {
-		var post posts.Post
-		if err := cursor.Decode(&post); err != nil {
-			return commentsPage, err
+	if page.Cursor == "" {
+		post, err := r.RetrieveByID(ctx, page.PostID)
+		if err != nil {
+			return posts.CommentsPage{}, err
 		}
-		commentsPage.Comments = append(commentsPage.Comments, post.Comments...)
+		commentsPage.Total = uint64(len(post.Comments))
 	}
-
-	post, err := r.RetrieveByID(ctx, page.PostID)
-	if err != nil {
-		return posts.CommentsPage{}, err
-	}
-
-	commentsPage.Total = uint64(len(post.Comments))
 	commentsPage.Offset = page.Offset
 	commentsPage.Limit = page.Limit
 
@@ -334,7 +333,7 @@ func (r *repository) CreateLike(ctx context.Context, postID string, like posts.L
 		return like, err
 	}
 	if result.MatchedCount == 0 {
-		return like, errors.New("post not found")
+		return like, posts.ErrNotFound
 	}
 
 	return like, nil
@@ -345,35 +344,21 @@ func (r *repository) RetrieveAllLikes(ctx context.Context, page posts.Page) (pos
 		return posts.LikesPage{}, errors.New("post ID is required")
 	}
 
-	objID, err := primitive.ObjectIDFromHex(page.PostID)
-	if err != nil {
-		return posts.LikesPage{}, err
-	}
-	filter := bson.D{{Key: "_id", Value: objID}}
-
-	opts := options.Find()
-	opts.SetProjection(bson.D{{Key: "likes", Value: bson.D{{Key: "$slice", Value: bson.A{page.Offset, page.Limit}}}}})
-	opts.SetSort(primitive.D{{Key: "likes.created_at", Value: -1}})
-
-	cursor, err := r.db.Find(ctx, filter, opts)
-	if err != nil {
+	var likesPage posts.LikesPage
+	if err := r.retrieveEmbedded(ctx, page, "likes", "user_id", &likesPage.Likes); err != nil {
 		return posts.LikesPage{}, err
 	}
-	defer cursor.Close(ctx)
+	likesPage.Likes, likesPage.NextCursor = icursor.Trim(likesPage.Likes, page.Limit, func(l posts.Like) icursor.Cursor {
+		return icursor.Cursor{CreatedAt: l.CreatedAt, ID: l.UserID}
+	})
 
-	var likesPage posts.LikesPage
-	for cursor.Next(ctx) {
-		var post posts.Post
-		if err := cursor.Decode(&post); err != nil {
-			return likesPage, err
+	if page.Cursor == "" {
+		post, err := r.RetrieveByID(ctx, page.PostID)
+		if err != nil {
+			return posts.LikesPage{}, err
 		}
-		likesPage.Likes = append(likesPage.Likes, post.Likes...)
-	}
-	post, err := r.RetrieveByID(ctx, page.PostID)
-	if err != nil {
-		return posts.LikesPage{}, err
+		likesPage.Total = uint64(len(post.Likes))
 	}
-	likesPage.Total = uint64(len(post.Likes))
 	likesPage.Offset = page.Offset
 	likesPage.Limit = page.Limit
 
@@ -422,17 +407,19 @@ func (r *repository) CreateShare(ctx context.Context, postID string, share posts
 }
 
 func (r *repository) RetrieveShareByID(ctx context.Context, id string) (posts.Share, error) {
-	opt := options.FindOne().SetProjection(bson.D{{Key: "share.$", Value: 1}})
+	opt := options.FindOne().SetProjection(bson.D{{Key: "shares.$", Value: 1}})
 
 	var result posts.Post
-	if err := r.db.FindOne(ctx, bson.D{{Key: "share.id", Value: id}}, opt).Decode(&result); err != nil {
+	if err := r.db.FindOne(ctx, bson.D{{Key: "shares.id", Value: id}}, opt).Decode(&result); err != nil {
 		return posts.Share{}, err
 	}
 	if len(result.Shares) == 0 {
 		return posts.Share{}, errors.New("share not found")
 	}
+	share := result.Shares[0]
+	share.PostID = result.ID
 
-	return result.Shares[0], nil
+	return share, nil
 }
 
 func (r *repository) RetrieveAllShares(ctx context.Context, page posts.Page) (posts.SharesPage, error) { //nolint:dupl
@@ -440,36 +427,21 @@ func (r *repository) RetrieveAllShares(ctx context.Context, page posts.Page) (po
 		return posts.SharesPage{}, errors.New("post ID is required")
 	}
 
-	objID, err := primitive.ObjectIDFromHex(page.PostID)
-	if err != nil {
-		return posts.SharesPage{}, err
-	}
-	filter := bson.D{{Key: "_id", Value: objID}}
-
-	opts := options.Find()
-	opts.SetProjection(bson.D{{Key: "shares", Value: bson.D{{Key: "$slice", Value: bson.A{page.Offset, page.Limit}}}}})
-	opts.SetSort(primitive.D{{Key: "shares.created_at", Value: -1}})
-
-	cursor, err := r.db.Find(ctx, filter, opts)
-	if err != nil {
+	var sharesPage posts.SharesPage
+	if err := r.retrieveEmbedded(ctx, page, "shares", "id", &sharesPage.Shares); err != nil {
 		return posts.SharesPage{}, err
 	}
-	defer cursor.Close(ctx)
+	sharesPage.Shares, sharesPage.NextCursor = icursor.Trim(sharesPage.Shares, page.Limit, func(s posts.Share) icursor.Cursor {
+		return icursor.Cursor{CreatedAt: s.CreatedAt, ID: s.ID}
+	})
 
-	var sharesPage posts.SharesPage
-	for cursor.Next(ctx) {
-		var post posts.Post
-		if err := cursor.Decode(&post); err != nil {
-			return sharesPage, err
+	if page.Cursor == "" {
+		post, err := r.RetrieveByID(ctx, page.PostID)
+		if err != nil {
+			return posts.SharesPage{}, err
 		}
-		sharesPage.Shares = append(sharesPage.Shares, post.Shares...)
-	}
-
-	post, err := r.RetrieveByID(ctx, page.PostID)
-	if err != nil {
-		return posts.SharesPage{}, err
+		sharesPage.Total = uint64(len(post.Shares))
 	}
-	sharesPage.Total = uint64(len(post.Shares))
 	sharesPage.Offset = page.Offset
 	sharesPage.Limit = page.Limit
 
@@ -491,11 +463,15 @@ func (r *repository) DeleteShare(ctx context.Context, id string) error {
 	return nil
 }
 
-func getFilter(page posts.Page) (bson.D, *options.FindOptions) {
+func getFilter(page posts.Page) (bson.D, *options.FindOptions, error) {
 	filter := bson.D{}
 	opts := options.Find()
 
-	opts.SetSort(primitive.D{{Key: "created_at", Value: -1}}).SetLimit(int64(page.Limit)).SetSkip(int64(page.Offset))
+	// One document past the limit is read to tell whether a next page exists.
+	opts.SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).SetLimit(int64(page.Limit + 1))
+	if page.Cursor == "" {
+		opts.SetSkip(int64(page.Offset))
+	}
 
 	if page.Tag != "" {
 		filter = append(filter, bson.E{Key: "tags", Value: bson.D{{Key: "$all", Value: bson.A{page.Tag}}}})
@@ -505,7 +481,7 @@ func getFilter(page posts.Page) (bson.D, *options.FindOptions) {
 	if page.PostID != "" {
 		objID, err := primitive.ObjectIDFromHex(page.PostID)
 		if err != nil {
-			return bson.D{}, opts
+			return bson.D{}, opts, nil
 		}
 		filter = append(filter, bson.E{Key: "_id", Value: objID})
 	}
@@ -514,5 +490,65 @@ func getFilter(page posts.Page) (bson.D, *options.FindOptions) {
 		filter = append(filter, bson.E{Key: "user_id", Value: page.UserID})
 	}
 
-	return filter, opts
+	if len(page.UserIDs) > 0 {
+		filter = append(filter, bson.E{Key: "user_id", Value: bson.D{{Key: "$in", Value: page.UserIDs}}})
+	}
+
+	if page.Cursor != "" {
+		c, err := icursor.Decode(page.Cursor)
+		if err != nil {
+			return bson.D{}, opts, err
+		}
+		objID, err := primitive.ObjectIDFromHex(c.ID)
+		if err != nil {
+			return bson.D{}, opts, icursor.ErrInvalidCursor
+		}
+		filter = append(filter, cursorFilter("_id", c.CreatedAt, objID))
+	}
+
+	return filter, opts, nil
+}
+
+// cursorFilter selects the documents after the cursor when they are ordered
+// by created_at and key, both descending.
+func cursorFilter(key string, createdAt time.Time, id interface{}) bson.E {
+	return bson.E{Key: "$or", Value: bson.A{
+		bson.D{{Key: "created_at", Value: bson.D{{Key: "$lt", Value: createdAt}}}},
+		bson.D{{Key: "created_at", Value: createdAt}, {Key: key, Value: bson.D{{Key: "$lt", Value: id}}}},
+	}}
+}
+
+// retrieveEmbedded decodes into items the elements of the array field of the post,
+// ordered by created_at and key from the newest to the oldest.
+func (r *repository) retrieveEmbedded(ctx context.Context, page posts.Page, field, key string, items interface{}) error {
+	objID, err := primitive.ObjectIDFromHex(page.PostID)
+	if err != nil {
+		return err
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.D{{Key: "_id", Value: objID}}}},
+		{{Key: "$unwind", Value: "$" + field}},
+		{{Key: "$replaceRoot", Value: bson.D{{Key: "newRoot", Value: "$" + field}}}},
+	}
+	if page.Cursor != "" {
+		c, err := icursor.Decode(page.Cursor)
+		if err != nil {
+			return err
+		}
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: bson.D{cursorFilter(key, c.CreatedAt, c.ID)}}})
+	}
+	pipeline = append(pipeline, bson.D{{Key: "$sort", Value: bson.D{{Key: "created_at", Value: -1}, {Key: key, Value: -1}}}})
+	if page.Cursor == "" && page.Offset > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$skip", Value: int64(page.Offset)}})
+	}
+	pipeline = append(pipeline, bson.D{{Key: "$limit", Value: int64(page.Limit + 1)}})
+
+	cursor, err := r.db.Aggregate(ctx, pipeline)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	return cursor.All(ctx, items)
 }
@@ -19,7 +19,9 @@ import (
 	"github.com/rodneyosodo/twiga/posts"
 	"github.com/rodneyosodo/twiga/posts/repository"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 var namegen = namegenerator.NewGenerator()
@@ -94,6 +96,12 @@ func TestRetrieveByID(t *testing.T) {
 			response: posts.Post{},
 			err:      errors.New("the provided hex string is not a valid ObjectID"),
 		},
+		{
+			desc:     "non-existent id",
+			id:       primitive.NewObjectID().Hex(),
+			response: posts.Post{},
+			err:      errors.New("post not found"),
+		},
 	}
 
 	for _, tc := range cases {
@@ -515,6 +523,7 @@ func TestRetrieveComment(t *testing.T) {
 		UserID:  uuid.Must(uuid.NewV4()).String(),
 	})
 	assert.NoError(t, err)
+	comment.PostID = saved.ID
 
 	_, err = repo.CreateComment(context.Background(), saved.ID, posts.Comment{
 		Content: strings.Repeat("b", 100),
@@ -1243,3 +1252,103 @@ func generatePost() posts.Post {
 		UserID:     uuid.Must(uuid.NewV4()).String(),
 	}
 }
+
+func TestRetrieveAllCursor(t *testing.T) {
+	t.Cleanup(func() {
+		_, err := collection.DeleteMany(context.Background(), bson.M{})
+		assert.NoError(t, err)
+	})
+
+	repo := repository.NewRepository(collection)
+
+	num := 5
+	userID := uuid.Must(uuid.NewV4()).String()
+	for range num {
+		post := generatePost()
+		post.UserID = userID
+		post.Visibility = true
+		_, err := repo.Create(context.Background(), post)
+		require.NoError(t, err)
+	}
+
+	page := posts.Page{Limit: 2, UserID: userID, Visibility: true}
+	seen := map[string]bool{}
+	for pages := 0; ; pages++ {
+		postsPage, err := repo.RetrieveAll(context.Background(), page)
+		require.NoError(t, err)
+		if page.Cursor == "" {
+			assert.Equal(t, uint64(num), postsPage.Total, "offset pages should be counted")
+		} else {
+			assert.Zero(t, postsPage.Total, "cursor pages should not be counted")
+		}
+
+		for _, post := range postsPage.Posts {
+			assert.False(t, seen[post.ID], "posts should not repeat across pages")
+			seen[post.ID] = true
+		}
+		if pages == 0 {
+			// Posts created while paging must not shift the following pages.
+			post := generatePost()
+			post.UserID = userID
+			post.Visibility = true
+			_, err := repo.Create(context.Background(), post)
+			require.NoError(t, err)
+		}
+
+		if postsPage.NextCursor == "" {
+			break
+		}
+		page.Cursor = postsPage.NextCursor
+	}
+	assert.Len(t, seen, num)
+
+	postsPage, err := repo.RetrieveAll(context.Background(), posts.Page{Limit: 2, UserID: userID, Visibility: true})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(num+1), postsPage.Total)
+}
+
+func TestRetrieveAllCommentsCursor(t *testing.T) {
+	t.Cleanup(func() {
+		_, err := collection.DeleteMany(context.Background(), bson.M{})
+		assert.NoError(t, err)
+	})
+
+	repo := repository.NewRepository(collection)
+
+	post := generatePost()
+	post.Visibility = true
+	post, err := repo.Create(context.Background(), post)
+	require.NoError(t, err)
+
+	num := 5
+	for range num {
+		_, err := repo.CreateComment(context.Background(), post.ID, posts.Comment{
+			Content: namegen.Generate(),
+			UserID:  uuid.Must(uuid.NewV4()).String(),
+		})
+		require.NoError(t, err)
+	}
+
+	page := posts.Page{Limit: 2, PostID: post.ID}
+	seen := map[string]bool{}
+	for {
+		commentsPage, err := repo.RetrieveAllComments(context.Background(), page)
+		require.NoError(t, err)
+		if page.Cursor == "" {
+			assert.Equal(t, uint64(num), commentsPage.Total, "offset pages should be counted")
+		} else {
+			assert.Zero(t, commentsPage.Total, "cursor pages should not be counted")
+		}
+
+		for _, comment := range commentsPage.Comments {
+			assert.False(t, seen[comment.ID], "comments should not repeat across pages")
+			seen[comment.ID] = true
+		}
+
+		if commentsPage.NextCursor == "" {
+			break
+		}
+		page.Cursor = commentsPage.NextCursor
+	}
+	assert.Len(t, seen, num)
+}
@@ -17,13 +17,16 @@ import (
 
 var _ Service = (*service)(nil)
 
+// CacheNamespace holds the cached posts, along with their comments, likes and shares.
+var CacheNamespace = cache.Namespace{Name: "posts", Version: 1}
+
 type service struct {
 	repo   Repository
 	users  proto.UsersServiceClient
-	cacher cache.Cacher
+	cacher cache.Loader[Post]
 }
 
-func NewService(repo Repository, users proto.UsersServiceClient, cacher cache.Cacher) Service {
+func NewService(repo Repository, users proto.UsersServiceClient, cacher cache.Loader[Post]) Service {
 	return &service{
 		repo:   repo,
 		users:  users,
@@ -31,7 +34,7 @@ func NewService(repo Repository, users proto.UsersServiceClient, cacher cache.Ca
 	}
 }
 
-func (s *service) CreatePost(ctx context.Context, token string, post Post) (Post, error) {
+func (s *service) CreatePost(ctx context.Context, token string, post Post) (saved Post, err error) {
 	userID, err := s.IdentifyUser(ctx, token)
 	if err != nil {
 		return Post{}, err
@@ -40,27 +43,19 @@ func (s *service) CreatePost(ctx context.Context, token string, post Post) (Post
 
 	defer func() {
 		if err == nil {
-			if err = s.cacher.Add(ctx, post.ID, post); err != nil {
-				err = errors.New("failed to cache post")
-			}
+			err = s.cachePost(ctx, saved)
 		}
 	}()
 
 	return s.repo.Create(ctx, post)
 }
 
-func (s *service) RetrievePostByID(ctx context.Context, token string, id string) (saved Post, err error) {
+func (s *service) RetrievePostByID(ctx context.Context, token string, id string) (Post, error) {
 	if _, err := s.IdentifyUser(ctx, token); err != nil {
 		return Post{}, err
 	}
-	if cached := s.cacher.Get(ctx, id); cached != nil {
-		saved, ok := cached.(Post)
-		if ok {
-			return saved, nil
-		}
-	}
 
-	return s.repo.RetrieveByID(ctx, id)
+	return s.RetrievePost(ctx, id)
 }
 
 func (s *service) RetrieveAllPosts(ctx context.Context, token string, page Page) (PostsPage, error) {
@@ -78,9 +73,7 @@ func (s *service) UpdatePost(ctx context.Context, token string, post Post) (upda
 
 	defer func() {
 		if err == nil {
-			if err = s.cacher.Add(ctx, post.ID, post); err != nil {
-				err = errors.New("failed to cache post")
-			}
+			err = s.cacher.Remove(ctx, post.ID)
 		}
 	}()
 
@@ -93,16 +86,7 @@ func (s *service) authorize(ctx context.Context, token, id string) error {
 		return err
 	}
 
-	if cached := s.cacher.Get(ctx, id); cached != nil {
-		saved, ok := cached.(Post)
-		if ok {
-			if saved.ID == userID {
-				return nil
-			}
-		}
-	}
-
-	saved, err := s.repo.RetrieveByID(ctx, id)
+	saved, err := s.RetrievePost(ctx, id)
 	if err != nil {
 		return err
 	}
@@ -119,9 +103,7 @@ func (s *service) UpdatePostContent(ctx context.Context, token string, post Post
 	}
 	defer func() {
 		if err == nil {
-			if err = s.cacher.Add(ctx, post.ID, post); err != nil {
-				err = errors.New("failed to cache post")
-			}
+			err = s.cacher.Remove(ctx, post.ID)
 		}
 	}()
 
@@ -134,9 +116,7 @@ func (s *service) UpdatePostTags(ctx context.Context, token string, post Post) (
 	}
 	defer func() {
 		if err == nil {
-			if err = s.cacher.Add(ctx, post.ID, post); err != nil {
-				err = errors.New("failed to cache post")
-			}
+			err = s.cacher.Remove(ctx, post.ID)
 		}
 	}()
 
@@ -149,9 +129,7 @@ func (s *service) UpdatePostImageURL(ctx context.Context, token string, post Pos
 	}
 	defer func() {
 		if err == nil {
-			if err = s.cacher.Add(ctx, post.ID, post); err != nil {
-				err = errors.New("failed to cache post")
-			}
+			err = s.cacher.Remove(ctx, post.ID)
 		}
 	}()
 
@@ -164,9 +142,7 @@ func (s *service) UpdatePostVisibility(ctx context.Context, token string, post P
 	}
 	defer func() {
 		if err == nil {
-			if err = s.cacher.Add(ctx, post.ID, post); err != nil {
-				err = errors.New("failed to cache post")
-			}
+			err = s.cacher.Remove(ctx, post.ID)
 		}
 	}()
 
@@ -177,11 +153,11 @@ func (s *service) DeletePost(ctx context.Context, token string, id string) error
 	if err := s.authorize(ctx, token, id); err != nil {
 		return err
 	}
-	if err := s.cacher.Remove(ctx, id); err != nil {
+	if err := s.repo.Delete(ctx, id); err != nil {
 		return err
 	}
 
-	return s.repo.Delete(ctx, id)
+	return s.cacher.Remove(ctx, id)
 }
 
 func (s *service) CreateComment(ctx context.Context, token string, postID string, comment Comment) (Comment, error) {
@@ -191,7 +167,12 @@ func (s *service) CreateComment(ctx context.Context, token string, postID string
 	}
 	comment.UserID = userID
 
-	return s.repo.CreateComment(ctx, postID, comment)
+	saved, err := s.repo.CreateComment(ctx, postID, comment)
+	if err != nil {
+		return Comment{}, err
+	}
+
+	return saved, s.cacher.Remove(ctx, postID)
 }
 
 func (s *service) RetrieveCommentByID(ctx context.Context, token string, id string) (Comment, error) {
@@ -222,8 +203,15 @@ func (s *service) UpdateComment(ctx context.Context, token string, comment Comme
 	if saved.UserID != userID {
 		return Comment{}, errors.New("unauthorized")
 	}
+	comment.PostID = saved.PostID
+	comment.UserID = saved.UserID
+
+	updated, err := s.repo.UpdateComment(ctx, comment)
+	if err != nil {
+		return Comment{}, err
+	}
 
-	return s.repo.UpdateComment(ctx, comment)
+	return updated, s.cacher.Remove(ctx, saved.PostID)
 }
 
 func (s *service) DeleteComment(ctx context.Context, token string, id string) error {
@@ -238,8 +226,11 @@ func (s *service) DeleteComment(ctx context.Context, token string, id string) er
 	if saved.UserID != userID {
 		return errors.New("unauthorized")
 	}
+	if err := s.repo.DeleteComment(ctx, id); err != nil {
+		return err
+	}
 
-	return s.repo.DeleteComment(ctx, id)
+	return s.cacher.Remove(ctx, saved.PostID)
 }
 
 func (s *service) CreateLike(ctx context.Context, token string, postID string, like Like) (Like, error) {
@@ -249,7 +240,12 @@ func (s *service) CreateLike(ctx context.Context, token string, postID string, l
 	}
 	like.UserID = userID
 
-	return s.repo.CreateLike(ctx, postID, like)
+	saved, err := s.repo.CreateLike(ctx, postID, like)
+	if err != nil {
+		return Like{}, err
+	}
+
+	return saved, s.cacher.Remove(ctx, postID)
 }
 
 func (s *service) RetrieveAllLikes(ctx context.Context, token string, page Page) (LikesPage, error) {
@@ -265,8 +261,11 @@ func (s *service) DeleteLike(ctx context.Context, token, postID string) error {
 	if err != nil {
 		return err
 	}
+	if err := s.repo.DeleteLike(ctx, postID, userID); err != nil {
+		return err
+	}
 
-	return s.repo.DeleteLike(ctx, postID, userID)
+	return s.cacher.Remove(ctx, postID)
 }
 
 func (s *service) CreateShare(ctx context.Context, token string, postID string, share Share) (Share, error) {
@@ -276,7 +275,12 @@ func (s *service) CreateShare(ctx context.Context, token string, postID string,
 	}
 	share.UserID = userID
 
-	return s.repo.CreateShare(ctx, postID, share)
+	saved, err := s.repo.CreateShare(ctx, postID, share)
+	if err != nil {
+		return Share{}, err
+	}
+
+	return saved, s.cacher.Remove(ctx, postID)
 }
 
 func (s *service) RetrieveAllShares(ctx context.Context, token string, page Page) (SharesPage, error) {
@@ -299,8 +303,29 @@ func (s *service) DeleteShare(ctx context.Context, token string, id string) erro
 	if saved.UserID != userID {
 		return errors.New("unauthorized")
 	}
+	if err := s.repo.DeleteShare(ctx, id); err != nil {
+		return err
+	}
+
+	return s.cacher.Remove(ctx, saved.PostID)
+}
+
+// RetrievePost returns the post from the cache, loading it from the repository on a miss.
+func (s *service) RetrievePost(ctx context.Context, id string) (Post, error) {
+	return s.cacher.Load(ctx, id, func(ctx context.Context) (Post, error) {
+		return s.repo.RetrieveByID(ctx, id)
+	})
+}
+
+// cachePost caches the post along with its comments, likes and shares, so
+// changing any of them drops the cached post. Updates drop it as well, since
+// the repository only returns the updated fields.
+func (s *service) cachePost(ctx context.Context, post Post) error {
+	if err := s.cacher.Add(ctx, post.ID, post, 0); err != nil {
+		return errors.New("failed to cache post")
+	}
 
-	return s.repo.DeleteShare(ctx, id)
+	return nil
 }
 
 func (s *service) IdentifyUser(ctx context.Context, token string) (string, error) {
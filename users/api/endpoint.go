@@ -177,7 +177,7 @@ func IssueTokenEndpoint(svc users.Service) endpoint.Endpoint {
 			return nil, err
 		}
 
-		return map[string]string{"token": token}, nil
+		return token, nil
 	}
 }
 
@@ -192,12 +192,39 @@ func RefreshTokenEndpoint(svc users.Service) endpoint.Endpoint {
 			return nil, err
 		}
 
-		token, err := svc.RefreshToken(ctx, req.Token)
+		token, err := svc.RefreshToken(ctx, req.RefreshToken)
 		if err != nil {
 			return nil, err
 		}
 
-		return map[string]string{"token": token}, nil
+		return token, nil
+	}
+}
+
+func RevokeTokenEndpoint(svc users.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req, ok := request.(RevokeTokenReq)
+		if !ok {
+			return nil, errors.New("invalid request")
+		}
+
+		if err := req.validate(); err != nil {
+			return nil, err
+		}
+
+		if req.All {
+			if err := svc.RevokeAllTokens(ctx, req.Token); err != nil {
+				return nil, err
+			}
+
+			return map[string]string{"message": "all tokens revoked"}, nil
+		}
+
+		if err := svc.RevokeToken(ctx, req.Token); err != nil {
+			return nil, err
+		}
+
+		return map[string]string{"message": "token revoked"}, nil
 	}
 }
 
@@ -366,7 +393,7 @@ func CreateFeedEndpoint(svc users.Service) endpoint.Endpoint {
 			return nil, err
 		}
 
-		if err := svc.CreateFeed(ctx, req.Feed); err != nil {
+		if err := svc.CreateFeed(ctx, req.Feeds...); err != nil {
 			return nil, err
 		}
 
@@ -374,6 +401,25 @@ func CreateFeedEndpoint(svc users.Service) endpoint.Endpoint {
 	}
 }
 
+func DeleteFeedEndpoint(svc users.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req, ok := request.(DeleteFeedReq)
+		if !ok {
+			return nil, errors.New("invalid request")
+		}
+
+		if err := req.validate(); err != nil {
+			return nil, err
+		}
+
+		if err := svc.DeleteFeed(ctx, req.Feed); err != nil {
+			return nil, err
+		}
+
+		return map[string]string{"message": "feed deleted"}, nil
+	}
+}
+
 func GetFeedEndpoint(svc users.Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		req, ok := request.(EntitiesReq)
@@ -396,7 +442,7 @@ func GetFeedEndpoint(svc users.Service) endpoint.Endpoint {
 
 func IdentifyUserEndpoint(svc users.Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
-		req, ok := request.(RefreshTokenReq)
+		req, ok := request.(IdentifyUserReq)
 		if !ok {
 			return nil, errors.New("invalid request")
 		}
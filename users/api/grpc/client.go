@@ -28,9 +28,11 @@ var _ proto.UsersServiceClient = (*client)(nil)
 type client struct {
 	timeout            time.Duration
 	getUserByID        endpoint.Endpoint
+	getUserEmail       endpoint.Endpoint
 	getUserPreferences endpoint.Endpoint
 	getUserFollowers   endpoint.Endpoint
 	createFeed         endpoint.Endpoint
+	deleteFeed         endpoint.Endpoint
 	identifyUser       endpoint.Endpoint
 }
 
@@ -45,6 +47,15 @@ func NewClient(conn *grpc.ClientConn, timeout time.Duration) proto.UsersServiceC
 			proto.GetUserByIDResponse{},
 		).Endpoint(),
 
+		getUserEmail: kitgrpc.NewClient(
+			conn,
+			svcName,
+			"GetUserEmail",
+			encodeGetUserEmailRequest,
+			decodeGetUserEmailResponse,
+			proto.GetUserEmailResponse{},
+		).Endpoint(),
+
 		getUserPreferences: kitgrpc.NewClient(
 			conn,
 			svcName,
@@ -72,6 +83,15 @@ func NewClient(conn *grpc.ClientConn, timeout time.Duration) proto.UsersServiceC
 			proto.CreateFeedResponse{},
 		).Endpoint(),
 
+		deleteFeed: kitgrpc.NewClient(
+			conn,
+			svcName,
+			"DeleteFeed",
+			encodeDeleteFeedRequest,
+			decodeDeleteFeedResponse,
+			proto.DeleteFeedResponse{},
+		).Endpoint(),
+
 		identifyUser: kitgrpc.NewClient(
 			conn,
 			svcName,
@@ -120,6 +140,41 @@ func decodeGetUserByIDResponse(_ context.Context, grpcRes interface{}) (interfac
 	return res, nil
 }
 
+func (c *client) GetUserEmail(ctx context.Context, in *proto.GetUserEmailRequest, _ ...grpc.CallOption) (*proto.GetUserEmailResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	res, err := c.getUserEmail(ctx, in)
+	if err != nil {
+		return nil, decodeError(err)
+	}
+
+	response, ok := res.(*proto.GetUserEmailResponse)
+	if !ok {
+		return nil, errors.New("invalid response")
+	}
+
+	return response, nil
+}
+
+func encodeGetUserEmailRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req, ok := grpcReq.(*proto.GetUserEmailRequest)
+	if !ok {
+		return nil, errors.New("invalid request")
+	}
+
+	return req, nil
+}
+
+func decodeGetUserEmailResponse(_ context.Context, grpcRes interface{}) (interface{}, error) {
+	res, ok := grpcRes.(*proto.GetUserEmailResponse)
+	if !ok {
+		return nil, errors.New("invalid response")
+	}
+
+	return res, nil
+}
+
 func (c *client) GetUserPreferences(ctx context.Context, in *proto.GetUserPreferencesRequest, _ ...grpc.CallOption) (*proto.GetUserPreferencesResponse, error) {
 	ctx, cancel := context.WithTimeout(ctx, c.timeout)
 	defer cancel()
@@ -225,6 +280,41 @@ func decodeCreateFeedResponse(_ context.Context, grpcRes interface{}) (interface
 	return res, nil
 }
 
+func (c *client) DeleteFeed(ctx context.Context, in *proto.DeleteFeedRequest, _ ...grpc.CallOption) (*proto.DeleteFeedResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	res, err := c.deleteFeed(ctx, in)
+	if err != nil {
+		return nil, decodeError(err)
+	}
+
+	response, ok := res.(*proto.DeleteFeedResponse)
+	if !ok {
+		return nil, errors.New("invalid response")
+	}
+
+	return response, nil
+}
+
+func encodeDeleteFeedRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req, ok := grpcReq.(*proto.DeleteFeedRequest)
+	if !ok {
+		return nil, errors.New("invalid request")
+	}
+
+	return req, nil
+}
+
+func decodeDeleteFeedResponse(_ context.Context, grpcRes interface{}) (interface{}, error) {
+	res, ok := grpcRes.(*proto.DeleteFeedResponse)
+	if !ok {
+		return nil, errors.New("invalid response")
+	}
+
+	return res, nil
+}
+
 func (c *client) IdentifyUser(ctx context.Context, in *proto.IdentifyUserRequest, _ ...grpc.CallOption) (*proto.IdentifyUserResponse, error) {
 	ctx, cancel := context.WithTimeout(ctx, c.timeout)
 	defer cancel()
@@ -26,9 +26,11 @@ var _ proto.UsersServiceServer = (*server)(nil)
 type server struct {
 	proto.UnimplementedUsersServiceServer
 	getUserByID        kitgrpc.Handler
+	getUserEmail       kitgrpc.Handler
 	getUserPreferences kitgrpc.Handler
 	getUserFollowers   kitgrpc.Handler
 	createFeed         kitgrpc.Handler
+	deleteFeed         kitgrpc.Handler
 	identifyUser       kitgrpc.Handler
 }
 
@@ -39,6 +41,11 @@ func NewServer(svc users.Service) proto.UsersServiceServer {
 			decodeGetUserByIDRequest,
 			encodeGetUserByIDResponse,
 		),
+		getUserEmail: kitgrpc.NewServer(
+			api.GetUserEndpoint(svc),
+			decodeGetUserEmailRequest,
+			encodeGetUserEmailResponse,
+		),
 		getUserPreferences: kitgrpc.NewServer(
 			api.GetUserPreferenceEndpoint(svc),
 			decodeGetUserPreferencesRequest,
@@ -54,6 +61,11 @@ func NewServer(svc users.Service) proto.UsersServiceServer {
 			decodeCreateFeedRequest,
 			encodeCreateFeedResponse,
 		),
+		deleteFeed: kitgrpc.NewServer(
+			api.DeleteFeedEndpoint(svc),
+			decodeDeleteFeedRequest,
+			encodeDeleteFeedResponse,
+		),
 		identifyUser: kitgrpc.NewServer(
 			api.IdentifyUserEndpoint(svc),
 			decodeIdentifyUserRequest,
@@ -104,6 +116,42 @@ func encodeGetUserByIDResponse(_ context.Context, grpcRes interface{}) (interfac
 	}, nil
 }
 
+func (s *server) GetUserEmail(ctx context.Context, req *proto.GetUserEmailRequest) (*proto.GetUserEmailResponse, error) {
+	_, res, err := s.getUserEmail.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, encodeError(err)
+	}
+	resp, ok := res.(*proto.GetUserEmailResponse)
+	if !ok {
+		return nil, encodeError(errors.New("invalid response"))
+	}
+
+	return resp, nil
+}
+
+func decodeGetUserEmailRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req, ok := grpcReq.(*proto.GetUserEmailRequest)
+	if !ok {
+		return nil, encodeError(errors.New("invalid request"))
+	}
+
+	return api.EntityReq{
+		ID:  req.GetId(),
+		SVC: api.GRPC,
+	}, nil
+}
+
+func encodeGetUserEmailResponse(_ context.Context, grpcRes interface{}) (interface{}, error) {
+	res, ok := grpcRes.(users.User)
+	if !ok {
+		return nil, encodeError(errors.New("invalid response"))
+	}
+
+	return &proto.GetUserEmailResponse{
+		Email: res.Email,
+	}, nil
+}
+
 func (s *server) GetUserPreferences(ctx context.Context, req *proto.GetUserPreferencesRequest) (*proto.GetUserPreferencesResponse, error) {
 	_, res, err := s.getUserPreferences.ServeGRPC(ctx, req)
 	if err != nil {
@@ -164,9 +212,10 @@ func decodeGetUserFollowersRequest(_ context.Context, grpcReq interface{}) (inte
 	return api.EntitiesReq{
 		SVC: api.GRPC,
 		Page: users.Page{
-			Offset: req.GetOffset(),
-			Limit:  req.GetLimit(),
-			UserID: req.GetId(),
+			Offset:     req.GetOffset(),
+			Limit:      req.GetLimit(),
+			Cursor:     req.GetCursor(),
+			FollowerID: req.GetId(),
 		},
 	}, nil
 }
@@ -191,6 +240,7 @@ func encodeGetUserFollowersResponse(_ context.Context, grpcRes interface{}) (int
 		Total:      res.Total,
 		Offset:     res.Offset,
 		Limit:      res.Limit,
+		NextCursor: res.NextCursor,
 	}, nil
 }
 
@@ -213,7 +263,58 @@ func decodeCreateFeedRequest(_ context.Context, grpcReq interface{}) (interface{
 		return nil, encodeError(errors.New("invalid request"))
 	}
 
+	feeds := make([]users.Feed, 0, len(req.GetUserIds())+1)
+	if req.GetUserId() != "" {
+		feeds = append(feeds, users.Feed{
+			UserID:   req.GetUserId(),
+			PostID:   req.GetPostId(),
+			AuthorID: req.GetAuthorId(),
+		})
+	}
+	for _, userID := range req.GetUserIds() {
+		feeds = append(feeds, users.Feed{
+			UserID:   userID,
+			PostID:   req.GetPostId(),
+			AuthorID: req.GetAuthorId(),
+		})
+	}
+
 	return api.CreateFeedReq{
+		Feeds: feeds,
+	}, nil
+}
+
+func encodeCreateFeedResponse(_ context.Context, grpcRes interface{}) (interface{}, error) {
+	res, ok := grpcRes.(map[string]string)
+	if !ok {
+		return nil, encodeError(errors.New("invalid response"))
+	}
+
+	return &proto.CreateFeedResponse{
+		Message: res["message"],
+	}, nil
+}
+
+func (s *server) DeleteFeed(ctx context.Context, req *proto.DeleteFeedRequest) (*proto.DeleteFeedResponse, error) {
+	_, res, err := s.deleteFeed.ServeGRPC(ctx, req)
+	if err != nil {
+		return nil, encodeError(err)
+	}
+	resp, ok := res.(*proto.DeleteFeedResponse)
+	if !ok {
+		return nil, encodeError(errors.New("invalid response"))
+	}
+
+	return resp, nil
+}
+
+func decodeDeleteFeedRequest(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req, ok := grpcReq.(*proto.DeleteFeedRequest)
+	if !ok {
+		return nil, encodeError(errors.New("invalid request"))
+	}
+
+	return api.DeleteFeedReq{
 		Feed: users.Feed{
 			UserID: req.GetUserId(),
 			PostID: req.GetPostId(),
@@ -221,13 +322,13 @@ func decodeCreateFeedRequest(_ context.Context, grpcReq interface{}) (interface{
 	}, nil
 }
 
-func encodeCreateFeedResponse(_ context.Context, grpcRes interface{}) (interface{}, error) {
+func encodeDeleteFeedResponse(_ context.Context, grpcRes interface{}) (interface{}, error) {
 	res, ok := grpcRes.(map[string]string)
 	if !ok {
 		return nil, encodeError(errors.New("invalid response"))
 	}
 
-	return &proto.CreateFeedResponse{
+	return &proto.DeleteFeedResponse{
 		Message: res["message"],
 	}, nil
 }
@@ -251,7 +352,7 @@ func decodeIdentifyUserRequest(_ context.Context, grpcReq interface{}) (interfac
 		return nil, encodeError(errors.New("invalid request"))
 	}
 
-	return api.RefreshTokenReq{
+	return api.IdentifyUserReq{
 		Token: req.GetToken(),
 	}, nil
 }
@@ -141,6 +141,18 @@ func MakeHandler(svc users.Service) http.Handler {
 				iapi.EncodeResponse,
 				opts...,
 			), "refresh_token").ServeHTTP)
+			r.Post("/revoke", otelhttp.NewHandler(kithttp.NewServer(
+				api.RevokeTokenEndpoint(svc),
+				decodeRevokeTokenRequest,
+				iapi.EncodeResponse,
+				opts...,
+			), "revoke_token").ServeHTTP)
+			r.Post("/revoke/all", otelhttp.NewHandler(kithttp.NewServer(
+				api.RevokeTokenEndpoint(svc),
+				decodeRevokeAllTokensRequest,
+				iapi.EncodeResponse,
+				opts...,
+			), "revoke_all_tokens").ServeHTTP)
 		})
 
 		r.Route("/preferences", func(r chi.Router) {
@@ -221,6 +233,7 @@ func decodeGetUsersRequest(_ context.Context, r *http.Request) (interface{}, err
 		return nil, err
 	}
 	req.Page.Limit = uint64(intLimit)
+	req.Page.Cursor = r.URL.Query().Get("cursor")
 
 	return req, nil
 }
@@ -265,7 +278,26 @@ func decodeIssueTokenRequest(_ context.Context, r *http.Request) (interface{}, e
 
 func decodeRefreshTokenRequest(_ context.Context, r *http.Request) (interface{}, error) {
 	var req api.RefreshTokenReq
-	req.Token = iapi.ExtractToken(r)
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+func decodeRevokeTokenRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	req := api.RevokeTokenReq{
+		Token: iapi.ExtractToken(r),
+	}
+
+	return req, nil
+}
+
+func decodeRevokeAllTokensRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	req := api.RevokeTokenReq{
+		Token: iapi.ExtractToken(r),
+		All:   true,
+	}
 
 	return req, nil
 }
@@ -327,6 +359,7 @@ func decodeGetFollowersRequest(_ context.Context, r *http.Request) (interface{},
 		return nil, err
 	}
 	req.Page.Limit = uint64(intLimit)
+	req.Page.Cursor = r.URL.Query().Get("cursor")
 
 	req.Page.FollowerID = chi.URLParam(r, "userID")
 
@@ -355,6 +388,7 @@ func decodeGetFollowingsRequest(_ context.Context, r *http.Request) (interface{}
 		return nil, err
 	}
 	req.Page.Limit = uint64(intLimit)
+	req.Page.Cursor = r.URL.Query().Get("cursor")
 
 	req.Page.FolloweeID = chi.URLParam(r, "userID")
 
@@ -139,10 +139,36 @@ func (req IssueTokenReq) validate() error {
 }
 
 type RefreshTokenReq struct {
-	Token string
+	RefreshToken string `json:"refresh_token"`
 }
 
 func (req RefreshTokenReq) validate() error {
+	if req.RefreshToken == "" {
+		return errors.New("refresh token is required")
+	}
+
+	return nil
+}
+
+type IdentifyUserReq struct {
+	Token string
+}
+
+func (req IdentifyUserReq) validate() error {
+	if req.Token == "" {
+		return errors.New("token is required")
+	}
+
+	return nil
+}
+
+type RevokeTokenReq struct {
+	Token string
+	// All revokes every session of the user rather than the current one.
+	All bool
+}
+
+func (req RevokeTokenReq) validate() error {
 	if req.Token == "" {
 		return errors.New("token is required")
 	}
@@ -215,9 +241,35 @@ func (req FollowReq) validate() error {
 }
 
 type CreateFeedReq struct {
-	users.Feed
+	Feeds []users.Feed
 }
 
 func (req CreateFeedReq) validate() error {
+	if len(req.Feeds) == 0 {
+		return errors.New("feeds are required")
+	}
+
+	for _, feed := range req.Feeds {
+		if feed.UserID == "" {
+			return errors.New("user id is required")
+		}
+
+		if feed.PostID == "" {
+			return errors.New("post id is required")
+		}
+	}
+
+	return nil
+}
+
+type DeleteFeedReq struct {
+	users.Feed
+}
+
+func (req DeleteFeedReq) validate() error {
+	if req.PostID == "" {
+		return errors.New("post id is required")
+	}
+
 	return nil
 }
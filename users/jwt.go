@@ -7,7 +7,82 @@
 
 package users
 
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+)
+
+// refreshTokenSize is the number of random bytes in a refresh token.
+const refreshTokenSize = 32
+
+// Token is the pair of tokens issued when a user logs in or refreshes their
+// tokens. The access token authenticates requests until it expires shortly
+// after, while the refresh token is exchanged for a new pair.
+type Token struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// RefreshToken is a stored refresh token. Only the hash of the token is kept.
+// Every login starts a family, and the tokens issued by refreshing a token
+// join its family, so that a reused token can revoke the whole family.
+type RefreshToken struct {
+	ID        string     `db:"id"`
+	FamilyID  string     `db:"family_id"`
+	UserID    string     `db:"user_id"`
+	Hash      string     `db:"token_hash"`
+	ExpiresAt time.Time  `db:"expires_at"`
+	UsedAt    *time.Time `db:"used_at"`
+	RevokedAt *time.Time `db:"revoked_at"`
+	CreatedAt time.Time  `db:"created_at"`
+}
+
 type Tokenizer interface {
-	Issue(userID string) (string, error)
-	Validate(token string) (string, error)
+	// Issue returns an access token of the user for the session, which is the
+	// family of the refresh token issued along with it.
+	Issue(userID, sessionID string) (string, error)
+	// Validate returns the user of the access token, rejecting it once either
+	// the token or its session has been revoked.
+	Validate(ctx context.Context, token string) (string, error)
+	// Revoke rejects the access token until it expires and returns its session.
+	Revoke(ctx context.Context, token string) (string, error)
+	// RevokeSession rejects the access tokens of the session until they expire.
+	RevokeSession(ctx context.Context, sessionID string) error
+}
+
+//go:generate mockery --name RefreshTokenRepository --output=./mocks --filename refresh.go --quiet
+type RefreshTokenRepository interface {
+	Create(ctx context.Context, token RefreshToken) error
+	RetrieveByHash(ctx context.Context, hash string) (RefreshToken, error)
+	// Use marks the token as used. It fails once the token has been used or
+	// revoked, so that concurrent refreshes cannot both rotate it.
+	Use(ctx context.Context, id string) error
+	RevokeFamily(ctx context.Context, familyID string) error
+	// RevokeUser revokes the tokens of the user and returns the families of
+	// those that were still usable.
+	RevokeUser(ctx context.Context, userID string) ([]string, error)
+}
+
+// NewRefreshToken returns a random refresh token along with its hash.
+func NewRefreshToken() (string, string, error) {
+	b := make([]byte, refreshTokenSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	return token, HashRefreshToken(token), nil
+}
+
+// HashRefreshToken returns the hash under which the refresh token is stored.
+// Refresh tokens are random, so unlike passwords they need neither salt nor
+// a slow hash.
+func HashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(sum[:])
 }
@@ -15,27 +15,48 @@ import (
 	"github.com/gofrs/uuid"
 	"github.com/lestrrat-go/jwx/v2/jwa"
 	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/rodneyosodo/twiga/internal/cache"
 	"github.com/rodneyosodo/twiga/users"
 )
 
-const issuer = "twiga"
+const (
+	issuer = "twiga"
+	// sessionClaim holds the session of the access token.
+	sessionClaim = "sid"
+
+	tokenPrefix   = "jti:"
+	sessionPrefix = "sid:"
+)
+
+// DenylistNamespace holds the revoked access tokens and sessions.
+var DenylistNamespace = cache.Namespace{Name: "revoked", Version: 1}
+
+var errRevoked = errors.New("token has been revoked")
 
 type tokenizer struct {
-	secret  []byte
-	expires time.Duration
+	secret   []byte
+	expires  time.Duration
+	denylist cache.Cacher[bool]
 }
 
-func NewTokenizer(secret string, expires time.Duration) users.Tokenizer {
+// NewTokenizer returns a tokenizer of access tokens that expire after the
+// given duration. Revoked tokens and sessions are kept in the denylist until
+// the tokens they reject have expired.
+func NewTokenizer(secret string, expires time.Duration, denylist cache.Cacher[bool]) users.Tokenizer {
 	return &tokenizer{
-		secret:  []byte(secret),
-		expires: expires,
+		secret:   []byte(secret),
+		expires:  expires,
+		denylist: denylist,
 	}
 }
 
-func (t *tokenizer) Issue(userID string) (string, error) {
+func (t *tokenizer) Issue(userID, sessionID string) (string, error) {
 	if userID == "" {
 		return "", errors.New("user id is required")
 	}
+	if sessionID == "" {
+		return "", errors.New("session id is required")
+	}
 	id, err := uuid.NewV4()
 	if err != nil {
 		return "", err
@@ -46,6 +67,7 @@ func (t *tokenizer) Issue(userID string) (string, error) {
 		JwtID(id.String()).
 		IssuedAt(time.Now()).
 		Expiration(time.Now().Add(t.expires)).
+		Claim(sessionClaim, sessionID).
 		Build()
 	if err != nil {
 		return "", err
@@ -59,9 +81,52 @@ func (t *tokenizer) Issue(userID string) (string, error) {
 	return string(signedToken), nil
 }
 
-func (t *tokenizer) Validate(token string) (string, error) {
+func (t *tokenizer) Validate(ctx context.Context, token string) (string, error) {
+	tkn, err := t.parse(token)
+	if err != nil {
+		return "", err
+	}
+
+	// Tokens are rejected when the denylist cannot be read, rather than risk
+	// accepting a revoked one.
+	revoked, err := t.denylist.MGet(ctx, []string{tokenPrefix + tkn.JwtID(), sessionPrefix + session(tkn)})
+	if err != nil {
+		return "", err
+	}
+	if len(revoked) > 0 {
+		return "", errRevoked
+	}
+
+	return tkn.Subject(), nil
+}
+
+func (t *tokenizer) Revoke(ctx context.Context, token string) (string, error) {
+	tkn, err := t.parse(token)
+	if err != nil {
+		return "", err
+	}
+
+	if ttl := time.Until(tkn.Expiration()); ttl > 0 {
+		if err := t.denylist.Add(ctx, tokenPrefix+tkn.JwtID(), true, ttl); err != nil {
+			return "", err
+		}
+	}
+
+	return session(tkn), nil
+}
+
+func (t *tokenizer) RevokeSession(ctx context.Context, sessionID string) error {
+	if sessionID == "" {
+		return errors.New("session id is required")
+	}
+
+	// Every access token of the session was issued at most expires ago.
+	return t.denylist.Add(ctx, sessionPrefix+sessionID, true, t.expires)
+}
+
+func (t *tokenizer) parse(token string) (jwt.Token, error) {
 	if token == "" {
-		return "", errors.New("token is required")
+		return nil, errors.New("token is required")
 	}
 
 	tkn, err := jwt.Parse(
@@ -70,7 +135,7 @@ func (t *tokenizer) Validate(token string) (string, error) {
 		jwt.WithKey(jwa.HS512, t.secret),
 	)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	validator := jwt.ValidatorFunc(func(_ context.Context, jwtToken jwt.Token) jwt.ValidationError {
 		if jwtToken.Issuer() != issuer {
@@ -80,8 +145,15 @@ func (t *tokenizer) Validate(token string) (string, error) {
 		return nil
 	})
 	if err := jwt.Validate(tkn, jwt.WithValidator(validator)); err != nil {
-		return "", err
+		return nil, err
 	}
 
-	return tkn.Subject(), nil
+	return tkn, nil
+}
+
+func session(tkn jwt.Token) string {
+	v, _ := tkn.Get(sessionClaim)
+	sessionID, _ := v.(string)
+
+	return sessionID
 }
@@ -8,6 +8,7 @@
 package jwt_test
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"testing"
@@ -16,6 +17,8 @@ import (
 	"github.com/gofrs/uuid"
 	"github.com/lestrrat-go/jwx/v2/jwa"
 	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/rodneyosodo/twiga/internal/cache"
+	"github.com/rodneyosodo/twiga/users"
 	tjwt "github.com/rodneyosodo/twiga/users/jwt"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -23,6 +26,10 @@ import (
 
 const secret = "longsecretkey"
 
+func newTokenizer() users.Tokenizer {
+	return tjwt.NewTokenizer(secret, time.Minute, cache.NewMemory[bool](100, time.Minute))
+}
+
 func newToken(t *testing.T, expired bool, issuer bool) string {
 	id, err := uuid.NewV4()
 	require.Nil(t, err, fmt.Sprintf("generating new UUID expected to succeed: %s", err))
@@ -51,30 +58,38 @@ func newToken(t *testing.T, expired bool, issuer bool) string {
 }
 
 func TestIssue(t *testing.T) {
-	tokenizer := tjwt.NewTokenizer(secret, time.Minute)
+	tokenizer := newTokenizer()
 
 	cases := []struct {
-		desc   string
-		userID string
-		err    error
+		desc      string
+		userID    string
+		sessionID string
+		err       error
 	}{
 		{
-			desc:   "issue new token",
-			userID: uuid.Must(uuid.NewV4()).String(),
-			err:    nil,
+			desc:      "issue new token",
+			userID:    uuid.Must(uuid.NewV4()).String(),
+			sessionID: uuid.Must(uuid.NewV4()).String(),
+			err:       nil,
+		},
+		{
+			desc:      "issue with empty user",
+			sessionID: uuid.Must(uuid.NewV4()).String(),
+			err:       errors.New("user id is required"),
 		},
 		{
-			desc: "issue with empty user",
-			err:  errors.New("user id is required"),
+			desc:   "issue with empty session",
+			userID: uuid.Must(uuid.NewV4()).String(),
+			err:    errors.New("session id is required"),
 		},
 	}
 	for _, tc := range cases {
 		t.Run(tc.desc, func(t *testing.T) {
-			tkn, err := tokenizer.Issue(tc.userID)
+			tkn, err := tokenizer.Issue(tc.userID, tc.sessionID)
 			switch tc.err {
 			case nil:
 				assert.NotEmpty(t, tkn)
-				userID, err := tokenizer.Validate(tkn)
+				userID, err := tokenizer.Validate(context.Background(), tkn)
 				assert.Nil(t, err)
 				assert.Equal(t, tc.userID, userID)
 			default:
@@ -85,7 +100,7 @@ func TestIssue(t *testing.T) {
 }
 
 func TestValidate(t *testing.T) {
-	tokenizer := tjwt.NewTokenizer(secret, time.Minute)
+	tokenizer := newTokenizer()
 
 	cases := []struct {
 		desc string
@@ -121,7 +136,7 @@ func TestValidate(t *testing.T) {
 
 	for _, tc := range cases {
 		t.Run(tc.desc, func(t *testing.T) {
-			userID, err := tokenizer.Validate(tc.tkn)
+			userID, err := tokenizer.Validate(context.Background(), tc.tkn)
 			switch tc.err {
 			case nil:
 				assert.NotEmpty(t, userID)
@@ -131,3 +146,52 @@ func TestValidate(t *testing.T) {
 		})
 	}
 }
+
+func TestRevoke(t *testing.T) {
+	ctx := context.Background()
+	tokenizer := newTokenizer()
+
+	sessionID := uuid.Must(uuid.NewV4()).String()
+	revoked, err := tokenizer.Issue("user", sessionID)
+	require.NoError(t, err)
+	other, err := tokenizer.Issue("user", sessionID)
+	require.NoError(t, err)
+
+	session, err := tokenizer.Revoke(ctx, revoked)
+	require.NoError(t, err)
+	assert.Equal(t, sessionID, session)
+
+	_, err = tokenizer.Validate(ctx, revoked)
+	assert.ErrorContains(t, err, "token has been revoked")
+
+	userID, err := tokenizer.Validate(ctx, other)
+	require.NoError(t, err, "other tokens of the session should still be valid")
+	assert.Equal(t, "user", userID)
+
+	_, err = tokenizer.Revoke(ctx, newToken(t, true, false))
+	assert.ErrorContains(t, err, "\"exp\" not satisfied")
+}
+
+func TestRevokeSession(t *testing.T) {
+	ctx := context.Background()
+	tokenizer := newTokenizer()
+
+	sessionID := uuid.Must(uuid.NewV4()).String()
+	first, err := tokenizer.Issue("user", sessionID)
+	require.NoError(t, err)
+	second, err := tokenizer.Issue("user", sessionID)
+	require.NoError(t, err)
+	other, err := tokenizer.Issue("user", uuid.Must(uuid.NewV4()).String())
+	require.NoError(t, err)
+
+	require.NoError(t, tokenizer.RevokeSession(ctx, sessionID))
+
+	for _, tkn := range []string{first, second} {
+		_, err := tokenizer.Validate(ctx, tkn)
+		assert.ErrorContains(t, err, "token has been revoked")
+	}
+	_, err = tokenizer.Validate(ctx, other)
+	assert.NoError(t, err, "tokens of other sessions should still be valid")
+
+	assert.EqualError(t, tokenizer.RevokeSession(ctx, ""), "session id is required")
+}
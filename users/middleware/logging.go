@@ -30,7 +30,7 @@ func NewLoggingMiddleware(logger *slog.Logger, svc users.Service) users.Service
 	}
 }
 
-func (lm *loggingMiddleware) IssueToken(ctx context.Context, user users.User) (token string, err error) {
+func (lm *loggingMiddleware) IssueToken(ctx context.Context, user users.User) (token users.Token, err error) {
 	defer func(begin time.Time) {
 		args := []any{
 			slog.String("duration", time.Since(begin).String()),
@@ -47,7 +47,7 @@ func (lm *loggingMiddleware) IssueToken(ctx context.Context, user users.User) (t
 	return lm.svc.IssueToken(ctx, user)
 }
 
-func (lm *loggingMiddleware) RefreshToken(ctx context.Context, token string) (t string, err error) {
+func (lm *loggingMiddleware) RefreshToken(ctx context.Context, refreshToken string) (t users.Token, err error) {
 	defer func(begin time.Time) {
 		args := []any{
 			slog.String("duration", time.Since(begin).String()),
@@ -61,7 +61,41 @@ func (lm *loggingMiddleware) RefreshToken(ctx context.Context, token string) (t
 		lm.logger.Info("Refresh token completed successfully", args...)
 	}(time.Now())
 
-	return lm.svc.RefreshToken(ctx, token)
+	return lm.svc.RefreshToken(ctx, refreshToken)
+}
+
+func (lm *loggingMiddleware) RevokeToken(ctx context.Context, token string) (err error) {
+	defer func(begin time.Time) {
+		args := []any{
+			slog.String("duration", time.Since(begin).String()),
+		}
+		if err != nil {
+			args = append(args, slog.Any("error", err))
+			lm.logger.Warn("Revoke token failed", args...)
+
+			return
+		}
+		lm.logger.Info("Revoke token completed successfully", args...)
+	}(time.Now())
+
+	return lm.svc.RevokeToken(ctx, token)
+}
+
+func (lm *loggingMiddleware) RevokeAllTokens(ctx context.Context, token string) (err error) {
+	defer func(begin time.Time) {
+		args := []any{
+			slog.String("duration", time.Since(begin).String()),
+		}
+		if err != nil {
+			args = append(args, slog.Any("error", err))
+			lm.logger.Warn("Revoke all tokens failed", args...)
+
+			return
+		}
+		lm.logger.Info("Revoke all tokens completed successfully", args...)
+	}(time.Now())
+
+	return lm.svc.RevokeAllTokens(ctx, token)
 }
 
 func (lm *loggingMiddleware) IdentifyUser(ctx context.Context, token string) (userID string, err error) {
@@ -511,15 +545,14 @@ func (lm *loggingMiddleware) DeleteFollower(ctx context.Context, token string, f
 	return lm.svc.DeleteFollower(ctx, token, following)
 }
 
-func (lm *loggingMiddleware) CreateFeed(ctx context.Context, feed users.Feed) (err error) {
+func (lm *loggingMiddleware) CreateFeed(ctx context.Context, feeds ...users.Feed) (err error) {
 	defer func(begin time.Time) {
 		args := []any{
 			slog.String("duration", time.Since(begin).String()),
-			slog.Group("feed",
-				slog.String("id", feed.ID),
-				slog.String("user_id", feed.UserID),
-				slog.String("post_id", feed.PostID),
-			),
+			slog.Int("feeds", len(feeds)),
+		}
+		if len(feeds) > 0 {
+			args = append(args, slog.String("post_id", feeds[0].PostID))
 		}
 		if err != nil {
 			args = append(args, slog.Any("error", err))
@@ -530,7 +563,7 @@ func (lm *loggingMiddleware) CreateFeed(ctx context.Context, feed users.Feed) (e
 		lm.logger.Info("Create feed completed successfully", args...)
 	}(time.Now())
 
-	return lm.svc.CreateFeed(ctx, feed)
+	return lm.svc.CreateFeed(ctx, feeds...)
 }
 
 func (lm *loggingMiddleware) GetUserFeed(ctx context.Context, token string, page users.Page) (fp users.FeedPage, err error) {
@@ -553,3 +586,24 @@ func (lm *loggingMiddleware) GetUserFeed(ctx context.Context, token string, page
 
 	return lm.svc.GetUserFeed(ctx, token, page)
 }
+
+func (lm *loggingMiddleware) DeleteFeed(ctx context.Context, feed users.Feed) (err error) {
+	defer func(begin time.Time) {
+		args := []any{
+			slog.String("duration", time.Since(begin).String()),
+			slog.Group("feed",
+				slog.String("user_id", feed.UserID),
+				slog.String("post_id", feed.PostID),
+			),
+		}
+		if err != nil {
+			args = append(args, slog.Any("error", err))
+			lm.logger.Warn("Delete feed failed", args...)
+
+			return
+		}
+		lm.logger.Info("Delete feed completed successfully", args...)
+	}(time.Now())
+
+	return lm.svc.DeleteFeed(ctx, feed)
+}
@@ -31,7 +31,7 @@ func NewMetricsMiddleware(counter metrics.Counter, latency metrics.Histogram, sv
 	}
 }
 
-func (m *metricsMiddleware) IssueToken(ctx context.Context, user users.User) (string, error) {
+func (m *metricsMiddleware) IssueToken(ctx context.Context, user users.User) (users.Token, error) {
 	defer func(begin time.Time) {
 		m.counter.With("method", "issue_token").Add(1)
 		m.latency.With("method", "issue_token").Observe(time.Since(begin).Seconds())
@@ -40,13 +40,31 @@ func (m *metricsMiddleware) IssueToken(ctx context.Context, user users.User) (st
 	return m.svc.IssueToken(ctx, user)
 }
 
-func (m *metricsMiddleware) RefreshToken(ctx context.Context, token string) (string, error) {
+func (m *metricsMiddleware) RefreshToken(ctx context.Context, refreshToken string) (users.Token, error) {
 	defer func(begin time.Time) {
 		m.counter.With("method", "refresh_token").Add(1)
 		m.latency.With("method", "refresh_token").Observe(time.Since(begin).Seconds())
 	}(time.Now())
 
-	return m.svc.RefreshToken(ctx, token)
+	return m.svc.RefreshToken(ctx, refreshToken)
+}
+
+func (m *metricsMiddleware) RevokeToken(ctx context.Context, token string) error {
+	defer func(begin time.Time) {
+		m.counter.With("method", "revoke_token").Add(1)
+		m.latency.With("method", "revoke_token").Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return m.svc.RevokeToken(ctx, token)
+}
+
+func (m *metricsMiddleware) RevokeAllTokens(ctx context.Context, token string) error {
+	defer func(begin time.Time) {
+		m.counter.With("method", "revoke_all_tokens").Add(1)
+		m.latency.With("method", "revoke_all_tokens").Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return m.svc.RevokeAllTokens(ctx, token)
 }
 
 func (m *metricsMiddleware) IdentifyUser(ctx context.Context, token string) (userID string, err error) {
@@ -247,13 +265,13 @@ func (m *metricsMiddleware) DeleteFollower(ctx context.Context, token string, fo
 	return m.svc.DeleteFollower(ctx, token, following)
 }
 
-func (m *metricsMiddleware) CreateFeed(ctx context.Context, feed users.Feed) error {
+func (m *metricsMiddleware) CreateFeed(ctx context.Context, feeds ...users.Feed) error {
 	defer func(begin time.Time) {
 		m.counter.With("method", "create_feed").Add(1)
 		m.latency.With("method", "create_feed").Observe(time.Since(begin).Seconds())
 	}(time.Now())
 
-	return m.svc.CreateFeed(ctx, feed)
+	return m.svc.CreateFeed(ctx, feeds...)
 }
 
 func (m *metricsMiddleware) GetUserFeed(ctx context.Context, token string, page users.Page) (feeds users.FeedPage, err error) {
@@ -264,3 +282,12 @@ func (m *metricsMiddleware) GetUserFeed(ctx context.Context, token string, page
 
 	return m.svc.GetUserFeed(ctx, token, page)
 }
+
+func (m *metricsMiddleware) DeleteFeed(ctx context.Context, feed users.Feed) error {
+	defer func(begin time.Time) {
+		m.counter.With("method", "delete_feed").Add(1)
+		m.latency.With("method", "delete_feed").Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return m.svc.DeleteFeed(ctx, feed)
+}
@@ -29,18 +29,32 @@ func NewTracingMiddleware(tracer trace.Tracer, svc users.Service) users.Service
 	}
 }
 
-func (m *tracingMiddleware) IssueToken(ctx context.Context, user users.User) (string, error) {
+func (m *tracingMiddleware) IssueToken(ctx context.Context, user users.User) (users.Token, error) {
 	ctx, span := m.tracer.Start(ctx, "issue_token")
 	defer span.End()
 
 	return m.svc.IssueToken(ctx, user)
 }
 
-func (m *tracingMiddleware) RefreshToken(ctx context.Context, token string) (string, error) {
+func (m *tracingMiddleware) RefreshToken(ctx context.Context, refreshToken string) (users.Token, error) {
 	ctx, span := m.tracer.Start(ctx, "refresh_token")
 	defer span.End()
 
-	return m.svc.RefreshToken(ctx, token)
+	return m.svc.RefreshToken(ctx, refreshToken)
+}
+
+func (m *tracingMiddleware) RevokeToken(ctx context.Context, token string) error {
+	ctx, span := m.tracer.Start(ctx, "revoke_token")
+	defer span.End()
+
+	return m.svc.RevokeToken(ctx, token)
+}
+
+func (m *tracingMiddleware) RevokeAllTokens(ctx context.Context, token string) error {
+	ctx, span := m.tracer.Start(ctx, "revoke_all_tokens")
+	defer span.End()
+
+	return m.svc.RevokeAllTokens(ctx, token)
 }
 
 func (m *tracingMiddleware) IdentifyUser(ctx context.Context, token string) (userID string, err error) {
@@ -245,14 +259,13 @@ func (m *tracingMiddleware) DeleteFollower(ctx context.Context, token string, fo
 	return m.svc.DeleteFollower(ctx, token, following)
 }
 
-func (m *tracingMiddleware) CreateFeed(ctx context.Context, feed users.Feed) (err error) {
+func (m *tracingMiddleware) CreateFeed(ctx context.Context, feeds ...users.Feed) (err error) {
 	ctx, span := m.tracer.Start(ctx, "create_feed", trace.WithAttributes(
-		attribute.String("user_id", feed.UserID),
-		attribute.String("post_id", feed.PostID),
+		attribute.Int("feeds", len(feeds)),
 	))
 	defer span.End()
 
-	return m.svc.CreateFeed(ctx, feed)
+	return m.svc.CreateFeed(ctx, feeds...)
 }
 
 func (m *tracingMiddleware) GetUserFeed(ctx context.Context, token string, page users.Page) (feed users.FeedPage, err error) {
@@ -261,3 +274,13 @@ func (m *tracingMiddleware) GetUserFeed(ctx context.Context, token string, page
 
 	return m.svc.GetUserFeed(ctx, token, page)
 }
+
+func (m *tracingMiddleware) DeleteFeed(ctx context.Context, feed users.Feed) error {
+	ctx, span := m.tracer.Start(ctx, "delete_feed", trace.WithAttributes(
+		attribute.String("user_id", feed.UserID),
+		attribute.String("post_id", feed.PostID),
+	))
+	defer span.End()
+
+	return m.svc.DeleteFeed(ctx, feed)
+}
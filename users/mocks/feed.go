@@ -14,14 +14,39 @@ type FeedRepository struct {
 	mock.Mock
 }
 
-// Create provides a mock function with given fields: ctx, feed
-func (_m *FeedRepository) Create(ctx context.Context, feed users.Feed) error {
-	ret := _m.Called(ctx, feed)
+// Create provides a mock function with given fields: ctx, feeds
+func (_m *FeedRepository) Create(ctx context.Context, feeds ...users.Feed) error {
+	_va := make([]interface{}, len(feeds))
+	for _i := range feeds {
+		_va[_i] = feeds[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
 
 	if len(ret) == 0 {
 		panic("no return value specified for Create")
 	}
 
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, ...users.Feed) error); ok {
+		r0 = rf(ctx, feeds...)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Delete provides a mock function with given fields: ctx, feed
+func (_m *FeedRepository) Delete(ctx context.Context, feed users.Feed) error {
+	ret := _m.Called(ctx, feed)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Delete")
+	}
+
 	var r0 error
 	if rf, ok := ret.Get(0).(func(context.Context, users.Feed) error); ok {
 		r0 = rf(ctx, feed)
@@ -88,6 +88,36 @@ func (_m *FollowingRepository) RetrieveAll(ctx context.Context, page users.Page)
 	return r0, r1
 }
 
+// RetrievePopular provides a mock function with given fields: ctx, followerID, threshold
+func (_m *FollowingRepository) RetrievePopular(ctx context.Context, followerID string, threshold uint64) ([]string, error) {
+	ret := _m.Called(ctx, followerID, threshold)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RetrievePopular")
+	}
+
+	var r0 []string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, uint64) ([]string, error)); ok {
+		return rf(ctx, followerID, threshold)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string, uint64) []string); ok {
+		r0 = rf(ctx, followerID, threshold)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string, uint64) error); ok {
+		r1 = rf(ctx, followerID, threshold)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // NewFollowingRepository creates a new instance of FollowingRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewFollowingRepository(t interface {
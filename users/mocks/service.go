@@ -14,17 +14,24 @@ type Service struct {
 	mock.Mock
 }
 
-// CreateFeed provides a mock function with given fields: ctx, feed
-func (_m *Service) CreateFeed(ctx context.Context, feed users.Feed) error {
-	ret := _m.Called(ctx, feed)
+// CreateFeed provides a mock function with given fields: ctx, feeds
+func (_m *Service) CreateFeed(ctx context.Context, feeds ...users.Feed) error {
+	_va := make([]interface{}, len(feeds))
+	for _i := range feeds {
+		_va[_i] = feeds[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
 
 	if len(ret) == 0 {
 		panic("no return value specified for CreateFeed")
 	}
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, users.Feed) error); ok {
-		r0 = rf(ctx, feed)
+	if rf, ok := ret.Get(0).(func(context.Context, ...users.Feed) error); ok {
+		r0 = rf(ctx, feeds...)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -116,6 +123,24 @@ func (_m *Service) CreateUser(ctx context.Context, user users.User) (users.User,
 	return r0, r1
 }
 
+// DeleteFeed provides a mock function with given fields: ctx, feed
+func (_m *Service) DeleteFeed(ctx context.Context, feed users.Feed) error {
+	ret := _m.Called(ctx, feed)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteFeed")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, users.Feed) error); ok {
+		r0 = rf(ctx, feed)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // DeleteFollower provides a mock function with given fields: ctx, token, following
 func (_m *Service) DeleteFollower(ctx context.Context, token string, following users.Following) error {
 	ret := _m.Called(ctx, token, following)
@@ -367,22 +392,22 @@ func (_m *Service) IdentifyUser(ctx context.Context, token string) (string, erro
 }
 
 // IssueToken provides a mock function with given fields: ctx, user
-func (_m *Service) IssueToken(ctx context.Context, user users.User) (string, error) {
+func (_m *Service) IssueToken(ctx context.Context, user users.User) (users.Token, error) {
 	ret := _m.Called(ctx, user)
 
 	if len(ret) == 0 {
 		panic("no return value specified for IssueToken")
 	}
 
-	var r0 string
+	var r0 users.Token
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, users.User) (string, error)); ok {
+	if rf, ok := ret.Get(0).(func(context.Context, users.User) (users.Token, error)); ok {
 		return rf(ctx, user)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, users.User) string); ok {
+	if rf, ok := ret.Get(0).(func(context.Context, users.User) users.Token); ok {
 		r0 = rf(ctx, user)
 	} else {
-		r0 = ret.Get(0).(string)
+		r0 = ret.Get(0).(users.Token)
 	}
 
 	if rf, ok := ret.Get(1).(func(context.Context, users.User) error); ok {
@@ -394,27 +419,27 @@ func (_m *Service) IssueToken(ctx context.Context, user users.User) (string, err
 	return r0, r1
 }
 
-// RefreshToken provides a mock function with given fields: ctx, token
-func (_m *Service) RefreshToken(ctx context.Context, token string) (string, error) {
-	ret := _m.Called(ctx, token)
+// RefreshToken provides a mock function with given fields: ctx, refreshToken
+func (_m *Service) RefreshToken(ctx context.Context, refreshToken string) (users.Token, error) {
+	ret := _m.Called(ctx, refreshToken)
 
 	if len(ret) == 0 {
 		panic("no return value specified for RefreshToken")
 	}
 
-	var r0 string
+	var r0 users.Token
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, string) (string, error)); ok {
-		return rf(ctx, token)
+	if rf, ok := ret.Get(0).(func(context.Context, string) (users.Token, error)); ok {
+		return rf(ctx, refreshToken)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, string) string); ok {
-		r0 = rf(ctx, token)
+	if rf, ok := ret.Get(0).(func(context.Context, string) users.Token); ok {
+		r0 = rf(ctx, refreshToken)
 	} else {
-		r0 = ret.Get(0).(string)
+		r0 = ret.Get(0).(users.Token)
 	}
 
 	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
-		r1 = rf(ctx, token)
+		r1 = rf(ctx, refreshToken)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -422,6 +447,42 @@ func (_m *Service) RefreshToken(ctx context.Context, token string) (string, erro
 	return r0, r1
 }
 
+// RevokeAllTokens provides a mock function with given fields: ctx, token
+func (_m *Service) RevokeAllTokens(ctx context.Context, token string) error {
+	ret := _m.Called(ctx, token)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RevokeAllTokens")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, token)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RevokeToken provides a mock function with given fields: ctx, token
+func (_m *Service) RevokeToken(ctx context.Context, token string) error {
+	ret := _m.Called(ctx, token)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RevokeToken")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, token)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // UpdateEmailPreferences provides a mock function with given fields: ctx, token, preference
 func (_m *Service) UpdateEmailPreferences(ctx context.Context, token string, preference users.Preference) (users.Preference, error) {
 	ret := _m.Called(ctx, token, preference)
@@ -0,0 +1,105 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package posts
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rodneyosodo/twiga/users"
+)
+
+var _ users.PostsClient = (*client)(nil)
+
+type client struct {
+	url        string
+	httpClient *http.Client
+}
+
+type post struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type postsPage struct {
+	Total      uint64 `json:"total"`
+	Offset     uint64 `json:"offset"`
+	Limit      uint64 `json:"limit"`
+	NextCursor string `json:"next_cursor"`
+	Posts      []post `json:"posts"`
+}
+
+func NewClient(url string, timeout time.Duration) users.PostsClient {
+	return &client{
+		url:        strings.TrimSuffix(url, "/"),
+		httpClient: &http.Client{Timeout: timeout},
+	}
+}
+
+func (c *client) RetrievePosts(ctx context.Context, token string, authorIDs []string, page users.Page) (users.FeedPage, error) {
+	if len(authorIDs) == 0 {
+		return users.FeedPage{Page: page}, nil
+	}
+
+	query := url.Values{}
+	query.Set("user_ids", strings.Join(authorIDs, ","))
+	query.Set("visibility", "true")
+	query.Set("offset", strconv.FormatUint(page.Offset, 10))
+	query.Set("limit", strconv.FormatUint(page.Limit, 10))
+	if page.Cursor != "" {
+		query.Set("cursor", page.Cursor)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/posts?%s", c.url, query.Encode()), http.NoBody)
+	if err != nil {
+		return users.FeedPage{}, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return users.FeedPage{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return users.FeedPage{}, errors.New("failed to retrieve posts: " + resp.Status)
+	}
+
+	var ppage postsPage
+	if err := json.NewDecoder(resp.Body).Decode(&ppage); err != nil {
+		return users.FeedPage{}, err
+	}
+
+	feeds := make([]users.Feed, 0, len(ppage.Posts))
+	for _, p := range ppage.Posts {
+		feeds = append(feeds, users.Feed{
+			PostID:    p.ID,
+			AuthorID:  p.UserID,
+			CreatedAt: p.CreatedAt,
+		})
+	}
+
+	return users.FeedPage{
+		Page: users.Page{
+			Total:      ppage.Total,
+			Offset:     ppage.Offset,
+			Limit:      ppage.Limit,
+			NextCursor: ppage.NextCursor,
+		},
+		Feeds: feeds,
+	}, nil
+}
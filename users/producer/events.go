@@ -9,32 +9,44 @@ package producer
 
 import (
 	"context"
-	"encoding/json"
 
 	"github.com/rodneyosodo/twiga/internal/events"
+	"github.com/rodneyosodo/twiga/internal/events/outbox"
 	"github.com/rodneyosodo/twiga/users"
 )
 
+const source = "/twiga/users"
+
 var _ users.Service = (*eventStore)(nil)
 
 type eventStore struct {
-	publisher events.Publisher
-	svc       users.Service
+	outbox outbox.Store
+	svc    users.Service
 }
 
-func NewEventStore(pub events.Publisher, svc users.Service) users.Service {
+// NewEventStore returns a service that records an event for every change in the
+// outbox, in the same transaction as the change, for the relay to publish.
+func NewEventStore(store outbox.Store, svc users.Service) users.Service {
 	return &eventStore{
-		publisher: pub,
-		svc:       svc,
+		outbox: store,
+		svc:    svc,
 	}
 }
 
-func (e *eventStore) IssueToken(ctx context.Context, user users.User) (string, error) {
+func (e *eventStore) IssueToken(ctx context.Context, user users.User) (users.Token, error) {
 	return e.svc.IssueToken(ctx, user)
 }
 
-func (e *eventStore) RefreshToken(ctx context.Context, token string) (string, error) {
-	return e.svc.RefreshToken(ctx, token)
+func (e *eventStore) RefreshToken(ctx context.Context, refreshToken string) (users.Token, error) {
+	return e.svc.RefreshToken(ctx, refreshToken)
+}
+
+func (e *eventStore) RevokeToken(ctx context.Context, token string) error {
+	return e.svc.RevokeToken(ctx, token)
+}
+
+func (e *eventStore) RevokeAllTokens(ctx context.Context, token string) error {
+	return e.svc.RevokeAllTokens(ctx, token)
 }
 
 func (e *eventStore) IdentifyUser(ctx context.Context, token string) (string, error) {
@@ -86,7 +98,18 @@ func (e *eventStore) DeleteUser(ctx context.Context, token string, id string) er
 }
 
 func (e *eventStore) CreatePreferences(ctx context.Context, token string, preference users.Preference) (users.Preference, error) {
-	return e.svc.CreatePreferences(ctx, token, preference)
+	err := e.outbox.WithTx(ctx, func(ctx context.Context) (err error) {
+		if preference, err = e.svc.CreatePreferences(ctx, token, preference); err != nil {
+			return err
+		}
+
+		return e.add(ctx, events.PreferencesCreated, preferenceEvent(preference))
+	})
+	if err != nil {
+		return users.Preference{}, err
+	}
+
+	return preference, nil
 }
 
 func (e *eventStore) GetPreferencesByUserID(ctx context.Context, token, id string) (users.Preference, error) {
@@ -98,31 +121,77 @@ func (e *eventStore) GetPreferences(ctx context.Context, token string, page user
 }
 
 func (e *eventStore) UpdatePreferences(ctx context.Context, token string, preference users.Preference) (users.Preference, error) {
-	return e.svc.UpdatePreferences(ctx, token, preference)
+	err := e.outbox.WithTx(ctx, func(ctx context.Context) (err error) {
+		if preference, err = e.svc.UpdatePreferences(ctx, token, preference); err != nil {
+			return err
+		}
+
+		return e.add(ctx, events.PreferencesUpdated, preferenceEvent(preference))
+	})
+	if err != nil {
+		return users.Preference{}, err
+	}
+
+	return preference, nil
 }
 
 func (e *eventStore) UpdateEmailPreferences(ctx context.Context, token string, preference users.Preference) (users.Preference, error) {
-	return e.svc.UpdateEmailPreferences(ctx, token, preference)
+	err := e.outbox.WithTx(ctx, func(ctx context.Context) (err error) {
+		if preference, err = e.svc.UpdateEmailPreferences(ctx, token, preference); err != nil {
+			return err
+		}
+
+		return e.add(ctx, events.PreferencesUpdated, preferenceEvent(preference))
+	})
+	if err != nil {
+		return users.Preference{}, err
+	}
+
+	return preference, nil
 }
 
 func (e *eventStore) UpdatePushPreferences(ctx context.Context, token string, preference users.Preference) (users.Preference, error) {
-	return e.svc.UpdatePushPreferences(ctx, token, preference)
+	err := e.outbox.WithTx(ctx, func(ctx context.Context) (err error) {
+		if preference, err = e.svc.UpdatePushPreferences(ctx, token, preference); err != nil {
+			return err
+		}
+
+		return e.add(ctx, events.PreferencesUpdated, preferenceEvent(preference))
+	})
+	if err != nil {
+		return users.Preference{}, err
+	}
+
+	return preference, nil
 }
 
 func (e *eventStore) DeletePreferences(ctx context.Context, token string) error {
-	return e.svc.DeletePreferences(ctx, token)
+	userID, err := e.svc.IdentifyUser(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	return e.outbox.WithTx(ctx, func(ctx context.Context) error {
+		if err := e.svc.DeletePreferences(ctx, token); err != nil {
+			return err
+		}
+
+		return e.add(ctx, events.PreferencesDeleted, events.PreferencesEvent{UserID: userID})
+	})
 }
 
 func (e *eventStore) CreateFollower(ctx context.Context, token string, following users.Following) (users.Following, error) {
-	following, err := e.svc.CreateFollower(ctx, token, following)
+	err := e.outbox.WithTx(ctx, func(ctx context.Context) (err error) {
+		if following, err = e.svc.CreateFollower(ctx, token, following); err != nil {
+			return err
+		}
+
+		return e.add(ctx, events.FollowerCreated, followerEvent(following))
+	})
 	if err != nil {
 		return users.Following{}, err
 	}
 
-	if err := e.Publish(ctx, e.publisher, "followers.created", following); err != nil {
-		return following, err
-	}
-
 	return following, nil
 }
 
@@ -134,24 +203,47 @@ func (e *eventStore) DeleteFollower(ctx context.Context, token string, following
 	return e.svc.DeleteFollower(ctx, token, following)
 }
 
-func (e *eventStore) CreateFeed(ctx context.Context, feed users.Feed) error {
-	return e.svc.CreateFeed(ctx, feed)
+func (e *eventStore) CreateFeed(ctx context.Context, feeds ...users.Feed) error {
+	return e.svc.CreateFeed(ctx, feeds...)
 }
 
 func (e *eventStore) GetUserFeed(ctx context.Context, token string, page users.Page) (users.FeedPage, error) {
 	return e.svc.GetUserFeed(ctx, token, page)
 }
 
-func (e *eventStore) Publish(ctx context.Context, pub events.Publisher, topic string, entity users.Following) error {
-	jsonEntity, err := json.Marshal(entity)
+func (e *eventStore) DeleteFeed(ctx context.Context, feed users.Feed) error {
+	return e.svc.DeleteFeed(ctx, feed)
+}
+
+func (e *eventStore) add(ctx context.Context, eventType string, data events.Data) error {
+	env, err := events.NewEnvelope(ctx, source, eventType, data)
 	if err != nil {
 		return err
 	}
-
-	var payload map[string]interface{}
-	if err := json.Unmarshal(jsonEntity, &payload); err != nil {
+	event, err := outbox.NewEvent(env)
+	if err != nil {
 		return err
 	}
 
-	return pub.Publish(ctx, topic, payload)
+	return e.outbox.Add(ctx, event)
+}
+
+func preferenceEvent(p users.Preference) events.PreferencesEvent {
+	return events.PreferencesEvent{
+		ID:           p.ID,
+		UserID:       p.UserID,
+		EmailEnabled: p.EmailEnable,
+		PushEnabled:  p.PushEnable,
+		CreatedAt:    p.CreatedAt,
+		UpdatedAt:    p.UpdatedAt,
+	}
+}
+
+func followerEvent(f users.Following) events.FollowerEvent {
+	return events.FollowerEvent{
+		ID:         f.ID,
+		FollowerID: f.FollowerID,
+		FolloweeID: f.FolloweeID,
+		CreatedAt:  f.CreatedAt,
+	}
 }
@@ -27,9 +27,11 @@ const _ = grpc.SupportPackageIsVersion8
 
 const (
 	UsersService_GetUserByID_FullMethodName        = "/proto.UsersService/GetUserByID"
+	UsersService_GetUserEmail_FullMethodName       = "/proto.UsersService/GetUserEmail"
 	UsersService_GetUserPreferences_FullMethodName = "/proto.UsersService/GetUserPreferences"
 	UsersService_GetUserFollowers_FullMethodName   = "/proto.UsersService/GetUserFollowers"
 	UsersService_CreateFeed_FullMethodName         = "/proto.UsersService/CreateFeed"
+	UsersService_DeleteFeed_FullMethodName         = "/proto.UsersService/DeleteFeed"
 	UsersService_IdentifyUser_FullMethodName       = "/proto.UsersService/IdentifyUser"
 )
 
@@ -38,9 +40,11 @@ const (
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type UsersServiceClient interface {
 	GetUserByID(ctx context.Context, in *GetUserByIDRequest, opts ...grpc.CallOption) (*GetUserByIDResponse, error)
+	GetUserEmail(ctx context.Context, in *GetUserEmailRequest, opts ...grpc.CallOption) (*GetUserEmailResponse, error)
 	GetUserPreferences(ctx context.Context, in *GetUserPreferencesRequest, opts ...grpc.CallOption) (*GetUserPreferencesResponse, error)
 	GetUserFollowers(ctx context.Context, in *GetUserFollowersRequest, opts ...grpc.CallOption) (*GetUserFollowersResponse, error)
 	CreateFeed(ctx context.Context, in *CreateFeedRequest, opts ...grpc.CallOption) (*CreateFeedResponse, error)
+	DeleteFeed(ctx context.Context, in *DeleteFeedRequest, opts ...grpc.CallOption) (*DeleteFeedResponse, error)
 	IdentifyUser(ctx context.Context, in *IdentifyUserRequest, opts ...grpc.CallOption) (*IdentifyUserResponse, error)
 }
 
@@ -62,6 +66,16 @@ func (c *usersServiceClient) GetUserByID(ctx context.Context, in *GetUserByIDReq
 	return out, nil
 }
 
+func (c *usersServiceClient) GetUserEmail(ctx context.Context, in *GetUserEmailRequest, opts ...grpc.CallOption) (*GetUserEmailResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetUserEmailResponse)
+	err := c.cc.Invoke(ctx, UsersService_GetUserEmail_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *usersServiceClient) GetUserPreferences(ctx context.Context, in *GetUserPreferencesRequest, opts ...grpc.CallOption) (*GetUserPreferencesResponse, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(GetUserPreferencesResponse)
@@ -92,6 +106,16 @@ func (c *usersServiceClient) CreateFeed(ctx context.Context, in *CreateFeedReque
 	return out, nil
 }
 
+func (c *usersServiceClient) DeleteFeed(ctx context.Context, in *DeleteFeedRequest, opts ...grpc.CallOption) (*DeleteFeedResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteFeedResponse)
+	err := c.cc.Invoke(ctx, UsersService_DeleteFeed_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *usersServiceClient) IdentifyUser(ctx context.Context, in *IdentifyUserRequest, opts ...grpc.CallOption) (*IdentifyUserResponse, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(IdentifyUserResponse)
@@ -107,9 +131,11 @@ func (c *usersServiceClient) IdentifyUser(ctx context.Context, in *IdentifyUserR
 // for forward compatibility
 type UsersServiceServer interface {
 	GetUserByID(context.Context, *GetUserByIDRequest) (*GetUserByIDResponse, error)
+	GetUserEmail(context.Context, *GetUserEmailRequest) (*GetUserEmailResponse, error)
 	GetUserPreferences(context.Context, *GetUserPreferencesRequest) (*GetUserPreferencesResponse, error)
 	GetUserFollowers(context.Context, *GetUserFollowersRequest) (*GetUserFollowersResponse, error)
 	CreateFeed(context.Context, *CreateFeedRequest) (*CreateFeedResponse, error)
+	DeleteFeed(context.Context, *DeleteFeedRequest) (*DeleteFeedResponse, error)
 	IdentifyUser(context.Context, *IdentifyUserRequest) (*IdentifyUserResponse, error)
 	mustEmbedUnimplementedUsersServiceServer()
 }
@@ -121,6 +147,9 @@ type UnimplementedUsersServiceServer struct {
 func (UnimplementedUsersServiceServer) GetUserByID(context.Context, *GetUserByIDRequest) (*GetUserByIDResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetUserByID not implemented")
 }
+func (UnimplementedUsersServiceServer) GetUserEmail(context.Context, *GetUserEmailRequest) (*GetUserEmailResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetUserEmail not implemented")
+}
 func (UnimplementedUsersServiceServer) GetUserPreferences(context.Context, *GetUserPreferencesRequest) (*GetUserPreferencesResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetUserPreferences not implemented")
 }
@@ -130,6 +159,9 @@ func (UnimplementedUsersServiceServer) GetUserFollowers(context.Context, *GetUse
 func (UnimplementedUsersServiceServer) CreateFeed(context.Context, *CreateFeedRequest) (*CreateFeedResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method CreateFeed not implemented")
 }
+func (UnimplementedUsersServiceServer) DeleteFeed(context.Context, *DeleteFeedRequest) (*DeleteFeedResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteFeed not implemented")
+}
 func (UnimplementedUsersServiceServer) IdentifyUser(context.Context, *IdentifyUserRequest) (*IdentifyUserResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method IdentifyUser not implemented")
 }
@@ -164,6 +196,24 @@ func _UsersService_GetUserByID_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _UsersService_GetUserEmail_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetUserEmailRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UsersServiceServer).GetUserEmail(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UsersService_GetUserEmail_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UsersServiceServer).GetUserEmail(ctx, req.(*GetUserEmailRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _UsersService_GetUserPreferences_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(GetUserPreferencesRequest)
 	if err := dec(in); err != nil {
@@ -218,6 +268,24 @@ func _UsersService_CreateFeed_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _UsersService_DeleteFeed_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteFeedRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UsersServiceServer).DeleteFeed(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UsersService_DeleteFeed_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UsersServiceServer).DeleteFeed(ctx, req.(*DeleteFeedRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _UsersService_IdentifyUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(IdentifyUserRequest)
 	if err := dec(in); err != nil {
@@ -247,6 +315,10 @@ var UsersService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetUserByID",
 			Handler:    _UsersService_GetUserByID_Handler,
 		},
+		{
+			MethodName: "GetUserEmail",
+			Handler:    _UsersService_GetUserEmail_Handler,
+		},
 		{
 			MethodName: "GetUserPreferences",
 			Handler:    _UsersService_GetUserPreferences_Handler,
@@ -259,6 +331,10 @@ var UsersService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "CreateFeed",
 			Handler:    _UsersService_CreateFeed_Handler,
 		},
+		{
+			MethodName: "DeleteFeed",
+			Handler:    _UsersService_DeleteFeed_Handler,
+		},
 		{
 			MethodName: "IdentifyUser",
 			Handler:    _UsersService_IdentifyUser_Handler,
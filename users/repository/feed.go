@@ -12,6 +12,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/rodneyosodo/twiga/internal/cursor"
 	"github.com/rodneyosodo/twiga/internal/postgres"
 	"github.com/rodneyosodo/twiga/users"
 )
@@ -26,36 +27,49 @@ func NewFeedRepository(db postgres.Database) *feedRepository {
 	return &feedRepository{db}
 }
 
-func (r *feedRepository) Create(ctx context.Context, feed users.Feed) error {
-	query := `INSERT INTO feeds (user_id, post_id)
-			VALUES (:user_id, :post_id)`
+func (r *feedRepository) Create(ctx context.Context, feeds ...users.Feed) error {
+	if len(feeds) == 0 {
+		return errors.New("feeds are required")
+	}
 
-	result, err := r.NamedExecContext(ctx, query, feed)
-	if err != nil {
+	// Duplicates are ignored so that retried fan-outs are idempotent.
+	query := `INSERT INTO feeds (user_id, post_id, author_id)
+			VALUES (:user_id, :post_id, :author_id)
+			ON CONFLICT (user_id, post_id) DO NOTHING`
+
+	if _, err := r.NamedExecContext(ctx, query, feeds); err != nil {
 		return err
 	}
-	if rows, _ := result.RowsAffected(); rows == 0 {
-		return errors.New("could not create feed")
-	}
 
 	return nil
 }
 
 func (r *feedRepository) RetrieveAll(ctx context.Context, page users.Page) (fpage users.FeedPage, err error) {
+	dPage, err := toDBPage(page)
+	if err != nil {
+		return users.FeedPage{}, err
+	}
+
 	filter := ""
 	filters := []string{}
 	if page.UserID != "" {
-		filters = append(filters, fmt.Sprintf("user_id = '%s'", page.UserID))
+		filters = append(filters, "user_id = :user_id")
+	}
+	if len(page.ExcludedAuthors) > 0 {
+		filters = append(filters, "author_id <> ALL(:excluded_authors)")
 	}
 	if len(filters) > 0 {
 		filter = fmt.Sprintf("WHERE %s", strings.Join(filters, " AND "))
 	}
-
-	query := fmt.Sprintf(`SELECT * FROM feeds %s ORDER BY created_at DESC LIMIT :limit OFFSET :offset`, filter)
-	dPage := users.FollowingsPage{
-		Page: page,
+	// Feed entries are keyed on the post ID so that cursors can be shared
+	// with the posts merged into the feed at read time.
+	cfilter := filter
+	if page.Cursor != "" {
+		cfilter = fmt.Sprintf("WHERE %s", strings.Join(append(filters, postgres.CursorFilter("post_id")), " AND "))
 	}
 
+	query := fmt.Sprintf(`SELECT * FROM feeds %s ORDER BY created_at DESC, post_id DESC LIMIT :limit OFFSET :offset`, cfilter)
+
 	rows, err := r.NamedQueryContext(ctx, query, dPage)
 	if err != nil {
 		return users.FeedPage{}, err
@@ -71,23 +85,40 @@ func (r *feedRepository) RetrieveAll(ctx context.Context, page users.Page) (fpag
 
 		items = append(items, feed)
 	}
+	items, next := cursor.Trim(items, page.Limit, func(f users.Feed) cursor.Cursor {
+		return cursor.Cursor{CreatedAt: f.CreatedAt, ID: f.PostID}
+	})
 
-	totalQuery := `SELECT COUNT(*) FROM feeds`
-	if filter != "" {
-		totalQuery = fmt.Sprintf(`SELECT COUNT(*) FROM feeds %s`, filter)
-	}
+	total := uint64(0)
+	if page.Cursor == "" {
+		totalQuery := fmt.Sprintf(`SELECT COUNT(*) FROM feeds %s`, filter)
 
-	total, err := postgres.Total(ctx, r.Database, totalQuery, dPage)
-	if err != nil {
-		return users.FeedPage{}, err
+		total, err = postgres.Total(ctx, r.Database, totalQuery, dPage)
+		if err != nil {
+			return users.FeedPage{}, err
+		}
 	}
 
 	return users.FeedPage{
 		Page: users.Page{
-			Limit:  page.Limit,
-			Offset: page.Offset,
-			Total:  total,
+			Limit:      page.Limit,
+			Offset:     page.Offset,
+			Total:      total,
+			NextCursor: next,
 		},
 		Feeds: items,
 	}, nil
 }
+
+func (r *feedRepository) Delete(ctx context.Context, feed users.Feed) error {
+	query := `DELETE FROM feeds WHERE post_id = :post_id`
+	if feed.UserID != "" {
+		query = `DELETE FROM feeds WHERE post_id = :post_id AND user_id = :user_id`
+	}
+
+	if _, err := r.NamedExecContext(ctx, query, feed); err != nil {
+		return errors.Join(errors.New("could not delete feed"), err)
+	}
+
+	return nil
+}
@@ -45,6 +45,14 @@ func TestCreateFeed(t *testing.T) {
 			},
 			err: nil,
 		},
+		{
+			desc: "duplicate feed",
+			feed: users.Feed{
+				UserID: savedUser.ID,
+				PostID: invalidID,
+			},
+			err: nil,
+		},
 		{
 			desc: "same ids",
 			feed: users.Feed{
@@ -64,7 +72,7 @@ func TestCreateFeed(t *testing.T) {
 				UserID: invalidID,
 				PostID: "post_id",
 			},
-			err: errors.New("invalid input syntax"),
+			err: errors.New("insert or update on table"),
 		},
 		{
 			desc: "malformed feed",
@@ -221,3 +229,92 @@ func getUserFeeds(feeds []users.Feed, id string) []users.Feed {
 
 	return items
 }
+
+func TestDeleteFeed(t *testing.T) {
+	t.Cleanup(func() {
+		_, err := db.Exec("DELETE FROM feeds")
+		require.NoError(t, err)
+	})
+	repo := repository.NewFeedRepository(db)
+	urepo := repository.NewUsersRepository(db)
+
+	postID := uuid.Must(uuid.NewV4()).String()
+	for range 3 {
+		savedUser, err := urepo.Create(context.Background(), generateUser())
+		if err != nil {
+			t.Fatalf("failed to create user: %v", err)
+		}
+		if err := repo.Create(context.Background(), users.Feed{UserID: savedUser.ID, PostID: postID}); err != nil {
+			t.Fatalf("failed to create feed: %v", err)
+		}
+	}
+
+	cases := []struct {
+		desc  string
+		feed  users.Feed
+		total uint64
+		err   error
+	}{
+		{
+			desc:  "unknown post",
+			feed:  users.Feed{PostID: invalidID},
+			total: 3,
+			err:   nil,
+		},
+		{
+			desc:  "valid post",
+			feed:  users.Feed{PostID: postID},
+			total: 0,
+			err:   nil,
+		},
+		{
+			desc:  "malformed user",
+			feed:  users.Feed{UserID: malformedID, PostID: postID},
+			total: 0,
+			err:   errors.New("invalid input syntax"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := repo.Delete(context.Background(), tc.feed)
+			switch {
+			case err == nil:
+				page, err := repo.RetrieveAll(context.Background(), users.Page{Limit: 10})
+				require.NoError(t, err)
+				assert.Equal(t, tc.total, page.Total)
+			default:
+				assert.ErrorContains(t, err, tc.err.Error())
+			}
+		})
+	}
+}
+
+func TestRetrieveAllFeedExcludedAuthors(t *testing.T) {
+	t.Cleanup(func() {
+		_, err := db.Exec("DELETE FROM feeds")
+		require.NoError(t, err)
+	})
+	repo := repository.NewFeedRepository(db)
+	urepo := repository.NewUsersRepository(db)
+
+	savedUser, err := urepo.Create(context.Background(), generateUser())
+	require.NoError(t, err)
+
+	authors := []string{"author-1", "author-2", "author-3"}
+	for _, author := range authors {
+		feed := users.Feed{UserID: savedUser.ID, PostID: uuid.Must(uuid.NewV4()).String(), AuthorID: author}
+		require.NoError(t, repo.Create(context.Background(), feed))
+	}
+
+	page, err := repo.RetrieveAll(context.Background(), users.Page{
+		Limit:           10,
+		UserID:          savedUser.ID,
+		ExcludedAuthors: []string{"author-1", "author-3' OR '1' = '1"},
+	})
+	require.NoError(t, err)
+	require.Len(t, page.Feeds, 2)
+	for _, feed := range page.Feeds {
+		assert.NotEqual(t, "author-1", feed.AuthorID)
+	}
+}
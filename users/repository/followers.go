@@ -12,6 +12,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/rodneyosodo/twiga/internal/cursor"
 	"github.com/rodneyosodo/twiga/internal/postgres"
 	"github.com/rodneyosodo/twiga/users"
 )
@@ -47,6 +48,11 @@ func (r *fRepository) Create(ctx context.Context, follower users.Following) (use
 }
 
 func (r *fRepository) RetrieveAll(ctx context.Context, page users.Page) (fpage users.FollowingsPage, err error) {
+	dPage, err := toDBPage(page)
+	if err != nil {
+		return users.FollowingsPage{}, err
+	}
+
 	filter := ""
 	filters := []string{}
 	if page.FolloweeID != "" {
@@ -58,12 +64,13 @@ func (r *fRepository) RetrieveAll(ctx context.Context, page users.Page) (fpage u
 	if len(filters) > 0 {
 		filter = fmt.Sprintf("WHERE %s", strings.Join(filters, " AND "))
 	}
-
-	query := fmt.Sprintf(`SELECT * FROM followers %s ORDER BY created_at DESC LIMIT :limit OFFSET :offset`, filter)
-	dPage := users.FollowingsPage{
-		Page: page,
+	cfilter := filter
+	if page.Cursor != "" {
+		cfilter = fmt.Sprintf("WHERE %s", strings.Join(append(filters, postgres.CursorFilter("id")), " AND "))
 	}
 
+	query := fmt.Sprintf(`SELECT * FROM followers %s ORDER BY created_at DESC, id DESC LIMIT :limit OFFSET :offset`, cfilter)
+
 	rows, err := r.NamedQueryContext(ctx, query, dPage)
 	if err != nil {
 		return users.FollowingsPage{}, err
@@ -79,27 +86,59 @@ func (r *fRepository) RetrieveAll(ctx context.Context, page users.Page) (fpage u
 
 		items = append(items, following)
 	}
+	items, next := cursor.Trim(items, page.Limit, func(f users.Following) cursor.Cursor {
+		return cursor.Cursor{CreatedAt: f.CreatedAt, ID: f.ID}
+	})
+
+	total := uint64(0)
+	if page.Cursor == "" {
+		totalQuery := fmt.Sprintf(`SELECT COUNT(*) FROM followers %s`, filter)
+		// The followers of a user are counted as they come and go.
+		if page.FollowerID != "" && page.FolloweeID == "" {
+			totalQuery = `SELECT COALESCE((SELECT followers FROM follower_counts WHERE user_id = :follower_id), 0)`
+		}
 
-	totalQuery := `SELECT COUNT(*) FROM followers`
-	if filter != "" {
-		totalQuery = fmt.Sprintf(`SELECT COUNT(*) FROM followers %s`, filter)
-	}
-
-	total, err := postgres.Total(ctx, r.Database, totalQuery, dPage)
-	if err != nil {
-		return users.FollowingsPage{}, err
+		total, err = postgres.Total(ctx, r.Database, totalQuery, dPage)
+		if err != nil {
+			return users.FollowingsPage{}, err
+		}
 	}
 
 	return users.FollowingsPage{
 		Page: users.Page{
-			Limit:  page.Limit,
-			Offset: page.Offset,
-			Total:  total,
+			Limit:      page.Limit,
+			Offset:     page.Offset,
+			Total:      total,
+			NextCursor: next,
 		},
 		Followings: items,
 	}, nil
 }
 
+func (r *fRepository) RetrievePopular(ctx context.Context, followerID string, threshold uint64) ([]string, error) {
+	query := `SELECT f.followee_id FROM followers f
+			JOIN follower_counts c ON c.user_id = f.followee_id
+			WHERE f.follower_id = $1 AND c.followers > $2`
+
+	rows, err := r.QueryxContext(ctx, query, followerID, threshold)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make([]string, 0)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
 func (r *fRepository) Delete(ctx context.Context, following users.Following) error {
 	q := "DELETE FROM followers WHERE follower_id = $1 AND followee_id = $2"
 
@@ -338,3 +338,40 @@ func follows(followings []users.Following, id string, follow bool) []users.Follo
 
 	return followings[:followers]
 }
+
+func TestRetrievePopularFollowing(t *testing.T) {
+	t.Cleanup(func() {
+		_, err := db.Exec("DELETE FROM followers")
+		require.NoError(t, err)
+	})
+	repo := repository.NewFollowingRepository(db)
+	urepo := repository.NewUsersRepository(db)
+
+	savedUsers := make([]users.User, 4)
+	for i := range savedUsers {
+		user, err := urepo.Create(context.Background(), generateUser())
+		require.NoError(t, err)
+		savedUsers[i] = user
+	}
+	reader, popular, other, follower := savedUsers[0], savedUsers[1], savedUsers[2], savedUsers[3]
+
+	for _, following := range []users.Following{
+		{FollowerID: reader.ID, FolloweeID: popular.ID},
+		{FollowerID: follower.ID, FolloweeID: popular.ID},
+		{FollowerID: other.ID, FolloweeID: popular.ID},
+		{FollowerID: reader.ID, FolloweeID: other.ID},
+	} {
+		_, err := repo.Create(context.Background(), following)
+		require.NoError(t, err)
+	}
+
+	ids, err := repo.RetrievePopular(context.Background(), reader.ID, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{popular.ID}, ids)
+
+	require.NoError(t, repo.Delete(context.Background(), users.Following{FollowerID: follower.ID, FolloweeID: popular.ID}))
+
+	ids, err = repo.RetrievePopular(context.Background(), reader.ID, 2)
+	require.NoError(t, err)
+	assert.Empty(t, ids, "unfollows should lower the follower count")
+}
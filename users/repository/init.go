@@ -74,6 +74,99 @@ func Migration() *migrate.MemoryMigrationSource {
 					`DROP TABLE IF EXISTS users CASCADE`,
 				},
 			},
+			{
+				Id: "users_02",
+				Up: []string{
+					`ALTER TABLE feeds DROP CONSTRAINT IF EXISTS feeds_check`,
+					`ALTER TABLE feeds ALTER COLUMN post_id TYPE VARCHAR(254) USING post_id::TEXT`,
+					`ALTER TABLE feeds ADD CONSTRAINT feeds_check CHECK (user_id::TEXT <> post_id)`,
+				},
+				Down: []string{
+					`ALTER TABLE feeds DROP CONSTRAINT IF EXISTS feeds_check`,
+					`ALTER TABLE feeds ALTER COLUMN post_id TYPE UUID USING post_id::UUID`,
+					`ALTER TABLE feeds ADD CONSTRAINT feeds_check CHECK (user_id <> post_id)`,
+				},
+			},
+			{
+				Id: "users_03",
+				Up: []string{
+					`ALTER TABLE feeds ADD COLUMN IF NOT EXISTS author_id VARCHAR(254) NOT NULL DEFAULT ''`,
+					`CREATE INDEX IF NOT EXISTS idx_feeds_author_id ON feeds(author_id);`,
+				},
+				Down: []string{
+					`DROP INDEX IF EXISTS idx_feeds_author_id`,
+					`ALTER TABLE feeds DROP COLUMN IF EXISTS author_id`,
+				},
+			},
+			{
+				Id: "users_04",
+				Up: []string{
+					`CREATE TABLE IF NOT EXISTS outbox (
+						seq BIGSERIAL PRIMARY KEY,
+						id UUID UNIQUE NOT NULL,
+						topic VARCHAR(254) NOT NULL,
+						payload JSONB NOT NULL,
+						created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+					)`,
+				},
+				Down: []string{
+					`DROP TABLE IF EXISTS outbox`,
+				},
+			},
+			{
+				Id: "users_05",
+				Up: []string{
+					`CREATE TABLE IF NOT EXISTS refresh_tokens (
+						id UUID PRIMARY KEY NOT NULL,
+						family_id UUID NOT NULL,
+						user_id UUID NOT NULL,
+						token_hash VARCHAR(64) UNIQUE NOT NULL,
+						expires_at TIMESTAMP NOT NULL,
+						used_at TIMESTAMP,
+						revoked_at TIMESTAMP,
+						created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+						FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+					)`,
+					`CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family_id ON refresh_tokens(family_id)`,
+					`CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user_id ON refresh_tokens(user_id)`,
+				},
+				Down: []string{
+					`DROP TABLE IF EXISTS refresh_tokens`,
+				},
+			},
+			{
+				Id: "users_06",
+				Up: []string{
+					`CREATE TABLE IF NOT EXISTS follower_counts (
+						user_id UUID PRIMARY KEY NOT NULL,
+						followers BIGINT NOT NULL DEFAULT 0,
+						FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+					)`,
+					`CREATE OR REPLACE FUNCTION count_followers() RETURNS TRIGGER AS $$
+					BEGIN
+						IF TG_OP = 'INSERT' THEN
+							INSERT INTO follower_counts (user_id, followers) VALUES (NEW.followee_id, 1)
+							ON CONFLICT (user_id) DO UPDATE SET followers = follower_counts.followers + 1;
+
+							RETURN NEW;
+						END IF;
+						UPDATE follower_counts SET followers = followers - 1 WHERE user_id = OLD.followee_id;
+
+						RETURN OLD;
+					END;
+					$$ LANGUAGE plpgsql`,
+					`CREATE TRIGGER trg_followers_count AFTER INSERT OR DELETE ON followers
+						FOR EACH ROW EXECUTE FUNCTION count_followers()`,
+					`INSERT INTO follower_counts (user_id, followers)
+						SELECT followee_id, COUNT(*) FROM followers GROUP BY followee_id
+						ON CONFLICT (user_id) DO UPDATE SET followers = EXCLUDED.followers`,
+				},
+				Down: []string{
+					`DROP TRIGGER IF EXISTS trg_followers_count ON followers`,
+					`DROP FUNCTION IF EXISTS count_followers`,
+					`DROP TABLE IF EXISTS follower_counts`,
+				},
+			},
 		},
 	}
 }
@@ -0,0 +1,46 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+package repository
+
+import (
+	"time"
+
+	"github.com/jackc/pgtype"
+	"github.com/rodneyosodo/twiga/internal/cursor"
+	"github.com/rodneyosodo/twiga/users"
+)
+
+type dbPage struct {
+	users.Page
+	CursorCreatedAt time.Time        `db:"cursor_created_at"`
+	CursorID        string           `db:"cursor_id"`
+	Excluded        pgtype.TextArray `db:"excluded_authors"`
+}
+
+// toDBPage binds the page cursor and reads one row past the limit
+// so that the repository can tell whether a next page exists.
+func toDBPage(page users.Page) (dbPage, error) {
+	dPage := dbPage{Page: page}
+	dPage.Limit = page.Limit + 1
+	if err := dPage.Excluded.Set(page.ExcludedAuthors); err != nil {
+		return dbPage{}, err
+	}
+
+	if page.Cursor == "" {
+		return dPage, nil
+	}
+
+	c, err := cursor.Decode(page.Cursor)
+	if err != nil {
+		return dbPage{}, err
+	}
+	dPage.Offset = 0
+	dPage.CursorCreatedAt = c.CreatedAt
+	dPage.CursorID = c.ID
+
+	return dPage, nil
+}
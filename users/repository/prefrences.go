@@ -9,9 +9,11 @@ package repository
 import (
 	"context"
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/jackc/pgtype"
+	"github.com/rodneyosodo/twiga/internal/cursor"
 	"github.com/rodneyosodo/twiga/internal/postgres"
 	"github.com/rodneyosodo/twiga/users"
 )
@@ -76,11 +78,18 @@ func (r *pRepository) RetrieveByUserID(ctx context.Context, userID string) (user
 }
 
 func (r *pRepository) RetrieveAll(ctx context.Context, page users.Page) (ppage users.PreferencesPage, err error) {
-	query := `SELECT * FROM preferences ORDER BY created_at DESC LIMIT :limit OFFSET :offset`
-	dPage := users.PreferencesPage{
-		Page: page,
+	dPage, err := toDBPage(page)
+	if err != nil {
+		return users.PreferencesPage{}, err
 	}
 
+	filter := ""
+	if page.Cursor != "" {
+		filter = fmt.Sprintf("WHERE %s", postgres.CursorFilter("id"))
+	}
+
+	query := fmt.Sprintf(`SELECT * FROM preferences %s ORDER BY created_at DESC, id DESC LIMIT :limit OFFSET :offset`, filter)
+
 	rows, err := r.NamedQueryContext(ctx, query, dPage)
 	if err != nil {
 		return users.PreferencesPage{}, err
@@ -96,19 +105,26 @@ func (r *pRepository) RetrieveAll(ctx context.Context, page users.Page) (ppage u
 
 		items = append(items, fromDBPreference(dPreference))
 	}
+	items, next := cursor.Trim(items, page.Limit, func(p users.Preference) cursor.Cursor {
+		return cursor.Cursor{CreatedAt: p.CreatedAt, ID: p.ID}
+	})
 
-	totalQuery := `SELECT COUNT(*) FROM preferences`
+	total := uint64(0)
+	if page.Cursor == "" {
+		totalQuery := `SELECT COUNT(*) FROM preferences`
 
-	total, err := postgres.Total(ctx, r.Database, totalQuery, dPage)
-	if err != nil {
-		return users.PreferencesPage{}, err
+		total, err = postgres.Total(ctx, r.Database, totalQuery, dPage)
+		if err != nil {
+			return users.PreferencesPage{}, err
+		}
 	}
 
 	return users.PreferencesPage{
 		Page: users.Page{
-			Limit:  page.Limit,
-			Offset: page.Offset,
-			Total:  total,
+			Limit:      page.Limit,
+			Offset:     page.Offset,
+			Total:      total,
+			NextCursor: next,
 		},
 		Preferences: items,
 	}, nil
@@ -0,0 +1,108 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/rodneyosodo/twiga/internal/postgres"
+	"github.com/rodneyosodo/twiga/users"
+)
+
+var _ users.RefreshTokenRepository = (*tRepository)(nil)
+
+type tRepository struct {
+	postgres.Database
+}
+
+func NewRefreshTokenRepository(db postgres.Database) *tRepository {
+	return &tRepository{db}
+}
+
+func (r *tRepository) Create(ctx context.Context, token users.RefreshToken) error {
+	query := `INSERT INTO refresh_tokens (id, family_id, user_id, token_hash, expires_at)
+			VALUES (:id, :family_id, :user_id, :token_hash, :expires_at)`
+
+	if _, err := r.NamedExecContext(ctx, query, token); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *tRepository) RetrieveByHash(ctx context.Context, hash string) (users.RefreshToken, error) {
+	query := `SELECT * FROM refresh_tokens WHERE token_hash = $1`
+
+	rows, err := r.QueryxContext(ctx, query, hash)
+	if err != nil {
+		return users.RefreshToken{}, err
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		var token users.RefreshToken
+		if err := rows.StructScan(&token); err != nil {
+			return users.RefreshToken{}, err
+		}
+
+		return token, nil
+	}
+
+	return users.RefreshToken{}, errors.New("refresh token not found")
+}
+
+func (r *tRepository) Use(ctx context.Context, id string) error {
+	// The conditions make the update the single winner among concurrent
+	// refreshes of the same token.
+	query := `UPDATE refresh_tokens SET used_at = CURRENT_TIMESTAMP
+			WHERE id = $1 AND used_at IS NULL AND revoked_at IS NULL`
+
+	result, err := r.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return errors.New("refresh token already used or revoked")
+	}
+
+	return nil
+}
+
+func (r *tRepository) RevokeFamily(ctx context.Context, familyID string) error {
+	query := `UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP
+			WHERE family_id = $1 AND revoked_at IS NULL`
+
+	if _, err := r.ExecContext(ctx, query, familyID); err != nil {
+		return errors.Join(errors.New("could not revoke refresh tokens"), err)
+	}
+
+	return nil
+}
+
+func (r *tRepository) RevokeUser(ctx context.Context, userID string) ([]string, error) {
+	query := `UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP
+			WHERE user_id = $1 AND used_at IS NULL AND revoked_at IS NULL
+			RETURNING family_id`
+
+	rows, err := r.QueryxContext(ctx, query, userID)
+	if err != nil {
+		return nil, errors.Join(errors.New("could not revoke refresh tokens"), err)
+	}
+	defer rows.Close()
+
+	families := make([]string, 0)
+	for rows.Next() {
+		var familyID string
+		if err := rows.Scan(&familyID); err != nil {
+			return nil, err
+		}
+		families = append(families, familyID)
+	}
+
+	return families, rows.Err()
+}
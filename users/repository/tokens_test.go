@@ -0,0 +1,89 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/rodneyosodo/twiga/users"
+	"github.com/rodneyosodo/twiga/users/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func generateRefreshToken(t *testing.T, userID, familyID string) users.RefreshToken {
+	_, hash, err := users.NewRefreshToken()
+	require.NoError(t, err)
+
+	return users.RefreshToken{
+		ID:        uuid.Must(uuid.NewV4()).String(),
+		FamilyID:  familyID,
+		UserID:    userID,
+		Hash:      hash,
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+}
+
+func TestRefreshTokens(t *testing.T) {
+	t.Cleanup(func() {
+		_, err := db.Exec("DELETE FROM refresh_tokens")
+		require.NoError(t, err)
+	})
+	ctx := context.Background()
+	repo := repository.NewRefreshTokenRepository(db)
+	urepo := repository.NewUsersRepository(db)
+
+	savedUser, err := urepo.Create(ctx, generateUser())
+	require.NoError(t, err)
+
+	family := uuid.Must(uuid.NewV4()).String()
+	first := generateRefreshToken(t, savedUser.ID, family)
+	require.NoError(t, repo.Create(ctx, first))
+	assert.Error(t, repo.Create(ctx, first), "duplicate tokens should be rejected")
+	assert.Error(t, repo.Create(ctx, generateRefreshToken(t, invalidID, family)), "tokens of unknown users should be rejected")
+
+	saved, err := repo.RetrieveByHash(ctx, first.Hash)
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, saved.ID)
+	assert.Equal(t, family, saved.FamilyID)
+	assert.Nil(t, saved.UsedAt)
+	assert.Nil(t, saved.RevokedAt)
+
+	_, err = repo.RetrieveByHash(ctx, "unknown")
+	assert.ErrorContains(t, err, "refresh token not found")
+
+	require.NoError(t, repo.Use(ctx, first.ID))
+	assert.ErrorContains(t, repo.Use(ctx, first.ID), "already used or revoked")
+
+	saved, err = repo.RetrieveByHash(ctx, first.Hash)
+	require.NoError(t, err)
+	assert.NotNil(t, saved.UsedAt)
+
+	second := generateRefreshToken(t, savedUser.ID, family)
+	require.NoError(t, repo.Create(ctx, second))
+	other := generateRefreshToken(t, savedUser.ID, uuid.Must(uuid.NewV4()).String())
+	require.NoError(t, repo.Create(ctx, other))
+
+	require.NoError(t, repo.RevokeFamily(ctx, family))
+	assert.ErrorContains(t, repo.Use(ctx, second.ID), "already used or revoked")
+
+	saved, err = repo.RetrieveByHash(ctx, second.Hash)
+	require.NoError(t, err)
+	assert.NotNil(t, saved.RevokedAt)
+
+	families, err := repo.RevokeUser(ctx, savedUser.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{other.FamilyID}, families)
+
+	families, err = repo.RevokeUser(ctx, savedUser.ID)
+	require.NoError(t, err)
+	assert.Empty(t, families)
+}
@@ -13,6 +13,7 @@ import (
 	"time"
 
 	"github.com/jackc/pgtype"
+	"github.com/rodneyosodo/twiga/internal/cursor"
 	"github.com/rodneyosodo/twiga/internal/postgres"
 	"github.com/rodneyosodo/twiga/users"
 )
@@ -75,7 +76,7 @@ func (r *uRepository) RetrieveByID(ctx context.Context, id string) (users.User,
 		return fromDBUser(dUser), nil
 	}
 
-	return users.User{}, errors.New("user not found")
+	return users.User{}, users.ErrNotFound
 }
 
 func (r *uRepository) RetrieveByEmail(ctx context.Context, email string) (users.User, error) {
@@ -99,15 +100,22 @@ func (r *uRepository) RetrieveByEmail(ctx context.Context, email string) (users.
 		return fromDBUser(dUser), nil
 	}
 
-	return users.User{}, errors.New("user not found")
+	return users.User{}, users.ErrNotFound
 }
 
 func (r *uRepository) RetrieveAll(ctx context.Context, page users.Page) (users.UsersPage, error) {
-	query := fmt.Sprintf(`SELECT %s FROM users ORDER BY created_at DESC LIMIT :limit OFFSET :offset`, defReturnCols)
-	dPage := users.UsersPage{
-		Page: page,
+	dPage, err := toDBPage(page)
+	if err != nil {
+		return users.UsersPage{}, err
 	}
 
+	filter := ""
+	if page.Cursor != "" {
+		filter = fmt.Sprintf("WHERE %s", postgres.CursorFilter("id"))
+	}
+
+	query := fmt.Sprintf(`SELECT %s FROM users %s ORDER BY created_at DESC, id DESC LIMIT :limit OFFSET :offset`, defReturnCols, filter)
+
 	rows, err := r.NamedQueryContext(ctx, query, dPage)
 	if err != nil {
 		return users.UsersPage{}, err
@@ -123,19 +131,26 @@ func (r *uRepository) RetrieveAll(ctx context.Context, page users.Page) (users.U
 
 		items = append(items, fromDBUser(dUser))
 	}
+	items, next := cursor.Trim(items, page.Limit, func(u users.User) cursor.Cursor {
+		return cursor.Cursor{CreatedAt: u.CreatedAt, ID: u.ID}
+	})
 
-	totalQuery := `SELECT COUNT(*) FROM users`
+	total := uint64(0)
+	if page.Cursor == "" {
+		totalQuery := `SELECT COUNT(*) FROM users`
 
-	total, err := postgres.Total(ctx, r.Database, totalQuery, dPage)
-	if err != nil {
-		return users.UsersPage{}, err
+		total, err = postgres.Total(ctx, r.Database, totalQuery, dPage)
+		if err != nil {
+			return users.UsersPage{}, err
+		}
 	}
 
 	return users.UsersPage{
 		Page: users.Page{
-			Limit:  page.Limit,
-			Offset: page.Offset,
-			Total:  total,
+			Limit:      page.Limit,
+			Offset:     page.Offset,
+			Total:      total,
+			NextCursor: next,
 		},
 		Users: items,
 	}, nil
@@ -222,7 +237,7 @@ func (r *uRepository) update(ctx context.Context, query string, user users.User)
 		return fromDBUser(dUser), nil
 	}
 
-	return users.User{}, errors.New("user not found")
+	return users.User{}, users.ErrNotFound
 }
 
 func (r *uRepository) Delete(ctx context.Context, id string) error {
@@ -233,7 +248,7 @@ func (r *uRepository) Delete(ctx context.Context, id string) error {
 		return errors.Join(errors.New("could not delete user"), err)
 	}
 	if rows, _ := result.RowsAffected(); rows == 0 {
-		return errors.New("user not found")
+		return users.ErrNotFound
 	}
 
 	return nil
@@ -127,7 +127,7 @@ func TestRetrieveUserByID(t *testing.T) {
 			desc: "invalid user",
 			id:   invalidID,
 			user: users.User{},
-			err:  errors.New("user not found"),
+			err:  users.ErrNotFound,
 		},
 		{
 			desc: "malformed user",
@@ -185,13 +185,13 @@ func TestRetrieveUserByEmail(t *testing.T) {
 			desc:  "invalid user",
 			email: namegen.Generate() + "@example.com",
 			user:  users.User{},
-			err:   errors.New("user not found"),
+			err:   users.ErrNotFound,
 		},
 		{
 			desc:  "malformed user",
 			email: malformedID,
 			user:  users.User{},
-			err:   errors.New("user not found"),
+			err:   users.ErrNotFound,
 		},
 	}
 
@@ -354,7 +354,7 @@ func TestUpdateUser(t *testing.T) {
 				DisplayName: namegen.Generate(),
 				Bio:         strings.Repeat("a", 100),
 			},
-			err: errors.New("user not found"),
+			err: users.ErrNotFound,
 		},
 		{
 			desc: "malformed user",
@@ -427,7 +427,7 @@ func TestUpdateUsername(t *testing.T) {
 				ID:       invalidID,
 				Username: namegen.Generate(),
 			},
-			err: errors.New("user not found"),
+			err: users.ErrNotFound,
 		},
 		{
 			desc: "malformed user",
@@ -493,7 +493,7 @@ func TestUpdatePassword(t *testing.T) {
 				ID:       invalidID,
 				Password: "newpassword",
 			},
-			err: errors.New("user not found"),
+			err: users.ErrNotFound,
 		},
 		{
 			desc: "malformed user",
@@ -560,7 +560,7 @@ func TestUpdateEmail(t *testing.T) {
 				ID:    invalidID,
 				Email: namegen.Generate() + "@example.com",
 			},
-			err: errors.New("user not found"),
+			err: users.ErrNotFound,
 		},
 		{
 			desc: "malformed user",
@@ -626,7 +626,7 @@ func TestUpdateBio(t *testing.T) {
 				ID:  invalidID,
 				Bio: strings.Repeat("b", 100),
 			},
-			err: errors.New("user not found"),
+			err: users.ErrNotFound,
 		},
 		{
 			desc: "malformed user",
@@ -692,7 +692,7 @@ func TestUpdatePictureURL(t *testing.T) {
 				ID:         invalidID,
 				PictureURL: "https://example.com" + namegen.Generate(),
 			},
-			err: errors.New("user not found"),
+			err: users.ErrNotFound,
 		},
 		{
 			desc: "malformed user",
@@ -758,7 +758,7 @@ func TestUpdatePreferences(t *testing.T) {
 				ID:          invalidID,
 				Preferences: []string{namegen.Generate()},
 			},
-			err: errors.New("user not found"),
+			err: users.ErrNotFound,
 		},
 		{
 			desc: "malformed user",
@@ -818,7 +818,7 @@ func TestDeleteUser(t *testing.T) {
 		{
 			desc: "invalid user",
 			id:   invalidID,
-			err:  errors.New("user not found"),
+			err:  users.ErrNotFound,
 		},
 		{
 			desc: "malformed user",
@@ -850,3 +850,58 @@ func generateUser() users.User {
 		Preferences: namegen.GenerateMultiple(5),
 	}
 }
+
+func TestRetrieveAllUsersCursor(t *testing.T) {
+	t.Cleanup(func() {
+		_, err := db.Exec("DELETE FROM users")
+		require.NoError(t, err)
+	})
+	repo := repository.NewUsersRepository(db)
+
+	num := 5
+	for range num {
+		_, err := repo.Create(context.Background(), generateUser())
+		require.NoError(t, err)
+	}
+
+	page := users.Page{Limit: 2}
+	seen := map[string]bool{}
+	var last users.User
+	for pages := 0; ; pages++ {
+		usersPage, err := repo.RetrieveAll(context.Background(), page)
+		require.NoError(t, err)
+		if page.Cursor == "" {
+			assert.Equal(t, uint64(num), usersPage.Total, "offset pages should be counted")
+		} else {
+			assert.Zero(t, usersPage.Total, "cursor pages should not be counted")
+		}
+
+		for _, user := range usersPage.Users {
+			assert.False(t, seen[user.ID], "users should not repeat across pages")
+			seen[user.ID] = true
+			if last.ID != "" {
+				assert.False(t, user.CreatedAt.After(last.CreatedAt), "users should be ordered from the newest")
+			}
+			last = user
+		}
+		if pages == 0 {
+			// Users created while paging must not shift the following pages.
+			_, err := repo.Create(context.Background(), generateUser())
+			require.NoError(t, err)
+		}
+
+		if usersPage.NextCursor == "" {
+			break
+		}
+		page.Cursor = usersPage.NextCursor
+	}
+	assert.Len(t, seen, num)
+
+	usersPage, err := repo.RetrieveAll(context.Background(), users.Page{Limit: 2, Cursor: "invalid"})
+	assert.Error(t, err)
+	assert.Empty(t, usersPage.Users)
+
+	usersPage, err = repo.RetrieveAll(context.Background(), users.Page{Limit: 2})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(num+1), usersPage.Total)
+}
@@ -10,63 +10,148 @@ package users
 import (
 	"context"
 	"errors"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/0x6flab/namegenerator"
 	"github.com/gofrs/uuid"
 	"github.com/rodneyosodo/twiga/internal/cache"
+	"github.com/rodneyosodo/twiga/internal/cursor"
 )
 
+// MaxFeedOffset bounds the offset of feeds merged at read time, which are read
+// from the start up to the requested page. Deeper pages are read with the cursor.
+const MaxFeedOffset = 1000
+
 var (
 	namegen   = namegenerator.NewGenerator()
 	defAvatar = "https://ui-avatars.com/api/?name="
+
+	ErrFeedOffsetTooLarge = errors.New("feed offset is too large, page with the cursor instead")
 )
 
+// CacheNamespace holds the cached users. Its version follows the shape of User.
+var CacheNamespace = cache.Namespace{Name: "users", Version: 1}
+
 type service struct {
 	usersRepo       UsersRepository
 	preferencesRepo PreferencesRepository
 	followingRepo   FollowingRepository
 	feedRepo        FeedRepository
+	tokensRepo      RefreshTokenRepository
+	posts           PostsClient
+	fanOutThreshold uint64
 	tokenizer       Tokenizer
-	cacher          cache.Cacher
+	refreshExpiry   time.Duration
+	cacher          cache.Loader[User]
 }
 
-func NewService(usersRepo UsersRepository, preferencesRepo PreferencesRepository, followingRepo FollowingRepository, feedRepo FeedRepository, tokenizer Tokenizer, cacher cache.Cacher) Service {
+// NewService returns a users service. Posts by users with more than fanOutThreshold
+// followers are merged into feeds at read time; a zero threshold disables merging.
+// Refresh tokens expire after refreshExpiry.
+func NewService(usersRepo UsersRepository, preferencesRepo PreferencesRepository, followingRepo FollowingRepository, feedRepo FeedRepository, tokensRepo RefreshTokenRepository, posts PostsClient, fanOutThreshold uint64, tokenizer Tokenizer, refreshExpiry time.Duration, cacher cache.Loader[User]) Service {
 	return &service{
 		usersRepo:       usersRepo,
 		preferencesRepo: preferencesRepo,
 		followingRepo:   followingRepo,
 		feedRepo:        feedRepo,
+		tokensRepo:      tokensRepo,
+		posts:           posts,
+		fanOutThreshold: fanOutThreshold,
 		tokenizer:       tokenizer,
+		refreshExpiry:   refreshExpiry,
 		cacher:          cacher,
 	}
 }
 
-func (s *service) IssueToken(ctx context.Context, user User) (string, error) {
+func (s *service) IssueToken(ctx context.Context, user User) (Token, error) {
 	saved, err := s.usersRepo.RetrieveByEmail(ctx, user.Email)
 	if err != nil {
-		return "", err
+		return Token{}, err
 	}
 	if err := ComparePassword(user.Password, saved.Password); err != nil {
-		return "", err
+		return Token{}, err
+	}
+
+	// Every login starts a new family of refresh tokens.
+	familyID, err := uuid.NewV4()
+	if err != nil {
+		return Token{}, err
 	}
 
-	return s.tokenizer.Issue(saved.ID)
+	return s.issueTokens(ctx, saved.ID, familyID.String())
 }
 
-func (s *service) RefreshToken(ctx context.Context, token string) (string, error) {
-	userID, err := s.tokenizer.Validate(token)
+func (s *service) RefreshToken(ctx context.Context, refreshToken string) (Token, error) {
+	if refreshToken == "" {
+		return Token{}, errors.New("refresh token is required")
+	}
+
+	saved, err := s.tokensRepo.RetrieveByHash(ctx, HashRefreshToken(refreshToken))
 	if err != nil {
-		return "", err
+		return Token{}, err
 	}
-	if _, err := s.usersRepo.RetrieveByID(ctx, userID); err != nil {
-		return "", err
+	if saved.RevokedAt != nil {
+		return Token{}, errors.New("refresh token has been revoked")
+	}
+	if time.Now().After(saved.ExpiresAt) {
+		return Token{}, errors.New("refresh token has expired")
+	}
+
+	// A refresh token is only ever used once, so using it again means that it
+	// leaked. Neither party can be told apart, hence the whole family is revoked.
+	if saved.UsedAt != nil {
+		return Token{}, s.revokeFamily(ctx, saved.FamilyID, errors.New("refresh token has already been used"))
+	}
+	if err := s.tokensRepo.Use(ctx, saved.ID); err != nil {
+		return Token{}, s.revokeFamily(ctx, saved.FamilyID, err)
+	}
+
+	if _, err := s.usersRepo.RetrieveByID(ctx, saved.UserID); err != nil {
+		return Token{}, err
+	}
+
+	return s.issueTokens(ctx, saved.UserID, saved.FamilyID)
+}
+
+func (s *service) RevokeToken(ctx context.Context, token string) error {
+	familyID, err := s.tokenizer.Revoke(ctx, token)
+	if err != nil {
+		return err
+	}
+	// Tokens issued before sessions were introduced have none to revoke.
+	if familyID == "" {
+		return nil
+	}
+	if err := s.tokensRepo.RevokeFamily(ctx, familyID); err != nil {
+		return err
 	}
 
-	return s.tokenizer.Issue(userID)
+	return s.tokenizer.RevokeSession(ctx, familyID)
 }
 
-func (s *service) CreateUser(ctx context.Context, user User) (User, error) {
+func (s *service) RevokeAllTokens(ctx context.Context, token string) error {
+	userID, err := s.tokenizer.Validate(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	families, err := s.tokensRepo.RevokeUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for _, familyID := range families {
+		if err := s.tokenizer.RevokeSession(ctx, familyID); err != nil {
+			return err
+		}
+	}
+
+	// The session of the token may have no usable refresh token left.
+	return s.RevokeToken(ctx, token)
+}
+
+func (s *service) CreateUser(ctx context.Context, user User) (saved User, err error) {
 	id, err := uuid.NewV4()
 	if err != nil {
 		return User{}, err
@@ -94,29 +179,19 @@ func (s *service) CreateUser(ctx context.Context, user User) (User, error) {
 
 	defer func() {
 		if err == nil {
-			user.Password = ""
-			if err = s.cacher.Add(ctx, user.ID, user); err != nil {
-				err = errors.New("failed to cache user")
-			}
+			err = s.cacheUser(ctx, saved)
 		}
 	}()
 
 	return s.usersRepo.Create(ctx, user)
 }
 
-func (s *service) GetUserByID(ctx context.Context, token string, id string) (saved User, err error) {
-	if cached := s.cacher.Get(ctx, id); cached != nil {
-		saved, ok := cached.(User)
-		if ok {
-			return saved, nil
-		}
-	}
-
-	return s.usersRepo.RetrieveByID(ctx, id)
+func (s *service) GetUserByID(ctx context.Context, token string, id string) (User, error) {
+	return s.retrieveUser(ctx, id)
 }
 
 func (s *service) GetUsers(ctx context.Context, token string, page Page) (UsersPage, error) {
-	if _, err := s.tokenizer.Validate(token); err != nil {
+	if _, err := s.tokenizer.Validate(ctx, token); err != nil {
 		return UsersPage{}, err
 	}
 
@@ -130,9 +205,7 @@ func (s *service) UpdateUser(ctx context.Context, token string, user User) (upda
 
 	defer func() {
 		if err == nil {
-			if err = s.cacher.Add(ctx, updated.ID, updated); err != nil {
-				err = errors.New("failed to cache user")
-			}
+			err = s.cacheUser(ctx, updated)
 		}
 	}()
 
@@ -145,9 +218,7 @@ func (s *service) UpdateUserUsername(ctx context.Context, token string, user Use
 	}
 	defer func() {
 		if err == nil {
-			if err = s.cacher.Add(ctx, updated.ID, updated); err != nil {
-				err = errors.New("failed to cache user")
-			}
+			err = s.cacheUser(ctx, updated)
 		}
 	}()
 
@@ -155,7 +226,7 @@ func (s *service) UpdateUserUsername(ctx context.Context, token string, user Use
 }
 
 func (s *service) UpdateUserPassword(ctx context.Context, token string, oldPassword, currentPassowrd string) error {
-	userID, err := s.tokenizer.Validate(token)
+	userID, err := s.tokenizer.Validate(ctx, token)
 	if err != nil {
 		return err
 	}
@@ -192,9 +263,7 @@ func (s *service) UpdateUserEmail(ctx context.Context, token string, user User)
 
 	defer func() {
 		if err == nil {
-			if err = s.cacher.Add(ctx, updated.ID, updated); err != nil {
-				err = errors.New("failed to cache user")
-			}
+			err = s.cacheUser(ctx, updated)
 		}
 	}()
 
@@ -208,9 +277,7 @@ func (s *service) UpdateUserBio(ctx context.Context, token string, user User) (u
 
 	defer func() {
 		if err == nil {
-			if err = s.cacher.Add(ctx, updated.ID, updated); err != nil {
-				err = errors.New("failed to cache user")
-			}
+			err = s.cacheUser(ctx, updated)
 		}
 	}()
 
@@ -224,9 +291,7 @@ func (s *service) UpdateUserPictureURL(ctx context.Context, token string, user U
 
 	defer func() {
 		if err == nil {
-			if err = s.cacher.Add(ctx, updated.ID, updated); err != nil {
-				err = errors.New("failed to cache user")
-			}
+			err = s.cacheUser(ctx, updated)
 		}
 	}()
 
@@ -240,9 +305,7 @@ func (s *service) UpdateUserPreferences(ctx context.Context, token string, user
 
 	defer func() {
 		if err == nil {
-			if err = s.cacher.Add(ctx, updated.ID, updated); err != nil {
-				err = errors.New("failed to cache user")
-			}
+			err = s.cacheUser(ctx, updated)
 		}
 	}()
 
@@ -250,19 +313,22 @@ func (s *service) UpdateUserPreferences(ctx context.Context, token string, user
 }
 
 func (s *service) DeleteUser(ctx context.Context, token string, id string) error {
-	userID, err := s.tokenizer.Validate(token)
+	userID, err := s.tokenizer.Validate(ctx, token)
 	if err != nil {
 		return err
 	}
 	if userID != id {
 		return errors.New("unauthorized")
 	}
+	if err := s.usersRepo.Delete(ctx, id); err != nil {
+		return err
+	}
 
-	return s.usersRepo.Delete(ctx, id)
+	return s.cacher.Remove(ctx, id)
 }
 
 func (s *service) CreatePreferences(ctx context.Context, token string, preference Preference) (Preference, error) {
-	userID, err := s.tokenizer.Validate(token)
+	userID, err := s.tokenizer.Validate(ctx, token)
 	if err != nil {
 		return Preference{}, err
 	}
@@ -273,7 +339,7 @@ func (s *service) CreatePreferences(ctx context.Context, token string, preferenc
 
 func (s *service) GetPreferencesByUserID(ctx context.Context, token, id string) (Preference, error) {
 	if token != "" {
-		userID, err := s.tokenizer.Validate(token)
+		userID, err := s.tokenizer.Validate(ctx, token)
 		if err != nil {
 			return Preference{}, err
 		}
@@ -285,7 +351,7 @@ func (s *service) GetPreferencesByUserID(ctx context.Context, token, id string)
 }
 
 func (s *service) GetPreferences(ctx context.Context, token string, page Page) (PreferencesPage, error) {
-	userID, err := s.tokenizer.Validate(token)
+	userID, err := s.tokenizer.Validate(ctx, token)
 	if err != nil {
 		return PreferencesPage{}, err
 	}
@@ -295,7 +361,7 @@ func (s *service) GetPreferences(ctx context.Context, token string, page Page) (
 }
 
 func (s *service) UpdatePreferences(ctx context.Context, token string, preference Preference) (Preference, error) {
-	userID, err := s.tokenizer.Validate(token)
+	userID, err := s.tokenizer.Validate(ctx, token)
 	if err != nil {
 		return Preference{}, err
 	}
@@ -305,7 +371,7 @@ func (s *service) UpdatePreferences(ctx context.Context, token string, preferenc
 }
 
 func (s *service) UpdateEmailPreferences(ctx context.Context, token string, preference Preference) (Preference, error) {
-	userID, err := s.tokenizer.Validate(token)
+	userID, err := s.tokenizer.Validate(ctx, token)
 	if err != nil {
 		return Preference{}, err
 	}
@@ -315,7 +381,7 @@ func (s *service) UpdateEmailPreferences(ctx context.Context, token string, pref
 }
 
 func (s *service) UpdatePushPreferences(ctx context.Context, token string, preference Preference) (Preference, error) {
-	userID, err := s.tokenizer.Validate(token)
+	userID, err := s.tokenizer.Validate(ctx, token)
 	if err != nil {
 		return Preference{}, err
 	}
@@ -325,7 +391,7 @@ func (s *service) UpdatePushPreferences(ctx context.Context, token string, prefe
 }
 
 func (s *service) DeletePreferences(ctx context.Context, token string) error {
-	userID, err := s.tokenizer.Validate(token)
+	userID, err := s.tokenizer.Validate(ctx, token)
 	if err != nil {
 		return err
 	}
@@ -334,7 +400,7 @@ func (s *service) DeletePreferences(ctx context.Context, token string) error {
 }
 
 func (s *service) CreateFollower(ctx context.Context, token string, following Following) (Following, error) {
-	userID, err := s.tokenizer.Validate(token)
+	userID, err := s.tokenizer.Validate(ctx, token)
 	if err != nil {
 		return Following{}, err
 	}
@@ -345,7 +411,7 @@ func (s *service) CreateFollower(ctx context.Context, token string, following Fo
 
 func (s *service) GetUserFollowings(ctx context.Context, token string, page Page) (FollowingsPage, error) {
 	if token != "" {
-		if _, err := s.tokenizer.Validate(token); err != nil {
+		if _, err := s.tokenizer.Validate(ctx, token); err != nil {
 			return FollowingsPage{}, err
 		}
 	}
@@ -354,7 +420,7 @@ func (s *service) GetUserFollowings(ctx context.Context, token string, page Page
 }
 
 func (s *service) DeleteFollower(ctx context.Context, token string, following Following) error {
-	userID, err := s.tokenizer.Validate(token)
+	userID, err := s.tokenizer.Validate(ctx, token)
 	if err != nil {
 		return err
 	}
@@ -363,22 +429,90 @@ func (s *service) DeleteFollower(ctx context.Context, token string, following Fo
 	return s.followingRepo.Delete(ctx, following)
 }
 
-func (s *service) CreateFeed(ctx context.Context, feed Feed) error {
-	return s.feedRepo.Create(ctx, feed)
+func (s *service) CreateFeed(ctx context.Context, feeds ...Feed) error {
+	if len(feeds) == 0 {
+		return nil
+	}
+
+	return s.feedRepo.Create(ctx, feeds...)
 }
 
 func (s *service) GetUserFeed(ctx context.Context, token string, page Page) (FeedPage, error) {
-	userID, err := s.tokenizer.Validate(token)
+	userID, err := s.tokenizer.Validate(ctx, token)
 	if err != nil {
 		return FeedPage{}, err
 	}
 	page.UserID = userID
 
-	return s.feedRepo.RetrieveAll(ctx, page)
+	if s.fanOutThreshold == 0 || s.posts == nil {
+		return s.feedRepo.RetrieveAll(ctx, page)
+	}
+
+	popular, err := s.followingRepo.RetrievePopular(ctx, userID, s.fanOutThreshold)
+	if err != nil {
+		return FeedPage{}, err
+	}
+	if len(popular) == 0 {
+		return s.feedRepo.RetrieveAll(ctx, page)
+	}
+
+	// Both sources are read from the start up to the end of the requested page
+	// so that the merged result can be sliced at the requested offset. Cursor
+	// pages start right after the cursor in both sources since they share its key.
+	if page.Cursor == "" && page.Offset > MaxFeedOffset {
+		return FeedPage{}, ErrFeedOffsetTooLarge
+	}
+	offset := page.Offset
+	window := Page{
+		Limit:           page.Offset + page.Limit,
+		Cursor:          page.Cursor,
+		UserID:          userID,
+		ExcludedAuthors: popular,
+	}
+	if page.Cursor != "" {
+		offset = 0
+		window.Limit = page.Limit
+	}
+	fpage, err := s.feedRepo.RetrieveAll(ctx, window)
+	if err != nil {
+		return FeedPage{}, err
+	}
+	ppage, err := s.posts.RetrievePosts(ctx, token, popular, Page{Limit: window.Limit, Cursor: page.Cursor})
+	if err != nil {
+		return FeedPage{}, err
+	}
+	for i := range ppage.Feeds {
+		ppage.Feeds[i].UserID = userID
+	}
+
+	feeds, more := mergeFeeds(fpage.Feeds, ppage.Feeds, offset, page.Limit)
+	next := ""
+	if len(feeds) > 0 && (more || fpage.NextCursor != "" || ppage.NextCursor != "") {
+		last := feeds[len(feeds)-1]
+		next = cursor.Encode(last.CreatedAt, last.PostID)
+	}
+
+	return FeedPage{
+		Page: Page{
+			Offset:     page.Offset,
+			Limit:      page.Limit,
+			Total:      fpage.Total + ppage.Total,
+			NextCursor: next,
+		},
+		Feeds: feeds,
+	}, nil
+}
+
+func (s *service) DeleteFeed(ctx context.Context, feed Feed) error {
+	if feed.PostID == "" {
+		return errors.New("post id is required")
+	}
+
+	return s.feedRepo.Delete(ctx, feed)
 }
 
 func (s *service) IdentifyUser(ctx context.Context, token string) (string, error) {
-	userID, err := s.tokenizer.Validate(token)
+	userID, err := s.tokenizer.Validate(ctx, token)
 	if err != nil {
 		return "", err
 	}
@@ -387,21 +521,12 @@ func (s *service) IdentifyUser(ctx context.Context, token string) (string, error
 }
 
 func (s *service) authorize(ctx context.Context, token, id string) error {
-	userID, err := s.tokenizer.Validate(token)
+	userID, err := s.tokenizer.Validate(ctx, token)
 	if err != nil {
 		return err
 	}
 
-	if cached := s.cacher.Get(ctx, id); cached != nil {
-		saved, ok := cached.(User)
-		if ok {
-			if saved.ID == userID {
-				return nil
-			}
-		}
-	}
-
-	saved, err := s.usersRepo.RetrieveByID(ctx, userID)
+	saved, err := s.retrieveUser(ctx, userID)
 	if err != nil {
 		return err
 	}
@@ -412,3 +537,88 @@ func (s *service) authorize(ctx context.Context, token, id string) error {
 
 	return nil
 }
+
+// issueTokens issues an access token along with a refresh token that joins
+// the family.
+func (s *service) issueTokens(ctx context.Context, userID, familyID string) (Token, error) {
+	accessToken, err := s.tokenizer.Issue(userID, familyID)
+	if err != nil {
+		return Token{}, err
+	}
+
+	id, err := uuid.NewV4()
+	if err != nil {
+		return Token{}, err
+	}
+	refreshToken, hash, err := NewRefreshToken()
+	if err != nil {
+		return Token{}, err
+	}
+	if err := s.tokensRepo.Create(ctx, RefreshToken{
+		ID:        id.String(),
+		FamilyID:  familyID,
+		UserID:    userID,
+		Hash:      hash,
+		ExpiresAt: time.Now().UTC().Add(s.refreshExpiry),
+	}); err != nil {
+		return Token{}, err
+	}
+
+	return Token{AccessToken: accessToken, RefreshToken: refreshToken}, nil
+}
+
+// revokeFamily revokes the refresh tokens and access tokens of the family
+// and returns the error that caused it.
+func (s *service) revokeFamily(ctx context.Context, familyID string, cause error) error {
+	if err := s.tokensRepo.RevokeFamily(ctx, familyID); err != nil {
+		return errors.Join(cause, err)
+	}
+	if err := s.tokenizer.RevokeSession(ctx, familyID); err != nil {
+		return errors.Join(cause, err)
+	}
+
+	return cause
+}
+
+// retrieveUser returns the user from the cache, loading it from the repository
+// on a miss. Like cached users, it has no password hash.
+func (s *service) retrieveUser(ctx context.Context, id string) (User, error) {
+	return s.cacher.Load(ctx, id, func(ctx context.Context) (User, error) {
+		user, err := s.usersRepo.RetrieveByID(ctx, id)
+		user.Password = ""
+
+		return user, err
+	})
+}
+
+// cacheUser caches the user without its password hash.
+func (s *service) cacheUser(ctx context.Context, user User) error {
+	user.Password = ""
+	if err := s.cacher.Add(ctx, user.ID, user, 0); err != nil {
+		return errors.New("failed to cache user")
+	}
+
+	return nil
+}
+
+// mergeFeeds merges feed entries from the newest to the oldest and returns the
+// entries within the given offset and limit, and whether more entries follow.
+func mergeFeeds(fanned, merged []Feed, offset, limit uint64) ([]Feed, bool) {
+	feeds := make([]Feed, 0, len(fanned)+len(merged))
+	feeds = append(feeds, fanned...)
+	feeds = append(feeds, merged...)
+	slices.SortStableFunc(feeds, func(a, b Feed) int {
+		if c := b.CreatedAt.Compare(a.CreatedAt); c != 0 {
+			return c
+		}
+
+		return strings.Compare(b.PostID, a.PostID)
+	})
+
+	if offset >= uint64(len(feeds)) {
+		return []Feed{}, false
+	}
+	end := min(offset+limit, uint64(len(feeds)))
+
+	return feeds[offset:end], end < uint64(len(feeds))
+}
@@ -0,0 +1,318 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package users_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	jwtx "github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/rodneyosodo/twiga/internal/cache"
+	"github.com/rodneyosodo/twiga/users"
+	"github.com/rodneyosodo/twiga/users/jwt"
+	"github.com/rodneyosodo/twiga/users/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newTokenizer() users.Tokenizer {
+	return jwt.NewTokenizer("secret", time.Hour, cache.NewMemory[bool](100, time.Hour))
+}
+
+func TestGetUserFeed(t *testing.T) {
+	tokenizer := newTokenizer()
+	userID := "reader"
+	token, err := tokenizer.Issue(userID, "session")
+	require.NoError(t, err)
+
+	now := time.Now()
+	fanned := []users.Feed{
+		{UserID: userID, PostID: "fanned-1", AuthorID: "author", CreatedAt: now.Add(-1 * time.Minute)},
+		{UserID: userID, PostID: "fanned-2", AuthorID: "author", CreatedAt: now.Add(-3 * time.Minute)},
+		{UserID: userID, PostID: "fanned-3", AuthorID: "author", CreatedAt: now.Add(-5 * time.Minute)},
+	}
+	merged := []users.Feed{
+		{PostID: "merged-1", AuthorID: "popular", CreatedAt: now.Add(-2 * time.Minute)},
+		{PostID: "merged-2", AuthorID: "popular", CreatedAt: now.Add(-4 * time.Minute)},
+	}
+
+	cases := []struct {
+		desc      string
+		threshold uint64
+		popular   []string
+		page      users.Page
+		postIDs   []string
+		total     uint64
+		err       error
+	}{
+		{
+			desc:      "fan out on write only",
+			threshold: 0,
+			page:      users.Page{Offset: 0, Limit: 10},
+			postIDs:   []string{"fanned-1", "fanned-2", "fanned-3"},
+			total:     3,
+		},
+		{
+			desc:      "no popular followees",
+			threshold: 100,
+			popular:   []string{},
+			page:      users.Page{Offset: 0, Limit: 10},
+			postIDs:   []string{"fanned-1", "fanned-2", "fanned-3"},
+			total:     3,
+		},
+		{
+			desc:      "merged first page",
+			threshold: 100,
+			popular:   []string{"popular"},
+			page:      users.Page{Offset: 0, Limit: 3},
+			postIDs:   []string{"fanned-1", "merged-1", "fanned-2"},
+			total:     5,
+		},
+		{
+			desc:      "merged second page",
+			threshold: 100,
+			popular:   []string{"popular"},
+			page:      users.Page{Offset: 3, Limit: 3},
+			postIDs:   []string{"merged-2", "fanned-3"},
+			total:     5,
+		},
+		{
+			desc:      "merged big offset",
+			threshold: 100,
+			popular:   []string{"popular"},
+			page:      users.Page{Offset: 10, Limit: 3},
+			postIDs:   []string{},
+			total:     5,
+		},
+		{
+			desc:      "merged offset beyond the maximum",
+			threshold: 100,
+			popular:   []string{"popular"},
+			page:      users.Page{Offset: users.MaxFeedOffset + 1, Limit: 3},
+			err:       users.ErrFeedOffsetTooLarge,
+		},
+		{
+			desc:      "fan out on write only beyond the maximum offset",
+			threshold: 0,
+			page:      users.Page{Offset: users.MaxFeedOffset + 1, Limit: 3},
+			postIDs:   []string{"fanned-1", "fanned-2", "fanned-3"},
+			total:     3,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			followingRepo := mocks.NewFollowingRepository(t)
+			feedRepo := mocks.NewFeedRepository(t)
+			postsClient := mocks.NewPostsClient(t)
+			svc := users.NewService(nil, nil, followingRepo, feedRepo, nil, postsClient, tc.threshold, tokenizer, time.Hour, nil)
+
+			if tc.threshold > 0 {
+				followingRepo.On("RetrievePopular", mock.Anything, userID, tc.threshold).Return(tc.popular, nil)
+			}
+			if tc.err != nil {
+				_, err := svc.GetUserFeed(context.Background(), token, tc.page)
+				assert.ErrorIs(t, err, tc.err)
+
+				return
+			}
+			feedRepo.On("RetrieveAll", mock.Anything, mock.Anything).Return(users.FeedPage{
+				Page:  users.Page{Total: uint64(len(fanned))},
+				Feeds: fanned,
+			}, nil)
+			if len(tc.popular) > 0 {
+				postsClient.On("RetrievePosts", mock.Anything, token, tc.popular, mock.Anything).Return(users.FeedPage{
+					Page:  users.Page{Total: uint64(len(merged))},
+					Feeds: append([]users.Feed{}, merged...),
+				}, nil)
+			}
+
+			page, err := svc.GetUserFeed(context.Background(), token, tc.page)
+			require.NoError(t, err)
+			assert.Equal(t, tc.total, page.Total)
+
+			postIDs := make([]string, 0, len(page.Feeds))
+			for _, feed := range page.Feeds {
+				assert.Equal(t, userID, feed.UserID)
+				postIDs = append(postIDs, feed.PostID)
+			}
+			assert.Equal(t, tc.postIDs, postIDs)
+		})
+	}
+}
+
+func TestRefreshToken(t *testing.T) {
+	ctx := context.Background()
+	userID := "user"
+	familyID := "family"
+	refreshToken, hash, err := users.NewRefreshToken()
+	require.NoError(t, err)
+	now := time.Now()
+
+	cases := []struct {
+		desc    string
+		saved   users.RefreshToken
+		useErr  error
+		revoked bool
+		err     string
+	}{
+		{
+			desc:  "rotate unused token",
+			saved: users.RefreshToken{ID: "1", FamilyID: familyID, UserID: userID, Hash: hash, ExpiresAt: now.Add(time.Hour)},
+		},
+		{
+			desc:    "reuse used token",
+			saved:   users.RefreshToken{ID: "1", FamilyID: familyID, UserID: userID, Hash: hash, ExpiresAt: now.Add(time.Hour), UsedAt: &now},
+			revoked: true,
+			err:     "refresh token has already been used",
+		},
+		{
+			desc:    "lose concurrent rotation",
+			saved:   users.RefreshToken{ID: "1", FamilyID: familyID, UserID: userID, Hash: hash, ExpiresAt: now.Add(time.Hour)},
+			useErr:  errors.New("refresh token already used or revoked"),
+			revoked: true,
+			err:     "refresh token already used or revoked",
+		},
+		{
+			desc:  "use revoked token",
+			saved: users.RefreshToken{ID: "1", FamilyID: familyID, UserID: userID, Hash: hash, ExpiresAt: now.Add(time.Hour), RevokedAt: &now},
+			err:   "refresh token has been revoked",
+		},
+		{
+			desc:  "use expired token",
+			saved: users.RefreshToken{ID: "1", FamilyID: familyID, UserID: userID, Hash: hash, ExpiresAt: now.Add(-time.Hour)},
+			err:   "refresh token has expired",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			tokenizer := newTokenizer()
+			// An access token issued along with the refresh token earlier.
+			issued, err := tokenizer.Issue(userID, familyID)
+			require.NoError(t, err)
+
+			usersRepo := mocks.NewUsersRepository(t)
+			tokensRepo := mocks.NewRefreshTokenRepository(t)
+			svc := users.NewService(usersRepo, nil, nil, nil, tokensRepo, nil, 0, tokenizer, time.Hour, nil)
+
+			tokensRepo.On("RetrieveByHash", mock.Anything, hash).Return(tc.saved, nil)
+			if tc.saved.UsedAt == nil && tc.saved.RevokedAt == nil && tc.saved.ExpiresAt.After(now) {
+				tokensRepo.On("Use", mock.Anything, tc.saved.ID).Return(tc.useErr)
+			}
+			if tc.revoked {
+				tokensRepo.On("RevokeFamily", mock.Anything, familyID).Return(nil)
+			}
+			if tc.err == "" {
+				usersRepo.On("RetrieveByID", mock.Anything, userID).Return(users.User{ID: userID}, nil)
+				tokensRepo.On("Create", mock.Anything, mock.MatchedBy(func(token users.RefreshToken) bool {
+					return token.FamilyID == familyID && token.UserID == userID && token.Hash != hash &&
+						token.ExpiresAt.Location() == time.UTC
+				})).Return(nil)
+			}
+
+			token, err := svc.RefreshToken(ctx, refreshToken)
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+			} else {
+				require.NoError(t, err)
+				assert.NotEqual(t, refreshToken, token.RefreshToken)
+				validated, err := tokenizer.Validate(ctx, token.AccessToken)
+				require.NoError(t, err)
+				assert.Equal(t, userID, validated)
+			}
+
+			_, err = tokenizer.Validate(ctx, issued)
+			if tc.revoked {
+				assert.Error(t, err, "access tokens of a reused family should be revoked")
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+// sessionless returns an access token issued before sessions were introduced.
+func sessionless(t *testing.T, userID string) string {
+	tkn, err := jwtx.NewBuilder().
+		Issuer("twiga").
+		Subject(userID).
+		JwtID("pre-upgrade").
+		IssuedAt(time.Now()).
+		Expiration(time.Now().Add(time.Hour)).
+		Build()
+	require.NoError(t, err)
+	signed, err := jwtx.Sign(tkn, jwtx.WithKey(jwa.HS512, []byte("secret")))
+	require.NoError(t, err)
+
+	return string(signed)
+}
+
+func TestRevokeToken(t *testing.T) {
+	ctx := context.Background()
+	userID := "user"
+
+	t.Run("token of a session", func(t *testing.T) {
+		tokenizer := newTokenizer()
+		token, err := tokenizer.Issue(userID, "session")
+		require.NoError(t, err)
+		other, err := tokenizer.Issue(userID, "session")
+		require.NoError(t, err)
+
+		tokensRepo := mocks.NewRefreshTokenRepository(t)
+		tokensRepo.On("RevokeFamily", mock.Anything, "session").Return(nil).Once()
+		svc := users.NewService(nil, nil, nil, nil, tokensRepo, nil, 0, tokenizer, time.Hour, nil)
+
+		require.NoError(t, svc.RevokeToken(ctx, token))
+		_, err = tokenizer.Validate(ctx, other)
+		assert.Error(t, err, "the other access tokens of the session should be revoked")
+	})
+
+	t.Run("token without session", func(t *testing.T) {
+		tokenizer := newTokenizer()
+		token := sessionless(t, userID)
+		_, err := tokenizer.Validate(ctx, token)
+		require.NoError(t, err)
+
+		tokensRepo := mocks.NewRefreshTokenRepository(t)
+		svc := users.NewService(nil, nil, nil, nil, tokensRepo, nil, 0, tokenizer, time.Hour, nil)
+
+		require.NoError(t, svc.RevokeToken(ctx, token))
+		_, err = tokenizer.Validate(ctx, token)
+		assert.Error(t, err)
+		tokensRepo.AssertNotCalled(t, "RevokeFamily", mock.Anything, mock.Anything)
+	})
+}
+
+func TestRevokeAllTokens(t *testing.T) {
+	ctx := context.Background()
+	userID := "user"
+	tokenizer := newTokenizer()
+
+	current, err := tokenizer.Issue(userID, "current")
+	require.NoError(t, err)
+	other, err := tokenizer.Issue(userID, "other")
+	require.NoError(t, err)
+
+	tokensRepo := mocks.NewRefreshTokenRepository(t)
+	svc := users.NewService(nil, nil, nil, nil, tokensRepo, nil, 0, tokenizer, time.Hour, nil)
+
+	tokensRepo.On("RevokeUser", mock.Anything, userID).Return([]string{"current", "other"}, nil)
+	tokensRepo.On("RevokeFamily", mock.Anything, "current").Return(nil)
+
+	require.NoError(t, svc.RevokeAllTokens(ctx, current))
+
+	for _, token := range []string{current, other} {
+		_, err := tokenizer.Validate(ctx, token)
+		assert.Error(t, err)
+	}
+}
@@ -10,16 +10,23 @@ package users
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"time"
 )
 
+// ErrNotFound is returned when the user does not exist.
+var ErrNotFound = errors.New("user not found")
+
 type Page struct {
-	Total      uint64 `db:"total"       json:"total"`
-	Offset     uint64 `db:"offset"      json:"offset"`
-	Limit      uint64 `db:"limit"       json:"limit"`
-	FollowerID string `db:"follower_id" json:"follower_id,omitempty"`
-	FolloweeID string `db:"followee_id" json:"followee_id,omitempty"`
-	UserID     string `db:"user_id"     json:"user_id,omitempty"`
+	Total           uint64   `db:"total"       json:"total"`
+	Offset          uint64   `db:"offset"      json:"offset"`
+	Limit           uint64   `db:"limit"       json:"limit"`
+	Cursor          string   `db:"-"           json:"cursor,omitempty"`
+	NextCursor      string   `db:"-"           json:"next_cursor,omitempty"`
+	FollowerID      string   `db:"follower_id" json:"follower_id,omitempty"`
+	FolloweeID      string   `db:"followee_id" json:"followee_id,omitempty"`
+	UserID          string   `db:"user_id"     json:"user_id,omitempty"`
+	ExcludedAuthors []string `db:"-"           json:"-"`
 }
 
 type User struct {
@@ -96,6 +103,7 @@ type Feed struct {
 	ID        string    `db:"id"         json:"id"`
 	UserID    string    `db:"user_id"    json:"user_id"`
 	PostID    string    `db:"post_id"    json:"post_id"`
+	AuthorID  string    `db:"author_id"  json:"author_id,omitempty"`
 	CreatedAt time.Time `db:"created_at" json:"created_at"`
 }
 
@@ -180,19 +188,34 @@ type PreferencesRepository interface {
 type FollowingRepository interface {
 	Create(ctx context.Context, following Following) (Following, error)
 	RetrieveAll(ctx context.Context, page Page) (FollowingsPage, error)
+	// RetrievePopular returns the IDs of the users followed by followerID
+	// that have more than threshold followers.
+	RetrievePopular(ctx context.Context, followerID string, threshold uint64) ([]string, error)
 	Delete(ctx context.Context, following Following) error
 }
 
 //go:generate mockery --name FeedRepository --output=./mocks --filename feed.go --quiet
 type FeedRepository interface {
-	Create(ctx context.Context, feed Feed) error
+	Create(ctx context.Context, feeds ...Feed) error
 	RetrieveAll(ctx context.Context, page Page) (FeedPage, error)
+	Delete(ctx context.Context, feed Feed) error
+}
+
+//go:generate mockery --name PostsClient --output=./mocks --filename posts.go --quiet
+type PostsClient interface {
+	// RetrievePosts returns the public posts written by any of the given authors
+	// as feed entries ordered from the newest to the oldest.
+	RetrievePosts(ctx context.Context, token string, authorIDs []string, page Page) (FeedPage, error)
 }
 
 //go:generate mockery --name Service --output=./mocks --filename service.go --quiet
 type Service interface { //nolint:interfacebloat
-	IssueToken(ctx context.Context, user User) (string, error)
-	RefreshToken(ctx context.Context, token string) (string, error)
+	IssueToken(ctx context.Context, user User) (Token, error)
+	RefreshToken(ctx context.Context, refreshToken string) (Token, error)
+	// RevokeToken logs the access token out of its session.
+	RevokeToken(ctx context.Context, token string) error
+	// RevokeAllTokens logs the owner of the access token out of every session.
+	RevokeAllTokens(ctx context.Context, token string) error
 	IdentifyUser(ctx context.Context, token string) (string, error)
 
 	CreateUser(ctx context.Context, user User) (User, error)
@@ -219,6 +242,7 @@ type Service interface { //nolint:interfacebloat
 	GetUserFollowings(ctx context.Context, token string, page Page) (FollowingsPage, error)
 	DeleteFollower(ctx context.Context, token string, following Following) error
 
-	CreateFeed(ctx context.Context, feed Feed) error
+	CreateFeed(ctx context.Context, feeds ...Feed) error
 	GetUserFeed(ctx context.Context, token string, page Page) (FeedPage, error)
+	DeleteFeed(ctx context.Context, feed Feed) error
 }
@@ -0,0 +1,245 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/chenjiandongx/ginprom"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	iapi "github.com/rodneyosodo/twiga/internal/api"
+	"github.com/rodneyosodo/twiga/webhooks"
+)
+
+func Endpoints(router *gin.Engine, svc webhooks.Service) {
+	router.POST("/webhooks", createWebhook(svc))
+	router.GET("/webhooks", getWebhooks(svc))
+	router.GET("/webhooks/:id", getWebhook(svc))
+	router.DELETE("/webhooks/:id", deleteWebhook(svc))
+	router.GET("/webhooks/:id/deliveries", getDeliveries(svc))
+	router.POST("/webhooks/:id/deliveries/:delivery_id/redeliver", redeliver(svc))
+
+	router.GET("/version", iapi.GinVersion("webhooks"))
+	router.GET("/metrics", ginprom.PromHandler(promhttp.Handler()))
+}
+
+func createWebhook(svc webhooks.Service) func(ctx *gin.Context) {
+	return func(ctx *gin.Context) {
+		token := iapi.GinExtractToken(ctx)
+		if token == "" {
+			ctx.JSON(http.StatusUnauthorized, gin.H{
+				"error": "Unauthorized",
+			})
+
+			return
+		}
+
+		var webhook webhooks.Webhook
+		if err := ctx.BindJSON(&webhook); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+
+			return
+		}
+
+		webhook, err := svc.CreateWebhook(ctx, token, webhook)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+
+			return
+		}
+
+		ctx.JSON(http.StatusCreated, webhook)
+	}
+}
+
+func getWebhook(svc webhooks.Service) func(ctx *gin.Context) {
+	return func(ctx *gin.Context) {
+		token := iapi.GinExtractToken(ctx)
+		if token == "" {
+			ctx.JSON(http.StatusUnauthorized, gin.H{
+				"error": "Unauthorized",
+			})
+
+			return
+		}
+		id := ctx.Param("id")
+		if id == "" {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error": "ID is required",
+			})
+
+			return
+		}
+
+		webhook, err := svc.RetrieveWebhook(ctx, token, id)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+
+			return
+		}
+
+		ctx.JSON(http.StatusOK, webhook)
+	}
+}
+
+func getWebhooks(svc webhooks.Service) func(ctx *gin.Context) {
+	return func(ctx *gin.Context) {
+		token := iapi.GinExtractToken(ctx)
+		if token == "" {
+			ctx.JSON(http.StatusUnauthorized, gin.H{
+				"error": "Unauthorized",
+			})
+
+			return
+		}
+
+		page, err := readPage(ctx)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+
+			return
+		}
+
+		webhooks, err := svc.RetrieveAllWebhooks(ctx, token, page)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+
+			return
+		}
+
+		ctx.JSON(http.StatusOK, webhooks)
+	}
+}
+
+func deleteWebhook(svc webhooks.Service) func(ctx *gin.Context) {
+	return func(ctx *gin.Context) {
+		token := iapi.GinExtractToken(ctx)
+		if token == "" {
+			ctx.JSON(http.StatusUnauthorized, gin.H{
+				"error": "Unauthorized",
+			})
+
+			return
+		}
+		id := ctx.Param("id")
+		if id == "" {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error": "ID is required",
+			})
+
+			return
+		}
+
+		if err := svc.DeleteWebhook(ctx, token, id); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+
+			return
+		}
+
+		ctx.JSON(http.StatusNoContent, gin.H{
+			"message": "Webhook removed",
+		})
+	}
+}
+
+func getDeliveries(svc webhooks.Service) func(ctx *gin.Context) {
+	return func(ctx *gin.Context) {
+		token := iapi.GinExtractToken(ctx)
+		if token == "" {
+			ctx.JSON(http.StatusUnauthorized, gin.H{
+				"error": "Unauthorized",
+			})
+
+			return
+		}
+
+		page, err := readPage(ctx)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+
+			return
+		}
+		page.WebhookID = ctx.Param("id")
+		page.Status = ctx.DefaultQuery("status", "")
+
+		deliveries, err := svc.RetrieveAllDeliveries(ctx, token, page)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+
+			return
+		}
+
+		ctx.JSON(http.StatusOK, deliveries)
+	}
+}
+
+func redeliver(svc webhooks.Service) func(ctx *gin.Context) {
+	return func(ctx *gin.Context) {
+		token := iapi.GinExtractToken(ctx)
+		if token == "" {
+			ctx.JSON(http.StatusUnauthorized, gin.H{
+				"error": "Unauthorized",
+			})
+
+			return
+		}
+		id := ctx.Param("id")
+		deliveryID := ctx.Param("delivery_id")
+		if id == "" || deliveryID == "" {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error": "ID is required",
+			})
+
+			return
+		}
+
+		delivery, err := svc.Redeliver(ctx, token, id, deliveryID)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+
+			return
+		}
+
+		ctx.JSON(http.StatusAccepted, delivery)
+	}
+}
+
+func readPage(ctx *gin.Context) (webhooks.Page, error) {
+	strOffset := ctx.DefaultQuery("offset", "0")
+	offset, err := strconv.ParseUint(strOffset, 10, 64)
+	if err != nil {
+		return webhooks.Page{}, err
+	}
+	strLimit := ctx.DefaultQuery("limit", "10")
+	limit, err := strconv.ParseUint(strLimit, 10, 64)
+	if err != nil {
+		return webhooks.Page{}, err
+	}
+
+	return webhooks.Page{Offset: offset, Limit: limit}, nil
+}
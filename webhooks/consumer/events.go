@@ -0,0 +1,120 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package consumer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/rodneyosodo/twiga/internal/events"
+	"github.com/rodneyosodo/twiga/webhooks"
+)
+
+type eventHandler struct {
+	svc webhooks.Service
+}
+
+// NewEventHandler returns an event handler that queues a delivery of every event
+// to the webhooks subscribed to its topic. Events are only delivered to the owners
+// of the entities they are about, except for events of public posts.
+func NewEventHandler(svc webhooks.Service) events.EventHandler {
+	return &eventHandler{svc: svc}
+}
+
+func (eh *eventHandler) Handle(ctx context.Context, msg map[string]interface{}) error {
+	env, err := events.Decode(msg)
+	if err != nil {
+		return err
+	}
+	audience, err := audienceOf(env)
+	if err != nil {
+		return err
+	}
+	if !audience.Public && len(audience.Owners) == 0 {
+		return nil
+	}
+
+	// Receivers get the envelope without the attributes added by the broker.
+	event, err := env.Encode()
+	if err != nil {
+		return err
+	}
+
+	// The deliveries of an event are created at once, so a failed dispatch is safe to retry.
+	if err := eh.svc.Dispatch(ctx, env.Type, audience, event); err != nil {
+		return fmt.Errorf("failed to dispatch %s event to webhooks: %w", env.Type, err)
+	}
+
+	return nil
+}
+
+func (eh *eventHandler) Cancel() error {
+	return nil
+}
+
+// audienceOf returns the users whose webhooks may receive the event, which are
+// the owners of the entities it is about. Events of other entities reach nobody.
+func audienceOf(env events.Envelope) (webhooks.Audience, error) {
+	entity, _, _ := strings.Cut(env.Type, ".")
+	switch entity {
+	case "posts":
+		data, err := events.DecodeData[events.PostEvent](env)
+		if err != nil {
+			return webhooks.Audience{}, err
+		}
+
+		// Events that do not carry the visibility, such as deletions, only
+		// reach the author since the post may be private.
+		public := events.HasVisibility(env.Type) && data.Visibility
+
+		return webhooks.Audience{Public: public, Owners: owners(data.UserID)}, nil
+	case "comments":
+		data, err := events.DecodeData[events.CommentEvent](env)
+		if err != nil {
+			return webhooks.Audience{}, err
+		}
+
+		return webhooks.Audience{Owners: owners(data.UserID)}, nil
+	case "likes":
+		data, err := events.DecodeData[events.LikeEvent](env)
+		if err != nil {
+			return webhooks.Audience{}, err
+		}
+
+		return webhooks.Audience{Owners: owners(data.UserID)}, nil
+	case "shares":
+		data, err := events.DecodeData[events.ShareEvent](env)
+		if err != nil {
+			return webhooks.Audience{}, err
+		}
+
+		return webhooks.Audience{Owners: owners(data.UserID)}, nil
+	case "followers":
+		data, err := events.DecodeData[events.FollowerEvent](env)
+		if err != nil {
+			return webhooks.Audience{}, err
+		}
+
+		return webhooks.Audience{Owners: owners(data.FollowerID, data.FolloweeID)}, nil
+	default:
+		return webhooks.Audience{}, nil
+	}
+}
+
+// owners drops the unknown owners of events published before they were recorded.
+func owners(ids ...string) []string {
+	known := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if id != "" {
+			known = append(known, id)
+		}
+	}
+
+	return known
+}
@@ -0,0 +1,143 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package consumer_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rodneyosodo/twiga/internal/events"
+	"github.com/rodneyosodo/twiga/webhooks"
+	"github.com/rodneyosodo/twiga/webhooks/consumer"
+	"github.com/rodneyosodo/twiga/webhooks/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func encode(t *testing.T, eventType string, data events.Data) map[string]interface{} {
+	env, err := events.NewEnvelope(context.Background(), "/twiga/posts", eventType, data)
+	require.NoError(t, err)
+	msg, err := env.Encode()
+	require.NoError(t, err)
+
+	return msg
+}
+
+func TestHandleAudience(t *testing.T) {
+	cases := []struct {
+		desc      string
+		eventType string
+		data      events.Data
+		audience  webhooks.Audience
+		delivered bool
+	}{
+		{
+			desc:      "public post",
+			eventType: events.PostCreated,
+			data:      events.PostEvent{ID: "post", UserID: "author", Visibility: true},
+			audience:  webhooks.Audience{Public: true, Owners: []string{"author"}},
+			delivered: true,
+		},
+		{
+			desc:      "private post",
+			eventType: events.PostCreated,
+			data:      events.PostEvent{ID: "post", UserID: "author"},
+			audience:  webhooks.Audience{Owners: []string{"author"}},
+			delivered: true,
+		},
+		{
+			desc:      "deleted post",
+			eventType: events.PostDeleted,
+			data:      events.PostEvent{ID: "post", UserID: "author"},
+			audience:  webhooks.Audience{Owners: []string{"author"}},
+			delivered: true,
+		},
+		{
+			desc:      "public post updated",
+			eventType: events.PostUpdated,
+			data:      events.PostEvent{ID: "post", UserID: "author", Visibility: true},
+			audience:  webhooks.Audience{Public: true, Owners: []string{"author"}},
+			delivered: true,
+		},
+		{
+			desc:      "post made private",
+			eventType: events.PostVisibilityUpdated,
+			data:      events.PostEvent{ID: "post", UserID: "author"},
+			audience:  webhooks.Audience{Owners: []string{"author"}},
+			delivered: true,
+		},
+		{
+			desc:      "post content updated",
+			eventType: events.PostContentUpdated,
+			data:      events.PostEvent{ID: "post", UserID: "author", Content: "content"},
+			audience:  webhooks.Audience{Owners: []string{"author"}},
+			delivered: true,
+		},
+		{
+			desc:      "deleted post with visibility",
+			eventType: events.PostDeleted,
+			data:      events.PostEvent{ID: "post", UserID: "author", Visibility: true},
+			audience:  webhooks.Audience{Owners: []string{"author"}},
+			delivered: true,
+		},
+		{
+			desc:      "comment",
+			eventType: events.CommentCreated,
+			data:      events.CommentEvent{ID: "comment", PostID: "post", UserID: "commenter"},
+			audience:  webhooks.Audience{Owners: []string{"commenter"}},
+			delivered: true,
+		},
+		{
+			desc:      "like",
+			eventType: events.LikeDeleted,
+			data:      events.LikeEvent{PostID: "post", UserID: "liker"},
+			audience:  webhooks.Audience{Owners: []string{"liker"}},
+			delivered: true,
+		},
+		{
+			desc:      "share",
+			eventType: events.ShareCreated,
+			data:      events.ShareEvent{ID: "share", PostID: "post", UserID: "sharer"},
+			audience:  webhooks.Audience{Owners: []string{"sharer"}},
+			delivered: true,
+		},
+		{
+			desc:      "follower",
+			eventType: events.FollowerCreated,
+			data:      events.FollowerEvent{ID: "follow", FollowerID: "follower", FolloweeID: "followee"},
+			audience:  webhooks.Audience{Owners: []string{"follower", "followee"}},
+			delivered: true,
+		},
+		{
+			desc:      "comment without owner",
+			eventType: events.CommentDeleted,
+			data:      events.CommentEvent{ID: "comment"},
+		},
+		{
+			desc:      "preferences",
+			eventType: events.PreferencesUpdated,
+			data:      events.PreferencesEvent{ID: "preferences", UserID: "user"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			svc := mocks.NewService(t)
+			if tc.delivered {
+				svc.On("Dispatch", mock.Anything, tc.eventType, tc.audience, mock.Anything).Return(nil).Once()
+			}
+
+			err := consumer.NewEventHandler(svc).Handle(context.Background(), encode(t, tc.eventType, tc.data))
+			assert.NoError(t, err)
+			if !tc.delivered {
+				svc.AssertNotCalled(t, "Dispatch", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
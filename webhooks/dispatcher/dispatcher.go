@@ -0,0 +1,51 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package dispatcher
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/rodneyosodo/twiga/webhooks"
+)
+
+type Dispatcher struct {
+	svc      webhooks.Service
+	interval time.Duration
+	logger   *slog.Logger
+}
+
+// New returns a dispatcher that attempts the due deliveries every interval.
+// The interval bounds how late a delivery can be attempted after it is due.
+func New(svc webhooks.Service, interval time.Duration, logger *slog.Logger) *Dispatcher {
+	return &Dispatcher{
+		svc:      svc,
+		interval: interval,
+		logger:   logger,
+	}
+}
+
+// Start attempts the due deliveries until the context is canceled.
+func (d *Dispatcher) Start(ctx context.Context) error {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			// Failed deliveries are rescheduled by the service and retried on a later tick.
+			if err := d.svc.ProcessDeliveries(ctx); err != nil {
+				d.logger.Warn(fmt.Sprintf("Failed to deliver webhooks: %s", err))
+			}
+		}
+	}
+}
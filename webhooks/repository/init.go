@@ -0,0 +1,86 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+package repository
+
+import (
+	_ "github.com/jackc/pgx/v5/stdlib" // required for SQL access
+	migrate "github.com/rubenv/sql-migrate"
+)
+
+func Migration() *migrate.MemoryMigrationSource {
+	return &migrate.MemoryMigrationSource{
+		Migrations: []*migrate.Migration{
+			{
+				Id: "webhooks_01",
+				Up: []string{
+					`CREATE TABLE IF NOT EXISTS webhooks (
+						id UUID PRIMARY KEY NOT NULL DEFAULT gen_random_uuid(),
+						owner_id UUID NOT NULL,
+						url TEXT NOT NULL CHECK (url <> ''),
+						topics TEXT[] NOT NULL,
+						secret TEXT NOT NULL CHECK (secret <> ''),
+						created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+						updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+					)`,
+					`CREATE INDEX idx_webhooks_owner_id ON webhooks(owner_id);`,
+					`CREATE TABLE IF NOT EXISTS deliveries (
+						id UUID PRIMARY KEY NOT NULL,
+						webhook_id UUID NOT NULL,
+						topic VARCHAR(256) NOT NULL CHECK (topic <> ''),
+						payload JSONB NOT NULL,
+						status VARCHAR(32) NOT NULL,
+						attempts BIGINT NOT NULL DEFAULT 0,
+						status_code INTEGER NOT NULL DEFAULT 0,
+						error TEXT NOT NULL DEFAULT '',
+						next_attempt_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+						created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+						updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+						FOREIGN KEY (webhook_id) REFERENCES webhooks(id) ON DELETE CASCADE
+					)`,
+					`CREATE INDEX idx_deliveries_webhook_id ON deliveries(webhook_id);`,
+					`CREATE INDEX idx_deliveries_status_next_attempt_at ON deliveries(status, next_attempt_at);`,
+				},
+				Down: []string{
+					`DROP TABLE IF EXISTS deliveries`,
+					`DROP TABLE IF EXISTS webhooks`,
+				},
+			},
+			{
+				Id: "webhooks_02",
+				Up: []string{
+					`CREATE TABLE IF NOT EXISTS inbox (
+						id VARCHAR(254) PRIMARY KEY,
+						created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+					)`,
+				},
+				Down: []string{
+					`DROP TABLE IF EXISTS inbox`,
+				},
+			},
+			{
+				Id: "webhooks_03",
+				Up: []string{
+					`CREATE INDEX idx_inbox_created_at ON inbox(created_at);`,
+				},
+				Down: []string{
+					`DROP INDEX IF EXISTS idx_inbox_created_at`,
+				},
+			},
+			{
+				Id: "webhooks_04",
+				Up: []string{
+					`ALTER TABLE deliveries ADD COLUMN event_id VARCHAR(254) NOT NULL DEFAULT ''`,
+					`CREATE UNIQUE INDEX idx_deliveries_webhook_id_event_id ON deliveries(webhook_id, event_id) WHERE event_id <> '';`,
+				},
+				Down: []string{
+					`DROP INDEX IF EXISTS idx_deliveries_webhook_id_event_id`,
+					`ALTER TABLE deliveries DROP COLUMN event_id`,
+				},
+			},
+		},
+	}
+}
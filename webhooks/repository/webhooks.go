@@ -0,0 +1,401 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgtype"
+	"github.com/rodneyosodo/twiga/internal/postgres"
+	"github.com/rodneyosodo/twiga/webhooks"
+)
+
+var _ webhooks.Repository = (*repository)(nil)
+
+type repository struct {
+	postgres.Database
+}
+
+func NewRepository(db postgres.Database) *repository {
+	return &repository{db}
+}
+
+func (r *repository) CreateWebhook(ctx context.Context, webhook webhooks.Webhook) (webhooks.Webhook, error) {
+	query := `INSERT INTO webhooks (owner_id, url, topics, secret)
+			VALUES (:owner_id, :url, :topics, :secret)
+			RETURNING *`
+	dWebhook, err := toDBWebhook(webhook)
+	if err != nil {
+		return webhooks.Webhook{}, err
+	}
+
+	rows, err := r.NamedQueryContext(ctx, query, dWebhook)
+	if err != nil {
+		return webhooks.Webhook{}, err
+	}
+	defer rows.Close()
+
+	dWebhook = dbWebhook{}
+	if rows.Next() {
+		if err := rows.StructScan(&dWebhook); err != nil {
+			return webhooks.Webhook{}, err
+		}
+	}
+
+	return dWebhook.toWebhook(), nil
+}
+
+func (r *repository) RetrieveWebhook(ctx context.Context, id string) (webhooks.Webhook, error) {
+	query := `SELECT * FROM webhooks WHERE id = :id`
+
+	rows, err := r.NamedQueryContext(ctx, query, dbWebhook{ID: id})
+	if err != nil {
+		return webhooks.Webhook{}, err
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		var dWebhook dbWebhook
+		if err := rows.StructScan(&dWebhook); err != nil {
+			return webhooks.Webhook{}, err
+		}
+
+		return dWebhook.toWebhook(), nil
+	}
+
+	return webhooks.Webhook{}, errors.New("webhook not found")
+}
+
+func (r *repository) RetrieveAllWebhooks(ctx context.Context, page webhooks.Page) (webhooks.WebhooksPage, error) {
+	filter := ""
+	if page.OwnerID != "" {
+		filter = fmt.Sprintf("WHERE owner_id = '%s'", page.OwnerID)
+	}
+
+	query := fmt.Sprintf(`SELECT * FROM webhooks %s ORDER BY created_at DESC, id DESC LIMIT :limit OFFSET :offset`, filter)
+
+	rows, err := r.NamedQueryContext(ctx, query, page)
+	if err != nil {
+		return webhooks.WebhooksPage{}, err
+	}
+	defer rows.Close()
+
+	items := make([]webhooks.Webhook, 0)
+	for rows.Next() {
+		var dWebhook dbWebhook
+		if err := rows.StructScan(&dWebhook); err != nil {
+			return webhooks.WebhooksPage{}, err
+		}
+
+		items = append(items, dWebhook.toWebhook())
+	}
+
+	totalQuery := fmt.Sprintf(`SELECT COUNT(*) FROM webhooks %s`, filter)
+
+	total, err := postgres.Total(ctx, r.Database, totalQuery, page)
+	if err != nil {
+		return webhooks.WebhooksPage{}, err
+	}
+
+	return webhooks.WebhooksPage{
+		Page: webhooks.Page{
+			Limit:  page.Limit,
+			Offset: page.Offset,
+			Total:  total,
+		},
+		Webhooks: items,
+	}, nil
+}
+
+func (r *repository) RetrieveWebhooksByTopic(ctx context.Context, topic string) ([]webhooks.Webhook, error) {
+	// Wildcard topics are stored as is and turned into LIKE patterns, so "posts.*" matches "posts.created".
+	query := `SELECT * FROM webhooks
+			WHERE EXISTS (SELECT 1 FROM unnest(topics) AS t WHERE :topic LIKE replace(t, '*', '%'))`
+
+	rows, err := r.NamedQueryContext(ctx, query, dbDelivery{Topic: topic})
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]webhooks.Webhook, 0)
+	for rows.Next() {
+		var dWebhook dbWebhook
+		if err := rows.StructScan(&dWebhook); err != nil {
+			return nil, err
+		}
+
+		items = append(items, dWebhook.toWebhook())
+	}
+
+	return items, nil
+}
+
+func (r *repository) DeleteWebhook(ctx context.Context, id string) error {
+	query := `DELETE FROM webhooks WHERE id = :id`
+
+	result, err := r.NamedExecContext(ctx, query, dbWebhook{ID: id})
+	if err != nil {
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return errors.New("could not delete webhook")
+	}
+
+	return nil
+}
+
+func (r *repository) CreateDeliveries(ctx context.Context, deliveries ...webhooks.Delivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	query := `INSERT INTO deliveries (id, webhook_id, event_id, topic, payload, status, next_attempt_at)
+			VALUES (:id, :webhook_id, :event_id, :topic, :payload, :status, :next_attempt_at)
+			ON CONFLICT (webhook_id, event_id) WHERE event_id <> '' DO NOTHING`
+
+	dDeliveries := make([]dbDelivery, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		dDeliveries = append(dDeliveries, toDBDelivery(delivery))
+	}
+
+	if _, err := r.NamedExecContext(ctx, query, dDeliveries); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *repository) RetrieveDelivery(ctx context.Context, id string) (webhooks.Delivery, error) {
+	query := `SELECT * FROM deliveries WHERE id = :id`
+
+	rows, err := r.NamedQueryContext(ctx, query, dbDelivery{ID: id})
+	if err != nil {
+		return webhooks.Delivery{}, err
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		var dDelivery dbDelivery
+		if err := rows.StructScan(&dDelivery); err != nil {
+			return webhooks.Delivery{}, err
+		}
+
+		return dDelivery.toDelivery(), nil
+	}
+
+	return webhooks.Delivery{}, errors.New("delivery not found")
+}
+
+func (r *repository) RetrieveAllDeliveries(ctx context.Context, page webhooks.Page) (webhooks.DeliveriesPage, error) {
+	filter := ""
+	filters := []string{}
+	if page.WebhookID != "" {
+		filters = append(filters, fmt.Sprintf("webhook_id = '%s'", page.WebhookID))
+	}
+	if page.Status != "" {
+		filters = append(filters, fmt.Sprintf("status = '%s'", page.Status))
+	}
+	if len(filters) > 0 {
+		filter = fmt.Sprintf("WHERE %s", strings.Join(filters, " AND "))
+	}
+
+	query := fmt.Sprintf(`SELECT * FROM deliveries %s ORDER BY created_at DESC, id DESC LIMIT :limit OFFSET :offset`, filter)
+
+	rows, err := r.NamedQueryContext(ctx, query, page)
+	if err != nil {
+		return webhooks.DeliveriesPage{}, err
+	}
+	defer rows.Close()
+
+	items := make([]webhooks.Delivery, 0)
+	for rows.Next() {
+		var dDelivery dbDelivery
+		if err := rows.StructScan(&dDelivery); err != nil {
+			return webhooks.DeliveriesPage{}, err
+		}
+
+		items = append(items, dDelivery.toDelivery())
+	}
+
+	totalQuery := fmt.Sprintf(`SELECT COUNT(*) FROM deliveries %s`, filter)
+
+	total, err := postgres.Total(ctx, r.Database, totalQuery, page)
+	if err != nil {
+		return webhooks.DeliveriesPage{}, err
+	}
+
+	return webhooks.DeliveriesPage{
+		Page: webhooks.Page{
+			Limit:  page.Limit,
+			Offset: page.Offset,
+			Total:  total,
+		},
+		Deliveries: items,
+	}, nil
+}
+
+func (r *repository) ClaimDeliveries(ctx context.Context, limit uint64, lease time.Duration) ([]webhooks.Delivery, error) {
+	// Locked rows are skipped so that concurrent dispatchers claim disjoint deliveries.
+	query := fmt.Sprintf(`UPDATE deliveries SET next_attempt_at = :until
+			WHERE id IN (
+				SELECT id FROM deliveries
+				WHERE status = '%s' AND next_attempt_at <= :now
+				ORDER BY next_attempt_at LIMIT :limit
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING *`, webhooks.PendingStatus)
+	now := time.Now().UTC()
+	dClaim := dbClaim{
+		Now:   now,
+		Until: now.Add(lease),
+		Limit: limit,
+	}
+
+	rows, err := r.NamedQueryContext(ctx, query, dClaim)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]webhooks.Delivery, 0)
+	for rows.Next() {
+		var dDelivery dbDelivery
+		if err := rows.StructScan(&dDelivery); err != nil {
+			return nil, err
+		}
+
+		items = append(items, dDelivery.toDelivery())
+	}
+
+	return items, nil
+}
+
+func (r *repository) UpdateDelivery(ctx context.Context, delivery webhooks.Delivery) error {
+	query := `UPDATE deliveries SET
+				status = :status,
+				attempts = :attempts,
+				status_code = :status_code,
+				error = :error,
+				next_attempt_at = :next_attempt_at,
+				updated_at = CURRENT_TIMESTAMP
+			WHERE id = :id`
+
+	result, err := r.NamedExecContext(ctx, query, toDBDelivery(delivery))
+	if err != nil {
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return errors.New("delivery not found")
+	}
+
+	return nil
+}
+
+type dbWebhook struct {
+	ID        string           `db:"id"`
+	OwnerID   string           `db:"owner_id"`
+	URL       string           `db:"url"`
+	Topics    pgtype.TextArray `db:"topics"`
+	Secret    string           `db:"secret"`
+	CreatedAt time.Time        `db:"created_at"`
+	UpdatedAt time.Time        `db:"updated_at"`
+}
+
+func (w dbWebhook) toWebhook() webhooks.Webhook {
+	topics := make([]string, 0, len(w.Topics.Elements))
+	for _, topic := range w.Topics.Elements {
+		topics = append(topics, topic.String)
+	}
+
+	return webhooks.Webhook{
+		ID:        w.ID,
+		OwnerID:   w.OwnerID,
+		URL:       w.URL,
+		Topics:    topics,
+		Secret:    w.Secret,
+		CreatedAt: w.CreatedAt,
+		UpdatedAt: w.UpdatedAt,
+	}
+}
+
+func toDBWebhook(w webhooks.Webhook) (dbWebhook, error) {
+	var topics pgtype.TextArray
+	if err := topics.Set(w.Topics); err != nil {
+		return dbWebhook{}, err
+	}
+
+	return dbWebhook{
+		ID:        w.ID,
+		OwnerID:   w.OwnerID,
+		URL:       w.URL,
+		Topics:    topics,
+		Secret:    w.Secret,
+		CreatedAt: w.CreatedAt,
+		UpdatedAt: w.UpdatedAt,
+	}, nil
+}
+
+type dbDelivery struct {
+	ID            string    `db:"id"`
+	WebhookID     string    `db:"webhook_id"`
+	EventID       string    `db:"event_id"`
+	Topic         string    `db:"topic"`
+	Payload       []byte    `db:"payload"`
+	Status        string    `db:"status"`
+	Attempts      uint64    `db:"attempts"`
+	StatusCode    int       `db:"status_code"`
+	Error         string    `db:"error"`
+	NextAttemptAt time.Time `db:"next_attempt_at"`
+	CreatedAt     time.Time `db:"created_at"`
+	UpdatedAt     time.Time `db:"updated_at"`
+}
+
+type dbClaim struct {
+	Now   time.Time `db:"now"`
+	Until time.Time `db:"until"`
+	Limit uint64    `db:"limit"`
+}
+
+func (d dbDelivery) toDelivery() webhooks.Delivery {
+	return webhooks.Delivery{
+		ID:            d.ID,
+		WebhookID:     d.WebhookID,
+		EventID:       d.EventID,
+		Topic:         d.Topic,
+		Payload:       json.RawMessage(d.Payload),
+		Status:        d.Status,
+		Attempts:      d.Attempts,
+		StatusCode:    d.StatusCode,
+		Error:         d.Error,
+		NextAttemptAt: d.NextAttemptAt,
+		CreatedAt:     d.CreatedAt,
+		UpdatedAt:     d.UpdatedAt,
+	}
+}
+
+func toDBDelivery(d webhooks.Delivery) dbDelivery {
+	return dbDelivery{
+		ID:            d.ID,
+		WebhookID:     d.WebhookID,
+		EventID:       d.EventID,
+		Topic:         d.Topic,
+		Payload:       d.Payload,
+		Status:        d.Status,
+		Attempts:      d.Attempts,
+		StatusCode:    d.StatusCode,
+		Error:         d.Error,
+		NextAttemptAt: d.NextAttemptAt,
+		CreatedAt:     d.CreatedAt,
+		UpdatedAt:     d.UpdatedAt,
+	}
+}
@@ -0,0 +1,266 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/0x6flab/namegenerator"
+	"github.com/gofrs/uuid"
+	"github.com/rodneyosodo/twiga/webhooks"
+	"github.com/rodneyosodo/twiga/webhooks/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	malformedID = strings.Repeat("a", 100)
+	namegen     = namegenerator.NewGenerator()
+)
+
+func TestCreateWebhook(t *testing.T) {
+	t.Cleanup(func() {
+		_, err := db.Exec("DELETE FROM webhooks")
+		require.NoError(t, err)
+	})
+	repo := repository.NewRepository(db)
+
+	cases := []struct {
+		desc    string
+		webhook webhooks.Webhook
+		err     error
+	}{
+		{
+			desc: "valid webhook",
+			webhook: webhooks.Webhook{
+				OwnerID: uuid.Must(uuid.NewV4()).String(),
+				URL:     "https://example.com/" + namegen.Generate(),
+				Topics:  []string{"posts.*", "followers.created"},
+				Secret:  namegen.Generate(),
+			},
+			err: nil,
+		},
+		{
+			desc: "empty url",
+			webhook: webhooks.Webhook{
+				OwnerID: uuid.Must(uuid.NewV4()).String(),
+				Topics:  []string{"posts.*"},
+				Secret:  namegen.Generate(),
+			},
+			err: errors.New("violates check constraint"),
+		},
+		{
+			desc: "malformed owner id",
+			webhook: webhooks.Webhook{
+				OwnerID: malformedID,
+				URL:     "https://example.com/" + namegen.Generate(),
+				Topics:  []string{"posts.*"},
+				Secret:  namegen.Generate(),
+			},
+			err: errors.New("invalid input syntax for type uuid"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			saved, err := repo.CreateWebhook(context.Background(), tc.webhook)
+			if tc.err != nil {
+				assert.ErrorContains(t, err, tc.err.Error())
+
+				return
+			}
+			require.NoError(t, err)
+			assert.NotEmpty(t, saved.ID)
+			assert.Equal(t, tc.webhook.Topics, saved.Topics)
+
+			retrieved, err := repo.RetrieveWebhook(context.Background(), saved.ID)
+			require.NoError(t, err)
+			assert.Equal(t, saved, retrieved)
+		})
+	}
+}
+
+func TestRetrieveWebhooksByTopic(t *testing.T) {
+	t.Cleanup(func() {
+		_, err := db.Exec("DELETE FROM webhooks")
+		require.NoError(t, err)
+	})
+	repo := repository.NewRepository(db)
+
+	created := make(map[string]string)
+	for name, topics := range map[string][]string{
+		"posts":   {"posts.*"},
+		"follows": {"followers.created"},
+		"mixed":   {"likes.created", "posts.updated.*"},
+	} {
+		webhook, err := repo.CreateWebhook(context.Background(), webhooks.Webhook{
+			OwnerID: uuid.Must(uuid.NewV4()).String(),
+			URL:     "https://example.com/" + name,
+			Topics:  topics,
+			Secret:  namegen.Generate(),
+		})
+		require.NoError(t, err)
+		created[webhook.ID] = name
+	}
+
+	cases := []struct {
+		topic string
+		names []string
+	}{
+		{topic: "posts.created", names: []string{"posts"}},
+		{topic: "posts.updated.tags", names: []string{"mixed", "posts"}},
+		{topic: "followers.created", names: []string{"follows"}},
+		{topic: "followers.deleted", names: []string{}},
+		{topic: "likes.created", names: []string{"mixed"}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.topic, func(t *testing.T) {
+			items, err := repo.RetrieveWebhooksByTopic(context.Background(), tc.topic)
+			require.NoError(t, err)
+
+			names := make([]string, 0, len(items))
+			for _, item := range items {
+				names = append(names, created[item.ID])
+			}
+			assert.ElementsMatch(t, tc.names, names)
+		})
+	}
+}
+
+func TestCreateDeliveries(t *testing.T) {
+	t.Cleanup(func() {
+		_, err := db.Exec("DELETE FROM webhooks")
+		require.NoError(t, err)
+	})
+	repo := repository.NewRepository(db)
+
+	webhook, err := repo.CreateWebhook(context.Background(), webhooks.Webhook{
+		OwnerID: uuid.Must(uuid.NewV4()).String(),
+		URL:     "https://example.com/" + namegen.Generate(),
+		Topics:  []string{"posts.*"},
+		Secret:  namegen.Generate(),
+	})
+	require.NoError(t, err)
+
+	eventID := uuid.Must(uuid.NewV4()).String()
+	newDelivery := func(eventID string) webhooks.Delivery {
+		return webhooks.Delivery{
+			ID:            uuid.Must(uuid.NewV4()).String(),
+			WebhookID:     webhook.ID,
+			EventID:       eventID,
+			Topic:         "posts.created",
+			Payload:       []byte(`{"topic": "posts.created"}`),
+			Status:        webhooks.PendingStatus,
+			NextAttemptAt: time.Now().UTC(),
+		}
+	}
+
+	// The cases run in order against the same delivery log.
+	cases := []struct {
+		desc     string
+		delivery webhooks.Delivery
+		total    uint64
+	}{
+		{
+			desc:     "event",
+			delivery: newDelivery(eventID),
+			total:    1,
+		},
+		{
+			desc:     "replayed event",
+			delivery: newDelivery(eventID),
+			total:    1,
+		},
+		{
+			desc:     "redelivery",
+			delivery: newDelivery(""),
+			total:    2,
+		},
+		{
+			desc:     "second redelivery",
+			delivery: newDelivery(""),
+			total:    3,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			require.NoError(t, repo.CreateDeliveries(context.Background(), tc.delivery))
+
+			page, err := repo.RetrieveAllDeliveries(context.Background(), webhooks.Page{WebhookID: webhook.ID, Limit: 10})
+			require.NoError(t, err)
+			assert.Equal(t, tc.total, page.Total)
+		})
+	}
+}
+
+func TestClaimDeliveries(t *testing.T) {
+	t.Cleanup(func() {
+		_, err := db.Exec("DELETE FROM webhooks")
+		require.NoError(t, err)
+	})
+	repo := repository.NewRepository(db)
+
+	webhook, err := repo.CreateWebhook(context.Background(), webhooks.Webhook{
+		OwnerID: uuid.Must(uuid.NewV4()).String(),
+		URL:     "https://example.com/" + namegen.Generate(),
+		Topics:  []string{"posts.*"},
+		Secret:  namegen.Generate(),
+	})
+	require.NoError(t, err)
+
+	now := time.Now().UTC()
+	newDelivery := func(status string, nextAttemptAt time.Time) webhooks.Delivery {
+		return webhooks.Delivery{
+			ID:            uuid.Must(uuid.NewV4()).String(),
+			WebhookID:     webhook.ID,
+			Topic:         "posts.created",
+			Payload:       []byte(`{"topic": "posts.created"}`),
+			Status:        status,
+			NextAttemptAt: nextAttemptAt,
+		}
+	}
+	due := newDelivery(webhooks.PendingStatus, now.Add(-time.Minute))
+	later := newDelivery(webhooks.PendingStatus, now.Add(time.Hour))
+	delivered := newDelivery(webhooks.DeliveredStatus, now.Add(-time.Minute))
+	require.NoError(t, repo.CreateDeliveries(context.Background(), due, later, delivered))
+
+	claimed, err := repo.ClaimDeliveries(context.Background(), 10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	assert.Equal(t, due.ID, claimed[0].ID)
+	assert.JSONEq(t, string(due.Payload), string(claimed[0].Payload))
+
+	// Claimed deliveries are leased and not claimed again until the lease expires.
+	claimed, err = repo.ClaimDeliveries(context.Background(), 10, time.Minute)
+	require.NoError(t, err)
+	assert.Empty(t, claimed)
+
+	due.Status = webhooks.FailedStatus
+	due.Attempts = 4
+	due.StatusCode = 500
+	due.Error = "webhook responded with 500 Internal Server Error"
+	require.NoError(t, repo.UpdateDelivery(context.Background(), due))
+
+	page, err := repo.RetrieveAllDeliveries(context.Background(), webhooks.Page{WebhookID: webhook.ID, Status: webhooks.FailedStatus, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, page.Deliveries, 1)
+	assert.Equal(t, uint64(1), page.Total)
+	assert.Equal(t, due.Attempts, page.Deliveries[0].Attempts)
+	assert.Equal(t, due.Error, page.Deliveries[0].Error)
+
+	// Deleting the webhook removes its delivery log.
+	require.NoError(t, repo.DeleteWebhook(context.Background(), webhook.ID))
+	_, err = repo.RetrieveDelivery(context.Background(), due.ID)
+	assert.ErrorContains(t, err, "not found")
+}
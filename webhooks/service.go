@@ -0,0 +1,331 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/rodneyosodo/twiga/internal/netguard"
+	"github.com/rodneyosodo/twiga/users/proto"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	secretSize     = 32
+	maxBackoffStep = 16
+	defBatchSize   = 100
+	defConcurrency = 10
+	// defLease must outlast an attempt so that a claimed delivery is not claimed again while in flight.
+	defLease = time.Minute
+)
+
+var (
+	// publicEntities are the entities whose events can be subscribed to.
+	// Events of users and preferences carry private data and are never delivered.
+	publicEntities = []string{"posts", "comments", "likes", "shares", "followers"}
+
+	errInvalidURL    = errors.New("url must be an absolute http or https url")
+	errInvalidTopics = errors.New("topics must be events of posts, comments, likes, shares or followers")
+)
+
+var _ Service = (*service)(nil)
+
+type service struct {
+	repo       Repository
+	users      proto.UsersServiceClient
+	httpClient *http.Client
+	maxRetries uint64
+	retryDelay time.Duration
+}
+
+// NewService returns the webhooks service. Failed deliveries are retried up to
+// maxRetries times, doubling the delay between attempts starting from retryDelay.
+func NewService(repo Repository, users proto.UsersServiceClient, httpClient *http.Client, maxRetries uint64, retryDelay time.Duration) Service {
+	return &service{
+		repo:       repo,
+		users:      users,
+		httpClient: httpClient,
+		maxRetries: maxRetries,
+		retryDelay: retryDelay,
+	}
+}
+
+func (s *service) CreateWebhook(ctx context.Context, token string, webhook Webhook) (Webhook, error) {
+	u, err := url.Parse(webhook.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return Webhook{}, errInvalidURL
+	}
+	if err := netguard.CheckHost(ctx, u.Hostname()); err != nil {
+		return Webhook{}, err
+	}
+	if len(webhook.Topics) == 0 {
+		return Webhook{}, errInvalidTopics
+	}
+	for _, topic := range webhook.Topics {
+		if !validTopic(topic) {
+			return Webhook{}, errInvalidTopics
+		}
+	}
+
+	userID, err := s.identify(ctx, token)
+	if err != nil {
+		return Webhook{}, err
+	}
+	webhook.OwnerID = userID
+
+	if webhook.Secret == "" {
+		secret := make([]byte, secretSize)
+		if _, err := rand.Read(secret); err != nil {
+			return Webhook{}, err
+		}
+		webhook.Secret = hex.EncodeToString(secret)
+	}
+
+	return s.repo.CreateWebhook(ctx, webhook)
+}
+
+func (s *service) RetrieveWebhook(ctx context.Context, token string, id string) (Webhook, error) {
+	userID, err := s.identify(ctx, token)
+	if err != nil {
+		return Webhook{}, err
+	}
+
+	return s.authorize(ctx, userID, id)
+}
+
+func (s *service) RetrieveAllWebhooks(ctx context.Context, token string, page Page) (WebhooksPage, error) {
+	userID, err := s.identify(ctx, token)
+	if err != nil {
+		return WebhooksPage{}, err
+	}
+	page.OwnerID = userID
+
+	return s.repo.RetrieveAllWebhooks(ctx, page)
+}
+
+func (s *service) DeleteWebhook(ctx context.Context, token string, id string) error {
+	if _, err := s.RetrieveWebhook(ctx, token, id); err != nil {
+		return err
+	}
+
+	return s.repo.DeleteWebhook(ctx, id)
+}
+
+func (s *service) RetrieveAllDeliveries(ctx context.Context, token string, page Page) (DeliveriesPage, error) {
+	if _, err := s.RetrieveWebhook(ctx, token, page.WebhookID); err != nil {
+		return DeliveriesPage{}, err
+	}
+
+	return s.repo.RetrieveAllDeliveries(ctx, page)
+}
+
+func (s *service) Redeliver(ctx context.Context, token string, webhookID, id string) (Delivery, error) {
+	if _, err := s.RetrieveWebhook(ctx, token, webhookID); err != nil {
+		return Delivery{}, err
+	}
+	delivery, err := s.repo.RetrieveDelivery(ctx, id)
+	if err != nil {
+		return Delivery{}, err
+	}
+	if delivery.WebhookID != webhookID {
+		return Delivery{}, errors.New("delivery not found")
+	}
+
+	// The original delivery is kept as is so that the log shows every attempt.
+	redelivery, err := newDelivery(webhookID, delivery.Topic, delivery.Payload)
+	if err != nil {
+		return Delivery{}, err
+	}
+	if err := s.repo.CreateDeliveries(ctx, redelivery); err != nil {
+		return Delivery{}, err
+	}
+
+	return redelivery, nil
+}
+
+func (s *service) Dispatch(ctx context.Context, topic string, audience Audience, event map[string]interface{}) error {
+	subscribed, err := s.repo.RetrieveWebhooksByTopic(ctx, topic)
+	if err != nil {
+		return err
+	}
+	webhooks := make([]Webhook, 0, len(subscribed))
+	for _, webhook := range subscribed {
+		if audience.Public || slices.Contains(audience.Owners, webhook.OwnerID) {
+			webhooks = append(webhooks, webhook)
+		}
+	}
+	if len(webhooks) == 0 {
+		return nil
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	// The ID of the event keeps replays from delivering it twice to a webhook.
+	eventID, _ := event["id"].(string)
+	deliveries := make([]Delivery, 0, len(webhooks))
+	for _, webhook := range webhooks {
+		delivery, err := newDelivery(webhook.ID, topic, payload)
+		if err != nil {
+			return err
+		}
+		delivery.EventID = eventID
+		deliveries = append(deliveries, delivery)
+	}
+
+	return s.repo.CreateDeliveries(ctx, deliveries...)
+}
+
+func (s *service) ProcessDeliveries(ctx context.Context) error {
+	deliveries, err := s.repo.ClaimDeliveries(ctx, defBatchSize, defLease)
+	if err != nil {
+		return err
+	}
+
+	// Every delivery is attempted even when others fail.
+	var (
+		mu   sync.Mutex
+		errs error
+	)
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(defConcurrency)
+	for _, delivery := range deliveries {
+		g.Go(func() error {
+			if err := s.attempt(ctx, delivery); err != nil {
+				mu.Lock()
+				errs = errors.Join(errs, err)
+				mu.Unlock()
+			}
+
+			return nil
+		})
+	}
+	_ = g.Wait()
+
+	return errs
+}
+
+// attempt sends the delivery and records the outcome, scheduling the next
+// attempt with exponential backoff until the retries are exhausted.
+func (s *service) attempt(ctx context.Context, delivery Delivery) error {
+	webhook, err := s.repo.RetrieveWebhook(ctx, delivery.WebhookID)
+	if err != nil {
+		return err
+	}
+
+	delivery.Attempts++
+	delivery.StatusCode, err = s.send(ctx, webhook, delivery)
+	switch {
+	case err == nil:
+		delivery.Status = DeliveredStatus
+		delivery.Error = ""
+	case delivery.Attempts > s.maxRetries:
+		delivery.Status = FailedStatus
+		delivery.Error = err.Error()
+	default:
+		step := min(delivery.Attempts-1, maxBackoffStep)
+		delivery.Status = PendingStatus
+		delivery.Error = err.Error()
+		delivery.NextAttemptAt = time.Now().UTC().Add(s.retryDelay << step)
+	}
+
+	return s.repo.UpdateDelivery(ctx, delivery)
+}
+
+func (s *service) send(ctx context.Context, webhook Webhook, delivery Delivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Twiga-Webhooks")
+	req.Header.Set(EventHeader, delivery.Topic)
+	req.Header.Set(DeliveryHeader, delivery.ID)
+	req.Header.Set(SignatureHeader, Sign(webhook.Secret, delivery.Payload))
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return resp.StatusCode, fmt.Errorf("webhook responded with %s", resp.Status)
+	}
+
+	return resp.StatusCode, nil
+}
+
+func (s *service) identify(ctx context.Context, token string) (string, error) {
+	resp, err := s.users.IdentifyUser(ctx, &proto.IdentifyUserRequest{Token: token})
+	if err != nil {
+		return "", err
+	}
+
+	return resp.GetId(), nil
+}
+
+func (s *service) authorize(ctx context.Context, userID, id string) (Webhook, error) {
+	webhook, err := s.repo.RetrieveWebhook(ctx, id)
+	if err != nil {
+		return Webhook{}, err
+	}
+	if webhook.OwnerID != userID {
+		return Webhook{}, errors.New("unauthorized")
+	}
+
+	return webhook, nil
+}
+
+func newDelivery(webhookID, topic string, payload []byte) (Delivery, error) {
+	id, err := uuid.NewV4()
+	if err != nil {
+		return Delivery{}, err
+	}
+
+	return Delivery{
+		ID:            id.String(),
+		WebhookID:     webhookID,
+		Topic:         topic,
+		Payload:       payload,
+		Status:        PendingStatus,
+		NextAttemptAt: time.Now().UTC(),
+	}, nil
+}
+
+// validTopic accepts topics of public entities such as "posts.created" and "posts.*".
+func validTopic(topic string) bool {
+	parts := strings.Split(topic, ".")
+	if len(parts) < 2 || !slices.Contains(publicEntities, parts[0]) {
+		return false
+	}
+	for i, part := range parts {
+		if part == "" || (strings.Contains(part, "*") && (part != "*" || i != len(parts)-1)) {
+			return false
+		}
+	}
+
+	return true
+}
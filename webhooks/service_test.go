@@ -0,0 +1,354 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package webhooks_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rodneyosodo/twiga/internal/netguard"
+	"github.com/rodneyosodo/twiga/users/proto"
+	"github.com/rodneyosodo/twiga/webhooks"
+	"github.com/rodneyosodo/twiga/webhooks/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+const (
+	retryDelay = time.Minute
+	// hookURL is in a range reserved for documentation, which is public but never resolved.
+	hookURL = "https://203.0.113.10/hook"
+)
+
+type usersClient struct {
+	proto.UsersServiceClient
+}
+
+func (usersClient) IdentifyUser(_ context.Context, in *proto.IdentifyUserRequest, _ ...grpc.CallOption) (*proto.IdentifyUserResponse, error) {
+	if in.GetToken() == "" {
+		return nil, errors.New("invalid token")
+	}
+
+	return &proto.IdentifyUserResponse{Id: in.GetToken()}, nil
+}
+
+func TestCreateWebhook(t *testing.T) {
+	cases := []struct {
+		desc    string
+		webhook webhooks.Webhook
+		err     error
+	}{
+		{
+			desc:    "exact and wildcard topics",
+			webhook: webhooks.Webhook{URL: hookURL, Topics: []string{"posts.*", "followers.created"}},
+		},
+		{
+			desc:    "relative url",
+			webhook: webhooks.Webhook{URL: "/hook", Topics: []string{"posts.*"}},
+			err:     errors.New("url must be an absolute http or https url"),
+		},
+		{
+			desc:    "unsupported scheme",
+			webhook: webhooks.Webhook{URL: "ftp://203.0.113.10/hook", Topics: []string{"posts.*"}},
+			err:     errors.New("url must be an absolute http or https url"),
+		},
+		{
+			desc:    "localhost",
+			webhook: webhooks.Webhook{URL: "http://localhost:6000/hook", Topics: []string{"posts.*"}},
+			err:     errors.New("url must not resolve to a loopback, private, link-local or internal address"),
+		},
+		{
+			desc:    "loopback address",
+			webhook: webhooks.Webhook{URL: "http://127.0.0.1/hook", Topics: []string{"posts.*"}},
+			err:     errors.New("url must not resolve to a loopback, private, link-local or internal address"),
+		},
+		{
+			desc:    "ipv6 loopback address",
+			webhook: webhooks.Webhook{URL: "http://[::1]/hook", Topics: []string{"posts.*"}},
+			err:     errors.New("url must not resolve to a loopback, private, link-local or internal address"),
+		},
+		{
+			desc:    "ipv4-mapped loopback address",
+			webhook: webhooks.Webhook{URL: "http://[::ffff:127.0.0.1]/hook", Topics: []string{"posts.*"}},
+			err:     errors.New("url must not resolve to a loopback, private, link-local or internal address"),
+		},
+		{
+			desc:    "private address",
+			webhook: webhooks.Webhook{URL: "http://10.0.0.5:6001/hook", Topics: []string{"posts.*"}},
+			err:     errors.New("url must not resolve to a loopback, private, link-local or internal address"),
+		},
+		{
+			desc:    "metadata endpoint",
+			webhook: webhooks.Webhook{URL: "http://169.254.169.254/latest/meta-data", Topics: []string{"posts.*"}},
+			err:     errors.New("url must not resolve to a loopback, private, link-local or internal address"),
+		},
+		{
+			desc:    "carrier-grade nat address",
+			webhook: webhooks.Webhook{URL: "http://100.64.0.1/hook", Topics: []string{"posts.*"}},
+			err:     errors.New("url must not resolve to a loopback, private, link-local or internal address"),
+		},
+		{
+			desc:    "unspecified address",
+			webhook: webhooks.Webhook{URL: "http://0.0.0.0/hook", Topics: []string{"posts.*"}},
+			err:     errors.New("url must not resolve to a loopback, private, link-local or internal address"),
+		},
+		{
+			desc:    "unresolvable host",
+			webhook: webhooks.Webhook{URL: "http://twiga-users.invalid/hook", Topics: []string{"posts.*"}},
+			err:     errors.New("failed to resolve url host"),
+		},
+		{
+			desc:    "no topics",
+			webhook: webhooks.Webhook{URL: hookURL},
+			err:     errors.New("topics must be events of"),
+		},
+		{
+			desc:    "private entity",
+			webhook: webhooks.Webhook{URL: hookURL, Topics: []string{"users.created"}},
+			err:     errors.New("topics must be events of"),
+		},
+		{
+			desc:    "wildcard in the middle",
+			webhook: webhooks.Webhook{URL: hookURL, Topics: []string{"posts.*.tags"}},
+			err:     errors.New("topics must be events of"),
+		},
+		{
+			desc:    "wildcard entity",
+			webhook: webhooks.Webhook{URL: hookURL, Topics: []string{"*"}},
+			err:     errors.New("topics must be events of"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			repo := mocks.NewRepository(t)
+			svc := webhooks.NewService(repo, usersClient{}, http.DefaultClient, 3, retryDelay)
+
+			if tc.err == nil {
+				repo.On("CreateWebhook", mock.Anything, mock.Anything).Return(func(_ context.Context, w webhooks.Webhook) (webhooks.Webhook, error) {
+					return w, nil
+				})
+			}
+
+			webhook, err := svc.CreateWebhook(context.Background(), "owner", tc.webhook)
+			if tc.err != nil {
+				assert.ErrorContains(t, err, tc.err.Error())
+
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "owner", webhook.OwnerID)
+			assert.Len(t, webhook.Secret, 64)
+		})
+	}
+}
+
+func TestProcessDeliveries(t *testing.T) {
+	payload := []byte(`{"topic":"posts.created","id":"post"}`)
+
+	cases := []struct {
+		desc       string
+		statusCode int
+		attempts   uint64
+		status     string
+		backoff    time.Duration
+	}{
+		{
+			desc:       "delivered",
+			statusCode: http.StatusNoContent,
+			status:     webhooks.DeliveredStatus,
+		},
+		{
+			desc:       "first failure",
+			statusCode: http.StatusInternalServerError,
+			status:     webhooks.PendingStatus,
+			backoff:    retryDelay,
+		},
+		{
+			desc:       "third failure",
+			statusCode: http.StatusBadGateway,
+			attempts:   2,
+			status:     webhooks.PendingStatus,
+			backoff:    4 * retryDelay,
+		},
+		{
+			desc:       "retries exhausted",
+			statusCode: http.StatusInternalServerError,
+			attempts:   3,
+			status:     webhooks.FailedStatus,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, err := io.ReadAll(r.Body)
+				assert.NoError(t, err)
+				assert.Equal(t, payload, body)
+				assert.Equal(t, webhooks.Sign("secret", body), r.Header.Get(webhooks.SignatureHeader))
+				assert.Equal(t, "posts.created", r.Header.Get(webhooks.EventHeader))
+				assert.Equal(t, "delivery", r.Header.Get(webhooks.DeliveryHeader))
+				w.WriteHeader(tc.statusCode)
+			}))
+			defer server.Close()
+
+			repo := mocks.NewRepository(t)
+			svc := webhooks.NewService(repo, usersClient{}, server.Client(), 3, retryDelay)
+
+			delivery := webhooks.Delivery{
+				ID:        "delivery",
+				WebhookID: "webhook",
+				Topic:     "posts.created",
+				Payload:   payload,
+				Status:    webhooks.PendingStatus,
+				Attempts:  tc.attempts,
+			}
+			repo.On("ClaimDeliveries", mock.Anything, mock.Anything, mock.Anything).Return([]webhooks.Delivery{delivery}, nil)
+			repo.On("RetrieveWebhook", mock.Anything, "webhook").Return(webhooks.Webhook{ID: "webhook", URL: server.URL, Secret: "secret"}, nil)
+
+			var updated webhooks.Delivery
+			repo.On("UpdateDelivery", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+				updated = args.Get(1).(webhooks.Delivery)
+			}).Return(nil)
+
+			start := time.Now().UTC()
+			require.NoError(t, svc.ProcessDeliveries(context.Background()))
+
+			assert.Equal(t, tc.status, updated.Status)
+			assert.Equal(t, tc.attempts+1, updated.Attempts)
+			assert.Equal(t, tc.statusCode, updated.StatusCode)
+			if tc.status == webhooks.DeliveredStatus {
+				assert.Empty(t, updated.Error)
+			} else {
+				assert.NotEmpty(t, updated.Error)
+			}
+			if tc.backoff > 0 {
+				assert.WithinDuration(t, start.Add(tc.backoff), updated.NextAttemptAt, time.Second)
+			}
+		})
+	}
+}
+
+func TestRedeliver(t *testing.T) {
+	repo := mocks.NewRepository(t)
+	svc := webhooks.NewService(repo, usersClient{}, http.DefaultClient, 3, retryDelay)
+
+	original := webhooks.Delivery{
+		ID:        "delivery",
+		WebhookID: "webhook",
+		Topic:     "likes.created",
+		Payload:   []byte(`{"topic":"likes.created"}`),
+		Status:    webhooks.FailedStatus,
+		Attempts:  4,
+	}
+	repo.On("RetrieveWebhook", mock.Anything, "webhook").Return(webhooks.Webhook{ID: "webhook", OwnerID: "owner"}, nil)
+	repo.On("RetrieveDelivery", mock.Anything, "delivery").Return(original, nil)
+	repo.On("CreateDeliveries", mock.Anything, mock.Anything).Return(nil)
+
+	_, err := svc.Redeliver(context.Background(), "intruder", "webhook", "delivery")
+	assert.ErrorContains(t, err, "unauthorized")
+
+	redelivery, err := svc.Redeliver(context.Background(), "owner", "webhook", "delivery")
+	require.NoError(t, err)
+	assert.NotEqual(t, original.ID, redelivery.ID)
+	assert.Equal(t, webhooks.PendingStatus, redelivery.Status)
+	assert.Zero(t, redelivery.Attempts)
+	assert.Equal(t, original.Payload, redelivery.Payload)
+	assert.Equal(t, original.Topic, redelivery.Topic)
+}
+
+func TestDispatch(t *testing.T) {
+	subscribed := []webhooks.Webhook{
+		{ID: "alice-hook", OwnerID: "alice"},
+		{ID: "bob-hook", OwnerID: "bob"},
+		{ID: "carol-hook", OwnerID: "carol"},
+	}
+
+	cases := []struct {
+		desc     string
+		audience webhooks.Audience
+		webhooks []string
+	}{
+		{
+			desc:     "public",
+			audience: webhooks.Audience{Public: true, Owners: []string{"alice"}},
+			webhooks: []string{"alice-hook", "bob-hook", "carol-hook"},
+		},
+		{
+			desc:     "owners",
+			audience: webhooks.Audience{Owners: []string{"alice", "carol"}},
+			webhooks: []string{"alice-hook", "carol-hook"},
+		},
+		{
+			desc:     "owner without webhooks",
+			audience: webhooks.Audience{Owners: []string{"dave"}},
+		},
+		{
+			desc: "nobody",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(t *testing.T) {
+			repo := mocks.NewRepository(t)
+			svc := webhooks.NewService(repo, usersClient{}, http.DefaultClient, 3, retryDelay)
+
+			repo.On("RetrieveWebhooksByTopic", mock.Anything, "posts.created").Return(subscribed, nil)
+			var delivered []string
+			if len(tc.webhooks) > 0 {
+				// The deliveries are passed as variadic arguments.
+				args := []interface{}{mock.Anything}
+				for range tc.webhooks {
+					args = append(args, mock.Anything)
+				}
+				repo.On("CreateDeliveries", args...).Run(func(args mock.Arguments) {
+					for _, arg := range args[1:] {
+						delivery := arg.(webhooks.Delivery)
+						assert.Equal(t, "event", delivery.EventID, "deliveries should record the event they dispatch")
+						delivered = append(delivered, delivery.WebhookID)
+					}
+				}).Return(nil)
+			}
+
+			err := svc.Dispatch(context.Background(), "posts.created", tc.audience, map[string]interface{}{"id": "event", "type": "posts.created"})
+			require.NoError(t, err)
+			assert.ElementsMatch(t, tc.webhooks, delivered)
+		})
+	}
+}
+
+func TestHTTPClientRefusesInternalAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	repo := mocks.NewRepository(t)
+	svc := webhooks.NewService(repo, usersClient{}, netguard.NewHTTPClient(time.Second), 3, retryDelay)
+
+	// The host may have resolved to a public address when the webhook was registered.
+	delivery := webhooks.Delivery{ID: "delivery", WebhookID: "webhook", Topic: "posts.created", Payload: []byte(`{}`)}
+	repo.On("ClaimDeliveries", mock.Anything, mock.Anything, mock.Anything).Return([]webhooks.Delivery{delivery}, nil)
+	repo.On("RetrieveWebhook", mock.Anything, "webhook").Return(webhooks.Webhook{ID: "webhook", URL: server.URL, Secret: "secret"}, nil)
+
+	var updated webhooks.Delivery
+	repo.On("UpdateDelivery", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		updated = args.Get(1).(webhooks.Delivery)
+	}).Return(nil)
+
+	require.NoError(t, svc.ProcessDeliveries(context.Background()))
+	assert.Equal(t, webhooks.PendingStatus, updated.Status)
+	assert.Contains(t, updated.Error, "url must not resolve to a loopback, private, link-local or internal address")
+}
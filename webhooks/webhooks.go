@@ -0,0 +1,162 @@
+// Copyright (c) 2024 rodneyosodo
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package webhooks
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+const (
+	PendingStatus   = "pending"
+	DeliveredStatus = "delivered"
+	FailedStatus    = "failed"
+
+	// SignatureHeader carries the hex encoded HMAC-SHA256 of the request body keyed with the webhook secret.
+	SignatureHeader = "X-Twiga-Signature-256"
+	EventHeader     = "X-Twiga-Event"
+	DeliveryHeader  = "X-Twiga-Delivery"
+)
+
+// Webhook is a URL that receives the events whose topic matches one of its topics.
+// A topic ending with ".*" matches every topic with that prefix, such as "posts.*"
+// for "posts.created" and "posts.updated.tags".
+type Webhook struct {
+	ID        string    `json:"id"`
+	OwnerID   string    `json:"owner_id"`
+	URL       string    `json:"url"`
+	Topics    []string  `json:"topics"`
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Delivery is an attempt to send an event to a webhook. Failed attempts are retried
+// with exponential backoff until the delivery is either delivered or failed. The
+// event is only dispatched once to each webhook, while redeliveries have no EventID.
+type Delivery struct {
+	ID            string          `json:"id"`
+	WebhookID     string          `json:"webhook_id"`
+	EventID       string          `json:"event_id,omitempty"`
+	Topic         string          `json:"topic"`
+	Payload       json.RawMessage `json:"payload"`
+	Status        string          `json:"status"`
+	Attempts      uint64          `json:"attempts"`
+	StatusCode    int             `json:"status_code,omitempty"`
+	Error         string          `json:"error,omitempty"`
+	NextAttemptAt time.Time       `json:"next_attempt_at"`
+	CreatedAt     time.Time       `json:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+}
+
+// Audience restricts the webhooks an event is delivered to. Events are only
+// delivered to the webhooks of the owners of the entities they are about, and
+// to every webhook when they are about a public post.
+type Audience struct {
+	Public bool
+	Owners []string
+}
+
+type Page struct {
+	Total     uint64 `db:"total"                json:"total"`
+	Offset    uint64 `db:"offset"               json:"offset"`
+	Limit     uint64 `db:"limit"                json:"limit"`
+	OwnerID   string `db:"owner_id,omitempty"   json:"owner_id,omitempty"`
+	WebhookID string `db:"webhook_id,omitempty" json:"webhook_id,omitempty"`
+	Status    string `db:"status,omitempty"     json:"status,omitempty"`
+}
+
+type WebhooksPage struct {
+	Page
+	Webhooks []Webhook `json:"webhooks"`
+}
+
+func (page WebhooksPage) MarshalJSON() ([]byte, error) {
+	type Alias WebhooksPage
+	a := struct {
+		Alias
+	}{
+		Alias: Alias(page),
+	}
+
+	if a.Webhooks == nil {
+		a.Webhooks = make([]Webhook, 0)
+	}
+
+	return json.Marshal(a)
+}
+
+type DeliveriesPage struct {
+	Page
+	Deliveries []Delivery `json:"deliveries"`
+}
+
+func (page DeliveriesPage) MarshalJSON() ([]byte, error) {
+	type Alias DeliveriesPage
+	a := struct {
+		Alias
+	}{
+		Alias: Alias(page),
+	}
+
+	if a.Deliveries == nil {
+		a.Deliveries = make([]Delivery, 0)
+	}
+
+	return json.Marshal(a)
+}
+
+// Sign returns the value of the signature header for the payload.
+func Sign(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+//go:generate mockery --name Repository --output=./mocks --filename repository.go --quiet
+type Repository interface { //nolint:interfacebloat
+	CreateWebhook(ctx context.Context, webhook Webhook) (Webhook, error)
+	RetrieveWebhook(ctx context.Context, id string) (Webhook, error)
+	RetrieveAllWebhooks(ctx context.Context, page Page) (WebhooksPage, error)
+	// RetrieveWebhooksByTopic returns the webhooks with a topic matching the given one.
+	RetrieveWebhooksByTopic(ctx context.Context, topic string) ([]Webhook, error)
+	DeleteWebhook(ctx context.Context, id string) error
+
+	// CreateDeliveries stores the deliveries, skipping those of an event that
+	// was already dispatched to their webhook.
+	CreateDeliveries(ctx context.Context, deliveries ...Delivery) error
+	RetrieveDelivery(ctx context.Context, id string) (Delivery, error)
+	RetrieveAllDeliveries(ctx context.Context, page Page) (DeliveriesPage, error)
+	// ClaimDeliveries returns up to limit pending deliveries that are due and
+	// postpones them by lease so that no other replica attempts them meanwhile.
+	ClaimDeliveries(ctx context.Context, limit uint64, lease time.Duration) ([]Delivery, error)
+	UpdateDelivery(ctx context.Context, delivery Delivery) error
+}
+
+//go:generate mockery --name Service --output=./mocks --filename service.go --quiet
+type Service interface {
+	CreateWebhook(ctx context.Context, token string, webhook Webhook) (Webhook, error)
+	RetrieveWebhook(ctx context.Context, token string, id string) (Webhook, error)
+	RetrieveAllWebhooks(ctx context.Context, token string, page Page) (WebhooksPage, error)
+	DeleteWebhook(ctx context.Context, token string, id string) error
+
+	RetrieveAllDeliveries(ctx context.Context, token string, page Page) (DeliveriesPage, error)
+	// Redeliver queues a new delivery of the event of an existing one.
+	Redeliver(ctx context.Context, token string, webhookID, id string) (Delivery, error)
+
+	// Dispatch queues a delivery of the event to every webhook of the audience
+	// subscribed to its topic that it was not already dispatched to.
+	Dispatch(ctx context.Context, topic string, audience Audience, event map[string]interface{}) error
+	// ProcessDeliveries attempts the deliveries that are due.
+	ProcessDeliveries(ctx context.Context) error
+}